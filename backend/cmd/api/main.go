@@ -2,46 +2,200 @@ package main
 
 import (
     "context"
+    "errors"
+    "flag"
+    "fmt"
     "log"
+    "log/slog"
     "net/http"
+    "os"
+    "os/signal"
+    "syscall"
     "time"
+    _ "time/tzdata"
 
+    "mascotas/internal/attachments"
+    "mascotas/internal/clock"
     "mascotas/internal/config"
     "mascotas/internal/database"
+    "mascotas/internal/events"
     httphandlers "mascotas/internal/http"
+    "mascotas/internal/models"
+    "mascotas/internal/notify"
+    "mascotas/internal/reports"
+    "mascotas/internal/storage"
+    "mascotas/internal/webhook"
 )
 
 func main() {
-    cfg := config.Load()
-    port := cfg.Port
-    dsn := cfg.DB_DSN
+    cfg, err := config.Load(os.Args[1:])
+    if errors.Is(err, flag.ErrHelp) {
+        return
+    }
+    if err != nil {
+        fatal("config error", err)
+    }
+    if cfg.PrintConfig {
+        if err := cfg.Print(os.Stdout); err != nil {
+            fatal("print config error", err)
+        }
+        return
+    }
+    setupLogging(cfg.Log.Level)
 
-    ctx := context.Background()
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
 
-    db, err := database.Open(dsn)
+    db, err := database.Open(ctx, database.Options{
+        DSN:              cfg.DB.DSN,
+        Driver:           cfg.DB.Driver,
+        MaxOpenConns:     cfg.DB.MaxOpenConns,
+        MaxIdleConns:     cfg.DB.MaxIdleConns,
+        ConnMaxLifetime:  cfg.DB.ConnMaxLifetime,
+        ConnMaxIdleTime:  cfg.DB.ConnMaxIdleTime,
+        StatementTimeout: cfg.DB.StatementTimeout,
+        ApplicationName:  cfg.DB.ApplicationName,
+    })
     if err != nil {
-        log.Fatalf("db open error: %v", err)
+        fatal("db open error", err)
     }
     defer db.Close()
 
-    if err := database.Migrate(ctx, db); err != nil {
-        log.Fatalf("db migrate error: %v", err)
+    if cfg.Features.AutoMigrate {
+        if err := database.Migrate(ctx, db.DB); err != nil {
+            fatal("db migrate error", err)
+        }
     }
 
+    cuidados := models.CuidadoStore{DB: db.DB}
+    if cfg.Notify.Enabled {
+        cuidados.ReminderLead = cfg.Notify.ReminderLead
+    }
+    h := httphandlers.NewHandlers(models.MascotaStore{DB: db.DB}, cuidados)
+    h.Pool = db
+    broker := events.NewBroker()
+    eventos := models.EventoStore{DB: db.DB}
+    h.Eventos = eventos
+    h.Broker = broker
+    go (&events.Listener{DB: db.DB, Store: eventos, Broker: broker, RetryDelay: 2 * time.Second}).Run(ctx)
+    hooks := models.WebhookStore{DB: db.DB}
+    h.Webhooks = hooks
+    h.Documentos = models.DocumentoStore{DB: db.DB}
+    h.Medicacion = models.MedicacionStore{DB: db.DB}
+    h.Pesos = models.PesoStore{DB: db.DB}
+    h.Alertas = models.AlertaStore{DB: db.DB}
+    h.Veterinarios = models.VeterinarioStore{DB: db.DB}
+    reportes := models.ReporteStore{DB: db.DB, Materialized: cfg.Reports.Materialized}
+    h.Reportes = reportes
+    if cfg.Reports.Materialized {
+        go (&reports.Refresher{Repo: reportes, Interval: cfg.Reports.RefreshInterval}).Run(ctx)
+    }
+    store := newStorage(cfg.Attachments)
+    adjuntos := models.AdjuntoStore{DB: db.DB}
+    h.Adjuntos = adjuntos
+    h.Storage = store
+    go (&attachments.Sweeper{Repo: adjuntos, Store: store, Interval: cfg.Attachments.SweepInterval, BatchSize: 100}).Run(ctx)
+    if cfg.Attachments.SigningKey == "" {
+        slog.Warn("attachments: no signing key configured; download links only work on this instance until it restarts")
+    }
+    if cfg.Webhooks.Enabled {
+        d := &webhook.Dispatcher{
+            Queue:        hooks,
+            Client:       webhook.NewClient(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivate),
+            Clock:        clock.System{},
+            PollInterval: cfg.Webhooks.PollInterval,
+            BatchSize:    cfg.Webhooks.BatchSize,
+            MaxAttempts:  cfg.Webhooks.MaxAttempts,
+            RetryBackoff: cfg.Webhooks.RetryBackoff,
+            MaxBackoff:   cfg.Webhooks.MaxBackoff,
+            Timeout:      cfg.Webhooks.Timeout,
+        }
+        go d.Run(ctx)
+    }
+    if cfg.Notify.Enabled {
+        outbox := models.NotificacionStore{DB: db.DB}
+        h.Notificaciones = outbox
+        d := &notify.Dispatcher{
+            Outbox: outbox,
+            Sender: notify.SMTPSender{
+                Host:     cfg.Notify.SMTP.Host,
+                Port:     cfg.Notify.SMTP.Port,
+                Username: cfg.Notify.SMTP.Username,
+                Password: cfg.Notify.SMTP.Password,
+                From:     cfg.Notify.SMTP.From,
+                Timeout:  cfg.Notify.SMTP.Timeout,
+            },
+            Clock:        clock.System{},
+            Location:     cfg.Location(),
+            PollInterval: cfg.Notify.PollInterval,
+            BatchSize:    cfg.Notify.BatchSize,
+            MaxAttempts:  cfg.Notify.MaxAttempts,
+            RetryBackoff: cfg.Notify.RetryBackoff,
+            MaxBackoff:   cfg.Notify.MaxBackoff,
+            SendTimeout:  cfg.Notify.SMTP.Timeout,
+        }
+        go d.Run(ctx)
+        slog.Info("reminders enabled", "lead", cfg.Notify.ReminderLead, "smtp", fmt.Sprintf("%s:%d", cfg.Notify.SMTP.Host, cfg.Notify.SMTP.Port))
+    }
     // Build a simple handler with inlined CORS, logging and recovery.
-    handler := httphandlers.NewRouter(db)
+    handler := httphandlers.NewRouter(h, cfg)
 
     srv := &http.Server{
-        Addr:              ":" + port,
+        Addr:              ":" + cfg.Server.Port,
         Handler:           handler,
-        ReadHeaderTimeout: 10 * time.Second,
-        ReadTimeout:       15 * time.Second,
-        WriteTimeout:      15 * time.Second,
-        IdleTimeout:       60 * time.Second,
+        ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+        ReadTimeout:       cfg.Server.ReadTimeout,
+        WriteTimeout:      cfg.Server.WriteTimeout,
+        IdleTimeout:       cfg.Server.IdleTimeout,
     }
+    // Open event streams would otherwise hold the shutdown up.
+    srv.RegisterOnShutdown(broker.Close)
 
-    log.Printf("backend listening on :%s", port)
+    go func() {
+        <-ctx.Done()
+        shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+        defer cancel()
+        if err := srv.Shutdown(shutdownCtx); err != nil {
+            slog.Error("shutdown error", "err", err)
+        }
+    }()
+
+    slog.Info("backend listening", "addr", ":"+cfg.Server.Port)
     if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-        log.Fatalf("server error: %v", err)
+        fatal("server error", err)
+    }
+}
+
+// newStorage builds the blob store of the attachments backend.
+func newStorage(cfg config.AttachmentsConfig) storage.Store {
+    if cfg.Backend == "s3" {
+        return &storage.S3{
+            Endpoint:  cfg.S3.Endpoint,
+            Region:    cfg.S3.Region,
+            Bucket:    cfg.S3.Bucket,
+            AccessKey: cfg.S3.AccessKey,
+            SecretKey: cfg.S3.SecretKey,
+            PathStyle: cfg.S3.PathStyle,
+            Client:    &http.Client{Timeout: 2 * time.Minute},
+        }
     }
+    return storage.Local{Dir: cfg.Dir}
+}
+
+// setupLogging applies LOG_LEVEL to slog. The standard logger, which the
+// packages use for their errors, keeps writing to stderr unleveled: bridged
+// into slog at info level, LOG_LEVEL=warn would silence every one of them.
+func setupLogging(level string) {
+    var lvl slog.Level
+    _ = lvl.UnmarshalText([]byte(level))
+    slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: lvl})))
+    log.SetOutput(os.Stderr)
+    log.SetFlags(log.LstdFlags)
+}
+
+// fatal logs err at error level, which LOG_LEVEL never filters out, and
+// exits.
+func fatal(msg string, err error) {
+    slog.Error(msg, "err", err)
+    os.Exit(1)
 }
@@ -0,0 +1,132 @@
+// Package attachments holds the rules for files attached to mascotas and
+// cuidados: which types are accepted, thumbnails of images, signed
+// download links and the removal of files whose owner was deleted.
+package attachments
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "image"
+    "image/color"
+    "image/jpeg"
+    "net/http"
+    "strconv"
+    "time"
+
+    // Decoders for the accepted image types.
+    _ "image/gif"
+    _ "image/png"
+
+    "golang.org/x/image/draw"
+    _ "golang.org/x/image/webp"
+)
+
+// Types are the accepted content types, as detected from the bytes: the
+// type declared by the client is not trusted.
+var Types = map[string]bool{
+    "image/jpeg":      true,
+    "image/png":       true,
+    "image/gif":       true,
+    "image/webp":      true,
+    "application/pdf": true,
+}
+
+// ErrUnsupportedType is returned by Sniff for any other content.
+var ErrUnsupportedType = errors.New("unsupported content type")
+
+// Sniff detects the content type of data from its first bytes.
+func Sniff(data []byte) (string, error) {
+    ct := http.DetectContentType(data)
+    if !Types[ct] {
+        return ct, ErrUnsupportedType
+    }
+    return ct, nil
+}
+
+const (
+    // ThumbnailSize bounds the width and height of a thumbnail.
+    ThumbnailSize = 256
+    // maxPixels guards against images that are small files but huge once
+    // decoded; they are stored without a thumbnail.
+    maxPixels = 50_000_000
+)
+
+// ErrNoThumbnail is returned by Thumbnail for content that is not an image
+// or an image too large to decode.
+var ErrNoThumbnail = errors.New("no thumbnail")
+
+// Thumbnail scales an image down to fit in ThumbnailSize×ThumbnailSize,
+// keeping its aspect ratio, and encodes it as JPEG. Transparent areas
+// become white.
+func Thumbnail(data []byte) ([]byte, error) {
+    cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+    if err != nil || cfg.Width == 0 || cfg.Height == 0 || cfg.Width*cfg.Height > maxPixels {
+        return nil, ErrNoThumbnail
+    }
+    src, _, err := image.Decode(bytes.NewReader(data))
+    if err != nil {
+        return nil, ErrNoThumbnail
+    }
+    w, h := cfg.Width, cfg.Height
+    if w > ThumbnailSize || h > ThumbnailSize {
+        if w >= h {
+            w, h = ThumbnailSize, max(1, h*ThumbnailSize/w)
+        } else {
+            w, h = max(1, w*ThumbnailSize/h), ThumbnailSize
+        }
+    }
+    dst := image.NewRGBA(image.Rect(0, 0, w, h))
+    draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+    draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+    var buf bytes.Buffer
+    if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// Variants of an attachment that can be downloaded.
+const (
+    VariantOriginal  = "original"
+    VariantThumbnail = "miniatura"
+)
+
+// Signer signs download links, so they can be handed to an <img> tag or a
+// browser without further credentials and stop working after a while.
+type Signer struct {
+    Key []byte
+}
+
+// NewKey returns a random signing key, for deployments that configure
+// none; links it signs only work on the same process.
+func NewKey() ([]byte, error) {
+    key := make([]byte, 32)
+    if _, err := rand.Read(key); err != nil {
+        return nil, err
+    }
+    return key, nil
+}
+
+// Sign returns the signature of the link to a variant of attachment id
+// valid until expires.
+func (s Signer) Sign(id int64, variant string, expires time.Time) string {
+    mac := hmac.New(sha256.New, s.Key)
+    fmt.Fprintf(mac, "%d\n%s\n%d", id, variant, expires.Unix())
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign; expires is the Unix time
+// from the link.
+func (s Signer) Verify(id int64, variant, expires, signature string, now time.Time) bool {
+    unix, err := strconv.ParseInt(expires, 10, 64)
+    if err != nil || now.Unix() > unix {
+        return false
+    }
+    want := s.Sign(id, variant, time.Unix(unix, 0))
+    return hmac.Equal([]byte(signature), []byte(want))
+}
@@ -0,0 +1,140 @@
+package attachments
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "image"
+    "image/color"
+    "image/jpeg"
+    "image/png"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/storage"
+)
+
+func pngImage(t *testing.T, w, h int) []byte {
+    t.Helper()
+    img := image.NewNRGBA(image.Rect(0, 0, w, h))
+    for x := 0; x < w; x++ {
+        img.Set(x, h/2, color.NRGBA{200, 40, 40, 255})
+    }
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, img); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+    for _, tc := range []struct {
+        data string
+        want string
+        ok   bool
+    }{
+        {string(pngImage(t, 2, 2)), "image/png", true},
+        {"%PDF-1.7\n...", "application/pdf", true},
+        {"\xff\xd8\xff\xe0\x00\x10JFIF", "image/jpeg", true},
+        {"<html><script>alert(1)</script>", "text/html; charset=utf-8", false},
+        {"MZ\x90\x00", "application/octet-stream", false},
+    } {
+        got, err := Sniff([]byte(tc.data))
+        if got != tc.want || (err == nil) != tc.ok {
+            t.Errorf("Sniff(%.10q) = %q, %v; want %q, ok %v", tc.data, got, err, tc.want, tc.ok)
+        }
+    }
+}
+
+func TestThumbnail(t *testing.T) {
+    thumb, err := Thumbnail(pngImage(t, 600, 300))
+    if err != nil {
+        t.Fatal(err)
+    }
+    img, err := jpeg.Decode(bytes.NewReader(thumb))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 128 {
+        t.Fatalf("thumbnail is %dx%d, want 256x128", b.Dx(), b.Dy())
+    }
+    // Small images keep their size.
+    thumb, err = Thumbnail(pngImage(t, 40, 90))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if img, _ := jpeg.Decode(bytes.NewReader(thumb)); img.Bounds().Dx() != 40 || img.Bounds().Dy() != 90 {
+        t.Fatalf("small thumbnail is %v, want 40x90", img.Bounds())
+    }
+    if _, err := Thumbnail([]byte("%PDF-1.7")); !errors.Is(err, ErrNoThumbnail) {
+        t.Fatalf("pdf: err = %v, want ErrNoThumbnail", err)
+    }
+}
+
+func TestSigner(t *testing.T) {
+    s := Signer{Key: []byte("0123456789abcdef0123456789abcdef")}
+    now := time.Date(2030, 6, 5, 10, 0, 0, 0, time.UTC)
+    expires := now.Add(time.Hour)
+    sig := s.Sign(7, VariantOriginal, expires)
+    exp := strconv.FormatInt(expires.Unix(), 10)
+    if !s.Verify(7, VariantOriginal, exp, sig, now) {
+        t.Fatal("valid signature rejected")
+    }
+    for name, ok := range map[string]bool{
+        "other id":      s.Verify(8, VariantOriginal, exp, sig, now),
+        "other variant": s.Verify(7, VariantThumbnail, exp, sig, now),
+        "longer expiry": s.Verify(7, VariantOriginal, strconv.FormatInt(expires.Unix()+3600, 10), sig, now),
+        "expired":       s.Verify(7, VariantOriginal, exp, sig, expires.Add(time.Second)),
+        "other key":     Signer{Key: []byte("x")}.Verify(7, VariantOriginal, exp, sig, now),
+    } {
+        if ok {
+            t.Errorf("%s: signature accepted", name)
+        }
+    }
+}
+
+type fakeOrphans struct {
+    orphans []models.Adjunto
+    deleted []int64
+}
+
+func (f *fakeOrphans) ListOrphans(ctx context.Context, limit int) ([]models.Adjunto, error) {
+    n := min(limit, len(f.orphans))
+    return append([]models.Adjunto(nil), f.orphans[:n]...), nil
+}
+
+func (f *fakeOrphans) Delete(ctx context.Context, id int64) error {
+    f.deleted = append(f.deleted, id)
+    for i, a := range f.orphans {
+        if a.ID == id {
+            f.orphans = append(f.orphans[:i], f.orphans[i+1:]...)
+        }
+    }
+    return nil
+}
+
+func TestSweeper(t *testing.T) {
+    ctx := context.Background()
+    store := storage.Local{Dir: t.TempDir()}
+    repo := &fakeOrphans{}
+    for i, key := range []string{"a/1", "a/2", "a/3"} {
+        if err := store.Put(ctx, key, strings.NewReader("x"), 1, "image/png"); err != nil {
+            t.Fatal(err)
+        }
+        repo.orphans = append(repo.orphans, models.Adjunto{ID: int64(i + 1), Clave: key})
+    }
+    repo.orphans[0].ClaveMiniatura = "a/1-miniatura"
+    s := &Sweeper{Repo: repo, Store: store, BatchSize: 2}
+    n, err := s.RunOnce(ctx)
+    if err != nil || n != 3 {
+        t.Fatalf("RunOnce = %d, %v; want 3", n, err)
+    }
+    for _, key := range []string{"a/1", "a/2", "a/3"} {
+        if _, err := store.Open(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+            t.Errorf("%s still stored: %v", key, err)
+        }
+    }
+}
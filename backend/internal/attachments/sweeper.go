@@ -0,0 +1,71 @@
+package attachments
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/storage"
+)
+
+// Orphans is the part of models.AdjuntoStore the sweeper uses.
+type Orphans interface {
+    ListOrphans(ctx context.Context, limit int) ([]models.Adjunto, error)
+    Delete(ctx context.Context, id int64) error
+}
+
+// Sweeper deletes the files of orphaned adjuntos, those whose mascota or
+// cuidado was deleted, and then their records. A file that cannot be
+// deleted keeps its record, so the next round tries again.
+type Sweeper struct {
+    Repo      Orphans
+    Store     storage.Store
+    Interval  time.Duration
+    BatchSize int
+}
+
+// Run sweeps every Interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+    t := time.NewTicker(s.Interval)
+    defer t.Stop()
+    for {
+        if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("attachments: sweep error: %v", err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-t.C:
+        }
+    }
+}
+
+// RunOnce removes orphans in batches until none is left and returns how
+// many it removed.
+func (s *Sweeper) RunOnce(ctx context.Context) (int, error) {
+    removed := 0
+    for {
+        list, err := s.Repo.ListOrphans(ctx, s.BatchSize)
+        if err != nil || len(list) == 0 {
+            return removed, err
+        }
+        for _, a := range list {
+            for _, key := range []string{a.Clave, a.ClaveMiniatura} {
+                if key == "" {
+                    continue
+                }
+                if err := s.Store.Delete(ctx, key); err != nil {
+                    return removed, err
+                }
+            }
+            if err := s.Repo.Delete(ctx, a.ID); err != nil {
+                return removed, err
+            }
+            removed++
+        }
+        if len(list) < s.BatchSize {
+            return removed, nil
+        }
+    }
+}
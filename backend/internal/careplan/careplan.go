@@ -0,0 +1,102 @@
+// Package careplan holds the preventive care schedule: how often each
+// especie needs the cuidados that repeat (vaccines and dewormings) at each
+// age, and when a mascota is next due for one.
+package careplan
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// Rule is the interval of a tipo of cuidado for an especie while the
+// mascota is between DesdeMeses and HastaMeses old (HastaMeses 0 = no
+// upper bound) at the last completed one. PrimeraSemanas is the age of
+// the first one, for the rule that covers newborns.
+type Rule struct {
+    Tipo           string
+    Especie        string
+    DesdeMeses     int
+    HastaMeses     int
+    Intervalo      int // days
+    PrimeraSemanas int
+    Motivo         string
+}
+
+// Rules is the clinic's schedule. An especie or tipo without rules is
+// never due.
+var Rules = []Rule{
+    {Tipo: "Vacunacion", Especie: "Perro", HastaMeses: 4, Intervalo: 21, PrimeraSemanas: 8, Motivo: "Serie de vacunas de cachorro, cada 3 semanas hasta los 4 meses"},
+    {Tipo: "Vacunacion", Especie: "Perro", DesdeMeses: 4, Intervalo: 365, Motivo: "Refuerzo anual de vacunas"},
+    {Tipo: "Vacunacion", Especie: "Gato", HastaMeses: 4, Intervalo: 21, PrimeraSemanas: 8, Motivo: "Serie de vacunas de gatito, cada 3 semanas hasta los 4 meses"},
+    {Tipo: "Vacunacion", Especie: "Gato", DesdeMeses: 4, Intervalo: 365, Motivo: "Refuerzo anual de vacunas"},
+    {Tipo: "Vacunacion", Especie: "Conejo", Intervalo: 365, PrimeraSemanas: 10, Motivo: "Vacuna anual contra mixomatosis y enfermedad hemorrágica"},
+    {Tipo: "Desparasitacion", Especie: "Perro", HastaMeses: 6, Intervalo: 30, PrimeraSemanas: 2, Motivo: "Desparasitación mensual hasta los 6 meses"},
+    {Tipo: "Desparasitacion", Especie: "Perro", DesdeMeses: 6, Intervalo: 90, Motivo: "Desparasitación cada 3 meses"},
+    {Tipo: "Desparasitacion", Especie: "Gato", HastaMeses: 6, Intervalo: 30, PrimeraSemanas: 3, Motivo: "Desparasitación mensual hasta los 6 meses"},
+    {Tipo: "Desparasitacion", Especie: "Gato", DesdeMeses: 6, Intervalo: 90, Motivo: "Desparasitación cada 3 meses"},
+    {Tipo: "Desparasitacion", Especie: "Conejo", Intervalo: 180, PrimeraSemanas: 8, Motivo: "Desparasitación cada 6 meses"},
+}
+
+// Tipos lists the tipos of cuidado the schedule covers, in a stable order.
+func Tipos() []string {
+    var out []string
+    seen := make(map[string]bool)
+    for _, r := range Rules {
+        if !seen[r.Tipo] {
+            seen[r.Tipo] = true
+            out = append(out, r.Tipo)
+        }
+    }
+    return out
+}
+
+func find(especie, tipo string, meses int) (Rule, bool) {
+    for _, r := range Rules {
+        if r.Especie == especie && r.Tipo == tipo && meses >= r.DesdeMeses && (r.HastaMeses == 0 || meses < r.HastaMeses) {
+            return r, true
+        }
+    }
+    return Rule{}, false
+}
+
+// Pendiente is the next cuidado of a tipo a mascota is due for. Ultimo
+// is the day of the last completed one, zero when there is none.
+type Pendiente struct {
+    Tipo   string
+    Ultimo time.Time
+    Vence  time.Time
+    Motivo string
+}
+
+// Next computes when m is due for its next cuidado of tipo, given the
+// day of the last one completed (zero if none), as a midnight in loc, the
+// clinic's time zone. ok is false when the schedule does not cover the
+// especie and tipo.
+func Next(m models.Mascota, tipo string, ultimo time.Time, loc *time.Location) (p Pendiente, ok bool) {
+    nombre := strings.ToLower(models.NombreCuidado(tipo))
+    birth := time.Date(m.FechaNacimiento.Year(), m.FechaNacimiento.Month(), m.FechaNacimiento.Day(), 0, 0, 0, 0, loc)
+    if ultimo.IsZero() {
+        r, ok := find(m.Especie, tipo, 0)
+        if !ok {
+            return p, false
+        }
+        return Pendiente{
+            Tipo:   tipo,
+            Vence:  birth.AddDate(0, 0, 7*r.PrimeraSemanas),
+            Motivo: fmt.Sprintf("No tiene ninguna %s completada; la primera corresponde a las %d semanas de edad.", nombre, r.PrimeraSemanas),
+        }, true
+    }
+    r, ok := find(m.Especie, tipo, models.MesesCumplidos(birth, ultimo))
+    if !ok {
+        return p, false
+    }
+    return Pendiente{
+        Tipo:   tipo,
+        Ultimo: ultimo,
+        Vence:  ultimo.AddDate(0, 0, r.Intervalo),
+        Motivo: fmt.Sprintf("%s; la última %s fue el %s.", r.Motivo, nombre, ultimo.Format("2006-01-02")),
+    }, true
+}
@@ -0,0 +1,39 @@
+package careplan
+
+import (
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+func TestNext(t *testing.T) {
+    day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+    cachorro := models.Mascota{Especie: "Perro", FechaNacimiento: day(2030, 3, 1)}
+    adulto := models.Mascota{Especie: "Gato", FechaNacimiento: day(2021, 11, 2)}
+    for _, tc := range []struct {
+        name   string
+        m      models.Mascota
+        tipo   string
+        ultimo time.Time
+        vence  time.Time
+    }{
+        {"first vaccine at 8 weeks", cachorro, "Vacunacion", time.Time{}, day(2030, 4, 26)},
+        {"puppy series", cachorro, "Vacunacion", day(2030, 5, 10), day(2030, 5, 31)},
+        {"series ends at 4 months", cachorro, "Vacunacion", day(2030, 7, 1), day(2031, 7, 1)},
+        {"puppy deworming", cachorro, "Desparasitacion", day(2030, 5, 10), day(2030, 6, 9)},
+        {"adult deworming", adulto, "Desparasitacion", day(2030, 1, 15), day(2030, 4, 15)},
+        {"annual booster", adulto, "Vacunacion", day(2029, 6, 1), day(2030, 6, 1)},
+    } {
+        p, ok := Next(tc.m, tc.tipo, tc.ultimo, time.UTC)
+        if !ok || !p.Vence.Equal(tc.vence) || p.Motivo == "" {
+            t.Errorf("%s: Next = %+v, %v; want due %s", tc.name, p, ok, tc.vence.Format("2006-01-02"))
+        }
+    }
+    if _, ok := Next(adulto, "Bano", day(2030, 1, 1), time.UTC); ok {
+        t.Error("Bano is not on the schedule")
+    }
+    if _, ok := Next(models.Mascota{Especie: "Pez"}, "Vacunacion", time.Time{}, time.UTC); ok {
+        t.Error("Pez is not on the schedule")
+    }
+}
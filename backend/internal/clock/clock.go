@@ -0,0 +1,37 @@
+// Package clock abstracts the current time so date rules can be tested
+// and simulated.
+package clock
+
+import (
+    "context"
+    "time"
+)
+
+type Clock interface {
+    Now() time.Time
+}
+
+// System is the real wall clock.
+type System struct{}
+
+func (System) Now() time.Time { return time.Now() }
+
+// Fixed always returns the same instant.
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time { return time.Time(f) }
+
+type ctxKey struct{}
+
+// WithContext overrides the clock for everything handling ctx.
+func WithContext(ctx context.Context, c Clock) context.Context {
+    return context.WithValue(ctx, ctxKey{}, c)
+}
+
+// FromContext returns the clock stored in ctx, or fallback.
+func FromContext(ctx context.Context, fallback Clock) Clock {
+    if c, ok := ctx.Value(ctxKey{}).(Clock); ok {
+        return c
+    }
+    return fallback
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+  "bytes"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+)
+
+// clearEnv keeps the environment of the machine running the tests out of
+// Load: empty variables are ignored.
+func clearEnv(t *testing.T) {
+  t.Helper()
+  t.Setenv("CONFIG_FILE", "")
+  for _, s := range settings {
+    t.Setenv(s.env, "")
+  }
+}
+
+func writeFile(t *testing.T, name, body string) string {
+  t.Helper()
+  path := filepath.Join(t.TempDir(), name)
+  if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+    t.Fatal(err)
+  }
+  return path
+}
+
+// TestLoadPrecedence sets each value in more than one source: the file
+// wins over the defaults, the environment over the file and the flags
+// over everything.
+func TestLoadPrecedence(t *testing.T) {
+  clearEnv(t)
+  for _, file := range []struct{ name, body string }{
+    {"config.yaml", "server:\n  port: \"7001\"\n  read_timeout: 20s\nlog:\n  level: warn\ndb:\n  max_open_conns: 30\n"},
+    {"config.toml", "[server]\nport = \"7001\"\nread_timeout = \"20s\"\n[log]\nlevel = \"warn\"\n[db]\nmax_open_conns = 30\n"},
+  } {
+    t.Run(file.name, func(t *testing.T) {
+      t.Setenv("CONFIG_FILE", writeFile(t, file.name, file.body))
+      t.Setenv("LOG_LEVEL", "ERROR")
+      t.Setenv("DB_MAX_OPEN_CONNS", "40")
+      cfg, err := Load([]string{"--db-max-open-conns=50"})
+      if err != nil {
+        t.Fatal(err)
+      }
+      if cfg.Server.Port != "7001" || cfg.Server.ReadTimeout != 20*time.Second {
+        t.Errorf("from the file: port %q, read_timeout %s", cfg.Server.Port, cfg.Server.ReadTimeout)
+      }
+      if cfg.Log.Level != "error" {
+        t.Errorf("log.level = %q, want the environment's error", cfg.Log.Level)
+      }
+      if cfg.DB.MaxOpenConns != 50 {
+        t.Errorf("db.max_open_conns = %d, want the flag's 50", cfg.DB.MaxOpenConns)
+      }
+      if cfg.Server.WriteTimeout != Defaults().Server.WriteTimeout {
+        t.Errorf("server.write_timeout = %s, want the default", cfg.Server.WriteTimeout)
+      }
+    })
+  }
+
+  // --config takes the place of CONFIG_FILE.
+  t.Setenv("CONFIG_FILE", writeFile(t, "a.yaml", "server:\n  port: \"7001\"\n"))
+  cfg, err := Load([]string{"--config", writeFile(t, "b.yaml", "server:\n  port: \"7002\"\n")})
+  if err != nil || cfg.Server.Port != "7002" {
+    t.Fatalf("--config: port %q, %v", cfg.Server.Port, err)
+  }
+}
+
+func TestLoadRejectsBadInput(t *testing.T) {
+  for _, tc := range []struct {
+    name string
+    env  map[string]string
+    args []string
+    want []string
+  }{
+    {"env", map[string]string{"DB_MAX_OPEN_CONNS": "muchas", "HTTP_READ_TIMEOUT": "15"}, nil,
+      []string{`$DB_MAX_OPEN_CONNS: invalid integer "muchas"`, `$HTTP_READ_TIMEOUT: invalid duration "15"`}},
+    {"flag", nil, []string{"--auto-migrate=quizas"}, []string{`--auto-migrate: invalid boolean "quizas"`}},
+    {"tenant tokens", map[string]string{"TENANT_TOKENS": "norte=norte-token-0123456789,sur"}, nil,
+      []string{`$TENANT_TOKENS: invalid tenant token "sur", want tenant=token`}},
+    {"unknown key", map[string]string{"CONFIG_FILE": writeFile(t, "c.yaml", "server:\n  puerto: \"80\"\n")}, nil,
+      []string{"field puerto not found"}},
+    {"unknown extension", map[string]string{"CONFIG_FILE": writeFile(t, "c.json", "{}")}, nil,
+      []string{"unsupported extension"}},
+    {"validation", map[string]string{"PORT": "0", "DB_MAX_OPEN_CONNS": "2", "DB_MAX_IDLE_CONNS": "5", "DB_DRIVER": "mysql"}, nil,
+      []string{"invalid configuration", "server.port: must be a number between 1 and 65535", "db.max_idle_conns: (5) must not exceed db.max_open_conns (2)",
+        `db.driver: must be stdlib or pgxpool, got "mysql"`}},
+  } {
+    t.Run(tc.name, func(t *testing.T) {
+      clearEnv(t)
+      for k, v := range tc.env {
+        t.Setenv(k, v)
+      }
+      _, err := Load(tc.args)
+      if err == nil {
+        t.Fatal("Load accepted it")
+      }
+      for _, w := range tc.want {
+        if !strings.Contains(err.Error(), w) {
+          t.Errorf("error %q does not mention %q", err, w)
+        }
+      }
+    })
+  }
+}
+
+func TestValidate(t *testing.T) {
+  if err := Defaults().Validate(); err != nil {
+    t.Fatalf("defaults: %v", err)
+  }
+  c := Defaults()
+  c.DB.DSN = "mysql://root@localhost/mascotas"
+  c.Log.Level = "verbose"
+  c.Clinic.PublicURL = "/api"
+  c.Admin.StaffToken = "staff-token-0123456789"
+  c.Admin.TenantTokens = map[string]string{"Norte": "norte-token-0123456789", "sur": "corto", "este": c.Admin.StaffToken}
+  err := c.Validate()
+  if err == nil {
+    t.Fatal("Validate accepted it")
+  }
+  // Every problem is reported, one per line.
+  for _, key := range []string{"db.dsn", "log.level", "clinic.public_url", "admin.tenant_tokens.Norte", "admin.tenant_tokens.sur", "admin.tenant_tokens.este"} {
+    if !strings.Contains(err.Error(), "\n  "+key+": ") {
+      t.Errorf("error %q does not report %s", err, key)
+    }
+  }
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+  secrets := []string{"s3cr3t-db-pass", "admin-token-0123456789", "staff-token-0123456789", "smtp-pass-0001",
+    "feed-token-0123456789", "signing-key-0123456789", "s3-secret-0001", "norte-token-0123456789"}
+  c := Defaults()
+  c.DB.DSN = "postgres://app:" + secrets[0] + "@db:5432/mascotas"
+  c.Admin.Token, c.Admin.StaffToken = secrets[1], secrets[2]
+  c.Notify.SMTP.Password = secrets[3]
+  c.Calendar.FeedToken = secrets[4]
+  c.Attachments.SigningKey = secrets[5]
+  c.Attachments.S3.SecretKey = secrets[6]
+  c.Admin.TenantTokens = map[string]string{"norte": secrets[7]}
+
+  var buf bytes.Buffer
+  if err := c.Print(&buf); err != nil {
+    t.Fatal(err)
+  }
+  out := buf.String()
+  for _, s := range secrets {
+    if strings.Contains(out, s) {
+      t.Errorf("printed %q:\n%s", s, out)
+    }
+  }
+  if !strings.Contains(out, "postgres://app:"+redacted+"@db:5432/mascotas") || !strings.Contains(out, "port: \"8080\"") ||
+    !strings.Contains(out, "norte: "+redacted) {
+    t.Errorf("printed config lost its non-secret values:\n%s", out)
+  }
+  if c.Admin.Token != secrets[1] || c.Admin.TenantTokens["norte"] != secrets[7] {
+    t.Error("Print changed the config")
+  }
+
+  for dsn, want := range map[string]string{
+    "host=db user=app password=" + secrets[0] + " dbname=mascotas": "host=db user=app password=" + redacted + " dbname=mascotas",
+    "host=db password='" + secrets[0] + " x' dbname=mascotas":      "host=db password=" + redacted + " dbname=mascotas",
+    "postgres://app@db/mascotas":                                    "postgres://app@db/mascotas",
+  } {
+    if got := redactDSN(dsn); got != want {
+      t.Errorf("redactDSN(%q) = %q, want %q", dsn, got, want)
+    }
+  }
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/BurntSushi/toml"
+  "gopkg.in/yaml.v3"
+)
+
+// setting binds one configuration value to its environment variable and
+// CLI flag. Both sources go through the same parser so they behave alike.
+type setting struct {
+  env   string
+  flag  string
+  usage string
+  apply func(c *Config, v string) error
+}
+
+var settings = []setting{
+  {"PORT", "port", "puerto HTTP", func(c *Config, v string) error { c.Server.Port = v; return nil }},
+  {"HTTP_READ_HEADER_TIMEOUT", "read-header-timeout", "timeout de lectura de cabeceras", durationInto(func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout })},
+  {"HTTP_READ_TIMEOUT", "read-timeout", "timeout de lectura", durationInto(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+  {"HTTP_WRITE_TIMEOUT", "write-timeout", "timeout de escritura", durationInto(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+  {"HTTP_IDLE_TIMEOUT", "idle-timeout", "timeout de conexiones inactivas", durationInto(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+  {"HTTP_SHUTDOWN_TIMEOUT", "shutdown-timeout", "tiempo máximo de apagado ordenado", durationInto(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+  {"DB_DSN", "db-dsn", "cadena de conexión a Postgres", func(c *Config, v string) error { c.DB.DSN = v; return nil }},
+  {"DB_MAX_OPEN_CONNS", "db-max-open-conns", "máximo de conexiones abiertas (0 = sin límite)", intInto(func(c *Config) *int { return &c.DB.MaxOpenConns })},
+  {"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "máximo de conexiones inactivas", intInto(func(c *Config) *int { return &c.DB.MaxIdleConns })},
+  {"DB_DRIVER", "db-driver", "pool de conexiones: stdlib o pgxpool", func(c *Config, v string) error { c.DB.Driver = strings.ToLower(v); return nil }},
+  {"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "vida máxima de una conexión", durationInto(func(c *Config) *time.Duration { return &c.DB.ConnMaxLifetime })},
+  {"DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "tiempo máximo inactiva de una conexión", durationInto(func(c *Config) *time.Duration { return &c.DB.ConnMaxIdleTime })},
+  {"DB_QUERY_TIMEOUT", "db-query-timeout", "tiempo máximo de base de datos por petición", durationInto(func(c *Config) *time.Duration { return &c.DB.QueryTimeout })},
+  {"DB_STATEMENT_TIMEOUT", "db-statement-timeout", "statement_timeout de Postgres (0 = sin límite)", durationInto(func(c *Config) *time.Duration { return &c.DB.StatementTimeout })},
+  {"DB_APPLICATION_NAME", "db-application-name", "application_name reportado a Postgres", func(c *Config, v string) error { c.DB.ApplicationName = v; return nil }},
+  {"ALLOWED_ORIGINS", "allowed-origins", "orígenes CORS permitidos, separados por coma", func(c *Config, v string) error { c.CORS.AllowedOrigins = splitList(v); return nil }},
+  {"LOG_LEVEL", "log-level", "nivel de log (debug, info, warn, error)", func(c *Config, v string) error { c.Log.Level = strings.ToLower(v); return nil }},
+  {"APP_TIMEZONE", "timezone", "zona horaria para reglas de agenda (ej. America/Bogota)", func(c *Config, v string) error { c.Timezone = v; return nil }},
+  {"FEATURE_AUTO_MIGRATE", "auto-migrate", "ejecutar migraciones al iniciar", boolInto(func(c *Config) *bool { return &c.Features.AutoMigrate })},
+  {"FEATURE_REQUEST_LOG", "request-log", "registrar cada petición HTTP", boolInto(func(c *Config) *bool { return &c.Features.RequestLog })},
+  {"FEATURE_SAME_DAY_CARE", "same-day-care", "permitir cuidados el mismo día con una hora de anticipación", boolInto(func(c *Config) *bool { return &c.Features.SameDayCare })},
+  {"FEATURE_FAKE_NOW", "fake-now", "permitir X-Fake-Now en peticiones de administrador (solo staging)", boolInto(func(c *Config) *bool { return &c.Features.FakeNow })},
+  {"NOTIFY_ENABLED", "notify", "enviar recordatorios de cuidados por e-mail", boolInto(func(c *Config) *bool { return &c.Notify.Enabled })},
+  {"NOTIFY_REMINDER_LEAD", "notify-reminder-lead", "anticipación del recordatorio respecto al cuidado", durationInto(func(c *Config) *time.Duration { return &c.Notify.ReminderLead })},
+  {"NOTIFY_POLL_INTERVAL", "notify-poll-interval", "cada cuánto se buscan recordatorios pendientes", durationInto(func(c *Config) *time.Duration { return &c.Notify.PollInterval })},
+  {"NOTIFY_BATCH_SIZE", "notify-batch-size", "recordatorios procesados por ronda", intInto(func(c *Config) *int { return &c.Notify.BatchSize })},
+  {"NOTIFY_MAX_ATTEMPTS", "notify-max-attempts", "intentos de envío antes de marcar como fallido", intInto(func(c *Config) *int { return &c.Notify.MaxAttempts })},
+  {"NOTIFY_RETRY_BACKOFF", "notify-retry-backoff", "espera tras el primer fallo (se duplica en cada intento)", durationInto(func(c *Config) *time.Duration { return &c.Notify.RetryBackoff })},
+  {"NOTIFY_MAX_BACKOFF", "notify-max-backoff", "espera máxima entre intentos", durationInto(func(c *Config) *time.Duration { return &c.Notify.MaxBackoff })},
+  {"SMTP_HOST", "smtp-host", "servidor SMTP", func(c *Config, v string) error { c.Notify.SMTP.Host = v; return nil }},
+  {"SMTP_PORT", "smtp-port", "puerto SMTP", intInto(func(c *Config) *int { return &c.Notify.SMTP.Port })},
+  {"SMTP_USERNAME", "smtp-username", "usuario SMTP", func(c *Config, v string) error { c.Notify.SMTP.Username = v; return nil }},
+  {"SMTP_PASSWORD", "smtp-password", "contraseña SMTP", func(c *Config, v string) error { c.Notify.SMTP.Password = v; return nil }},
+  {"SMTP_FROM", "smtp-from", "remitente de los recordatorios", func(c *Config, v string) error { c.Notify.SMTP.From = v; return nil }},
+  {"SMTP_TIMEOUT", "smtp-timeout", "tiempo máximo por envío", durationInto(func(c *Config) *time.Duration { return &c.Notify.SMTP.Timeout })},
+  {"WEBHOOK_DISPATCHER", "webhook-dispatcher", "entregar webhooks desde esta instancia", boolInto(func(c *Config) *bool { return &c.Webhooks.Enabled })},
+  {"WEBHOOK_POLL_INTERVAL", "webhook-poll-interval", "cada cuánto se buscan entregas de webhooks pendientes", durationInto(func(c *Config) *time.Duration { return &c.Webhooks.PollInterval })},
+  {"WEBHOOK_BATCH_SIZE", "webhook-batch-size", "entregas de webhooks procesadas por ronda", intInto(func(c *Config) *int { return &c.Webhooks.BatchSize })},
+  {"WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "intentos antes de marcar una entrega como fallida", intInto(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+  {"WEBHOOK_RETRY_BACKOFF", "webhook-retry-backoff", "espera tras el primer fallo (se duplica en cada intento)", durationInto(func(c *Config) *time.Duration { return &c.Webhooks.RetryBackoff })},
+  {"WEBHOOK_MAX_BACKOFF", "webhook-max-backoff", "espera máxima entre intentos", durationInto(func(c *Config) *time.Duration { return &c.Webhooks.MaxBackoff })},
+  {"WEBHOOK_TIMEOUT", "webhook-timeout", "tiempo máximo por petición a un webhook", durationInto(func(c *Config) *time.Duration { return &c.Webhooks.Timeout })},
+  {"WEBHOOK_ALLOW_PRIVATE", "webhook-allow-private", "aceptar webhooks hacia localhost y redes privadas", boolInto(func(c *Config) *bool { return &c.Webhooks.AllowPrivate })},
+  {"CALENDAR_FEED_TOKEN", "calendar-feed-token", "token del calendario /agenda.ics (vacío = deshabilitado)", func(c *Config, v string) error { c.Calendar.FeedToken = v; return nil }},
+  {"CALENDAR_UID_DOMAIN", "calendar-uid-domain", "dominio de los UID de eventos iCalendar", func(c *Config, v string) error { c.Calendar.UIDDomain = v; return nil }},
+  {"CLINIC_NAME", "clinic-name", "nombre de la clínica en los documentos PDF", func(c *Config, v string) error { c.Clinic.Name = v; return nil }},
+  {"CLINIC_CONTACT", "clinic-contact", "dirección y teléfono de la clínica en los documentos PDF", func(c *Config, v string) error { c.Clinic.Contact = v; return nil }},
+  {"PUBLIC_URL", "public-url", "URL pública de la API, usada en los códigos QR de verificación", func(c *Config, v string) error { c.Clinic.PublicURL = v; return nil }},
+  {"ATTACHMENTS_BACKEND", "attachments-backend", "almacenamiento de adjuntos: local o s3", func(c *Config, v string) error { c.Attachments.Backend = strings.ToLower(v); return nil }},
+  {"ATTACHMENTS_DIR", "attachments-dir", "directorio de los adjuntos (backend local)", func(c *Config, v string) error { c.Attachments.Dir = v; return nil }},
+  {"ATTACHMENTS_MAX_SIZE_MB", "attachments-max-size-mb", "tamaño máximo de cada adjunto en MB", intInto(func(c *Config) *int { return &c.Attachments.MaxSizeMB })},
+  {"ATTACHMENTS_SIGNING_KEY", "attachments-signing-key", "clave de firma de los enlaces de descarga (vacío = aleatoria por proceso)", func(c *Config, v string) error { c.Attachments.SigningKey = v; return nil }},
+  {"ATTACHMENTS_URL_TTL", "attachments-url-ttl", "validez de los enlaces de descarga", durationInto(func(c *Config) *time.Duration { return &c.Attachments.URLTTL })},
+  {"ATTACHMENTS_SWEEP_INTERVAL", "attachments-sweep-interval", "cada cuánto se borran los adjuntos huérfanos", durationInto(func(c *Config) *time.Duration { return &c.Attachments.SweepInterval })},
+  {"S3_ENDPOINT", "s3-endpoint", "URL del servicio S3 compatible (ej. http://minio:9000)", func(c *Config, v string) error { c.Attachments.S3.Endpoint = v; return nil }},
+  {"S3_REGION", "s3-region", "región S3", func(c *Config, v string) error { c.Attachments.S3.Region = v; return nil }},
+  {"S3_BUCKET", "s3-bucket", "bucket de los adjuntos", func(c *Config, v string) error { c.Attachments.S3.Bucket = v; return nil }},
+  {"S3_ACCESS_KEY", "s3-access-key", "access key S3", func(c *Config, v string) error { c.Attachments.S3.AccessKey = v; return nil }},
+  {"S3_SECRET_KEY", "s3-secret-key", "secret key S3", func(c *Config, v string) error { c.Attachments.S3.SecretKey = v; return nil }},
+  {"S3_PATH_STYLE", "s3-path-style", "direccionar el bucket en la ruta (MinIO)", boolInto(func(c *Config) *bool { return &c.Attachments.S3.PathStyle })},
+  {"REPORTS_MATERIALIZED", "reports-materialized", "calcular los reportes de cuidados desde la vista materializada", boolInto(func(c *Config) *bool { return &c.Reports.Materialized })},
+  {"REPORTS_REFRESH_INTERVAL", "reports-refresh-interval", "cada cuánto se refresca la vista materializada de reportes", durationInto(func(c *Config) *time.Duration { return &c.Reports.RefreshInterval })},
+  {"ADMIN_TOKEN", "admin-token", "token de administrador (Authorization: Bearer)", func(c *Config, v string) error { c.Admin.Token = v; return nil }},
+  {"STAFF_TOKEN", "staff-token", "token del personal para buscar mascotas por microchip (Authorization: Bearer)", func(c *Config, v string) error { c.Admin.StaffToken = v; return nil }},
+  {"TENANT_TOKENS", "tenant-tokens", "tokens del stream /eventos por tenant, como tenant=token separados por comas", tenantTokens},
+}
+
+// Load resolves the configuration from defaults, the config file, the
+// environment and args (usually os.Args[1:]), then validates it.
+//
+// The config file is taken from --config or CONFIG_FILE; its format is
+// chosen by extension (.yaml, .yml or .toml).
+func Load(args []string) (Config, error) {
+  cfg := Defaults()
+
+  fs := flag.NewFlagSet("api", flag.ContinueOnError)
+  configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "ruta a un archivo de configuración YAML o TOML")
+  fs.BoolVar(&cfg.PrintConfig, "print-config", false, "imprime la configuración efectiva (sin secretos) y termina")
+  flagValues := make(map[string]*string, len(settings))
+  for _, s := range settings {
+    flagValues[s.flag] = fs.String(s.flag, "", s.usage+" ($"+s.env+")")
+  }
+  if err := fs.Parse(args); err != nil {
+    return cfg, err
+  }
+
+  if *configFile != "" {
+    if err := loadFile(&cfg, *configFile); err != nil {
+      return cfg, err
+    }
+  }
+
+  var errs []error
+  for _, s := range settings {
+    if v, ok := os.LookupEnv(s.env); ok && v != "" {
+      if err := s.apply(&cfg, v); err != nil {
+        errs = append(errs, fmt.Errorf("$%s: %w", s.env, err))
+      }
+    }
+  }
+  fs.Visit(func(f *flag.Flag) {
+    for _, s := range settings {
+      if s.flag == f.Name {
+        if err := s.apply(&cfg, *flagValues[s.flag]); err != nil {
+          errs = append(errs, fmt.Errorf("--%s: %w", s.flag, err))
+        }
+      }
+    }
+  })
+  if len(errs) > 0 {
+    return cfg, errors.Join(errs...)
+  }
+
+  return cfg, cfg.Validate()
+}
+
+func loadFile(cfg *Config, path string) error {
+  f, err := os.Open(path)
+  if err != nil {
+    return fmt.Errorf("config file: %w", err)
+  }
+  defer f.Close()
+
+  switch strings.ToLower(filepath.Ext(path)) {
+  case ".yaml", ".yml":
+    dec := yaml.NewDecoder(f)
+    dec.KnownFields(true)
+    if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+      return fmt.Errorf("config file %s: %w", path, err)
+    }
+  case ".toml":
+    md, err := toml.NewDecoder(f).Decode(cfg)
+    if err != nil {
+      return fmt.Errorf("config file %s: %w", path, err)
+    }
+    if undecoded := md.Undecoded(); len(undecoded) > 0 {
+      return fmt.Errorf("config file %s: unknown keys %v", path, undecoded)
+    }
+  default:
+    return fmt.Errorf("config file %s: unsupported extension (use .yaml, .yml or .toml)", path)
+  }
+  return nil
+}
+
+func durationInto(field func(*Config) *time.Duration) func(*Config, string) error {
+  return func(c *Config, v string) error {
+    d, err := time.ParseDuration(v)
+    if err != nil {
+      return fmt.Errorf("invalid duration %q", v)
+    }
+    *field(c) = d
+    return nil
+  }
+}
+
+func intInto(field func(*Config) *int) func(*Config, string) error {
+  return func(c *Config, v string) error {
+    n, err := strconv.Atoi(v)
+    if err != nil {
+      return fmt.Errorf("invalid integer %q", v)
+    }
+    *field(c) = n
+    return nil
+  }
+}
+
+func boolInto(field func(*Config) *bool) func(*Config, string) error {
+  return func(c *Config, v string) error {
+    b, err := strconv.ParseBool(v)
+    if err != nil {
+      return fmt.Errorf("invalid boolean %q", v)
+    }
+    *field(c) = b
+    return nil
+  }
+}
+
+// tenantTokens parses "norte=token1,sur=token2".
+func tenantTokens(c *Config, v string) error {
+  tokens := make(map[string]string)
+  for _, p := range splitList(v) {
+    name, token, ok := strings.Cut(p, "=")
+    if !ok {
+      return fmt.Errorf("invalid tenant token %q, want tenant=token", p)
+    }
+    tokens[strings.TrimSpace(name)] = strings.TrimSpace(token)
+  }
+  c.Admin.TenantTokens = tokens
+  return nil
+}
+
+func splitList(v string) []string {
+  out := make([]string, 0)
+  for _, p := range strings.Split(v, ",") {
+    if p = strings.TrimSpace(p); p != "" {
+      out = append(out, p)
+    }
+  }
+  return out
+}
@@ -0,0 +1,297 @@
+package config
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "net/mail"
+  "net/url"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "gopkg.in/yaml.v3"
+)
+
+// Validate checks every value and reports all problems at once so a bad
+// deployment can be fixed in a single pass.
+func (c Config) Validate() error {
+  var errs []error
+  bad := func(key, format string, args ...any) {
+    errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+  }
+
+  if n, err := strconv.Atoi(c.Server.Port); err != nil || n < 1 || n > 65535 {
+    bad("server.port", "must be a number between 1 and 65535, got %q", c.Server.Port)
+  }
+  for _, t := range []struct {
+    key string
+    d   time.Duration
+  }{
+    {"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+    {"server.read_timeout", c.Server.ReadTimeout},
+    {"server.write_timeout", c.Server.WriteTimeout},
+    {"server.idle_timeout", c.Server.IdleTimeout},
+  } {
+    if t.d <= 0 {
+      bad(t.key, "must be greater than zero, got %s", t.d)
+    }
+  }
+  if c.Server.ShutdownTimeout < 0 {
+    bad("server.shutdown_timeout", "must not be negative, got %s", c.Server.ShutdownTimeout)
+  }
+
+  if strings.TrimSpace(c.DB.DSN) == "" {
+    bad("db.dsn", "is required")
+  } else if strings.Contains(c.DB.DSN, "://") {
+    if u, err := url.Parse(c.DB.DSN); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+      bad("db.dsn", "must be a postgres:// URL or a key=value connection string")
+    }
+  }
+  if c.DB.MaxOpenConns < 0 {
+    bad("db.max_open_conns", "must not be negative, got %d", c.DB.MaxOpenConns)
+  }
+  if c.DB.MaxIdleConns < 0 {
+    bad("db.max_idle_conns", "must not be negative, got %d", c.DB.MaxIdleConns)
+  }
+  if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+    bad("db.max_idle_conns", "(%d) must not exceed db.max_open_conns (%d)", c.DB.MaxIdleConns, c.DB.MaxOpenConns)
+  }
+  if c.DB.Driver != "stdlib" && c.DB.Driver != "pgxpool" {
+    bad("db.driver", "must be stdlib or pgxpool, got %q", c.DB.Driver)
+  }
+  if c.DB.ConnMaxLifetime < 0 {
+    bad("db.conn_max_lifetime", "must not be negative, got %s", c.DB.ConnMaxLifetime)
+  }
+  if c.DB.ConnMaxIdleTime < 0 {
+    bad("db.conn_max_idle_time", "must not be negative, got %s", c.DB.ConnMaxIdleTime)
+  }
+  if c.DB.QueryTimeout <= 0 {
+    bad("db.query_timeout", "must be greater than zero, got %s", c.DB.QueryTimeout)
+  }
+  if c.DB.StatementTimeout < 0 {
+    bad("db.statement_timeout", "must not be negative, got %s", c.DB.StatementTimeout)
+  }
+
+  for _, o := range c.CORS.AllowedOrigins {
+    if o == "*" {
+      continue
+    }
+    u, err := url.Parse(o)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+      bad("cors.allowed_origins", "%q is not an origin like https://example.com", o)
+    }
+  }
+
+  switch c.Log.Level {
+  case "debug", "info", "warn", "error":
+  default:
+    bad("log.level", "must be one of debug, info, warn, error, got %q", c.Log.Level)
+  }
+
+  if _, err := time.LoadLocation(c.Timezone); err != nil {
+    bad("timezone", "unknown time zone %q", c.Timezone)
+  }
+
+  if c.Admin.Token != "" && len(c.Admin.Token) < 16 {
+    bad("admin.token", "must be at least 16 characters long")
+  }
+  if c.Admin.StaffToken != "" && len(c.Admin.StaffToken) < 16 {
+    bad("admin.staff_token", "must be at least 16 characters long")
+  }
+  tokens := map[string]bool{c.Admin.Token: true, c.Admin.StaffToken: true}
+  names := make([]string, 0, len(c.Admin.TenantTokens))
+  for name := range c.Admin.TenantTokens {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  for _, name := range names {
+    token := c.Admin.TenantTokens[name]
+    key := "admin.tenant_tokens." + name
+    switch {
+    case !tenantName.MatchString(name):
+      bad(key, "tenant must be up to 40 lowercase letters, digits, '-' or '_'")
+    case len(token) < 16:
+      bad(key, "must be at least 16 characters long")
+    case tokens[token]:
+      bad(key, "must differ from the other tokens")
+    }
+    tokens[token] = true
+  }
+  if c.Features.FakeNow && c.Admin.Token == "" {
+    bad("features.fake_now", "requires admin.token to be set")
+  }
+
+  if n := c.Notify; n.Enabled {
+    if n.ReminderLead <= 0 {
+      bad("notify.reminder_lead", "must be greater than zero, got %s", n.ReminderLead)
+    }
+    if n.PollInterval <= 0 {
+      bad("notify.poll_interval", "must be greater than zero, got %s", n.PollInterval)
+    }
+    if n.BatchSize < 1 {
+      bad("notify.batch_size", "must be at least 1, got %d", n.BatchSize)
+    }
+    if n.MaxAttempts < 1 {
+      bad("notify.max_attempts", "must be at least 1, got %d", n.MaxAttempts)
+    }
+    if n.RetryBackoff <= 0 {
+      bad("notify.retry_backoff", "must be greater than zero, got %s", n.RetryBackoff)
+    }
+    if n.MaxBackoff < n.RetryBackoff {
+      bad("notify.max_backoff", "(%s) must not be less than notify.retry_backoff (%s)", n.MaxBackoff, n.RetryBackoff)
+    }
+    if strings.TrimSpace(n.SMTP.Host) == "" {
+      bad("notify.smtp.host", "is required when notify.enabled is set")
+    }
+    if n.SMTP.Port < 1 || n.SMTP.Port > 65535 {
+      bad("notify.smtp.port", "must be between 1 and 65535, got %d", n.SMTP.Port)
+    }
+    if _, err := mail.ParseAddress(n.SMTP.From); err != nil {
+      bad("notify.smtp.from", "must be an e-mail address, got %q", n.SMTP.From)
+    }
+    if n.SMTP.Password != "" && n.SMTP.Username == "" {
+      bad("notify.smtp.username", "is required when notify.smtp.password is set")
+    }
+    if n.SMTP.Timeout <= 0 {
+      bad("notify.smtp.timeout", "must be greater than zero, got %s", n.SMTP.Timeout)
+    }
+  }
+
+  if wh := c.Webhooks; wh.Enabled {
+    if wh.PollInterval <= 0 {
+      bad("webhooks.poll_interval", "must be greater than zero, got %s", wh.PollInterval)
+    }
+    if wh.BatchSize < 1 {
+      bad("webhooks.batch_size", "must be at least 1, got %d", wh.BatchSize)
+    }
+    if wh.MaxAttempts < 1 {
+      bad("webhooks.max_attempts", "must be at least 1, got %d", wh.MaxAttempts)
+    }
+    if wh.RetryBackoff <= 0 {
+      bad("webhooks.retry_backoff", "must be greater than zero, got %s", wh.RetryBackoff)
+    }
+    if wh.MaxBackoff < wh.RetryBackoff {
+      bad("webhooks.max_backoff", "(%s) must not be less than webhooks.retry_backoff (%s)", wh.MaxBackoff, wh.RetryBackoff)
+    }
+    if wh.Timeout <= 0 {
+      bad("webhooks.timeout", "must be greater than zero, got %s", wh.Timeout)
+    }
+  }
+
+  if t := c.Calendar.FeedToken; t != "" && len(t) < 16 {
+    bad("calendar.feed_token", "must be at least 16 characters long")
+  }
+  if d := c.Calendar.UIDDomain; d == "" || strings.ContainsAny(d, " @\t\r\n") {
+    bad("calendar.uid_domain", "must be a host name, got %q", d)
+  }
+  switch a := c.Attachments; a.Backend {
+  case "local":
+    if a.Dir == "" {
+      bad("attachments.dir", "must not be empty")
+    }
+  case "s3":
+    if u, err := url.Parse(a.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+      bad("attachments.s3.endpoint", "must be an absolute http(s) URL, got %q", a.S3.Endpoint)
+    }
+    for _, f := range []struct{ key, v string }{
+      {"attachments.s3.region", a.S3.Region},
+      {"attachments.s3.bucket", a.S3.Bucket},
+      {"attachments.s3.access_key", a.S3.AccessKey},
+      {"attachments.s3.secret_key", a.S3.SecretKey},
+    } {
+      if f.v == "" {
+        bad(f.key, "must not be empty")
+      }
+    }
+  default:
+    bad("attachments.backend", "must be local or s3, got %q", a.Backend)
+  }
+  if n := c.Attachments.MaxSizeMB; n < 1 || n > 100 {
+    bad("attachments.max_size_mb", "must be between 1 and 100, got %d", n)
+  }
+  if k := c.Attachments.SigningKey; k != "" && len(k) < 32 {
+    bad("attachments.signing_key", "must be at least 32 characters long")
+  }
+  if c.Attachments.URLTTL <= 0 {
+    bad("attachments.url_ttl", "must be greater than zero, got %s", c.Attachments.URLTTL)
+  }
+  if c.Attachments.SweepInterval <= 0 {
+    bad("attachments.sweep_interval", "must be greater than zero, got %s", c.Attachments.SweepInterval)
+  }
+  if c.Reports.Materialized && c.Reports.RefreshInterval <= 0 {
+    bad("reports.refresh_interval", "must be greater than zero, got %s", c.Reports.RefreshInterval)
+  }
+  if strings.TrimSpace(c.Clinic.Name) == "" {
+    bad("clinic.name", "must not be empty")
+  }
+  if u, err := url.Parse(c.Clinic.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+    bad("clinic.public_url", "must be an absolute http(s) URL, got %q", c.Clinic.PublicURL)
+  }
+
+  if len(errs) > 0 {
+    msgs := make([]string, len(errs))
+    for i, e := range errs {
+      msgs[i] = e.Error()
+    }
+    return errors.New("invalid configuration:\n  " + strings.Join(msgs, "\n  "))
+  }
+  return nil
+}
+
+const redacted = "REDACTED"
+
+// tenantName matches models.ValidTenant; config does not import models.
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// Redacted returns a copy of c with secrets masked, safe to print or log.
+func (c Config) Redacted() Config {
+  out := c
+  out.CORS.AllowedOrigins = append([]string(nil), c.CORS.AllowedOrigins...)
+  out.DB.DSN = redactDSN(c.DB.DSN)
+  out.Admin.Token = redactSecret(c.Admin.Token)
+  out.Admin.StaffToken = redactSecret(c.Admin.StaffToken)
+  if c.Admin.TenantTokens != nil {
+    out.Admin.TenantTokens = make(map[string]string, len(c.Admin.TenantTokens))
+    for name, token := range c.Admin.TenantTokens {
+      out.Admin.TenantTokens[name] = redactSecret(token)
+    }
+  }
+  out.Notify.SMTP.Password = redactSecret(c.Notify.SMTP.Password)
+  out.Calendar.FeedToken = redactSecret(c.Calendar.FeedToken)
+  out.Attachments.SigningKey = redactSecret(c.Attachments.SigningKey)
+  out.Attachments.S3.SecretKey = redactSecret(c.Attachments.S3.SecretKey)
+  return out
+}
+
+func redactSecret(v string) string {
+  if v == "" {
+    return ""
+  }
+  return redacted
+}
+
+func redactDSN(dsn string) string {
+  if u, err := url.Parse(dsn); err == nil && u.User != nil {
+    if _, ok := u.User.Password(); ok {
+      u.User = url.UserPassword(u.User.Username(), redacted)
+      return u.String()
+    }
+    return dsn
+  }
+  return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// Print writes the redacted configuration as YAML.
+func (c Config) Print(w io.Writer) error {
+  enc := yaml.NewEncoder(w)
+  enc.SetIndent(2)
+  if err := enc.Encode(c.Redacted()); err != nil {
+    return err
+  }
+  return enc.Close()
+}
@@ -1,18 +1,145 @@
 package database
 
 import (
+    "context"
     "database/sql"
-    _ "github.com/jackc/pgx/v5/stdlib"
+    "fmt"
+    "strconv"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/pgxpool"
+    "github.com/jackc/pgx/v5/stdlib"
 )
 
-func Open(dsn string) (*sql.DB, error) {
-    db, err := sql.Open("pgx", dsn)
-    if err != nil {
-        return nil, err
+const (
+    DriverStdlib  = "stdlib"
+    DriverPgxpool = "pgxpool"
+)
+
+// Options tunes the connection pool and the session settings applied to
+// every new connection.
+type Options struct {
+    DSN             string
+    Driver          string // DriverStdlib (default) or DriverPgxpool
+    MaxOpenConns    int
+    MaxIdleConns    int
+    ConnMaxLifetime time.Duration
+    ConnMaxIdleTime time.Duration
+    // StatementTimeout and ApplicationName are sent as Postgres runtime
+    // parameters when each connection is established.
+    StatementTimeout time.Duration
+    ApplicationName  string
+}
+
+// DB is a *sql.DB that remembers the pgxpool backing it, if any, so the
+// pool can be closed and its statistics reported.
+type DB struct {
+    *sql.DB
+    pool *pgxpool.Pool
+}
+
+// PoolStats is a driver-independent snapshot of the connection pool.
+type PoolStats struct {
+    Driver       string `json:"driver"`
+    MaxOpen      int    `json:"max_open"`
+    Open         int    `json:"open"`
+    InUse        int    `json:"in_use"`
+    Idle         int    `json:"idle"`
+    WaitCount    int64  `json:"wait_count"`
+    WaitDuration string `json:"wait_duration"`
+    Saturated    bool   `json:"saturated"`
+}
+
+func Open(ctx context.Context, opts Options) (*DB, error) {
+    var db *DB
+    switch opts.Driver {
+    case "", DriverStdlib:
+        cc, err := pgx.ParseConfig(opts.DSN)
+        if err != nil {
+            return nil, err
+        }
+        applyRuntimeParams(cc.RuntimeParams, opts)
+        db = &DB{DB: stdlib.OpenDB(*cc)}
+        db.SetMaxIdleConns(opts.MaxIdleConns)
+    case DriverPgxpool:
+        pc, err := pgxpool.ParseConfig(opts.DSN)
+        if err != nil {
+            return nil, err
+        }
+        applyRuntimeParams(pc.ConnConfig.RuntimeParams, opts)
+        if opts.MaxOpenConns > 0 {
+            pc.MaxConns = int32(opts.MaxOpenConns)
+        }
+        if opts.ConnMaxLifetime > 0 {
+            pc.MaxConnLifetime = opts.ConnMaxLifetime
+        }
+        if opts.ConnMaxIdleTime > 0 {
+            pc.MaxConnIdleTime = opts.ConnMaxIdleTime
+        }
+        pool, err := pgxpool.NewWithConfig(ctx, pc)
+        if err != nil {
+            return nil, err
+        }
+        // OpenDBFromPool keeps database/sql from holding idle connections;
+        // idling is managed by pgxpool.
+        db = &DB{DB: stdlib.OpenDBFromPool(pool), pool: pool}
+    default:
+        return nil, fmt.Errorf("unknown database driver %q", opts.Driver)
     }
-    if err := db.Ping(); err != nil {
+    db.SetMaxOpenConns(opts.MaxOpenConns)
+    db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+    db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+    if err := db.PingContext(ctx); err != nil {
+        db.Close()
         return nil, err
     }
     return db, nil
 }
 
+func applyRuntimeParams(params map[string]string, opts Options) {
+    if opts.StatementTimeout > 0 {
+        params["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+    }
+    if opts.ApplicationName != "" {
+        params["application_name"] = opts.ApplicationName
+    }
+}
+
+func (db *DB) Close() error {
+    err := db.DB.Close()
+    if db.pool != nil {
+        db.pool.Close()
+    }
+    return err
+}
+
+// Stats reports pool usage. The pool is saturated when every allowed
+// connection is checked out, so new queries have to wait.
+func (db *DB) Stats() PoolStats {
+    if db.pool != nil {
+        s := db.pool.Stat()
+        return PoolStats{
+            Driver:       DriverPgxpool,
+            MaxOpen:      int(s.MaxConns()),
+            Open:         int(s.TotalConns()),
+            InUse:        int(s.AcquiredConns()),
+            Idle:         int(s.IdleConns()),
+            WaitCount:    s.EmptyAcquireCount(),
+            WaitDuration: s.AcquireDuration().String(),
+            Saturated:    s.AcquiredConns() >= s.MaxConns(),
+        }
+    }
+    s := db.DB.Stats()
+    return PoolStats{
+        Driver:       DriverStdlib,
+        MaxOpen:      s.MaxOpenConnections,
+        Open:         s.OpenConnections,
+        InUse:        s.InUse,
+        Idle:         s.Idle,
+        WaitCount:    s.WaitCount,
+        WaitDuration: s.WaitDuration.String(),
+        Saturated:    s.MaxOpenConnections > 0 && s.InUse >= s.MaxOpenConnections,
+    }
+}
@@ -4,17 +4,30 @@ import (
     "context"
     "database/sql"
     "embed"
+    "fmt"
+    "io/fs"
+    "sort"
 )
 
 //go:embed migrations/*.sql
 var migrationsFS embed.FS
 
+// Migrate applies every embedded migration in file name order. Migrations
+// are written to be idempotent, so they all run on every start.
 func Migrate(ctx context.Context, db *sql.DB) error {
-    b, err := migrationsFS.ReadFile("migrations/0001_init.sql")
+    names, err := fs.Glob(migrationsFS, "migrations/*.sql")
     if err != nil {
         return err
     }
-    _, err = db.ExecContext(ctx, string(b))
-    return err
+    sort.Strings(names)
+    for _, name := range names {
+        b, err := migrationsFS.ReadFile(name)
+        if err != nil {
+            return err
+        }
+        if _, err := db.ExecContext(ctx, string(b)); err != nil {
+            return fmt.Errorf("%s: %w", name, err)
+        }
+    }
+    return nil
 }
-
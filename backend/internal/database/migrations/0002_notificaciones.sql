@@ -0,0 +1,35 @@
+-- Datos de contacto del propietario, usados para los recordatorios.
+ALTER TABLE mascotas ADD COLUMN IF NOT EXISTS propietario_nombre TEXT NOT NULL DEFAULT '';
+ALTER TABLE mascotas ADD COLUMN IF NOT EXISTS propietario_email TEXT NOT NULL DEFAULT '';
+ALTER TABLE mascotas ADD COLUMN IF NOT EXISTS propietario_telefono TEXT NOT NULL DEFAULT '';
+
+-- Outbox de notificaciones: se escribe en la misma transacción que el
+-- cuidado y un despachador en segundo plano la procesa.
+CREATE TABLE IF NOT EXISTS notificaciones (
+  id BIGSERIAL PRIMARY KEY,
+  cuidado_id BIGINT NOT NULL REFERENCES cuidados(id) ON DELETE CASCADE,
+  tipo TEXT NOT NULL,
+  enviar_en TIMESTAMPTZ NOT NULL,
+  estado TEXT NOT NULL DEFAULT 'pendiente',
+  intentos INT NOT NULL DEFAULT 0,
+  proximo_intento TIMESTAMPTZ NOT NULL,
+  ultimo_error TEXT NOT NULL DEFAULT '',
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now(),
+  enviado_en TIMESTAMPTZ,
+  CONSTRAINT notificaciones_estado_check CHECK (estado IN ('pendiente','enviada','fallida','omitida'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_notificaciones_pendientes ON notificaciones(proximo_intento) WHERE estado = 'pendiente';
+CREATE INDEX IF NOT EXISTS idx_notificaciones_cuidado_id ON notificaciones(cuidado_id);
+
+-- Bitácora de cada intento de entrega.
+CREATE TABLE IF NOT EXISTS notificacion_entregas (
+  id BIGSERIAL PRIMARY KEY,
+  notificacion_id BIGINT NOT NULL REFERENCES notificaciones(id) ON DELETE CASCADE,
+  intento INT NOT NULL,
+  estado TEXT NOT NULL,
+  detalle TEXT NOT NULL DEFAULT '',
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notificacion_entregas_notificacion_id ON notificacion_entregas(notificacion_id);
@@ -0,0 +1,60 @@
+-- Estado del cuidado; 'Completado' dispara el evento cuidado.completed.
+ALTER TABLE cuidados ADD COLUMN IF NOT EXISTS estado TEXT NOT NULL DEFAULT 'Programado';
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'cuidados_estado_check') THEN
+    ALTER TABLE cuidados ADD CONSTRAINT cuidados_estado_check CHECK (estado IN ('Programado','Completado','Cancelado'));
+  END IF;
+END $$;
+
+-- Bitácora de eventos de dominio, escrita en la misma transacción que el
+-- cambio que la origina.
+CREATE TABLE IF NOT EXISTS eventos (
+  id BIGSERIAL PRIMARY KEY,
+  tipo TEXT NOT NULL,
+  recurso_id BIGINT NOT NULL,
+  payload JSONB NOT NULL,
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Suscripciones de webhooks. eventos admite '*' para recibir todos.
+CREATE TABLE IF NOT EXISTS webhooks (
+  id BIGSERIAL PRIMARY KEY,
+  url TEXT NOT NULL,
+  eventos TEXT[] NOT NULL,
+  secreto TEXT NOT NULL,
+  descripcion TEXT NOT NULL DEFAULT '',
+  activo BOOLEAN NOT NULL DEFAULT TRUE,
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Cola persistente: una entrega por evento y suscripción.
+CREATE TABLE IF NOT EXISTS webhook_entregas (
+  id BIGSERIAL PRIMARY KEY,
+  webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  evento_id BIGINT NOT NULL REFERENCES eventos(id) ON DELETE CASCADE,
+  estado TEXT NOT NULL DEFAULT 'pendiente',
+  intentos INT NOT NULL DEFAULT 0,
+  proximo_intento TIMESTAMPTZ NOT NULL DEFAULT now(),
+  ultimo_status INT,
+  ultimo_error TEXT NOT NULL DEFAULT '',
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now(),
+  entregado_en TIMESTAMPTZ,
+  CONSTRAINT webhook_entregas_estado_check CHECK (estado IN ('pendiente','entregada','fallida'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_entregas_pendientes ON webhook_entregas(proximo_intento) WHERE estado = 'pendiente';
+CREATE INDEX IF NOT EXISTS idx_webhook_entregas_webhook_id ON webhook_entregas(webhook_id, id DESC);
+
+-- Historial de cada intento HTTP.
+CREATE TABLE IF NOT EXISTS webhook_intentos (
+  id BIGSERIAL PRIMARY KEY,
+  entrega_id BIGINT NOT NULL REFERENCES webhook_entregas(id) ON DELETE CASCADE,
+  intento INT NOT NULL,
+  status_code INT,
+  error TEXT NOT NULL DEFAULT '',
+  duracion_ms INT NOT NULL DEFAULT 0,
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_intentos_entrega_id ON webhook_intentos(entrega_id);
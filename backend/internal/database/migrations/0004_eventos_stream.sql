@@ -0,0 +1,9 @@
+-- Mascota a la que pertenece cada evento, para filtrar el stream /eventos.
+-- No lleva clave foránea: los eventos sobreviven a la mascota borrada.
+ALTER TABLE eventos ADD COLUMN IF NOT EXISTS mascota_id BIGINT;
+
+UPDATE eventos SET mascota_id = recurso_id WHERE mascota_id IS NULL AND tipo LIKE 'mascota.%';
+UPDATE eventos SET mascota_id = (payload->>'mascota_id')::BIGINT
+  WHERE mascota_id IS NULL AND tipo LIKE 'cuidado.%' AND payload ? 'mascota_id';
+
+CREATE INDEX IF NOT EXISTS idx_eventos_mascota_id ON eventos(mascota_id, id);
@@ -0,0 +1,3 @@
+-- Versión de cada cuidado para el SEQUENCE de iCalendar: se incrementa en
+-- cada modificación para que los clientes de calendario reemplacen el evento.
+ALTER TABLE cuidados ADD COLUMN IF NOT EXISTS secuencia INT NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+-- Documentos emitidos (historial clínico, certificado de vacunación). Se
+-- guarda una copia de los datos impresos para que el código de verificación
+-- muestre lo que decía el documento aunque la ficha cambie o se borre.
+CREATE TABLE IF NOT EXISTS documentos (
+  id BIGSERIAL PRIMARY KEY,
+  codigo TEXT NOT NULL UNIQUE,
+  tipo TEXT NOT NULL CHECK (tipo IN ('historial','certificado_vacunacion')),
+  mascota_id BIGINT REFERENCES mascotas(id) ON DELETE SET NULL,
+  contenido JSONB NOT NULL,
+  sha256 TEXT NOT NULL,
+  emitido_en TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_documentos_mascota ON documentos(mascota_id);
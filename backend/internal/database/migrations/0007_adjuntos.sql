@@ -0,0 +1,21 @@
+-- Archivos adjuntos (fotos, resultados de laboratorio) de una mascota o de
+-- un cuidado. El contenido vive en el almacenamiento de objetos; aquí solo
+-- sus claves. Al borrar la mascota o el cuidado el adjunto queda huérfano
+-- (ambas referencias en NULL) y el barrido borra sus archivos y la fila.
+CREATE TABLE IF NOT EXISTS adjuntos (
+  id BIGSERIAL PRIMARY KEY,
+  mascota_id BIGINT REFERENCES mascotas(id) ON DELETE SET NULL,
+  cuidado_id BIGINT REFERENCES cuidados(id) ON DELETE SET NULL,
+  nombre TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  tamano BIGINT NOT NULL,
+  sha256 TEXT NOT NULL,
+  clave TEXT NOT NULL,
+  clave_miniatura TEXT NOT NULL DEFAULT '',
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (mascota_id IS NULL OR cuidado_id IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_adjuntos_mascota ON adjuntos(mascota_id);
+CREATE INDEX IF NOT EXISTS idx_adjuntos_cuidado ON adjuntos(cuidado_id);
+CREATE INDEX IF NOT EXISTS idx_adjuntos_huerfanos ON adjuntos(id) WHERE mascota_id IS NULL AND cuidado_id IS NULL;
@@ -0,0 +1,56 @@
+-- Pesajes de cada mascota; el más reciente es la base del cálculo de dosis.
+CREATE TABLE IF NOT EXISTS pesos (
+  id BIGSERIAL PRIMARY KEY,
+  mascota_id BIGINT NOT NULL REFERENCES mascotas(id) ON DELETE CASCADE,
+  peso_kg NUMERIC(6,2) NOT NULL CHECK (peso_kg > 0),
+  fecha TIMESTAMPTZ NOT NULL,
+  nota TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_pesos_mascota_fecha ON pesos(mascota_id, fecha DESC);
+
+-- Catálogo de medicamentos. La dosis va en mg por kg de peso y la
+-- concentración en mg por unidad de la presentación (ml, comprimido...).
+-- interacciones lista principios activos o grupos con los que no conviene
+-- combinarlo.
+CREATE TABLE IF NOT EXISTS medicamentos (
+  id BIGSERIAL PRIMARY KEY,
+  nombre TEXT NOT NULL UNIQUE,
+  principio_activo TEXT NOT NULL,
+  grupo TEXT NOT NULL DEFAULT '',
+  presentacion TEXT NOT NULL DEFAULT '',
+  concentracion_mg NUMERIC(10,3),
+  unidad TEXT NOT NULL DEFAULT '',
+  dosis_min_mg_kg NUMERIC(10,3) NOT NULL CHECK (dosis_min_mg_kg >= 0),
+  dosis_max_mg_kg NUMERIC(10,3) NOT NULL,
+  vias TEXT[] NOT NULL DEFAULT '{}',
+  especies_contraindicadas TEXT[] NOT NULL DEFAULT '{}',
+  interacciones TEXT[] NOT NULL DEFAULT '{}',
+  CHECK (dosis_max_mg_kg >= dosis_min_mg_kg),
+  CHECK (concentracion_mg IS NULL OR concentracion_mg > 0)
+);
+
+-- Prescripciones indicadas en un cuidado. Una prescripción está activa
+-- entre fecha_inicio y fecha_fin (inclusive) salvo que se suspenda; si se
+-- suspendió por una reacción adversa avisa en las siguientes.
+CREATE TABLE IF NOT EXISTS prescripciones (
+  id BIGSERIAL PRIMARY KEY,
+  mascota_id BIGINT NOT NULL REFERENCES mascotas(id) ON DELETE CASCADE,
+  cuidado_id BIGINT NOT NULL REFERENCES cuidados(id) ON DELETE CASCADE,
+  medicamento_id BIGINT NOT NULL REFERENCES medicamentos(id),
+  dosis_mg NUMERIC(10,3) NOT NULL CHECK (dosis_mg > 0),
+  frecuencia_horas INT NOT NULL CHECK (frecuencia_horas > 0),
+  via TEXT NOT NULL,
+  duracion_dias INT NOT NULL CHECK (duracion_dias > 0),
+  fecha_inicio DATE NOT NULL,
+  fecha_fin DATE NOT NULL,
+  indicaciones TEXT NOT NULL DEFAULT '',
+  suspendida_en TIMESTAMPTZ,
+  motivo_suspension TEXT NOT NULL DEFAULT '',
+  reaccion_adversa BOOLEAN NOT NULL DEFAULT false,
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (fecha_fin >= fecha_inicio)
+);
+
+CREATE INDEX IF NOT EXISTS idx_prescripciones_mascota ON prescripciones(mascota_id, fecha_inicio DESC);
+CREATE INDEX IF NOT EXISTS idx_prescripciones_cuidado ON prescripciones(cuidado_id);
@@ -0,0 +1,18 @@
+-- Alertas clínicas de una mascota: alergias, condiciones crónicas y avisos
+-- de comportamiento ("muerde"). alergeno es la sustancia, principio activo
+-- o grupo de medicamentos de una alergia; tipos_cuidado, los tipos de
+-- cuidado que la alergia afecta (p. ej. Vacunacion o Bano).
+CREATE TABLE IF NOT EXISTS alertas_clinicas (
+  id BIGSERIAL PRIMARY KEY,
+  mascota_id BIGINT NOT NULL REFERENCES mascotas(id) ON DELETE CASCADE,
+  tipo TEXT NOT NULL CHECK (tipo IN ('alergia','condicion_cronica','comportamiento')),
+  severidad TEXT NOT NULL CHECK (severidad IN ('leve','moderada','grave')),
+  descripcion TEXT NOT NULL,
+  alergeno TEXT NOT NULL DEFAULT '',
+  tipos_cuidado TEXT[] NOT NULL DEFAULT '{}',
+  activa BOOLEAN NOT NULL DEFAULT true,
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (tipo <> 'alergia' OR alergeno <> '')
+);
+
+CREATE INDEX IF NOT EXISTS idx_alertas_clinicas_mascota ON alertas_clinicas(mascota_id);
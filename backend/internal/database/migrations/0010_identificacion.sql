@@ -0,0 +1,10 @@
+-- Identificación de la mascota: número de microchip ISO 11784 (15 dígitos),
+-- tatuaje y placa de licencia municipal. Vacío significa "sin registrar";
+-- los valores registrados son únicos.
+ALTER TABLE mascotas ADD COLUMN IF NOT EXISTS microchip TEXT NOT NULL DEFAULT '';
+ALTER TABLE mascotas ADD COLUMN IF NOT EXISTS tatuaje TEXT NOT NULL DEFAULT '';
+ALTER TABLE mascotas ADD COLUMN IF NOT EXISTS licencia TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mascotas_microchip ON mascotas(microchip) WHERE microchip <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mascotas_tatuaje ON mascotas(tatuaje) WHERE tatuaje <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS uq_mascotas_licencia ON mascotas(licencia) WHERE licencia <> '';
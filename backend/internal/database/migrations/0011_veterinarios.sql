@@ -0,0 +1,19 @@
+-- Directorio del personal veterinario. horario es la lista de turnos
+-- semanales: [{"dia":"lunes","desde":"09:00","hasta":"13:00"}, ...] en la
+-- zona horaria de la clínica.
+CREATE TABLE IF NOT EXISTS veterinarios (
+  id BIGSERIAL PRIMARY KEY,
+  nombre TEXT NOT NULL,
+  email TEXT NOT NULL DEFAULT '',
+  telefono TEXT NOT NULL DEFAULT '',
+  especialidades TEXT[] NOT NULL DEFAULT '{}',
+  horario JSONB NOT NULL DEFAULT '[]',
+  activo BOOLEAN NOT NULL DEFAULT true,
+  creado_en TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Veterinario asignado a cada cuidado; al borrarlo el cuidado queda sin
+-- asignar.
+ALTER TABLE cuidados ADD COLUMN IF NOT EXISTS veterinario_id BIGINT REFERENCES veterinarios(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_cuidados_veterinario_fecha ON cuidados(veterinario_id, fecha_cuidado) WHERE veterinario_id IS NOT NULL;
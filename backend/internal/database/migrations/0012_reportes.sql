@@ -0,0 +1,9 @@
+-- Conteo de cuidados por hora, tipo y estado para los reportes cuando se
+-- usan vistas materializadas (REPORTS_MATERIALIZED). La refresca un job en
+-- segundo plano; el índice único permite REFRESH ... CONCURRENTLY.
+CREATE MATERIALIZED VIEW IF NOT EXISTS reporte_cuidados_hora AS
+  SELECT date_trunc('hour', fecha_cuidado) AS hora, tipo_cuidado, estado, count(*)::int AS total
+  FROM cuidados
+  GROUP BY 1, 2, 3;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_reporte_cuidados_hora ON reporte_cuidados_hora(hora, tipo_cuidado, estado);
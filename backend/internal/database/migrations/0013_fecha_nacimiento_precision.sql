@@ -0,0 +1,13 @@
+-- Fechas de nacimiento aproximadas: cuando solo se conoce el año o el mes,
+-- fecha_nacimiento guarda su primer día y la precisión lo indica.
+ALTER TABLE mascotas ADD COLUMN IF NOT EXISTS fecha_nacimiento_precision TEXT NOT NULL DEFAULT 'dia';
+
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint WHERE conname = 'mascotas_fecha_nacimiento_precision_check'
+  ) THEN
+    ALTER TABLE mascotas
+      ADD CONSTRAINT mascotas_fecha_nacimiento_precision_check CHECK (fecha_nacimiento_precision IN ('dia','mes','anio'));
+  END IF;
+END$$;
@@ -0,0 +1,9 @@
+-- Tenant (clínica o sede) de cada mascota; vacío en instalaciones de una
+-- sola clínica. Los eventos lo copian para que el stream /eventos pueda
+-- limitarse a un tenant.
+ALTER TABLE mascotas ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE eventos ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+
+UPDATE eventos e SET tenant = m.tenant FROM mascotas m WHERE e.mascota_id = m.id AND e.tenant <> m.tenant;
+
+CREATE INDEX IF NOT EXISTS idx_eventos_tenant ON eventos(tenant, id);
@@ -0,0 +1,33 @@
+-- Cuidados borrados, para que el calendario los publique como CANCELLED
+-- en lugar de dejarlos huérfanos en los calendarios que ya los importaron.
+-- El trigger cubre también los borrados en cascada de una mascota.
+CREATE TABLE IF NOT EXISTS cuidados_borrados (
+  id BIGINT PRIMARY KEY,
+  tipo_cuidado TEXT NOT NULL,
+  descripcion TEXT NOT NULL,
+  fecha_cuidado TIMESTAMPTZ NOT NULL,
+  mascota_id BIGINT NOT NULL,
+  mascota_nombre TEXT NOT NULL DEFAULT '',
+  mascota_especie TEXT NOT NULL DEFAULT '',
+  veterinario_id BIGINT,
+  secuencia INT NOT NULL,
+  borrado_en TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cuidados_borrados_fecha ON cuidados_borrados(fecha_cuidado);
+
+-- La secuencia avanza para que los clientes reemplacen el evento. En el
+-- borrado en cascada la mascota ya no existe y su nombre queda vacío.
+CREATE OR REPLACE FUNCTION cuidados_borrados_insert() RETURNS trigger AS $$
+BEGIN
+  INSERT INTO cuidados_borrados(id, tipo_cuidado, descripcion, fecha_cuidado, mascota_id, mascota_nombre, mascota_especie, veterinario_id, secuencia)
+  SELECT OLD.id, OLD.tipo_cuidado, OLD.descripcion, OLD.fecha_cuidado, OLD.mascota_id,
+         COALESCE(m.nombre, ''), COALESCE(m.especie, ''), OLD.veterinario_id, OLD.secuencia + 1
+  FROM (SELECT 1) AS uno LEFT JOIN mascotas m ON m.id = OLD.mascota_id
+  ON CONFLICT (id) DO NOTHING;
+  RETURN OLD;
+END$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS cuidados_borrados_trigger ON cuidados;
+CREATE TRIGGER cuidados_borrados_trigger AFTER DELETE ON cuidados
+  FOR EACH ROW EXECUTE FUNCTION cuidados_borrados_insert();
@@ -0,0 +1,324 @@
+// Package documents renders the PDF documents the clinic issues: the
+// clinical history of a mascota and its vaccination certificate. Every
+// document carries a verification code and a QR code pointing to the
+// public page where the code can be checked.
+package documents
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+
+    "github.com/go-pdf/fpdf"
+    "github.com/skip2/go-qrcode"
+
+    "mascotas/internal/models"
+)
+
+// Content is what a document says. It is printed and also stored with
+// the documento, so it leaves out the owner's contact details, which the
+// public verification must not disclose.
+type Content struct {
+    Titulo    string    `json:"titulo"`
+    Paciente  Paciente  `json:"paciente"`
+    Edad      string    `json:"edad"`
+    Secciones []Section `json:"secciones"`
+}
+
+type Paciente struct {
+    ID              int64     `json:"id"`
+    Nombre          string    `json:"nombre"`
+    Especie         string    `json:"especie"`
+    Raza            string    `json:"raza"`
+    Sexo            string    `json:"sexo"`
+    FechaNacimiento time.Time `json:"fecha_nacimiento"`
+    // FechaNacimientoPrecision is that of models.Mascota; documents
+    // issued before it was stored have none and are read as exact.
+    FechaNacimientoPrecision string `json:"fecha_nacimiento_precision,omitempty"`
+    Propietario              string `json:"propietario"`
+}
+
+// Section is a table of cuidados under a heading; Vacio is printed
+// instead of an empty table.
+type Section struct {
+    Titulo   string           `json:"titulo"`
+    Vacio    string           `json:"-"`
+    Cuidados []models.Cuidado `json:"cuidados"`
+}
+
+// Issue holds what is printed around the content.
+type Issue struct {
+    Clinica  string
+    Contacto string
+    // Codigo is the verification code as printed; VerifyURL is encoded in
+    // the QR code.
+    Codigo    string
+    VerifyURL string
+    Emitido   time.Time
+    // Contact details of the owner, printed but not stored.
+    PropietarioContacto string
+    Location            *time.Location
+}
+
+// NewPaciente copies the printable fields of m.
+func NewPaciente(m models.Mascota) Paciente {
+    return Paciente{
+        ID:                       m.ID,
+        Nombre:                   m.Nombre,
+        Especie:                  m.Especie,
+        Raza:                     m.Raza,
+        Sexo:                     m.Sexo,
+        FechaNacimiento:          m.FechaNacimiento,
+        FechaNacimientoPrecision: m.FechaNacimientoPrecision,
+        Propietario:              m.PropietarioNombre,
+    }
+}
+
+func (p Paciente) mascota() models.Mascota {
+    return models.Mascota{FechaNacimiento: p.FechaNacimiento, FechaNacimientoPrecision: p.FechaNacimientoPrecision}
+}
+
+// Nacimiento prints the birth date of p with only the parts known:
+// "10/03/2019", "03/2019" or "2019".
+func (p Paciente) Nacimiento() string {
+    switch p.FechaNacimientoPrecision {
+    case models.PrecisionAnio:
+        return p.FechaNacimiento.Format("2006")
+    case models.PrecisionMes:
+        return p.FechaNacimiento.Format("01/2006")
+    }
+    return p.FechaNacimiento.Format("02/01/2006")
+}
+
+// Edad describes the age of p at now: "4 años y 2 meses", "5 meses",
+// "12 días". An age counted from an approximate birth date says so,
+// "aprox. 4 años", and gives no days.
+func Edad(p Paciente, now time.Time) string {
+    if models.MesesCumplidos(p.FechaNacimiento, now) < 0 {
+        return "sin nacer"
+    }
+    e := p.mascota().Edad(now)
+    var s string
+    switch {
+    case e.Anios == 0 && e.Meses == 0:
+        switch p.FechaNacimientoPrecision {
+        case models.PrecisionAnio:
+            return "menos de 1 año"
+        case models.PrecisionMes:
+            return "menos de 1 mes"
+        }
+        y1, m1, d1 := p.FechaNacimiento.Date()
+        y2, m2, d2 := now.Date()
+        days := int(time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+        return plural(days, "día", "días")
+    case e.Anios == 0:
+        s = plural(e.Meses, "mes", "meses")
+    case e.Meses == 0:
+        s = plural(e.Anios, "año", "años")
+    default:
+        s = plural(e.Anios, "año", "años") + " y " + plural(e.Meses, "mes", "meses")
+    }
+    if e.Aproximada {
+        return "aprox. " + s
+    }
+    return s
+}
+
+func plural(n int, one, many string) string {
+    if n == 1 {
+        return "1 " + one
+    }
+    return fmt.Sprintf("%d %s", n, many)
+}
+
+// Page geometry, in millimetres on A4.
+const (
+    margin    = 12.0
+    pageWidth = 210.0
+    lineH     = 5.0
+    qrSize    = 34.0
+    // footerSpace is kept free at the bottom of each page for the footer.
+    footerSpace = 22.0
+)
+
+// columns of the cuidados tables; the widths add up to the text width.
+var columns = []struct {
+    titulo string
+    ancho  float64
+}{
+    {"Fecha", 32}, {"Tipo", 36}, {"Descripción", 88}, {"Estado", 30},
+}
+
+// Render writes c as a PDF. The output depends only on its arguments, so
+// the same document rendered twice has the same bytes.
+func Render(w io.Writer, c Content, is Issue) error {
+    qr, err := qrcode.Encode(is.VerifyURL, qrcode.Medium, 256)
+    if err != nil {
+        return fmt.Errorf("qr: %w", err)
+    }
+    loc := is.Location
+    if loc == nil {
+        loc = time.UTC
+    }
+
+    pdf := fpdf.New("P", "mm", "A4", "")
+    pdf.SetMargins(margin, margin, margin)
+    pdf.SetAutoPageBreak(true, footerSpace)
+    pdf.SetCreationDate(is.Emitido)
+    pdf.SetModificationDate(is.Emitido)
+    pdf.SetCatalogSort(true)
+    pdf.AliasNbPages("")
+    // The core fonts are cp1252; tr maps the UTF-8 text to it.
+    tr := pdf.UnicodeTranslatorFromDescriptor("")
+    pdf.SetTitle(c.Titulo+" - "+c.Paciente.Nombre, true)
+    pdf.SetAuthor(is.Clinica, true)
+    pdf.SetCreator("mascotas", false)
+
+    pdf.SetHeaderFunc(func() {
+        pdf.SetTextColor(30, 90, 120)
+        pdf.SetFont("Helvetica", "B", 16)
+        pdf.CellFormat(0, 8, tr(is.Clinica), "", 1, "L", false, 0, "")
+        pdf.SetTextColor(90, 90, 90)
+        pdf.SetFont("Helvetica", "", 9)
+        pdf.CellFormat(0, 5, tr(is.Contacto), "", 1, "L", false, 0, "")
+        pdf.SetDrawColor(30, 90, 120)
+        pdf.SetLineWidth(0.6)
+        y := pdf.GetY() + 1
+        pdf.Line(margin, y, pageWidth-margin, y)
+        pdf.SetLineWidth(0.2)
+        pdf.SetY(y + 4)
+        pdf.SetTextColor(0, 0, 0)
+    })
+    pdf.SetFooterFunc(func() {
+        pdf.SetY(-16)
+        pdf.SetFont("Helvetica", "", 8)
+        pdf.SetTextColor(90, 90, 90)
+        pdf.CellFormat(0, 4, tr("Código de verificación "+is.Codigo+" · "+is.VerifyURL), "", 1, "L", false, 0, "")
+        pdf.CellFormat(0, 4, tr(fmt.Sprintf("Emitido el %s · Página %d de {nb}",
+            is.Emitido.In(loc).Format("02/01/2006 15:04"), pdf.PageNo())), "", 0, "L", false, 0, "")
+        pdf.SetTextColor(0, 0, 0)
+    })
+    pdf.AddPage()
+
+    // QR code and code at the top right of the first page.
+    top := pdf.GetY()
+    pdf.RegisterImageOptionsReader("qr", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(qr))
+    pdf.ImageOptions("qr", pageWidth-margin-qrSize, top, qrSize, qrSize, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, is.VerifyURL)
+    pdf.SetXY(pageWidth-margin-qrSize, top+qrSize)
+    pdf.SetFont("Courier", "B", 10)
+    pdf.CellFormat(qrSize, 5, is.Codigo, "", 0, "C", false, 0, "")
+
+    pdf.SetXY(margin, top)
+    pdf.SetFont("Helvetica", "B", 14)
+    pdf.CellFormat(0, 8, tr(c.Titulo), "", 1, "L", false, 0, "")
+    pdf.Ln(2)
+    p := c.Paciente
+    for _, kv := range [][2]string{
+        {"Paciente", p.Nombre},
+        {"Especie y raza", strings.TrimSuffix(p.Especie+" · "+p.Raza, " · ")},
+        {"Sexo", p.Sexo},
+        {"Nacimiento", p.Nacimiento()},
+        {"Edad", c.Edad},
+        {"Propietario", p.Propietario},
+        {"Contacto", is.PropietarioContacto},
+    } {
+        if kv[1] == "" {
+            continue
+        }
+        pdf.SetFont("Helvetica", "B", 10)
+        pdf.CellFormat(34, lineH+1, tr(kv[0]), "", 0, "L", false, 0, "")
+        pdf.SetFont("Helvetica", "", 10)
+        // Stop short of the QR code.
+        pdf.CellFormat(pageWidth-2*margin-qrSize-38, lineH+1, tr(kv[1]), "", 1, "L", false, 0, "")
+    }
+    if y := top + qrSize + 8; pdf.GetY() < y {
+        pdf.SetY(y)
+    }
+
+    for _, s := range c.Secciones {
+        pdf.Ln(4)
+        pdf.SetFont("Helvetica", "B", 12)
+        pdf.SetTextColor(30, 90, 120)
+        pdf.CellFormat(0, 7, tr(s.Titulo), "", 1, "L", false, 0, "")
+        pdf.SetTextColor(0, 0, 0)
+        if len(s.Cuidados) == 0 {
+            pdf.SetFont("Helvetica", "I", 10)
+            pdf.CellFormat(0, lineH+1, tr(s.Vacio), "", 1, "L", false, 0, "")
+            continue
+        }
+        table(pdf, tr, s.Cuidados, loc)
+    }
+    return pdf.Output(w)
+}
+
+// table prints cuidados one row each, wrapping the description and
+// repeating the header row after a page break.
+func table(pdf *fpdf.Fpdf, tr func(string) string, list []models.Cuidado, loc *time.Location) {
+    _, pageHeight := pdf.GetPageSize()
+    limit := pageHeight - footerSpace
+    header := func() {
+        pdf.SetFont("Helvetica", "B", 9)
+        pdf.SetFillColor(225, 238, 244)
+        for _, col := range columns {
+            pdf.CellFormat(col.ancho, lineH+2, tr(col.titulo), "1", 0, "L", true, 0, "")
+        }
+        pdf.Ln(-1)
+        pdf.SetFont("Helvetica", "", 9)
+    }
+    header()
+    for _, c := range list {
+        cells := []string{
+            c.FechaCuidado.In(loc).Format("02/01/2006 15:04"),
+            models.NombreCuidado(c.TipoCuidado),
+            c.Descripcion,
+            c.Estado,
+        }
+        lines := make([][]string, len(cells))
+        rows := 1
+        for i, text := range cells {
+            lines[i] = wrap(pdf, tr, text, columns[i].ancho-2)
+            if len(lines[i]) > rows {
+                rows = len(lines[i])
+            }
+        }
+        h := float64(rows)*lineH + 2
+        if pdf.GetY()+h > limit {
+            // The auto break would split the row; start the page here.
+            pdf.AddPage()
+            header()
+        }
+        x, y := pdf.GetXY()
+        for i, col := range columns {
+            pdf.Rect(x, y, col.ancho, h, "D")
+            for j, line := range lines[i] {
+                pdf.SetXY(x+1, y+1+float64(j)*lineH)
+                pdf.CellFormat(col.ancho-2, lineH, line, "", 0, "L", false, 0, "")
+            }
+            x += col.ancho
+        }
+        pdf.SetXY(margin, y+h)
+    }
+}
+
+// wrap splits text into lines no wider than width, breaking at spaces and
+// newlines. fpdf's SplitText measures UTF-8 runes against the cp1252 font
+// metrics, which breaks on characters such as "—", so the lines are
+// measured after translation instead. The lines returned are translated.
+func wrap(pdf *fpdf.Fpdf, tr func(string) string, text string, width float64) []string {
+    var out []string
+    for _, para := range strings.Split(text, "\n") {
+        line := ""
+        for _, word := range strings.Fields(para) {
+            next := strings.TrimPrefix(line+" "+word, " ")
+            if line != "" && pdf.GetStringWidth(tr(next)) > width {
+                out = append(out, tr(line))
+                next = word
+            }
+            line = next
+        }
+        out = append(out, tr(line))
+    }
+    return out
+}
@@ -0,0 +1,88 @@
+package documents
+
+import (
+    "bytes"
+    "fmt"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+func TestEdad(t *testing.T) {
+    born := time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)
+    for _, tc := range []struct {
+        now  time.Time
+        want string
+    }{
+        {time.Date(2030, 6, 5, 10, 0, 0, 0, time.UTC), "11 años y 2 meses"},
+        {time.Date(2020, 3, 10, 0, 0, 0, 0, time.UTC), "1 año"},
+        {time.Date(2020, 3, 9, 0, 0, 0, 0, time.UTC), "11 meses"},
+        {time.Date(2019, 5, 11, 0, 0, 0, 0, time.UTC), "2 meses"},
+        {time.Date(2019, 4, 9, 0, 0, 0, 0, time.UTC), "30 días"},
+        {time.Date(2019, 3, 11, 0, 0, 0, 0, time.UTC), "1 día"},
+        {time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), "sin nacer"},
+    } {
+        if got := Edad(Paciente{FechaNacimiento: born}, tc.now); got != tc.want {
+            t.Errorf("Edad(%s) = %q, want %q", tc.now.Format("2006-01-02"), got, tc.want)
+        }
+    }
+}
+
+func TestEdadAproximada(t *testing.T) {
+    now := time.Date(2030, 6, 5, 10, 0, 0, 0, time.UTC)
+    for _, tc := range []struct {
+        p          Paciente
+        edad, naci string
+    }{
+        {Paciente{FechaNacimiento: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), FechaNacimientoPrecision: models.PrecisionAnio}, "aprox. 11 años", "2019"},
+        {Paciente{FechaNacimiento: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), FechaNacimientoPrecision: models.PrecisionAnio}, "menos de 1 año", "2030"},
+        {Paciente{FechaNacimiento: time.Date(2029, 3, 1, 0, 0, 0, 0, time.UTC), FechaNacimientoPrecision: models.PrecisionMes}, "aprox. 1 año y 3 meses", "03/2029"},
+        {Paciente{FechaNacimiento: time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC), FechaNacimientoPrecision: models.PrecisionMes}, "menos de 1 mes", "06/2030"},
+        {Paciente{FechaNacimiento: time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC), FechaNacimientoPrecision: models.PrecisionDia}, "4 días", "01/06/2030"},
+    } {
+        if got := Edad(tc.p, now); got != tc.edad {
+            t.Errorf("Edad(%s %s) = %q, want %q", tc.p.Nacimiento(), tc.p.FechaNacimientoPrecision, got, tc.edad)
+        }
+        if got := tc.p.Nacimiento(); got != tc.naci {
+            t.Errorf("Nacimiento() = %q, want %q", got, tc.naci)
+        }
+    }
+}
+
+// TestRender renders a history long enough to need several pages and
+// checks that rendering is reproducible.
+func TestRender(t *testing.T) {
+    emitido := time.Date(2030, 6, 5, 10, 0, 0, 0, time.UTC)
+    var list []models.Cuidado
+    for i := 0; i < 80; i++ {
+        list = append(list, models.Cuidado{ID: int64(i + 1), TipoCuidado: "Consulta Veterinaria",
+            Descripcion: fmt.Sprintf("Control número %d: revisión general, peso y vacunas al día", i+1),
+            FechaCuidado: emitido.AddDate(0, 0, -i), Estado: models.CuidadoCompletado})
+    }
+    c := Content{
+        Titulo:    "Historial clínico",
+        Paciente:  Paciente{ID: 1, Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", Sexo: "Macho", FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)},
+        Edad:      "11 años y 2 meses",
+        Secciones: []Section{{Titulo: "Cuidados", Cuidados: list}, {Titulo: "Vacío", Vacio: "Sin registros."}},
+    }
+    is := Issue{Clinica: "Clínica Añañuca", Contacto: "Calle 1", Codigo: "ABCD-EFGH-JK",
+        VerifyURL: "https://api.example.com/verificar/ABCDEFGHJK", Emitido: emitido}
+
+    var a, b bytes.Buffer
+    if err := Render(&a, c, is); err != nil {
+        t.Fatal(err)
+    }
+    if err := Render(&b, c, is); err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.HasPrefix(a.Bytes(), []byte("%PDF-")) {
+        t.Fatalf("output does not start with %%PDF-: %q", a.Bytes()[:16])
+    }
+    if !bytes.Equal(a.Bytes(), b.Bytes()) {
+        t.Fatal("rendering the same document twice gave different bytes")
+    }
+    if n := bytes.Count(a.Bytes(), []byte("/Type /Page\n")); n < 2 {
+        t.Fatalf("%d pages, want the table to continue on a second page", n)
+    }
+}
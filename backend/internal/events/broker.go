@@ -0,0 +1,92 @@
+// Package events fans the event log out to live subscribers (the SSE
+// stream). Every replica LISTENs on Postgres, so a change committed through
+// any replica reaches the clients of all of them.
+package events
+
+import (
+    "sync"
+
+    "mascotas/internal/models"
+)
+
+// bufferSize is how many events a subscriber may fall behind before it is
+// dropped. A dropped client reconnects and resumes with Last-Event-ID.
+const bufferSize = 64
+
+// Broker delivers published events to the subscribers whose filter
+// matches. It is safe for concurrent use.
+type Broker struct {
+    mu   sync.Mutex
+    subs map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+    return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+type Subscription struct {
+    b      *Broker
+    filter models.EventoFilter
+    ch     chan models.Evento
+    once   sync.Once
+}
+
+// Subscribe registers a subscriber. Call Close when done.
+func (b *Broker) Subscribe(f models.EventoFilter) *Subscription {
+    s := &Subscription{b: b, filter: f, ch: make(chan models.Evento, bufferSize)}
+    b.mu.Lock()
+    b.subs[s] = struct{}{}
+    b.mu.Unlock()
+    return s
+}
+
+// Events is closed when the subscription is closed or was dropped for
+// falling behind.
+func (s *Subscription) Events() <-chan models.Evento { return s.ch }
+
+func (s *Subscription) Close() {
+    s.b.mu.Lock()
+    defer s.b.mu.Unlock()
+    s.close()
+}
+
+// close must be called with b.mu held.
+func (s *Subscription) close() {
+    s.once.Do(func() {
+        delete(s.b.subs, s)
+        close(s.ch)
+    })
+}
+
+// Publish never blocks: a subscriber whose buffer is full is dropped.
+func (b *Broker) Publish(e models.Evento) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for s := range b.subs {
+        if !s.filter.Match(e) {
+            continue
+        }
+        select {
+        case s.ch <- e:
+        default:
+            s.close()
+        }
+    }
+}
+
+// Subscribers reports how many clients are connected.
+func (b *Broker) Subscribers() int {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return len(b.subs)
+}
+
+// Close ends every subscription, e.g. on server shutdown so open streams
+// return instead of holding the shutdown up.
+func (b *Broker) Close() {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for s := range b.subs {
+        s.close()
+    }
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+    "testing"
+
+    "mascotas/internal/models"
+)
+
+func ev(id int64, tipo string, mascota int64) models.Evento {
+    return models.Evento{ID: id, Tipo: tipo, RecursoID: id, MascotaID: mascota}
+}
+
+func TestFilterMatch(t *testing.T) {
+    e := ev(1, models.EventCuidadoCompleted, 7)
+    cases := []struct {
+        name string
+        f    models.EventoFilter
+        want bool
+    }{
+        {"empty", models.EventoFilter{}, true},
+        {"same mascota", models.EventoFilter{MascotaID: 7}, true},
+        {"other mascota", models.EventoFilter{MascotaID: 8}, false},
+        {"exact tipo", models.EventoFilter{Tipos: []string{"cuidado.completed"}}, true},
+        {"resource", models.EventoFilter{Tipos: []string{"cuidado"}}, true},
+        {"other tipo", models.EventoFilter{Tipos: []string{"mascota", "cuidado.created"}}, false},
+        {"both", models.EventoFilter{MascotaID: 7, Tipos: []string{"cuidado"}}, true},
+    }
+    for _, tc := range cases {
+        if got := tc.f.Match(e); got != tc.want {
+            t.Errorf("%s: Match = %v, want %v", tc.name, got, tc.want)
+        }
+    }
+}
+
+func TestBrokerFansOutByFilter(t *testing.T) {
+    b := NewBroker()
+    all := b.Subscribe(models.EventoFilter{})
+    defer all.Close()
+    one := b.Subscribe(models.EventoFilter{MascotaID: 1})
+    defer one.Close()
+
+    b.Publish(ev(1, models.EventMascotaCreated, 1))
+    b.Publish(ev(2, models.EventMascotaCreated, 2))
+
+    if got := drain(all); len(got) != 2 {
+        t.Errorf("unfiltered subscriber got %v, want 2 events", got)
+    }
+    if got := drain(one); len(got) != 1 || got[0] != 1 {
+        t.Errorf("mascota 1 subscriber got %v, want [1]", got)
+    }
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+    b := NewBroker()
+    slow := b.Subscribe(models.EventoFilter{})
+    for i := int64(1); i <= bufferSize+1; i++ {
+        b.Publish(ev(i, models.EventMascotaUpdated, 1))
+    }
+    if b.Subscribers() != 0 {
+        t.Fatalf("slow subscriber still registered")
+    }
+    n := 0
+    for range slow.Events() {
+        n++
+    }
+    if n != bufferSize {
+        t.Errorf("buffered %d events before the drop, want %d", n, bufferSize)
+    }
+    slow.Close() // closing again is harmless
+}
+
+func TestBrokerClose(t *testing.T) {
+    b := NewBroker()
+    s := b.Subscribe(models.EventoFilter{})
+    b.Close()
+    if _, ok := <-s.Events(); ok {
+        t.Fatal("subscription still open after Close")
+    }
+}
+
+func drain(s *Subscription) []int64 {
+    var ids []int64
+    for {
+        select {
+        case e := <-s.Events():
+            ids = append(ids, e.ID)
+        default:
+            return ids
+        }
+    }
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "log"
+    "strconv"
+    "time"
+
+    "github.com/jackc/pgx/v5"
+    "github.com/jackc/pgx/v5/stdlib"
+
+    "mascotas/internal/models"
+)
+
+// Listener LISTENs on models.EventosChannel and publishes each announced
+// event to Broker. NOTIFY only carries the event id; the event itself is
+// read from the log, which also lets the listener catch up on whatever was
+// committed while its connection was down.
+type Listener struct {
+    DB     *sql.DB
+    Store  models.EventoRepository
+    Broker *Broker
+    // RetryDelay is the pause before reconnecting after an error.
+    RetryDelay time.Duration
+}
+
+// Run listens until ctx is cancelled, reconnecting on errors.
+func (l *Listener) Run(ctx context.Context) {
+    var last int64
+    if err := l.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM eventos`).Scan(&last); err != nil && ctx.Err() == nil {
+        log.Printf("events: read last event id: %v", err)
+    }
+    for ctx.Err() == nil {
+        err := l.listen(ctx, &last)
+        if ctx.Err() != nil {
+            return
+        }
+        log.Printf("events: listener stopped: %v; reconnecting in %s", err, l.RetryDelay)
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(l.RetryDelay):
+        }
+    }
+}
+
+func (l *Listener) listen(ctx context.Context, last *int64) error {
+    conn, err := l.DB.Conn(ctx)
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+    return conn.Raw(func(driverConn any) error {
+        sc, ok := driverConn.(*stdlib.Conn)
+        if !ok {
+            return errors.New("events: LISTEN requires the pgx driver")
+        }
+        pc := sc.Conn()
+        if _, err := pc.Exec(ctx, "LISTEN "+pgx.Identifier{models.EventosChannel}.Sanitize()); err != nil {
+            return err
+        }
+        // Anything committed before LISTEN took effect (or while
+        // reconnecting) is read from the log.
+        if err := l.catchUp(ctx, last); err != nil {
+            return err
+        }
+        for {
+            n, err := pc.WaitForNotification(ctx)
+            if err != nil {
+                return err
+            }
+            id, err := strconv.ParseInt(n.Payload, 10, 64)
+            if err != nil {
+                continue
+            }
+            e, err := l.Store.Get(ctx, id)
+            if errors.Is(err, models.ErrNotFound) {
+                continue
+            }
+            if err != nil {
+                return err
+            }
+            l.Broker.Publish(*e)
+            if id > *last {
+                *last = id
+            }
+        }
+    })
+}
+
+func (l *Listener) catchUp(ctx context.Context, last *int64) error {
+    for {
+        list, err := l.Store.ListSince(ctx, *last, models.EventoFilter{}, 500)
+        if err != nil {
+            return err
+        }
+        for _, e := range list {
+            l.Broker.Publish(e)
+            *last = e.ID
+        }
+        if len(list) < 500 {
+            return nil
+        }
+    }
+}
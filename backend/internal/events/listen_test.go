@@ -0,0 +1,75 @@
+package events
+
+import (
+    "context"
+    "os"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/testutil/pgtest"
+)
+
+func TestMain(m *testing.M) {
+    os.Exit(pgtest.Main(m))
+}
+
+// TestListenerPublishesCommittedEvents commits changes through the stores
+// and expects them on a broker fed only by LISTEN/NOTIFY.
+func TestListenerPublishesCommittedEvents(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    store := models.EventoStore{DB: db.DB}
+    broker := NewBroker()
+    sub := broker.Subscribe(models.EventoFilter{Tipos: []string{"cuidado"}})
+    defer sub.Close()
+    go (&Listener{DB: db.DB, Store: store, Broker: broker, RetryDelay: 100 * time.Millisecond}).Run(ctx)
+
+    // Run starts from the newest event, so wait until it is listening.
+    for {
+        var n int
+        q := `SELECT count(*) FROM pg_stat_activity WHERE datname = current_database() AND query = 'LISTEN "eventos"'`
+        if err := db.QueryRowContext(ctx, q).Scan(&n); err != nil {
+            t.Fatal(err)
+        }
+        if n > 0 {
+            break
+        }
+        time.Sleep(20 * time.Millisecond)
+    }
+
+    mascotas := models.MascotaStore{DB: db.DB}
+    cuidados := models.CuidadoStore{DB: db.DB}
+    m := &models.Mascota{Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", Sexo: "Macho", FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)}
+    if err := mascotas.Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+    c := &models.Cuidado{TipoCuidado: "Bano", Descripcion: "Baño", FechaCuidado: time.Date(2030, 6, 10, 9, 0, 0, 0, time.UTC), MascotaID: m.ID}
+    if err := cuidados.Create(ctx, c); err != nil {
+        t.Fatal(err)
+    }
+    if err := cuidados.Delete(ctx, c.ID); err != nil {
+        t.Fatal(err)
+    }
+
+    for _, want := range []string{models.EventCuidadoCreated, models.EventCuidadoDeleted} {
+        select {
+        case e := <-sub.Events():
+            if e.Tipo != want || e.MascotaID != m.ID || e.RecursoID != c.ID {
+                t.Fatalf("event = %+v, want %s for mascota %d", e, want, m.ID)
+            }
+        case <-ctx.Done():
+            t.Fatalf("timed out waiting for %s", want)
+        }
+    }
+
+    all, err := store.ListSince(ctx, 0, models.EventoFilter{MascotaID: m.ID, Tipos: []string{"mascota"}}, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(all) != 1 || all[0].Tipo != models.EventMascotaCreated {
+        t.Fatalf("ListSince = %+v, want only mascota.created", all)
+    }
+}
@@ -0,0 +1,350 @@
+package http
+
+import (
+    "bytes"
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "mime"
+    "net/http"
+    "path"
+    "strconv"
+    "strings"
+    "time"
+    "unicode"
+
+    "mascotas/internal/attachments"
+    "mascotas/internal/models"
+    "mascotas/internal/storage"
+)
+
+// sweepBatch is how many orphaned adjuntos a delete cleans up per query.
+const sweepBatch = 100
+
+// UploadMascotaAdjunto serves POST /mascotas/{id}/adjuntos.
+func (h *Handlers) UploadMascotaAdjunto(w http.ResponseWriter, r *http.Request) {
+    h.uploadAdjunto(w, r, func(ctx context.Context, id int64) (*models.Adjunto, error) {
+        if _, err := h.Mascotas.Get(ctx, id); err != nil {
+            return nil, err
+        }
+        return &models.Adjunto{MascotaID: &id}, nil
+    })
+}
+
+// UploadCuidadoAdjunto serves POST /cuidados/{id}/adjuntos.
+func (h *Handlers) UploadCuidadoAdjunto(w http.ResponseWriter, r *http.Request) {
+    h.uploadAdjunto(w, r, func(ctx context.Context, id int64) (*models.Adjunto, error) {
+        if _, err := h.Cuidados.Get(ctx, id); err != nil {
+            return nil, err
+        }
+        return &models.Adjunto{CuidadoID: &id}, nil
+    })
+}
+
+// ListMascotaAdjuntos serves GET /mascotas/{id}/adjuntos.
+func (h *Handlers) ListMascotaAdjuntos(w http.ResponseWriter, r *http.Request) {
+    h.listAdjuntos(w, r, func(ctx context.Context, id int64) ([]models.Adjunto, error) {
+        if _, err := h.Mascotas.Get(ctx, id); err != nil {
+            return nil, err
+        }
+        return h.Adjuntos.ListByMascota(ctx, id)
+    })
+}
+
+// ListCuidadoAdjuntos serves GET /cuidados/{id}/adjuntos.
+func (h *Handlers) ListCuidadoAdjuntos(w http.ResponseWriter, r *http.Request) {
+    h.listAdjuntos(w, r, func(ctx context.Context, id int64) ([]models.Adjunto, error) {
+        if _, err := h.Cuidados.Get(ctx, id); err != nil {
+            return nil, err
+        }
+        return h.Adjuntos.ListByCuidado(ctx, id)
+    })
+}
+
+func (h *Handlers) adjuntosEnabled(w http.ResponseWriter) bool {
+    if h.Adjuntos == nil || h.Storage == nil {
+        writeError(w, NewNotFound("attachments_disabled", "los adjuntos no están disponibles en este servidor"))
+        return false
+    }
+    return true
+}
+
+// uploadAdjunto stores the uploaded file, and a thumbnail for images,
+// then records it. owner checks that the mascota or cuidado exists and
+// returns the adjunto pointing to it.
+func (h *Handlers) uploadAdjunto(w http.ResponseWriter, r *http.Request, owner func(context.Context, int64) (*models.Adjunto, error)) {
+    if !h.adjuntosEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    data, name, err := readUpload(w, r, h.MaxAttachmentBytes)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    contentType, err := attachments.Sniff(data)
+    if err != nil {
+        writeError(w, AppError{Code: "unsupported_media_type", Status: http.StatusUnsupportedMediaType,
+            Msg: "tipo de archivo no admitido: se aceptan imágenes JPEG, PNG, GIF o WebP y documentos PDF"})
+        return
+    }
+
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    a, err := owner(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    sum := sha256.Sum256(data)
+    a.Nombre = cleanFilename(name)
+    a.ContentType = contentType
+    a.Tamano = int64(len(data))
+    a.SHA256 = hex.EncodeToString(sum[:])
+    if a.Clave, err = newStorageKey(h.now(r)); err != nil {
+        writeError(w, err)
+        return
+    }
+
+    // The storage calls get the request's context: an upload to S3 may
+    // take longer than QueryTimeout.
+    if err := h.Storage.Put(r.Context(), a.Clave, bytes.NewReader(data), a.Tamano, contentType); err != nil {
+        writeError(w, err)
+        return
+    }
+    if strings.HasPrefix(contentType, "image/") {
+        thumb, err := attachments.Thumbnail(data)
+        switch {
+        case err == nil:
+            key := a.Clave + "-miniatura.jpg"
+            if err := h.Storage.Put(r.Context(), key, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+                h.removeFiles(r.Context(), a)
+                writeError(w, err)
+                return
+            }
+            a.ClaveMiniatura = key
+        case !errors.Is(err, attachments.ErrNoThumbnail):
+            log.Printf("adjuntos: thumbnail of %s: %v", a.Clave, err)
+        }
+    }
+    if err := h.Adjuntos.Create(ctx, a); err != nil {
+        h.removeFiles(r.Context(), a)
+        writeError(w, err)
+        return
+    }
+    h.signAdjunto(r, a)
+    respondJSON(w, http.StatusCreated, a)
+}
+
+func (h *Handlers) listAdjuntos(w http.ResponseWriter, r *http.Request, list func(context.Context, int64) ([]models.Adjunto, error)) {
+    if !h.adjuntosEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    out, err := list(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    for i := range out {
+        h.signAdjunto(r, &out[i])
+    }
+    respondJSON(w, http.StatusOK, out)
+}
+
+// GetAdjunto serves GET /adjuntos/{id}: the metadata with fresh links.
+func (h *Handlers) GetAdjunto(w http.ResponseWriter, r *http.Request) {
+    if !h.adjuntosEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "adjunto")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    a, err := h.Adjuntos.Get(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    h.signAdjunto(r, a)
+    respondJSON(w, http.StatusOK, a)
+}
+
+// DeleteAdjunto serves DELETE /adjuntos/{id}. The files go first: if that
+// fails the record stays and the delete can be retried.
+func (h *Handlers) DeleteAdjunto(w http.ResponseWriter, r *http.Request) {
+    if !h.adjuntosEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "adjunto")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    a, err := h.Adjuntos.Get(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    if err := h.removeFiles(r.Context(), a); err != nil {
+        writeError(w, err)
+        return
+    }
+    if err := h.Adjuntos.Delete(ctx, id); err != nil {
+        writeError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// DownloadAdjunto serves GET /adjuntos/{id}/contenido, the link signed by
+// signAdjunto: ?expira=&firma= and optionally variante=miniatura.
+func (h *Handlers) DownloadAdjunto(w http.ResponseWriter, r *http.Request) {
+    if !h.adjuntosEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "adjunto")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    q := r.URL.Query()
+    variant := q.Get("variante")
+    if variant == "" {
+        variant = attachments.VariantOriginal
+    }
+    now := h.now(r)
+    if !h.AttachmentSigner.Verify(id, variant, q.Get("expira"), q.Get("firma"), now) {
+        writeError(w, NewForbidden("invalid_signature", "el enlace no es válido o ha vencido"))
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    a, err := h.Adjuntos.Get(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    key, contentType, size := a.Clave, a.ContentType, a.Tamano
+    if variant == attachments.VariantThumbnail {
+        if a.ClaveMiniatura == "" {
+            writeError(w, NewNotFound("thumbnail_not_found", "el adjunto no tiene miniatura"))
+            return
+        }
+        key, contentType, size = a.ClaveMiniatura, "image/jpeg", -1
+    }
+    rc, err := h.Storage.Open(r.Context(), key)
+    if errors.Is(err, storage.ErrNotFound) {
+        writeError(w, NewNotFound("file_not_found", "el archivo del adjunto no existe"))
+        return
+    }
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    defer rc.Close()
+
+    expires, _ := strconv.ParseInt(q.Get("expira"), 10, 64)
+    w.Header().Set("Content-Type", contentType)
+    if size >= 0 {
+        w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+    }
+    w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": a.Nombre}))
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+    w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", expires-now.Unix()))
+    w.WriteHeader(http.StatusOK)
+    if _, err := io.Copy(w, rc); err != nil {
+        log.Printf("adjuntos: download %s: %v", key, err)
+    }
+}
+
+// signAdjunto fills in the download links of a, valid for
+// AttachmentURLTTL.
+func (h *Handlers) signAdjunto(r *http.Request, a *models.Adjunto) {
+    expires := h.now(r).Add(h.AttachmentURLTTL).Truncate(time.Second)
+    link := func(variant string) string {
+        u := fmt.Sprintf("%s/adjuntos/%d/contenido?expira=%d&firma=%s", strings.TrimRight(h.PublicURL, "/"),
+            a.ID, expires.Unix(), h.AttachmentSigner.Sign(a.ID, variant, expires))
+        if variant != attachments.VariantOriginal {
+            u += "&variante=" + variant
+        }
+        return u
+    }
+    a.URL = link(attachments.VariantOriginal)
+    if a.ClaveMiniatura != "" {
+        a.MiniaturaURL = link(attachments.VariantThumbnail)
+    }
+}
+
+func (h *Handlers) removeFiles(ctx context.Context, a *models.Adjunto) error {
+    for _, key := range []string{a.Clave, a.ClaveMiniatura} {
+        if key == "" {
+            continue
+        }
+        if err := h.Storage.Delete(ctx, key); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// sweepAdjuntos removes the files left orphaned by deleting a mascota or
+// a cuidado. Failures are only logged: the periodic sweeper retries them.
+func (h *Handlers) sweepAdjuntos(r *http.Request) {
+    if h.Adjuntos == nil || h.Storage == nil {
+        return
+    }
+    s := attachments.Sweeper{Repo: h.Adjuntos, Store: h.Storage, BatchSize: sweepBatch}
+    if _, err := s.RunOnce(r.Context()); err != nil {
+        log.Printf("adjuntos: sweep after delete: %v", err)
+    }
+}
+
+// newStorageKey returns a fresh random key under the month of now. The
+// client's file name is never part of it.
+func newStorageKey(now time.Time) (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return "adjuntos/" + now.UTC().Format("2006/01") + "/" + hex.EncodeToString(b), nil
+}
+
+// cleanFilename keeps the base name of an uploaded file without control
+// characters, at most 200 characters long.
+func cleanFilename(name string) string {
+    name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+    name = strings.Map(func(r rune) rune {
+        if unicode.IsControl(r) {
+            return -1
+        }
+        return r
+    }, name)
+    if name = strings.TrimSpace(name); name == "" || name == "." || name == "/" {
+        return "archivo"
+    }
+    if rs := []rune(name); len(rs) > 200 {
+        name = string(rs[:200])
+    }
+    return name
+}
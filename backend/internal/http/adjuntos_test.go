@@ -0,0 +1,255 @@
+package http_test
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "image"
+    "image/png"
+    "io/fs"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "path/filepath"
+    "sort"
+    "strings"
+    "testing"
+
+    "mascotas/internal/clock"
+    apphttp "mascotas/internal/http"
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+    "mascotas/internal/storage"
+)
+
+// adjuntoLog is an in-memory models.AdjuntoRepository. An adjunto is an
+// orphan once its mascota or cuidado no longer exists, as with the
+// ON DELETE SET NULL references in Postgres.
+type adjuntoLog struct {
+    r     repos
+    items map[int64]models.Adjunto
+    next  int64
+}
+
+func (l *adjuntoLog) orphan(a models.Adjunto) bool {
+    ctx := context.Background()
+    if a.MascotaID != nil {
+        _, err := l.r.mascotas.Get(ctx, *a.MascotaID)
+        return err != nil
+    }
+    _, err := l.r.cuidados.Get(ctx, *a.CuidadoID)
+    return err != nil
+}
+
+func (l *adjuntoLog) Create(ctx context.Context, a *models.Adjunto) error {
+    l.next++
+    a.ID = l.next
+    a.CreadoEn = fixedNow
+    l.items[a.ID] = *a
+    return nil
+}
+
+func (l *adjuntoLog) Get(ctx context.Context, id int64) (*models.Adjunto, error) {
+    a, ok := l.items[id]
+    if !ok || l.orphan(a) {
+        return nil, models.ErrNotFound
+    }
+    return &a, nil
+}
+
+func (l *adjuntoLog) list(match func(models.Adjunto) bool) []models.Adjunto {
+    out := make([]models.Adjunto, 0)
+    for _, a := range l.items {
+        if match(a) {
+            out = append(out, a)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+    return out
+}
+
+func (l *adjuntoLog) ListByMascota(ctx context.Context, id int64) ([]models.Adjunto, error) {
+    return l.list(func(a models.Adjunto) bool { return a.MascotaID != nil && *a.MascotaID == id }), nil
+}
+
+func (l *adjuntoLog) ListByCuidado(ctx context.Context, id int64) ([]models.Adjunto, error) {
+    return l.list(func(a models.Adjunto) bool { return a.CuidadoID != nil && *a.CuidadoID == id }), nil
+}
+
+func (l *adjuntoLog) Delete(ctx context.Context, id int64) error {
+    if _, ok := l.items[id]; !ok {
+        return models.ErrNotFound
+    }
+    delete(l.items, id)
+    return nil
+}
+
+func (l *adjuntoLog) ListOrphans(ctx context.Context, limit int) ([]models.Adjunto, error) {
+    out := l.list(l.orphan)
+    if len(out) > limit {
+        out = out[:limit]
+    }
+    return out, nil
+}
+
+func newAttachmentServer(t *testing.T) (http.Handler, repos, storage.Local) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    store := storage.Local{Dir: t.TempDir()}
+    h := apphttp.NewHandlers(r.mascotas, r.cuidados)
+    h.Clock = clock.Fixed(fixedNow)
+    h.Adjuntos = &adjuntoLog{r: r, items: make(map[int64]models.Adjunto)}
+    h.Storage = store
+    cfg := testConfig()
+    cfg.Attachments.MaxSizeMB = 1
+    return apphttp.NewRouter(h, cfg), r, store
+}
+
+func upload(t *testing.T, srv http.Handler, target, filename string, data []byte) *httptest.ResponseRecorder {
+    t.Helper()
+    var body bytes.Buffer
+    mw := multipart.NewWriter(&body)
+    fw, err := mw.CreateFormFile("archivo", filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    fw.Write(data)
+    mw.Close()
+    req := httptest.NewRequest("POST", target, &body)
+    req.Header.Set("Content-Type", mw.FormDataContentType())
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, req)
+    return rec
+}
+
+func get(srv http.Handler, target string) *httptest.ResponseRecorder {
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+    return rec
+}
+
+// localPath turns an absolute signed link into a request target.
+func localPath(t *testing.T, link string) string {
+    t.Helper()
+    u, err := url.Parse(link)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return u.RequestURI()
+}
+
+func TestAdjuntoUploadAndDownload(t *testing.T) {
+    srv, _, _ := newAttachmentServer(t)
+    var img bytes.Buffer
+    png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, 600, 300)))
+
+    rec := upload(t, srv, "/mascotas/1/adjuntos", `C:\fotos\firulais.png`, img.Bytes())
+    if rec.Code != http.StatusCreated {
+        t.Fatalf("upload: status = %d, body %s", rec.Code, rec.Body)
+    }
+    var a models.Adjunto
+    json.Unmarshal(rec.Body.Bytes(), &a)
+    if a.Nombre != "firulais.png" || a.ContentType != "image/png" || a.Tamano != int64(img.Len()) || a.MiniaturaURL == "" {
+        t.Fatalf("adjunto = %+v", a)
+    }
+    if !strings.HasPrefix(a.URL, "http://localhost:8080/adjuntos/1/contenido?expira=") {
+        t.Fatalf("url = %q", a.URL)
+    }
+
+    rec = get(srv, localPath(t, a.URL))
+    if rec.Code != 200 || !bytes.Equal(rec.Body.Bytes(), img.Bytes()) {
+        t.Fatalf("download: status = %d, %d bytes", rec.Code, rec.Body.Len())
+    }
+    if rec.Header().Get("Content-Type") != "image/png" || rec.Header().Get("X-Content-Type-Options") != "nosniff" ||
+        rec.Header().Get("Content-Disposition") != `inline; filename=firulais.png` {
+        t.Fatalf("download headers = %v", rec.Header())
+    }
+    rec = get(srv, localPath(t, a.MiniaturaURL))
+    if rec.Code != 200 || rec.Header().Get("Content-Type") != "image/jpeg" {
+        t.Fatalf("thumbnail: status = %d, headers %v", rec.Code, rec.Header())
+    }
+    if thumb, _, err := image.DecodeConfig(rec.Body); err != nil || thumb.Width != 256 || thumb.Height != 128 {
+        t.Fatalf("thumbnail = %+v, %v", thumb, err)
+    }
+
+    // The signature covers the id and the variant.
+    tampered := strings.Replace(localPath(t, a.URL), "/adjuntos/1/", "/adjuntos/2/", 1)
+    if rec := get(srv, tampered); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "invalid_signature") {
+        t.Fatalf("tampered link: status = %d, body %s", rec.Code, rec.Body)
+    }
+    if rec := get(srv, localPath(t, a.URL)+"&variante=miniatura"); rec.Code != http.StatusForbidden {
+        t.Fatalf("variant swap: status = %d", rec.Code)
+    }
+
+    rec = get(srv, "/mascotas/1/adjuntos")
+    var list []models.Adjunto
+    json.Unmarshal(rec.Body.Bytes(), &list)
+    if rec.Code != 200 || len(list) != 1 || list[0].URL == "" {
+        t.Fatalf("list: status = %d, body %s", rec.Code, rec.Body)
+    }
+}
+
+func TestAdjuntoRejected(t *testing.T) {
+    srv, _, _ := newAttachmentServer(t)
+    for _, tc := range []struct {
+        name, target string
+        data         []byte
+        status       int
+        code         string
+    }{
+        {"html disguised as pdf", "/mascotas/1/adjuntos", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType, "unsupported_media_type"},
+        {"too large", "/mascotas/1/adjuntos", append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("x"), 1<<20)...), http.StatusRequestEntityTooLarge, "file_too_large"},
+        {"unknown mascota", "/mascotas/99/adjuntos", []byte("%PDF-1.7\n"), http.StatusNotFound, "not_found"},
+        {"unknown cuidado", "/cuidados/99/adjuntos", []byte("%PDF-1.7\n"), http.StatusNotFound, "not_found"},
+    } {
+        rec := upload(t, srv, tc.target, "informe.pdf", tc.data)
+        if rec.Code != tc.status || !strings.Contains(rec.Body.String(), tc.code) {
+            t.Errorf("%s: status = %d, body %s", tc.name, rec.Code, rec.Body)
+        }
+    }
+}
+
+// Deleting a mascota removes the files of its adjuntos and of those of
+// its cuidados.
+func TestAdjuntoCleanupOnDelete(t *testing.T) {
+    srv, _, store := newAttachmentServer(t)
+    pdf := []byte("%PDF-1.7\nresultado")
+    for _, target := range []string{"/mascotas/1/adjuntos", "/cuidados/1/adjuntos", "/mascotas/2/adjuntos"} {
+        rec := upload(t, srv, target, "lab.pdf", pdf)
+        if rec.Code != http.StatusCreated {
+            t.Fatalf("upload %s: status = %d, body %s", target, rec.Code, rec.Body)
+        }
+    }
+    if n := len(storedFiles(t, store)); n != 3 {
+        t.Fatalf("%d files stored, want 3", n)
+    }
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("DELETE", "/mascotas/1", nil))
+    if rec.Code != http.StatusNoContent {
+        t.Fatalf("delete: status = %d", rec.Code)
+    }
+    if n := len(storedFiles(t, store)); n != 1 {
+        t.Fatalf("after delete %d files remain, want 1 (Misu's)", n)
+    }
+    if rec := get(srv, "/mascotas/2/adjuntos"); !strings.Contains(rec.Body.String(), `"mascota_id":2`) {
+        t.Fatalf("Misu's adjunto is gone: %s", rec.Body)
+    }
+}
+
+// storedFiles lists the files under the root of a Local store.
+func storedFiles(t *testing.T, store storage.Local) []string {
+    t.Helper()
+    var out []string
+    err := filepath.WalkDir(store.Dir, func(p string, d fs.DirEntry, err error) error {
+        if err == nil && !d.IsDir() {
+            out = append(out, p)
+        }
+        return err
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    return out
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+    "net/http"
+    "sort"
+    "strconv"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// maxClinicAgendaDays bounds the range of GET /agenda.
+const maxClinicAgendaDays = 31
+
+// clinicAgendaItem is a cuidado of the clinic agenda with the time its
+// slot ends.
+type clinicAgendaItem struct {
+    models.AgendaCuidado
+    Fin time.Time `json:"fin"`
+}
+
+type agendaDia struct {
+    Fecha    string             `json:"fecha"`
+    Dia      string             `json:"dia"`
+    Total    int                `json:"total"`
+    Cuidados []clinicAgendaItem `json:"cuidados"`
+}
+
+// listAgenda runs f, dropping the cancelled cuidados unless f asks for an
+// estado.
+func (h *Handlers) listAgenda(r *http.Request, f models.CuidadoFilter) ([]models.AgendaCuidado, error) {
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    list, err := h.Cuidados.ListAgenda(ctx, f)
+    if err != nil || f.Estado != "" {
+        return list, err
+    }
+    out := list[:0]
+    for _, c := range list {
+        if c.Estado != models.CuidadoCancelado {
+            out = append(out, c)
+        }
+    }
+    return out, nil
+}
+
+// Agenda serves GET /agenda: the cuidados of every mascota between the
+// desde and hasta days (YYYY-MM-DD, inclusive; today by default), grouped
+// by day in the clinic's time zone, days without cuidados included.
+// tipo, estado, veterinario and mascota_id filter them.
+func (h *Handlers) Agenda(w http.ResponseWriter, r *http.Request) {
+    f, err := h.cuidadoFilter(r.URL.Query())
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    if f.Desde.IsZero() {
+        f.Desde = dayOf(h.now(r).In(h.Location))
+    }
+    if f.Hasta.IsZero() {
+        f.Hasta = f.Desde.AddDate(0, 0, 1)
+    }
+    if !f.Hasta.After(f.Desde) || f.Hasta.After(f.Desde.AddDate(0, 0, maxClinicAgendaDays)) {
+        writeError(w, NewBadRequest("invalid_range", "hasta debe ser posterior a desde y el rango no puede superar "+strconv.Itoa(maxClinicAgendaDays)+" días"))
+        return
+    }
+    list, err := h.listAgenda(r, f)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    dias := make([]agendaDia, 0)
+    index := make(map[string]int)
+    for d := f.Desde; d.Before(f.Hasta); d = d.AddDate(0, 0, 1) {
+        index[d.Format("2006-01-02")] = len(dias)
+        dias = append(dias, agendaDia{Fecha: d.Format("2006-01-02"), Dia: models.Dias[d.Weekday()], Cuidados: make([]clinicAgendaItem, 0)})
+    }
+    for _, c := range list {
+        i := index[c.FechaCuidado.In(h.Location).Format("2006-01-02")]
+        dias[i].Cuidados = append(dias[i].Cuidados, clinicAgendaItem{c, c.FechaCuidado.Add(models.DuracionCuidado(c.TipoCuidado))})
+        dias[i].Total++
+    }
+    respondJSON(w, http.StatusOK, map[string]any{
+        "desde": f.Desde.Format("2006-01-02"),
+        "hasta": f.Hasta.AddDate(0, 0, -1).Format("2006-01-02"),
+        "total": len(list),
+        "dias":  dias,
+    })
+}
+
+type agendaFranja struct {
+    Inicio  string         `json:"inicio"`
+    Total   int            `json:"total"`
+    PorTipo map[string]int `json:"por_tipo"`
+}
+
+type agendaSemanaDia struct {
+    Fecha   string         `json:"fecha"`
+    Dia     string         `json:"dia"`
+    Total   int            `json:"total"`
+    Franjas []agendaFranja `json:"franjas"`
+}
+
+// AgendaSemana serves GET /agenda/semana: for the week (Monday to Sunday)
+// holding ?fecha= (today by default), how many cuidados start in each
+// slot of ?slot= minutes (30 or 60, the default), per day and tipo. Only
+// slots with cuidados are listed. It takes the filters of Agenda except
+// desde and hasta.
+func (h *Handlers) AgendaSemana(w http.ResponseWriter, r *http.Request) {
+    f, err := h.cuidadoFilter(r.URL.Query())
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    q := r.URL.Query()
+    slot := 60
+    if v := q.Get("slot"); v != "" {
+        if slot, err = strconv.Atoi(v); err != nil || (slot != 30 && slot != 60) {
+            writeError(w, NewBadRequest("invalid_slot", "slot debe ser 30 o 60"))
+            return
+        }
+    }
+    day := dayOf(h.now(r).In(h.Location))
+    if v := q.Get("fecha"); v != "" {
+        if day, err = time.ParseInLocation("2006-01-02", v, h.Location); err != nil {
+            writeError(w, NewBadRequest("invalid_date", "fecha debe ser YYYY-MM-DD"))
+            return
+        }
+    }
+    // Weekday counts from Sunday; the clinic's week starts on Monday.
+    f.Desde = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+    f.Hasta = f.Desde.AddDate(0, 0, 7)
+    list, err := h.listAgenda(r, f)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    dias := make([]agendaSemanaDia, 7)
+    franjas := make([]map[string]*agendaFranja, 7)
+    for i := range dias {
+        d := f.Desde.AddDate(0, 0, i)
+        dias[i] = agendaSemanaDia{Fecha: d.Format("2006-01-02"), Dia: models.Dias[d.Weekday()], Franjas: make([]agendaFranja, 0)}
+        franjas[i] = make(map[string]*agendaFranja)
+    }
+    for _, c := range list {
+        t := c.FechaCuidado.In(h.Location)
+        i := int(dayOf(t).Sub(f.Desde).Hours()+12) / 24
+        start := t.Hour()*60 + t.Minute()
+        start -= start % slot
+        inicio := time.Date(0, 1, 1, start/60, start%60, 0, 0, time.UTC).Format("15:04")
+        fr := franjas[i][inicio]
+        if fr == nil {
+            fr = &agendaFranja{Inicio: inicio, PorTipo: make(map[string]int)}
+            franjas[i][inicio] = fr
+        }
+        fr.Total++
+        fr.PorTipo[c.TipoCuidado]++
+        dias[i].Total++
+    }
+    for i := range dias {
+        for _, fr := range franjas[i] {
+            dias[i].Franjas = append(dias[i].Franjas, *fr)
+        }
+        sort.Slice(dias[i].Franjas, func(a, b int) bool { return dias[i].Franjas[a].Inicio < dias[i].Franjas[b].Inicio })
+    }
+    respondJSON(w, http.StatusOK, map[string]any{
+        "desde":        f.Desde.Format("2006-01-02"),
+        "hasta":        f.Hasta.AddDate(0, 0, -1).Format("2006-01-02"),
+        "slot_minutos": slot,
+        "total":        len(list),
+        "dias":         dias,
+    })
+}
@@ -0,0 +1,73 @@
+package http_test
+
+import (
+    "testing"
+)
+
+// TestAgenda books cuidados for both mascotas and reads them back by day
+// and by week slot.
+func TestAgenda(t *testing.T) {
+    srv := newVetServer(t)
+    send(t, srv, "POST", "/veterinarios", `{"nombre":"Dra. Gómez","horario":[{"dia":"jueves","desde":"09:00","hasta":"13:00"}]}`, 201, nil)
+    for _, b := range []struct{ mascota, body string }{
+        {"1", `{"tipo_cuidado":"Vacunacion","descripcion":"Refuerzo","fecha_cuidado":"2030-06-06T09:00:00Z","veterinario_id":1}`}, // 3
+        {"2", `{"tipo_cuidado":"Vacunacion","descripcion":"Refuerzo","fecha_cuidado":"2030-06-06T09:20:00Z","veterinario_id":1}`}, // 4
+        {"2", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-06T09:40:00Z"}`},                              // 5
+        {"1", `{"tipo_cuidado":"Desparasitacion","descripcion":"Oral","fecha_cuidado":"2030-06-07T16:00:00Z"}`},                   // 6
+    } {
+        send(t, srv, "POST", "/mascotas/"+b.mascota+"/cuidados", b.body, 201, nil)
+    }
+    send(t, srv, "PUT", "/cuidados/6", `{"tipo_cuidado":"Desparasitacion","descripcion":"Oral","fecha_cuidado":"2030-06-07T16:00:00Z","mascota_id":1,"estado":"Cancelado"}`, 200, nil)
+
+    type item struct {
+        ID            int64  `json:"id"`
+        MascotaNombre string `json:"mascota_nombre"`
+    }
+    var agenda struct {
+        Total int `json:"total"`
+        Dias  []struct {
+            Fecha    string `json:"fecha"`
+            Total    int    `json:"total"`
+            Cuidados []item `json:"cuidados"`
+        } `json:"dias"`
+    }
+    send(t, srv, "GET", "/agenda?desde=2030-06-06&hasta=2030-06-07", "", 200, &agenda)
+    if agenda.Total != 3 || len(agenda.Dias) != 2 || agenda.Dias[0].Total != 3 || agenda.Dias[1].Total != 0 {
+        t.Fatalf("agenda = %+v", agenda)
+    }
+    if d := agenda.Dias[0].Cuidados; d[0].ID != 3 || d[0].MascotaNombre != "Firulais" || d[1].MascotaNombre != "Misu" || d[2].ID != 5 {
+        t.Fatalf("day = %+v", d)
+    }
+    send(t, srv, "GET", "/agenda?desde=2030-06-06&hasta=2030-06-07&veterinario=1", "", 200, &agenda)
+    if agenda.Total != 2 {
+        t.Fatalf("by veterinario = %+v", agenda)
+    }
+    send(t, srv, "GET", "/agenda?desde=2030-06-07&hasta=2030-06-07&estado=Cancelado", "", 200, &agenda)
+    if agenda.Total != 1 || agenda.Dias[0].Cuidados[0].ID != 6 {
+        t.Fatalf("cancelled = %+v", agenda)
+    }
+
+    var semana struct {
+        Desde string `json:"desde"`
+        Total int    `json:"total"`
+        Dias  []struct {
+            Dia     string `json:"dia"`
+            Franjas []struct {
+                Inicio  string         `json:"inicio"`
+                Total   int            `json:"total"`
+                PorTipo map[string]int `json:"por_tipo"`
+            } `json:"franjas"`
+        } `json:"dias"`
+    }
+    send(t, srv, "GET", "/agenda/semana?fecha=2030-06-06", "", 200, &semana)
+    if semana.Desde != "2030-06-03" || semana.Total != 3 || semana.Dias[3].Dia != "jueves" || len(semana.Dias[3].Franjas) != 1 {
+        t.Fatalf("semana = %+v", semana)
+    }
+    if fr := semana.Dias[3].Franjas[0]; fr.Inicio != "09:00" || fr.Total != 3 || fr.PorTipo["Vacunacion"] != 2 || fr.PorTipo["Bano"] != 1 {
+        t.Fatalf("franja = %+v", fr)
+    }
+    send(t, srv, "GET", "/agenda/semana?fecha=2030-06-06&slot=30", "", 200, &semana)
+    if fr := semana.Dias[3].Franjas; len(fr) != 2 || fr[0].Total != 2 || fr[1].Inicio != "09:30" {
+        t.Fatalf("30-minute franjas = %+v", fr)
+    }
+}
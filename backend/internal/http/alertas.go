@@ -0,0 +1,207 @@
+package http
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+
+    "mascotas/internal/medication"
+    "mascotas/internal/models"
+)
+
+type alertaInput struct {
+    Tipo         string   `json:"tipo" validate:"required,oneof=alergia condicion_cronica comportamiento"`
+    Severidad    string   `json:"severidad" validate:"required,oneof=leve moderada grave"`
+    Descripcion  string   `json:"descripcion" validate:"required,min=2,max=500"`
+    Alergeno     string   `json:"alergeno" validate:"required_if=Tipo alergia,max=100"`
+    TiposCuidado []string `json:"tipos_cuidado" validate:"dive,oneof=Vacunacion Desparasitacion 'Consulta Veterinaria' Bano"`
+    Activa       *bool    `json:"activa"`
+}
+
+func (h *Handlers) decodeAlerta(w http.ResponseWriter, r *http.Request) (*models.AlertaClinica, error) {
+    var in alertaInput
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        return nil, err
+    }
+    a := &models.AlertaClinica{Tipo: in.Tipo, Severidad: in.Severidad, Descripcion: in.Descripcion, Alergeno: in.Alergeno,
+        TiposCuidado: in.TiposCuidado, Activa: true}
+    if a.TiposCuidado == nil {
+        a.TiposCuidado = []string{}
+    }
+    if in.Activa != nil {
+        a.Activa = *in.Activa
+    }
+    return a, nil
+}
+
+// alertasEnabled writes a 404 when the backend has no clinical alerts.
+func (h *Handlers) alertasEnabled(w http.ResponseWriter) bool {
+    if h.Alertas == nil {
+        writeError(w, NewNotFound("alerts_disabled", "las alertas clínicas no están habilitadas"))
+        return false
+    }
+    return true
+}
+
+// activeAlertas returns the active alerts of a mascota, most severe
+// first; none when alerts are disabled.
+func (h *Handlers) activeAlertas(ctx context.Context, mascotaID int64) ([]models.AlertaClinica, error) {
+    if h.Alertas == nil {
+        return nil, nil
+    }
+    list, err := h.Alertas.ListByMascota(ctx, mascotaID)
+    if err != nil {
+        return nil, err
+    }
+    out := make([]models.AlertaClinica, 0, len(list))
+    for _, a := range list {
+        if a.Activa {
+            out = append(out, a)
+        }
+    }
+    return out, nil
+}
+
+// cuidadoWarnings lists the allergies a cuidado of tipo with descripcion
+// conflicts with.
+func cuidadoWarnings(alertas []models.AlertaClinica, tipo, descripcion string) []models.Advertencia {
+    out := make([]models.Advertencia, 0)
+    for _, a := range alertas {
+        if a.AfectaCuidado(tipo, descripcion) {
+            id := a.ID
+            out = append(out, models.Advertencia{Tipo: medication.WarningAllergy, AlertaID: &id,
+                Mensaje: fmt.Sprintf("Alergia registrada (%s) a %s: %s", a.Severidad, a.Alergeno, a.Descripcion)})
+        }
+    }
+    return out
+}
+
+// allergyConflict rejects a cuidado or prescription with allergy warnings
+// unless the request confirmed them.
+func allergyConflict(warnings []models.Advertencia, confirmed bool) error {
+    if confirmed {
+        return nil
+    }
+    var fields []FieldError
+    for _, w := range warnings {
+        if w.Tipo == medication.WarningAllergy {
+            fields = append(fields, FieldError{Field: "confirmar_alergia", Message: w.Mensaje})
+        }
+    }
+    if len(fields) == 0 {
+        return nil
+    }
+    return AppError{Code: "allergy_conflict", Status: http.StatusConflict, Fields: fields,
+        Msg: "La mascota tiene una alergia registrada que entra en conflicto; revise las alertas y envíe confirmar_alergia=true para continuar."}
+}
+
+// ListAlertas serves GET /mascotas/{id}/alertas, active or not.
+func (h *Handlers) ListAlertas(w http.ResponseWriter, r *http.Request) {
+    if !h.alertasEnabled(w) {
+        return
+    }
+    mascotaID, err := pathID(r, "id", "mascota")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if _, err := h.Mascotas.Get(ctx, mascotaID); err != nil {
+        writeError(w, err)
+        return
+    }
+    list, err := h.Alertas.ListByMascota(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, list)
+}
+
+func (h *Handlers) CreateAlerta(w http.ResponseWriter, r *http.Request) {
+    if !h.alertasEnabled(w) {
+        return
+    }
+    mascotaID, err := pathID(r, "id", "mascota")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    a, err := h.decodeAlerta(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    a.MascotaID = mascotaID
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Alertas.Create(ctx, a); err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusCreated, a)
+}
+
+func (h *Handlers) GetAlerta(w http.ResponseWriter, r *http.Request) {
+    if !h.alertasEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "alerta")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    a, err := h.Alertas.Get(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, a)
+}
+
+// UpdateAlerta serves PUT /alertas/{id}; "activa": false keeps the alert
+// in the history without raising warnings.
+func (h *Handlers) UpdateAlerta(w http.ResponseWriter, r *http.Request) {
+    if !h.alertasEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "alerta")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    a, err := h.decodeAlerta(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    a.ID = id
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Alertas.Update(ctx, a); err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, a)
+}
+
+func (h *Handlers) DeleteAlerta(w http.ResponseWriter, r *http.Request) {
+    if !h.alertasEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "alerta")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Alertas.Delete(ctx, id); err != nil {
+        writeError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+    "cmp"
+    "crypto/subtle"
+    "fmt"
+    "net/http"
+    "slices"
+    "time"
+
+    "mascotas/internal/ical"
+    "mascotas/internal/models"
+)
+
+const (
+    calendarProdID  = "-//Mascotas//Cuidados//ES"
+    calendarRefresh = time.Hour
+    // The clinic feed covers recent history and the coming agenda; older
+    // cuidados stay in the calendars that already imported them.
+    agendaPast   = 90 * 24 * time.Hour
+    agendaFuture = 2 * 365 * 24 * time.Hour
+)
+
+// MascotaCalendar serves /mascotas/{id}/cuidados.ics, the cuidados of one
+// mascota, deleted ones included, as an iCalendar feed owners can
+// subscribe to.
+func (h *Handlers) MascotaCalendar(w http.ResponseWriter, r *http.Request) {
+    mascotaID, err := pathID(r, "id", "mascota")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    m, err := h.Mascotas.Get(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    list, err := h.Cuidados.ListByMascota(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    borrados, err := h.Cuidados.ListBorrados(ctx, models.CuidadoFilter{MascotaID: mascotaID})
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    agenda := make([]models.AgendaCuidado, 0, len(list)+len(borrados))
+    for _, c := range list {
+        agenda = append(agenda, models.AgendaCuidado{Cuidado: c, MascotaNombre: m.Nombre})
+    }
+    h.writeCalendar(w, r, "Cuidados de "+m.Nombre, fmt.Sprintf("mascota-%d.ics", m.ID), append(agenda, borrados...))
+}
+
+// AgendaCalendar serves /agenda.ics?token=, every cuidado of the clinic
+// from agendaPast ago to agendaFuture ahead, deleted ones included.
+// Calendar clients cannot send headers, so the feed is protected by a
+// token in the URL and disabled while no token is configured.
+func (h *Handlers) AgendaCalendar(w http.ResponseWriter, r *http.Request) {
+    if h.CalendarToken == "" {
+        writeError(w, NewNotFound("calendar_disabled", "el calendario de la clínica no está habilitado"))
+        return
+    }
+    token := r.URL.Query().Get("token")
+    if subtle.ConstantTimeCompare([]byte(token), []byte(h.CalendarToken)) != 1 {
+        writeError(w, NewForbidden("invalid_token", "token de calendario inválido"))
+        return
+    }
+    now := h.now(r)
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    f := models.CuidadoFilter{Desde: now.Add(-agendaPast), Hasta: now.Add(agendaFuture)}
+    list, err := h.Cuidados.ListAgenda(ctx, f)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    borrados, err := h.Cuidados.ListBorrados(ctx, f)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    h.writeCalendar(w, r, "Agenda de la clínica", "agenda.ics", append(list, borrados...))
+}
+
+// writeCalendar writes list oldest first; deleted cuidados among them are
+// published as CANCELLED, so calendars that imported them drop them.
+func (h *Handlers) writeCalendar(w http.ResponseWriter, r *http.Request, name, filename string, list []models.AgendaCuidado) {
+    slices.SortFunc(list, func(a, b models.AgendaCuidado) int {
+        if c := a.FechaCuidado.Compare(b.FechaCuidado); c != 0 {
+            return c
+        }
+        return cmp.Compare(a.ID, b.ID)
+    })
+    cal := ical.Calendar{
+        ProdID:  calendarProdID,
+        Name:    name,
+        Refresh: calendarRefresh,
+        Events:  make([]ical.Event, 0, len(list)),
+    }
+    stamp := h.now(r)
+    for _, a := range list {
+        cal.Events = append(cal.Events, h.cuidadoEvent(a.Cuidado, a.MascotaNombre, stamp))
+    }
+    w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+    w.Header().Set("Cache-Control", "private, max-age=300")
+    w.WriteHeader(http.StatusOK)
+    _ = cal.Write(w)
+}
+
+// cuidadoEvent maps a cuidado to its VEVENT. The UID depends only on the
+// cuidado id, so re-exports update the same event; SEQUENCE follows
+// Secuencia and a Cancelado or deleted cuidado is published as CANCELLED.
+func (h *Handlers) cuidadoEvent(c models.Cuidado, mascota string, stamp time.Time) ical.Event {
+    summary := models.NombreCuidado(c.TipoCuidado)
+    if mascota != "" {
+        summary += " — " + mascota
+    }
+    status := ical.StatusConfirmed
+    if c.Estado == models.CuidadoCancelado {
+        status = ical.StatusCancelled
+    }
+    return ical.Event{
+        UID:         fmt.Sprintf("cuidado-%d@%s", c.ID, h.CalendarDomain),
+        Stamp:       stamp,
+        Start:       c.FechaCuidado,
+        Duration:    models.DuracionCuidado(c.TipoCuidado),
+        Summary:     summary,
+        Description: c.Descripcion,
+        Sequence:    c.Secuencia,
+        Status:      status,
+    }
+}
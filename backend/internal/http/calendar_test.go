@@ -0,0 +1,98 @@
+package http_test
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "mascotas/internal/models/memory"
+)
+
+// TestCalendarUpdates checks what calendar clients rely on to replace an
+// event instead of duplicating it: the same UID, a higher SEQUENCE and
+// STATUS:CANCELLED once the cuidado is cancelled.
+func TestCalendarUpdates(t *testing.T) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    srv := newServer(r)
+
+    get := func() string { return getCalendar(t, srv, "/mascotas/1/cuidados.ics") }
+    event := func(cal, uid string) string { return calendarEvent(t, cal, uid) }
+
+    before := event(get(), "cuidado-2@test.example")
+    if !strings.Contains(before, "SEQUENCE:0\r\n") || !strings.Contains(before, "STATUS:CONFIRMED\r\n") {
+        t.Fatalf("new cuidado event:\n%s", before)
+    }
+
+    body := `{"tipo_cuidado":"Bano","descripcion":"Baño medicado","fecha_cuidado":"2030-06-20T14:00:00Z","mascota_id":1,"estado":"Cancelado"}`
+    req := httptest.NewRequest("PUT", "/cuidados/2", strings.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, req)
+    if rec.Code != http.StatusOK {
+        t.Fatalf("cancel: status = %d, body %s", rec.Code, rec.Body)
+    }
+
+    after := event(get(), "cuidado-2@test.example")
+    if !strings.Contains(after, "SEQUENCE:1\r\n") || !strings.Contains(after, "STATUS:CANCELLED\r\n") {
+        t.Fatalf("cancelled cuidado event:\n%s", after)
+    }
+}
+
+// TestCalendarKeepsDeletedCuidados checks that a deleted cuidado, alone or
+// with its mascota, stays in the feeds as CANCELLED with a higher
+// SEQUENCE, so calendars that imported it drop it.
+func TestCalendarKeepsDeletedCuidados(t *testing.T) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    srv := newServer(r)
+    del := func(path string) {
+        t.Helper()
+        rec := httptest.NewRecorder()
+        srv.ServeHTTP(rec, httptest.NewRequest("DELETE", path, nil))
+        if rec.Code != http.StatusNoContent {
+            t.Fatalf("DELETE %s: status = %d, body %s", path, rec.Code, rec.Body)
+        }
+    }
+    agenda := "/agenda.ics?token=" + calendarToken
+
+    del("/cuidados/2")
+    for _, path := range []string{"/mascotas/1/cuidados.ics", agenda} {
+        ev := calendarEvent(t, getCalendar(t, srv, path), "cuidado-2@test.example")
+        if !strings.Contains(ev, "SEQUENCE:1\r\n") || !strings.Contains(ev, "STATUS:CANCELLED\r\n") || !strings.Contains(ev, "Firulais") {
+            t.Errorf("%s: deleted cuidado event:\n%s", path, ev)
+        }
+    }
+
+    del("/mascotas/1")
+    ev := calendarEvent(t, getCalendar(t, srv, agenda), "cuidado-1@test.example")
+    if !strings.Contains(ev, "SEQUENCE:1\r\n") || !strings.Contains(ev, "STATUS:CANCELLED\r\n") {
+        t.Errorf("cuidado of a deleted mascota:\n%s", ev)
+    }
+}
+
+func getCalendar(t *testing.T, srv http.Handler, path string) string {
+    t.Helper()
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("GET %s: status = %d, body %s", path, rec.Code, rec.Body)
+    }
+    if ct := rec.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+        t.Fatalf("Content-Type = %q", ct)
+    }
+    return rec.Body.String()
+}
+
+// calendarEvent is the VEVENT of uid in cal.
+func calendarEvent(t *testing.T, cal, uid string) string {
+    t.Helper()
+    start := strings.Index(cal, "UID:"+uid+"\r\n")
+    if start < 0 {
+        t.Fatalf("no event %s in\n%s", uid, cal)
+    }
+    return cal[start : start+strings.Index(cal[start:], "END:VEVENT")]
+}
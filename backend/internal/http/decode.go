@@ -0,0 +1,133 @@
+package http
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "reflect"
+    "strconv"
+    "strings"
+
+    "github.com/go-playground/validator/v10"
+)
+
+// maxJSONBytes bounds the JSON bodies of the API; files go through the
+// multipart endpoints, with their own limits.
+const maxJSONBytes = 1 << 20
+
+// readJSON decodes the body of r into v, strictly: it must be sent as
+// application/json, hold a single JSON object of at most maxJSONBytes and
+// have no field v does not know. Each way of failing has its own code.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+    mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+        return AppError{Code: "unsupported_media_type", Status: http.StatusUnsupportedMediaType,
+            Msg: "el cuerpo debe ser JSON (Content-Type: application/json)"}
+    }
+    dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBytes))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(v); err != nil {
+        return jsonError(err)
+    }
+    if err := dec.Decode(&struct{}{}); err != io.EOF {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            return jsonError(err)
+        }
+        return NewBadRequest("json_trailing_data", "el cuerpo debe tener un solo objeto JSON")
+    }
+    return nil
+}
+
+// jsonError turns an error of the JSON decoder into an AppError.
+func jsonError(err error) error {
+    var (
+        syntax   *json.SyntaxError
+        mismatch *json.UnmarshalTypeError
+        tooLarge *http.MaxBytesError
+    )
+    switch {
+    case errors.As(err, &tooLarge):
+        return AppError{Code: "body_too_large", Status: http.StatusRequestEntityTooLarge,
+            Msg: fmt.Sprintf("el cuerpo supera el máximo de %d KB", tooLarge.Limit>>10)}
+    case errors.Is(err, io.EOF):
+        return NewBadRequest("empty_body", "el cuerpo está vacío")
+    case errors.As(err, &syntax):
+        return NewBadRequest("json_syntax_error", fmt.Sprintf("el JSON está mal formado en el byte %d", syntax.Offset))
+    case errors.Is(err, io.ErrUnexpectedEOF):
+        return NewBadRequest("json_syntax_error", "el JSON está incompleto")
+    case errors.As(err, &mismatch) && mismatch.Field == "":
+        return NewBadRequest("json_not_object", "el cuerpo debe ser un objeto JSON")
+    case errors.As(err, &mismatch):
+        return AppError{Code: "json_type_mismatch", Status: http.StatusBadRequest, Msg: "tipo de dato incorrecto",
+            Fields: []FieldError{fieldError(jsonFieldPath(mismatch.Field), "type", jsonType(mismatch.Type))}}
+    }
+    // DisallowUnknownFields has no error type of its own.
+    if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+        return AppError{Code: "json_unknown_field", Status: http.StatusBadRequest, Msg: "campo desconocido",
+            Fields: []FieldError{fieldError(strings.Trim(name, `"`), "unknown_field", "")}}
+    }
+    return NewBadRequest("invalid_json", "el cuerpo no es JSON válido")
+}
+
+// jsonFieldPath writes the path of a decoder error, "horario.0.desde",
+// the way the validation errors do: "horario[0].desde". Older Go releases
+// leave the indices out of the path.
+func jsonFieldPath(path string) string {
+    parts := strings.Split(path, ".")
+    var b strings.Builder
+    for i, p := range parts {
+        if _, err := strconv.Atoi(p); err == nil && i > 0 {
+            b.WriteString("[" + p + "]")
+            continue
+        }
+        if i > 0 {
+            b.WriteByte('.')
+        }
+        b.WriteString(p)
+    }
+    return b.String()
+}
+
+// jsonType names the JSON type a Go value is decoded from.
+func jsonType(t reflect.Type) string {
+    for t.Kind() == reflect.Pointer {
+        t = t.Elem()
+    }
+    switch t.Kind() {
+    case reflect.String:
+        return "string"
+    case reflect.Bool:
+        return "boolean"
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return "integer"
+    case reflect.Float32, reflect.Float64:
+        return "number"
+    case reflect.Slice, reflect.Array:
+        return "array"
+    }
+    return "object"
+}
+
+// decodeJSON reads the request body into v with readJSON and validates it.
+func (h *Handlers) decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+    if err := readJSON(w, r, v); err != nil {
+        return err
+    }
+    return h.validateStruct(v)
+}
+
+// validateStruct checks the validation tags of v.
+func (h *Handlers) validateStruct(v any) error {
+    if err := h.validate.Struct(v); err != nil {
+        if verrs, ok := err.(validator.ValidationErrors); ok {
+            return AppError{Code: "validation_error", Status: http.StatusBadRequest, Msg: "datos inválidos", Fields: mapFieldErrors(verrs)}
+        }
+        return NewBadRequest("validation_error", "datos inválidos")
+    }
+    return nil
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestReadJSON(t *testing.T) {
+    type turno struct {
+        Desde string `json:"desde"`
+    }
+    type input struct {
+        Nombre  string  `json:"nombre"`
+        Horario []turno `json:"horario"`
+    }
+    for _, tc := range []struct {
+        name, contentType, body string
+        status                  int
+        code, field             string
+    }{
+        {"ok", "application/json", `{"nombre":"Ana","horario":[{"desde":"09:00"}]}`, 0, "", ""},
+        {"charset and suffix", "application/merge-patch+json; charset=utf-8", `{"nombre":"Ana"}`, 0, "", ""},
+        {"no content type", "", `{"nombre":"Ana"}`, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+        {"text", "text/plain", `{"nombre":"Ana"}`, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+        {"empty", "application/json", ``, http.StatusBadRequest, "empty_body", ""},
+        {"syntax", "application/json", `{"nombre":"Ana",}`, http.StatusBadRequest, "json_syntax_error", ""},
+        {"truncated", "application/json", `{"nombre":`, http.StatusBadRequest, "json_syntax_error", ""},
+        {"type", "application/json", `{"nombre":["Ana"]}`, http.StatusBadRequest, "json_type_mismatch", "nombre"},
+        {"nested type", "application/json", `{"horario":[{"desde":900}]}`, http.StatusBadRequest, "json_type_mismatch", ""},
+        {"unknown", "application/json", `{"nombre":"Ana","edad":3}`, http.StatusBadRequest, "json_unknown_field", "edad"},
+        {"not an object", "application/json", `"Ana"`, http.StatusBadRequest, "json_not_object", ""},
+        {"trailing", "application/json", `{"nombre":"Ana"}[]`, http.StatusBadRequest, "json_trailing_data", ""},
+        {"too large", "application/json", `{"nombre":"` + strings.Repeat("a", maxJSONBytes) + `"}`, http.StatusRequestEntityTooLarge, "body_too_large", ""},
+    } {
+        req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+        if tc.contentType != "" {
+            req.Header.Set("Content-Type", tc.contentType)
+        }
+        var in input
+        err := readJSON(httptest.NewRecorder(), req, &in)
+        if tc.code == "" {
+            if err != nil || in.Nombre != "Ana" {
+                t.Errorf("%s: readJSON = %v, %+v", tc.name, err, in)
+            }
+            continue
+        }
+        var app AppError
+        if !errors.As(err, &app) || app.Status != tc.status || app.Code != tc.code {
+            t.Errorf("%s: readJSON = %#v, want %d %s", tc.name, err, tc.status, tc.code)
+            continue
+        }
+        if tc.field != "" && (len(app.Fields) != 1 || app.Fields[0].Field != tc.field) {
+            t.Errorf("%s: fields = %+v, want %s", tc.name, app.Fields, tc.field)
+        }
+    }
+}
+
+func TestJSONFieldPath(t *testing.T) {
+    for path, want := range map[string]string{
+        "nombre":            "nombre",
+        "horario.0.desde":   "horario[0].desde",
+        "horario.desde":     "horario.desde",
+        "especialidades.2":  "especialidades[2]",
+    } {
+        if got := jsonFieldPath(path); got != want {
+            t.Errorf("jsonFieldPath(%q) = %q, want %q", path, got, want)
+        }
+    }
+}
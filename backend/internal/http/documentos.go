@@ -0,0 +1,190 @@
+package http
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "mascotas/internal/documents"
+    "mascotas/internal/models"
+)
+
+// codeAttempts bounds the retries when a drawn verification code is
+// already taken, which with 2^49 codes only a broken generator would hit.
+const codeAttempts = 3
+
+// HistorialPDF serves /mascotas/{id}/historial.pdf: the mascota and its
+// whole care history, newest first.
+func (h *Handlers) HistorialPDF(w http.ResponseWriter, r *http.Request) {
+    h.issueDocumento(w, r, models.DocumentoHistorial)
+}
+
+// CertificadoVacunacionPDF serves /mascotas/{id}/certificado-vacunacion.pdf:
+// the vaccines applied so far and the ones already scheduled.
+func (h *Handlers) CertificadoVacunacionPDF(w http.ResponseWriter, r *http.Request) {
+    h.issueDocumento(w, r, models.DocumentoCertificadoVacunacion)
+}
+
+// issueDocumento renders a document of the given tipo, records it under a
+// new verification code and sends it. Each request issues a new document
+// with its own code.
+func (h *Handlers) issueDocumento(w http.ResponseWriter, r *http.Request, tipo string) {
+    if h.Documentos == nil {
+        writeError(w, NewNotFound("documents_disabled", "los documentos no están disponibles en este servidor"))
+        return
+    }
+    mascotaID, err := pathID(r, "id", "mascota")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    m, err := h.Mascotas.Get(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    list, err := h.Cuidados.ListByMascota(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    now := h.now(r)
+    content := documents.Content{Paciente: documents.NewPaciente(*m)}
+    content.Edad = documents.Edad(content.Paciente, now.In(h.Location))
+    name := "historial"
+    if tipo == models.DocumentoHistorial {
+        content.Titulo = "Historial clínico"
+        content.Secciones = []documents.Section{{Titulo: "Cuidados", Vacio: "No hay cuidados registrados.", Cuidados: list}}
+    } else {
+        name = "certificado-vacunacion"
+        content.Titulo = "Certificado de vacunación"
+        content.Secciones = vacunas(list, now)
+    }
+    stored, err := json.Marshal(content)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    var contacto []string
+    for _, v := range []string{m.PropietarioEmail, m.PropietarioTelefono} {
+        if v != "" {
+            contacto = append(contacto, v)
+        }
+    }
+
+    var buf bytes.Buffer
+    for attempt := 1; ; attempt++ {
+        codigo, err := models.NewCodigo()
+        if err != nil {
+            writeError(w, err)
+            return
+        }
+        buf.Reset()
+        err = documents.Render(&buf, content, documents.Issue{
+            Clinica:             h.ClinicName,
+            Contacto:            h.ClinicContact,
+            Codigo:              models.FormatCodigo(codigo),
+            VerifyURL:           h.verifyURL(codigo),
+            Emitido:             now,
+            PropietarioContacto: strings.Join(contacto, " · "),
+            Location:            h.Location,
+        })
+        if err != nil {
+            writeError(w, err)
+            return
+        }
+        sum := sha256.Sum256(buf.Bytes())
+        doc := &models.Documento{
+            Codigo:    codigo,
+            Tipo:      tipo,
+            MascotaID: &m.ID,
+            Contenido: stored,
+            SHA256:    hex.EncodeToString(sum[:]),
+            EmitidoEn: now,
+        }
+        err = h.Documentos.Create(ctx, doc)
+        if errors.Is(err, models.ErrDuplicateCode) && attempt < codeAttempts {
+            continue
+        }
+        if err != nil {
+            writeError(w, err)
+            return
+        }
+        break
+    }
+
+    filename := fmt.Sprintf("%s-%d-%s.pdf", name, m.ID, now.In(h.Location).Format("20060102"))
+    w.Header().Set("Content-Type", "application/pdf")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+    w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+    w.Header().Set("Cache-Control", "no-store")
+    w.WriteHeader(http.StatusOK)
+    _, _ = buf.WriteTo(w)
+}
+
+// vacunas splits the vaccinations into those applied (up to now and not
+// cancelled, newest first) and those still scheduled (soonest first).
+func vacunas(list []models.Cuidado, now time.Time) []documents.Section {
+    aplicadas := make([]models.Cuidado, 0)
+    proximas := make([]models.Cuidado, 0)
+    for _, c := range list {
+        if c.TipoCuidado != "Vacunacion" || c.Estado == models.CuidadoCancelado {
+            continue
+        }
+        if c.FechaCuidado.After(now) {
+            proximas = append(proximas, c)
+        } else {
+            aplicadas = append(aplicadas, c)
+        }
+    }
+    // list is newest first.
+    for i, j := 0, len(proximas)-1; i < j; i, j = i+1, j-1 {
+        proximas[i], proximas[j] = proximas[j], proximas[i]
+    }
+    return []documents.Section{
+        {Titulo: "Vacunas aplicadas", Vacio: "No hay vacunas registradas.", Cuidados: aplicadas},
+        {Titulo: "Próximas vacunas", Vacio: "No hay vacunas programadas.", Cuidados: proximas},
+    }
+}
+
+func (h *Handlers) verifyURL(codigo string) string {
+    return strings.TrimRight(h.PublicURL, "/") + "/verificar/" + codigo
+}
+
+// VerificarDocumento serves /verificar/{codigo}, the public check of an
+// issued document. The code is accepted as printed (with dashes, in any
+// case) and the answer shows what the document said when it was issued.
+func (h *Handlers) VerificarDocumento(w http.ResponseWriter, r *http.Request) {
+    if h.Documentos == nil {
+        writeError(w, NewNotFound("documents_disabled", "los documentos no están disponibles en este servidor"))
+        return
+    }
+    codigo := models.NormalizeCodigo(r.PathValue("codigo"))
+    if codigo == "" {
+        writeError(w, NewBadRequest("invalid_code", "código de verificación inválido"))
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    d, err := h.Documentos.Get(ctx, codigo)
+    if errors.Is(err, models.ErrNotFound) {
+        writeError(w, NewNotFound("document_not_found", "no existe un documento con ese código"))
+        return
+    }
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    d.Codigo = models.FormatCodigo(d.Codigo)
+    respondJSON(w, http.StatusOK, d)
+}
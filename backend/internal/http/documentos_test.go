@@ -0,0 +1,117 @@
+package http_test
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "mascotas/internal/clock"
+    apphttp "mascotas/internal/http"
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+)
+
+// documentLog is an in-memory models.DocumentoRepository.
+type documentLog map[string]models.Documento
+
+func (l documentLog) Create(ctx context.Context, d *models.Documento) error {
+    if _, ok := l[d.Codigo]; ok {
+        return models.ErrDuplicateCode
+    }
+    d.ID = int64(len(l) + 1)
+    l[d.Codigo] = *d
+    return nil
+}
+
+func (l documentLog) Get(ctx context.Context, codigo string) (*models.Documento, error) {
+    d, ok := l[codigo]
+    if !ok {
+        return nil, models.ErrNotFound
+    }
+    return &d, nil
+}
+
+// TestCertificadoVacunacion issues a certificate, checks what was recorded
+// for it and verifies it by the code as printed.
+func TestCertificadoVacunacion(t *testing.T) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    docs := documentLog{}
+    h := apphttp.NewHandlers(r.mascotas, r.cuidados)
+    h.Clock = clock.Fixed(fixedNow)
+    h.Documentos = docs
+    cfg := testConfig()
+    cfg.Clinic.PublicURL = "https://vet.example.com/"
+    srv := apphttp.NewRouter(h, cfg)
+
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("GET", "/mascotas/1/certificado-vacunacion.pdf", nil))
+    if rec.Code != 200 || !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-")) {
+        t.Fatalf("status = %d, body %.40q", rec.Code, rec.Body)
+    }
+    if ct := rec.Header().Get("Content-Type"); ct != "application/pdf" {
+        t.Fatalf("Content-Type = %q", ct)
+    }
+    if cd := rec.Header().Get("Content-Disposition"); cd != `inline; filename="certificado-vacunacion-1-20300605.pdf"` {
+        t.Fatalf("Content-Disposition = %q", cd)
+    }
+    if len(docs) != 1 {
+        t.Fatalf("%d documentos recorded, want 1", len(docs))
+    }
+    var doc models.Documento
+    for _, d := range docs {
+        doc = d
+    }
+    if doc.Tipo != models.DocumentoCertificadoVacunacion || doc.MascotaID == nil || *doc.MascotaID != 1 || !doc.EmitidoEn.Equal(fixedNow) {
+        t.Fatalf("documento = %+v", doc)
+    }
+    var content struct {
+        Secciones []struct {
+            Titulo   string           `json:"titulo"`
+            Cuidados []models.Cuidado `json:"cuidados"`
+        } `json:"secciones"`
+    }
+    if err := json.Unmarshal(doc.Contenido, &content); err != nil {
+        t.Fatal(err)
+    }
+    // The seeded vaccination on 2030-05-20 is applied; the bath is left out.
+    if len(content.Secciones) != 2 || len(content.Secciones[0].Cuidados) != 1 || content.Secciones[0].Cuidados[0].ID != 1 ||
+        len(content.Secciones[1].Cuidados) != 0 {
+        t.Fatalf("contenido = %s", doc.Contenido)
+    }
+    if !bytes.Contains(rec.Body.Bytes(), []byte("https://vet.example.com/verificar/"+doc.Codigo)) {
+        t.Fatal("the PDF does not link to its verification URL")
+    }
+
+    printed := strings.ToLower(models.FormatCodigo(doc.Codigo))
+    rec = httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("GET", "/verificar/"+printed, nil))
+    if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"sha256":"`+doc.SHA256+`"`) {
+        t.Fatalf("verify: status = %d, body %s", rec.Code, rec.Body)
+    }
+    if strings.Contains(rec.Body.String(), "propietario_email") {
+        t.Fatalf("verification discloses owner contact details: %s", rec.Body)
+    }
+
+    rec = httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("GET", "/verificar/ZZZZ-ZZZZ-ZZ", nil))
+    if rec.Code != 404 || !strings.Contains(rec.Body.String(), "document_not_found") {
+        t.Fatalf("unknown code: status = %d, body %s", rec.Code, rec.Body)
+    }
+}
+
+func TestHistorialPDFNotFound(t *testing.T) {
+    s := memory.New()
+    h := apphttp.NewHandlers(s.Mascotas(), s.Cuidados())
+    h.Documentos = documentLog{}
+    srv := apphttp.NewRouter(h, testConfig())
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("GET", "/mascotas/99/historial.pdf", nil))
+    if rec.Code != 404 {
+        t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+    }
+}
@@ -1,10 +1,13 @@
 package http
 
 import (
+    "context"
     "database/sql"
     "encoding/json"
     "errors"
     "net/http"
+
+    "mascotas/internal/models"
 )
 
 type AppError struct {
@@ -17,29 +20,46 @@ type AppError struct {
 func (e AppError) Error() string { return e.Msg }
 
 func NewBadRequest(code, msg string) AppError  { return AppError{Code: code, Status: http.StatusBadRequest, Msg: msg} }
+func NewForbidden(code, msg string) AppError   { return AppError{Code: code, Status: http.StatusForbidden, Msg: msg} }
 func NewNotFound(code, msg string) AppError    { return AppError{Code: code, Status: http.StatusNotFound, Msg: msg} }
 func NewConflict(code, msg string) AppError    { return AppError{Code: code, Status: http.StatusConflict, Msg: msg} }
 func NewInternal(code, msg string) AppError    { return AppError{Code: code, Status: http.StatusInternalServerError, Msg: msg} }
 
 func writeError(w http.ResponseWriter, err error) {
     var app AppError
+    var dup *models.DuplicateError
     switch {
     case errors.As(err, &app):
         respondErrorJSON(w, app.Status, app)
-    case errors.Is(err, sql.ErrNoRows):
+    case errors.As(err, &dup):
+        respondErrorJSON(w, http.StatusConflict, AppError{Code: "duplicate_" + dup.Field, Msg: "la identificación ya está registrada en otra mascota",
+            Fields: []FieldError{fieldError(dup.Field, "taken", "")}})
+    case errors.Is(err, models.ErrNotFound), errors.Is(err, sql.ErrNoRows):
         respondErrorJSON(w, http.StatusNotFound, AppError{Code: "not_found", Msg: "recurso no encontrado"})
+    case errors.Is(err, context.DeadlineExceeded):
+        respondErrorJSON(w, http.StatusServiceUnavailable, AppError{Code: "db_timeout", Msg: "la base de datos tardó demasiado en responder"})
     default:
         respondErrorJSON(w, http.StatusInternalServerError, AppError{Code: "internal_error", Msg: err.Error()})
     }
 }
 
+// respondErrorJSON writes e in the language negotiated for the response.
 func respondErrorJSON(w http.ResponseWriter, status int, e AppError) {
+    e = localize(e, responseLang(w))
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(status)
     _ = json.NewEncoder(w).Encode(map[string]any{"error": e})
 }
 
+// FieldError is the problem with one field of the request. Code names it
+// in the message catalog (the validation tag, the business rule...); the
+// Message is translated from it when the client asks for another language.
 type FieldError struct {
     Field   string `json:"field"`
+    Code    string `json:"code,omitempty"`
     Message string `json:"message"`
+    // key is the catalog entry when it is not Code, and param fills its
+    // {param}.
+    key     string
+    param   string
 }
@@ -0,0 +1,174 @@
+package http
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/url"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// replayPage is how many missed events are read per query when a client
+// resumes with Last-Event-ID.
+const replayPage = 500
+
+// StreamEventos is a Server-Sent Events stream of changes to mascotas and
+// cuidados. Filters: ?mascota_id=N and ?tipo=a,b where each tipo is an
+// event type (cuidado.updated) or a resource (cuidado), and ?tenant=name.
+// A client that reconnects with Last-Event-ID (sent automatically by
+// EventSource, or ?last_event_id= on the first connection) first receives
+// what it missed. It requires the staff or admin bearer token, or a tenant
+// token, which only streams the events of its tenant.
+func (h *Handlers) StreamEventos(w http.ResponseWriter, r *http.Request) {
+    if h.Eventos == nil || h.Broker == nil {
+        writeError(w, NewNotFound("events_disabled", "el stream de eventos no está habilitado"))
+        return
+    }
+    tenant, scoped := h.tenantOf(r)
+    if !scoped && !h.isStaff(r) {
+        writeError(w, NewForbidden("staff_required", "el stream de eventos solo está permitido al personal autorizado"))
+        return
+    }
+    filter, err := parseEventoFilter(r.URL.Query())
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    if scoped {
+        if filter.Tenant != "" && filter.Tenant != tenant {
+            writeError(w, NewForbidden("tenant_forbidden", "el token solo permite los eventos de su tenant"))
+            return
+        }
+        filter.Tenant = tenant
+    }
+    last, resume, err := lastEventID(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+
+    // Subscribe before replaying so nothing committed in between is lost.
+    sub := h.Broker.Subscribe(filter)
+    defer sub.Close()
+
+    rc := http.NewResponseController(w)
+    // The stream outlives the server's WriteTimeout.
+    _ = rc.SetWriteDeadline(time.Time{})
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+    w.Header().Set("X-Accel-Buffering", "no")
+    w.WriteHeader(http.StatusOK)
+    fmt.Fprint(w, "retry: 3000\n\n")
+    if rc.Flush() != nil {
+        return
+    }
+
+    if resume {
+        for {
+            ctx, cancel := h.dbContext(r)
+            missed, err := h.Eventos.ListSince(ctx, last, filter, replayPage)
+            cancel()
+            if err != nil {
+                // Headers are gone; end the stream and let the client retry.
+                return
+            }
+            for _, e := range missed {
+                if writeEvento(w, e) != nil {
+                    return
+                }
+                last = e.ID
+            }
+            if rc.Flush() != nil {
+                return
+            }
+            if len(missed) < replayPage {
+                break
+            }
+        }
+    }
+
+    heartbeat := time.NewTicker(h.Heartbeat)
+    defer heartbeat.Stop()
+    for {
+        select {
+        case <-r.Context().Done():
+            return
+        case e, ok := <-sub.Events():
+            if !ok {
+                // Dropped for falling behind or shutting down; the client
+                // reconnects with Last-Event-ID.
+                return
+            }
+            if resume && e.ID <= last {
+                continue // already sent by the replay
+            }
+            if writeEvento(w, e) != nil || rc.Flush() != nil {
+                return
+            }
+        case <-heartbeat.C:
+            fmt.Fprint(w, ": ping\n\n")
+            if rc.Flush() != nil {
+                return
+            }
+        }
+    }
+}
+
+func writeEvento(w http.ResponseWriter, e models.Evento) error {
+    data, err := json.Marshal(e)
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Tipo, data)
+    return err
+}
+
+func parseEventoFilter(q url.Values) (models.EventoFilter, error) {
+    var f models.EventoFilter
+    if v := q.Get("mascota_id"); v != "" {
+        n, err := strconv.ParseInt(v, 10, 64)
+        if err != nil || n <= 0 {
+            return f, NewBadRequest("invalid_mascota_id", "mascota_id debe ser un entero positivo")
+        }
+        f.MascotaID = n
+    }
+    if v := q.Get("tenant"); v != "" {
+        if !models.ValidTenant(v) {
+            return f, NewBadRequest("invalid_tenant", "tenant inválido: "+v)
+        }
+        f.Tenant = v
+    }
+    if v := q.Get("tipo"); v != "" {
+        for _, t := range strings.Split(v, ",") {
+            t = strings.TrimSpace(t)
+            if t != "mascota" && t != "cuidado" && !slices.Contains(models.EventTypes, t) {
+                return f, NewBadRequest("invalid_event", "tipo desconocido: "+t)
+            }
+            f.Tipos = append(f.Tipos, t)
+        }
+    }
+    return f, nil
+}
+
+// lastEventID reads Last-Event-ID (or ?last_event_id=). resume is false
+// when neither is present.
+func lastEventID(r *http.Request) (id int64, resume bool, err error) {
+    v := r.Header.Get("Last-Event-ID")
+    if v == "" {
+        v = r.URL.Query().Get("last_event_id")
+    }
+    if v == "" {
+        return 0, false, nil
+    }
+    id, perr := strconv.ParseInt(v, 10, 64)
+    if perr != nil || id < 0 {
+        return 0, false, NewBadRequest("invalid_last_event_id", "Last-Event-ID debe ser un id de evento")
+    }
+    return id, true, nil
+}
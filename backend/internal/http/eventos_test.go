@@ -0,0 +1,189 @@
+package http_test
+
+import (
+    "bufio"
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "mascotas/internal/events"
+    apphttp "mascotas/internal/http"
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+)
+
+// eventLog is an in-memory models.EventoRepository.
+type eventLog []models.Evento
+
+func (l eventLog) ListSince(ctx context.Context, afterID int64, f models.EventoFilter, limit int) ([]models.Evento, error) {
+    out := make([]models.Evento, 0)
+    for _, e := range l {
+        if e.ID > afterID && f.Match(e) && len(out) < limit {
+            out = append(out, e)
+        }
+    }
+    return out, nil
+}
+
+func (l eventLog) Get(ctx context.Context, id int64) (*models.Evento, error) {
+    for _, e := range l {
+        if e.ID == id {
+            return &e, nil
+        }
+    }
+    return nil, models.ErrNotFound
+}
+
+func evento(id int64, tipo string, mascota int64) models.Evento {
+    return models.Evento{ID: id, Tipo: tipo, RecursoID: mascota, MascotaID: mascota, Data: []byte(`{}`), CreadoEn: fixedNow}
+}
+
+func newStreamServer(t *testing.T, log eventLog) (*httptest.Server, *events.Broker) {
+    s := memory.New()
+    h := apphttp.NewHandlers(s.Mascotas(), s.Cuidados())
+    broker := events.NewBroker()
+    h.Eventos = log
+    h.Broker = broker
+    cfg := testConfig()
+    srv := httptest.NewServer(apphttp.NewRouter(h, cfg))
+    t.Cleanup(func() {
+        broker.Close()
+        srv.Close()
+    })
+    return srv, broker
+}
+
+// readEvents collects the ids of the next n events on the stream.
+func readEvents(t *testing.T, sc *bufio.Scanner, n int) []string {
+    t.Helper()
+    var ids []string
+    for len(ids) < n && sc.Scan() {
+        if id, ok := strings.CutPrefix(sc.Text(), "id: "); ok {
+            ids = append(ids, id)
+        }
+    }
+    if len(ids) < n {
+        t.Fatalf("stream ended after %v: %v", ids, sc.Err())
+    }
+    return ids
+}
+
+func TestEventStreamResumesAndFilters(t *testing.T) {
+    log := eventLog{
+        evento(1, models.EventMascotaCreated, 1),
+        evento(2, models.EventMascotaCreated, 2),
+        evento(3, models.EventCuidadoCreated, 1),
+        evento(4, models.EventCuidadoUpdated, 1),
+    }
+    srv, broker := newStreamServer(t, log)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/eventos?mascota_id=1", nil)
+    req.Header.Set("Last-Event-ID", "1")
+    req.Header.Set("Authorization", "Bearer "+staffToken)
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" {
+        t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+    }
+    sc := bufio.NewScanner(resp.Body)
+
+    if got := readEvents(t, sc, 2); got[0] != "3" || got[1] != "4" {
+        t.Fatalf("replayed %v, want [3 4]", got)
+    }
+
+    // Wait for the handler to subscribe, then publish: an already replayed
+    // event, one for another mascota and a new one.
+    for broker.Subscribers() == 0 {
+        time.Sleep(time.Millisecond)
+    }
+    broker.Publish(log[3])
+    broker.Publish(evento(5, models.EventMascotaUpdated, 2))
+    broker.Publish(evento(6, models.EventCuidadoCompleted, 1))
+    if got := readEvents(t, sc, 1); got[0] != "6" {
+        t.Fatalf("live event %v, want [6]", got)
+    }
+}
+
+func TestEventStreamRejectsBadFilters(t *testing.T) {
+    srv, _ := newStreamServer(t, nil)
+    for _, path := range []string{"/eventos?tipo=mascota.nacida", "/eventos?mascota_id=x", "/eventos?last_event_id=-1"} {
+        if got := streamStatus(t, srv, path, staffToken); got != http.StatusBadRequest {
+            t.Errorf("%s: status %d, want 400", path, got)
+        }
+    }
+}
+
+func TestEventStreamRequiresStaff(t *testing.T) {
+    srv, _ := newStreamServer(t, nil)
+    for _, token := range []string{"", "wrong-token-00000001"} {
+        if got := streamStatus(t, srv, "/eventos", token); got != http.StatusForbidden {
+            t.Errorf("token %q: status %d, want 403", token, got)
+        }
+    }
+}
+
+func TestEventStreamTenantToken(t *testing.T) {
+    norte := evento(2, models.EventMascotaCreated, 2)
+    norte.Tenant = "norte"
+    log := eventLog{evento(1, models.EventMascotaCreated, 1), norte, evento(3, models.EventCuidadoCreated, 1)}
+    srv, broker := newStreamServer(t, log)
+    if got := streamStatus(t, srv, "/eventos?tenant=sur", norteToken); got != http.StatusForbidden {
+        t.Errorf("another tenant: status %d, want 403", got)
+    }
+    if got := streamStatus(t, srv, "/eventos?tenant=Norte!", staffToken); got != http.StatusBadRequest {
+        t.Errorf("invalid tenant: status %d, want 400", got)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/eventos", nil)
+    req.Header.Set("Last-Event-ID", "0")
+    req.Header.Set("Authorization", "Bearer "+norteToken)
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != 200 {
+        t.Fatalf("status %d", resp.StatusCode)
+    }
+    sc := bufio.NewScanner(resp.Body)
+    if got := readEvents(t, sc, 1); got[0] != "2" {
+        t.Fatalf("replayed %v, want [2]", got)
+    }
+
+    for broker.Subscribers() == 0 {
+        time.Sleep(time.Millisecond)
+    }
+    broker.Publish(evento(4, models.EventMascotaUpdated, 1))
+    norte = evento(5, models.EventMascotaUpdated, 2)
+    norte.Tenant = "norte"
+    broker.Publish(norte)
+    if got := readEvents(t, sc, 1); got[0] != "5" {
+        t.Fatalf("live event %v, want [5]", got)
+    }
+}
+
+// streamStatus opens path with token, if any, and returns the status
+// without reading the stream.
+func streamStatus(t *testing.T, srv *httptest.Server, path, token string) int {
+    t.Helper()
+    req, _ := http.NewRequest("GET", srv.URL+path, nil)
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    return resp.StatusCode
+}
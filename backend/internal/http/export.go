@@ -0,0 +1,306 @@
+package http
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "mime"
+    "net/http"
+    "net/url"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/tabular"
+)
+
+const (
+    exportCSV   = "csv"
+    exportXLSX  = "xlsx"
+    exportJSONL = "jsonl"
+
+    // exportTimeout replaces QueryTimeout and, since Stream sets its
+    // statement_timeout from the deadline, DB_STATEMENT_TIMEOUT: an export
+    // reads the whole table.
+    exportTimeout = 5 * time.Minute
+    // exportFlushEvery rows the buffered output is pushed to the client.
+    exportFlushEvery = 500
+)
+
+var exportContentTypes = map[string]string{
+    exportCSV:   "text/csv; charset=utf-8",
+    exportXLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+    exportJSONL: "application/x-ndjson",
+}
+
+// exportAccept maps Accept media types to formats; */* and text/* get CSV.
+var exportAccept = map[string]string{
+    "text/csv":                exportCSV,
+    "application/x-ndjson":    exportJSONL,
+    "application/jsonl":       exportJSONL,
+    "application/jsonlines":   exportJSONL,
+    "application/x-jsonlines": exportJSONL,
+    "text/*":                  exportCSV,
+    "*/*":                     exportCSV,
+    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": exportXLSX,
+}
+
+var (
+    mascotaExportColumns = []string{"id", "nombre", "especie", "raza", "fecha_nacimiento", "sexo"}
+    // mascotaStaffColumns follow mascotaExportColumns for the staff only
+    // (see mascotaView).
+    mascotaStaffColumns  = []string{"propietario_nombre", "propietario_email", "propietario_telefono", "microchip", "tatuaje", "licencia"}
+    cuidadoExportColumns = []string{"id", "mascota_id", "tipo_cuidado", "descripcion", "fecha_cuidado", "estado", "veterinario_id"}
+)
+
+// ExportMascotas streams the mascotas matching the list filters (especie,
+// sexo, nombre; limit and offset are optional here) as CSV, XLSX or JSON
+// Lines, chosen by ?format= or the Accept header. The owner's contact and
+// the identification are only exported for the staff.
+func (h *Handlers) ExportMascotas(w http.ResponseWriter, r *http.Request) {
+    format, err := exportFormat(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    q := r.URL.Query()
+    f, err := h.mascotaFilter(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    if q.Has("limit") || q.Has("offset") {
+        if f.Limit, f.Offset, err = parsePagination(q); err != nil {
+            writeError(w, err)
+            return
+        }
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+    defer cancel()
+    hoy, staff := dayOf(h.now(r).In(h.Location)), h.isStaff(r)
+    columns := mascotaExportColumns
+    if staff {
+        columns = append(slices.Clip(columns), mascotaStaffColumns...)
+    }
+    ex := h.newExport(w, r, format, "mascotas", columns)
+    err = h.Mascotas.Stream(ctx, f, func(m models.Mascota) error {
+        row := []any{m.ID, m.Nombre, m.Especie, m.Raza, models.FormatFechaNacimiento(m.FechaNacimiento, m.FechaNacimientoPrecision), m.Sexo}
+        if staff {
+            row = append(row, m.PropietarioNombre, m.PropietarioEmail, m.PropietarioTelefono, m.Microchip, m.Tatuaje, m.Licencia)
+        }
+        return ex.write(newMascotaView(&m, hoy, staff), row...)
+    })
+    ex.finish(err)
+}
+
+// ExportCuidados streams the care history, oldest first, filtered by
+// mascota_id, veterinario, tipo, estado and the desde/hasta days (YYYY-MM-DD, both
+// inclusive, in the clinic's time zone). veterinario_id is empty for a
+// cuidado nobody is assigned to.
+func (h *Handlers) ExportCuidados(w http.ResponseWriter, r *http.Request) {
+    format, err := exportFormat(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    f, err := h.cuidadoFilter(r.URL.Query())
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+    defer cancel()
+    ex := h.newExport(w, r, format, "cuidados", cuidadoExportColumns)
+    err = h.Cuidados.Stream(ctx, f, func(c models.Cuidado) error {
+        var vet any = ""
+        if c.VeterinarioID != 0 {
+            vet = c.VeterinarioID
+        }
+        return ex.write(c, c.ID, c.MascotaID, c.TipoCuidado, c.Descripcion,
+            c.FechaCuidado.In(h.Location).Format(time.RFC3339), c.Estado, vet)
+    })
+    ex.finish(err)
+}
+
+// mascotaFilter reads the filters shared by GET /mascotas and its export:
+// especie, sexo, nombre and etapa, the life stage today.
+func (h *Handlers) mascotaFilter(r *http.Request) (models.MascotaFilter, error) {
+    q := r.URL.Query()
+    f := models.MascotaFilter{
+        Especie: q.Get("especie"),
+        Sexo:    q.Get("sexo"),
+        Nombre:  strings.TrimSpace(q.Get("nombre")),
+        Etapa:   q.Get("etapa"),
+    }
+    if f.Etapa != "" {
+        if !slices.Contains(models.Etapas, f.Etapa) {
+            return f, NewBadRequest("invalid_etapa", "etapa debe ser cachorro, adulto o senior")
+        }
+        f.Dia = dayOf(h.now(r).In(h.Location))
+    }
+    return f, nil
+}
+
+func (h *Handlers) cuidadoFilter(q url.Values) (models.CuidadoFilter, error) {
+    f := models.CuidadoFilter{Tipo: q.Get("tipo"), Estado: q.Get("estado")}
+    if v := q.Get("mascota_id"); v != "" {
+        id, err := strconv.ParseInt(v, 10, 64)
+        if err != nil || id <= 0 {
+            return f, NewBadRequest("invalid_mascota_id", "mascota_id debe ser un número positivo")
+        }
+        f.MascotaID = id
+    }
+    if v := q.Get("veterinario"); v != "" {
+        id, err := strconv.ParseInt(v, 10, 64)
+        if err != nil || id <= 0 {
+            return f, NewBadRequest("invalid_veterinario_id", "veterinario debe ser un número positivo")
+        }
+        f.VeterinarioID = id
+    }
+    day := func(name string) (time.Time, error) {
+        v := q.Get(name)
+        if v == "" {
+            return time.Time{}, nil
+        }
+        t, err := time.ParseInLocation("2006-01-02", v, h.Location)
+        if err != nil {
+            return time.Time{}, NewBadRequest("invalid_date", name+" debe ser YYYY-MM-DD")
+        }
+        return t, nil
+    }
+    var err error
+    if f.Desde, err = day("desde"); err != nil {
+        return f, err
+    }
+    if f.Hasta, err = day("hasta"); err != nil {
+        return f, err
+    }
+    if !f.Hasta.IsZero() {
+        f.Hasta = f.Hasta.AddDate(0, 0, 1)
+    }
+    return f, nil
+}
+
+// exportFormat picks the format from ?format=, else from Accept in the
+// order listed, ignoring q=0 entries. Without either it is CSV.
+func exportFormat(r *http.Request) (string, error) {
+    if v := r.URL.Query().Get("format"); v != "" {
+        if _, ok := exportContentTypes[v]; !ok {
+            return "", NewBadRequest("invalid_format", "format debe ser csv, xlsx o jsonl")
+        }
+        return v, nil
+    }
+    accept := r.Header.Get("Accept")
+    if strings.TrimSpace(accept) == "" {
+        return exportCSV, nil
+    }
+    for _, part := range strings.Split(accept, ",") {
+        mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+        if err != nil || params["q"] == "0" || params["q"] == "0.0" {
+            continue
+        }
+        if format, ok := exportAccept[mt]; ok {
+            return format, nil
+        }
+    }
+    return "", AppError{Code: "not_acceptable", Status: http.StatusNotAcceptable,
+        Msg: "formatos disponibles: text/csv, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet, application/x-ndjson"}
+}
+
+// export writes records as they come from a Stream callback. The status
+// line is sent with the first record, so a query that fails up front still
+// gets a JSON error.
+type export struct {
+    w        http.ResponseWriter
+    rc       *http.ResponseController
+    format   string
+    filename string
+    columns  []string
+    table    tabular.Writer
+    enc      *json.Encoder
+    rows     int
+    started  bool
+}
+
+func (h *Handlers) newExport(w http.ResponseWriter, r *http.Request, format, name string, columns []string) *export {
+    return &export{
+        w:        w,
+        rc:       http.NewResponseController(w),
+        format:   format,
+        filename: fmt.Sprintf("%s-%s.%s", name, h.now(r).In(h.Location).Format("20060102"), format),
+        columns:  columns,
+    }
+}
+
+func (e *export) start() error {
+    e.started = true
+    // The transfer may outlast the server's WriteTimeout.
+    _ = e.rc.SetWriteDeadline(time.Time{})
+    e.w.Header().Set("Content-Type", exportContentTypes[e.format])
+    e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+    e.w.Header().Add("Vary", "Accept")
+    e.w.WriteHeader(http.StatusOK)
+    var err error
+    switch e.format {
+    case exportJSONL:
+        e.enc = json.NewEncoder(e.w)
+        return nil
+    case exportXLSX:
+        e.table, err = tabular.NewXLSXWriter(e.w, strings.TrimSuffix(e.filename, "."+e.format))
+    default:
+        e.table, err = tabular.NewCSVWriter(e.w)
+    }
+    if err != nil {
+        return err
+    }
+    header := make([]any, len(e.columns))
+    for i, c := range e.columns {
+        header[i] = c
+    }
+    return e.table.WriteRow(header...)
+}
+
+// write emits one record: as JSON for JSON Lines, as row otherwise.
+func (e *export) write(record any, row ...any) error {
+    if !e.started {
+        if err := e.start(); err != nil {
+            return err
+        }
+    }
+    var err error
+    if e.enc != nil {
+        err = e.enc.Encode(record)
+    } else {
+        err = e.table.WriteRow(row...)
+    }
+    if e.rows++; err == nil && e.rows%exportFlushEvery == 0 {
+        // A table writer buffers; Flush only pushes what reached w.
+        err = e.rc.Flush()
+    }
+    return err
+}
+
+// finish completes the file, or reports err. Once data has been sent an
+// error can no longer change the status, so the connection is aborted
+// instead and the client sees a truncated transfer rather than a short
+// file that looks complete.
+func (e *export) finish(err error) {
+    if err == nil && !e.started {
+        err = e.start()
+    }
+    if err == nil && e.table != nil {
+        err = e.table.Close()
+    }
+    if err == nil {
+        return
+    }
+    if !e.started {
+        writeError(e.w, err)
+        return
+    }
+    log.Printf("export %s: %v", e.filename, err)
+    panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,89 @@
+package http_test
+
+import (
+    "context"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+    "mascotas/internal/tabular"
+)
+
+func TestExportXLSX(t *testing.T) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    srv := newServer(r)
+
+    req := httptest.NewRequest("GET", "/mascotas/export?nombre=fir", nil)
+    req.Header.Set("Accept", "text/html;q=0.9, application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+    req.Header.Set("Authorization", "Bearer "+staffToken)
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, req)
+    if rec.Code != 200 {
+        t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+    }
+    if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" {
+        t.Fatalf("Content-Type = %q", ct)
+    }
+    if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="mascotas-20300605.xlsx"` {
+        t.Fatalf("Content-Disposition = %q", cd)
+    }
+    rows, err := tabular.Read(rec.Body.Bytes())
+    if err != nil {
+        t.Fatalf("read xlsx: %v", err)
+    }
+    want := [][]string{
+        {"id", "nombre", "especie", "raza", "fecha_nacimiento", "sexo", "propietario_nombre", "propietario_email", "propietario_telefono", "microchip", "tatuaje", "licencia"},
+        {"1", "Firulais", "Perro", "Criollo", "2019-03-10", "Macho", "", "", "", "985112000123456", "", ""},
+    }
+    if !reflect.DeepEqual(rows, want) {
+        t.Fatalf("rows = %q, want %q", rows, want)
+    }
+}
+
+func TestExportCuidadoVeterinario(t *testing.T) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    c := &models.Cuidado{TipoCuidado: "Consulta Veterinaria", Descripcion: "Control", FechaCuidado: time.Date(2030, 6, 12, 10, 0, 0, 0, time.UTC),
+        MascotaID: 2, VeterinarioID: 7}
+    if err := r.cuidados.Create(context.Background(), c); err != nil {
+        t.Fatal(err)
+    }
+    srv := newServer(r)
+
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("GET", "/cuidados/export?format=xlsx&desde=2030-06-01", nil))
+    rows, err := tabular.Read(rec.Body.Bytes())
+    if err != nil || rec.Code != 200 {
+        t.Fatalf("status = %d, read: %v", rec.Code, err)
+    }
+    want := [][]string{
+        {"id", "mascota_id", "tipo_cuidado", "descripcion", "fecha_cuidado", "estado", "veterinario_id"},
+        {"3", "2", "Consulta Veterinaria", "Control", "2030-06-12T10:00:00Z", "Programado", "7"},
+        {"2", "1", "Bano", "Baño medicado", "2030-06-20T14:00:00Z", "Programado", ""},
+    }
+    if !reflect.DeepEqual(rows, want) {
+        t.Fatalf("rows = %q, want %q", rows, want)
+    }
+}
+
+// An export with no matching rows is still a complete file with its
+// header row.
+func TestExportEmpty(t *testing.T) {
+    s := memory.New()
+    srv := newServer(repos{s.Mascotas(), s.Cuidados()})
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, httptest.NewRequest("GET", "/cuidados/export?format=xlsx", nil))
+    rows, err := tabular.Read(rec.Body.Bytes())
+    if err != nil || rec.Code != 200 {
+        t.Fatalf("status = %d, read: %v", rec.Code, err)
+    }
+    if len(rows) != 1 || rows[0][0] != "id" {
+        t.Fatalf("rows = %q, want only the header", rows)
+    }
+}
@@ -1,35 +1,167 @@
 package http
 
 import (
+    "context"
     "encoding/json"
-    "errors"
     "net/http"
     "net/url"
     "strconv"
     "strings"
     "time"
 
+    "mascotas/internal/attachments"
+    "mascotas/internal/clock"
+    "mascotas/internal/database"
+    "mascotas/internal/events"
     "mascotas/internal/models"
+    "mascotas/internal/rules"
+    "mascotas/internal/storage"
     "github.com/go-playground/validator/v10"
-    "database/sql"
 )
 
 type Handlers struct {
-    DB           *sql.DB
+    Mascotas     models.MascotaRepository
+    Cuidados     models.CuidadoRepository
+    // Notificaciones exposes the reminder outbox; nil when reminders are
+    // disabled.
+    Notificaciones models.NotificacionRepository
+    // Webhooks manages subscriptions; nil when the backend has no event log.
+    Webhooks     models.WebhookRepository
+    // WebhookPrivateTargets accepts subscribers on loopback and private
+    // networks.
+    WebhookPrivateTargets bool
+    // Eventos and Broker back the /eventos stream; nil disables it.
+    Eventos      models.EventoRepository
+    Broker       *events.Broker
+    // Heartbeat is how often an idle event stream sends a keep-alive.
+    Heartbeat    time.Duration
+    // CalendarToken protects /agenda.ics; empty disables the feed.
+    CalendarToken  string
+    // CalendarDomain is the domain part of the iCalendar event UIDs.
+    CalendarDomain string
+    // Documentos records the issued PDFs; nil disables them.
+    Documentos   models.DocumentoRepository
+    // ClinicName and ClinicContact head the PDFs; PublicURL is the base of
+    // their verification links.
+    ClinicName    string
+    ClinicContact string
+    PublicURL     string
+    // Adjuntos and Storage back the attachments; nil disables them.
+    Adjuntos     models.AdjuntoRepository
+    Storage      storage.Store
+    // AttachmentSigner signs the download links, which stay valid for
+    // AttachmentURLTTL.
+    AttachmentSigner   attachments.Signer
+    AttachmentURLTTL   time.Duration
+    MaxAttachmentBytes int64
+    // Medicacion holds the medicamento catalog and the prescriptions, and
+    // Pesos the weighings the doses are computed from; nil disables them.
+    Medicacion   models.MedicacionRepository
+    Pesos        models.PesoRepository
+    // Alertas records allergies, chronic conditions and behavioral
+    // warnings; nil disables them and the allergy checks.
+    Alertas      models.AlertaRepository
+    // Veterinarios is the staff directory cuidados are assigned to; nil
+    // disables it and the assignment.
+    Veterinarios models.VeterinarioRepository
+    // Reportes computes the clinic statistics; nil disables them.
+    Reportes     models.ReporteRepository
+    // AdminToken and StaffToken are the bearer tokens of the restricted
+    // endpoints; empty ones match nothing.
+    AdminToken   string
+    StaffToken   string
+    // TenantTokens maps a tenant to the token that streams only the
+    // events of its mascotas.
+    TenantTokens map[string]string
+    // Pool is checked by Ready; when nil the service always reports ready.
+    Pool         Pool
+    // Location is the clinic's time zone used by the scheduling rules.
+    Location     *time.Location
+    // QueryTimeout bounds the database work of a single request.
+    QueryTimeout time.Duration
+    // Clock drives every date rule; a request may override it with
+    // X-Fake-Now (see appHandler).
+    Clock        clock.Clock
+    // AllowSameDayCare lets cuidados be scheduled today, at least
+    // minLeadTime ahead, instead of from tomorrow on.
+    AllowSameDayCare bool
+    // MascotaRules and CuidadoRules are the business rules checked after
+    // the validation tags, before anything is stored.
+    MascotaRules rules.Set[rules.MascotaCheck]
+    CuidadoRules rules.Set[rules.CuidadoCheck]
     validate     *validator.Validate
 }
 
-func NewHandlers(db *sql.DB) *Handlers {
+// Pool is what readiness needs from the database; *database.DB satisfies it.
+type Pool interface {
+    PingContext(ctx context.Context) error
+    Stats() database.PoolStats
+}
+
+func NewHandlers(mascotas models.MascotaRepository, cuidados models.CuidadoRepository) *Handlers {
+    // crypto/rand does not fail on the supported platforms.
+    key, _ := attachments.NewKey()
     return &Handlers{
-        DB:       db,
-        validate: validator.New(),
+        Mascotas:     mascotas,
+        Cuidados:     cuidados,
+        Location:     time.Local,
+        QueryTimeout: 5 * time.Second,
+        Clock:        clock.System{},
+        Heartbeat:    15 * time.Second,
+        CalendarDomain: "mascotas.local",
+        ClinicName:     "Clínica Veterinaria",
+        PublicURL:      "http://localhost:8080",
+        AttachmentSigner:   attachments.Signer{Key: key},
+        AttachmentURLTTL:   time.Hour,
+        MaxAttachmentBytes: 10 << 20,
+        MascotaRules: rules.Mascotas(),
+        CuidadoRules: rules.Cuidados(),
+        validate:     newValidator(),
     }
 }
 
+// newValidator registers the tags of the API's own formats:
+// fecha_nacimiento takes YYYY-MM-DD, YYYY-MM or YYYY.
+func newValidator() *validator.Validate {
+    v := validator.New()
+    v.RegisterTagNameFunc(jsonFieldName)
+    v.RegisterValidation("fecha_nacimiento", func(fl validator.FieldLevel) bool {
+        _, _, err := models.ParseFechaNacimiento(fl.Field().String())
+        return err == nil
+    })
+    v.RegisterValidation("tenant", func(fl validator.FieldLevel) bool {
+        return models.ValidTenant(fl.Field().String())
+    })
+    return v
+}
+
 func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
     respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+// Ready fails when Postgres is unreachable or every pooled connection is
+// busy, so load balancers stop sending more work.
+func (h *Handlers) Ready(w http.ResponseWriter, r *http.Request) {
+    if h.Pool == nil {
+        respondJSON(w, http.StatusOK, map[string]any{"status": "ready"})
+        return
+    }
+    // A saturated pool has no connection to ping with: the ping would wait
+    // for one and time out, reporting the database as unreachable.
+    stats := h.Pool.Stats()
+    if stats.Saturated {
+        respondJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "pool_saturated", "pool": stats})
+        return
+    }
+    ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+    defer cancel()
+    status, code := "ready", http.StatusOK
+    if err := h.Pool.PingContext(ctx); err != nil {
+        status, code = "db_unreachable", http.StatusServiceUnavailable
+    }
+    respondJSON(w, code, map[string]any{"status": status, "pool": stats})
+}
+
 // Mascotas
 
 func (h *Handlers) ListMascotas(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +170,20 @@ func (h *Handlers) ListMascotas(w http.ResponseWriter, r *http.Request) {
         writeError(w, appErr)
         return
     }
-    list, err := (models.MascotaStore{DB: h.DB}).ListPaged(r.Context(), limit, offset)
+    f, err := h.mascotaFilter(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    f.Limit, f.Offset = limit, offset
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    hoy, staff := dayOf(h.now(r).In(h.Location)), h.isStaff(r)
+    list := make([]mascotaView, 0)
+    err = h.Mascotas.Stream(ctx, f, func(m models.Mascota) error {
+        list = append(list, newMascotaView(&m, hoy, staff))
+        return nil
+    })
     if err != nil {
         writeError(w, err)
         return
@@ -46,113 +191,192 @@ func (h *Handlers) ListMascotas(w http.ResponseWriter, r *http.Request) {
     respondJSON(w, http.StatusOK, list)
 }
 
-func (h *Handlers) CreateMascota(w http.ResponseWriter, r *http.Request) {
-    var in struct {
-        Nombre          string `json:"nombre" validate:"required,min=2,max=100"`
-        Especie         string `json:"especie" validate:"required,oneof=Perro Gato Conejo"`
-        Raza            string `json:"raza" validate:"required,min=2,max=100"`
-        FechaNacimiento string `json:"fecha_nacimiento" validate:"required,datetime=2006-01-02"`
-        Sexo            string `json:"sexo" validate:"required,oneof=Macho Hembra"`
+// mascotaView is a mascota as the API returns it, with its age and life
+// stage on the day it is read.
+type mascotaView struct {
+    *models.Mascota
+    Edad  models.Edad `json:"edad"`
+    Etapa string      `json:"etapa"`
+    // The owner and the identification of the animal, only for the staff.
+    // Being shallower, these hide the fields of Mascota with the same JSON
+    // names, and they are nil, so left out, for anyone else.
+    Propietario         *string `json:"propietario_nombre,omitempty"`
+    PropietarioEmail    *string `json:"propietario_email,omitempty"`
+    PropietarioTelefono *string `json:"propietario_telefono,omitempty"`
+    Microchip           *string `json:"microchip,omitempty"`
+    Tatuaje             *string `json:"tatuaje,omitempty"`
+    Licencia            *string `json:"licencia,omitempty"`
+}
+
+func newMascotaView(m *models.Mascota, hoy time.Time, staff bool) mascotaView {
+    v := mascotaView{Mascota: m, Edad: m.Edad(hoy), Etapa: m.Etapa(hoy)}
+    if staff {
+        v.Propietario, v.PropietarioEmail, v.PropietarioTelefono = &m.PropietarioNombre, &m.PropietarioEmail, &m.PropietarioTelefono
+        v.Microchip, v.Tatuaje, v.Licencia = &m.Microchip, &m.Tatuaje, &m.Licencia
     }
-    if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-        writeError(w, NewBadRequest("invalid_json", "JSON inválido"))
-        return
+    return v
+}
+
+// mascotaView computes the age of m today and shows its private fields
+// to the staff.
+func (h *Handlers) mascotaView(r *http.Request, m *models.Mascota) mascotaView {
+    return newMascotaView(m, dayOf(h.now(r).In(h.Location)), h.isStaff(r))
+}
+
+// mascotaInput is the body of POST /mascotas and PUT /mascotas/{id}, and
+// one row of an import. It is an alias of an unnamed struct so validation
+// errors name the bare field ("nombre").
+type mascotaInput = struct {
+    Nombre          string `json:"nombre" validate:"required,min=2,max=100"`
+    Especie         string `json:"especie" validate:"required,oneof=Perro Gato Conejo"`
+    Raza            string `json:"raza" validate:"required,min=2,max=100"`
+    FechaNacimiento string `json:"fecha_nacimiento" validate:"required,fecha_nacimiento"`
+    Sexo            string `json:"sexo" validate:"required,oneof=Macho Hembra"`
+    PropietarioNombre   string `json:"propietario_nombre" validate:"omitempty,max=100"`
+    PropietarioEmail    string `json:"propietario_email" validate:"omitempty,email,max=254"`
+    PropietarioTelefono string `json:"propietario_telefono" validate:"omitempty,max=30"`
+    Microchip           string `json:"microchip" validate:"omitempty,max=30"`
+    Tatuaje             string `json:"tatuaje" validate:"omitempty,max=20"`
+    Licencia            string `json:"licencia" validate:"omitempty,max=30"`
+    Tenant              string `json:"tenant" validate:"omitempty,tenant"`
+}
+
+// mascotaFromInput validates in and builds the mascota it describes, which
+// must satisfy MascotaRules given the rest of check.
+func (h *Handlers) mascotaFromInput(in mascotaInput, check rules.MascotaCheck) (*models.Mascota, error) {
+    if err := h.validateStruct(in); err != nil {
+        return nil, err
     }
-    if err := h.validate.Struct(in); err != nil {
-        if verrs, ok := err.(validator.ValidationErrors); ok {
-            writeError(w, AppError{Code: "validation_error", Status: http.StatusBadRequest, Msg: "Datos inválidos", Fields: mapFieldErrors(verrs)})
-        } else {
-            writeError(w, NewBadRequest("validation_error", "Datos inválidos"))
+    dob, precision, err := models.ParseFechaNacimiento(in.FechaNacimiento)
+    if err != nil {
+        return nil, NewBadRequest("invalid_date", "fecha_nacimiento debe ser YYYY-MM-DD, YYYY-MM o YYYY")
+    }
+    chip := ""
+    if strings.TrimSpace(in.Microchip) != "" {
+        if chip, err = models.NormalizeMicrochip(in.Microchip); err != nil {
+            return nil, AppError{Code: "invalid_microchip", Status: http.StatusBadRequest, Msg: "Número de microchip inválido",
+                Fields: []FieldError{fieldError("microchip", "iso11784", "")}}
         }
+    }
+    m := &models.Mascota{Nombre: in.Nombre, Especie: in.Especie, Raza: in.Raza, FechaNacimiento: dob, FechaNacimientoPrecision: precision, Sexo: in.Sexo,
+        PropietarioNombre: in.PropietarioNombre, PropietarioEmail: in.PropietarioEmail, PropietarioTelefono: in.PropietarioTelefono,
+        Microchip: chip, Tatuaje: models.NormalizeTag(in.Tatuaje), Licencia: models.NormalizeTag(in.Licencia),
+        Tenant: in.Tenant}
+    check.Mascota = *m
+    if err := ruleError(h.MascotaRules.Check(check)); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+
+func (h *Handlers) CreateMascota(w http.ResponseWriter, r *http.Request) {
+    var in mascotaInput
+    if err := readJSON(w, r, &in); err != nil {
+        writeError(w, err)
         return
     }
-    dob, err := parseDate(in.FechaNacimiento)
+    m, err := h.mascotaFromInput(in, rules.MascotaCheck{Hoy: dayOf(h.now(r).In(h.Location))})
     if err != nil {
-        writeError(w, NewBadRequest("invalid_date", "fecha_nacimiento debe ser YYYY-MM-DD"))
+        writeError(w, err)
         return
     }
-    m := &models.Mascota{Nombre: in.Nombre, Especie: in.Especie, Raza: in.Raza, FechaNacimiento: dob, Sexo: in.Sexo}
-    if err := (models.MascotaStore{DB: h.DB}).Create(r.Context(), m); err != nil {
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Mascotas.Create(ctx, m); err != nil {
         writeError(w, err)
         return
     }
-    respondJSON(w, http.StatusCreated, m)
+    respondJSON(w, http.StatusCreated, h.mascotaView(r, m))
 }
 
 func (h *Handlers) GetMascota(w http.ResponseWriter, r *http.Request) {
-    id, err := idFromPath(r.URL.Path)
+    id, err := pathID(r, "id", "mascota")
     if err != nil {
-        http.Error(w, "invalid id", http.StatusBadRequest)
+        writeError(w, err)
         return
     }
-    m, err := (models.MascotaStore{DB: h.DB}).Get(r.Context(), id)
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    m, err := h.Mascotas.Get(ctx, id)
     if err != nil {
         writeError(w, err)
         return
     }
-    respondJSON(w, http.StatusOK, m)
+    if h.Alertas == nil {
+        respondJSON(w, http.StatusOK, h.mascotaView(r, m))
+        return
+    }
+    // The active alerts go with the mascota so no client can show it
+    // without them.
+    alertas, err := h.activeAlertas(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, struct {
+        mascotaView
+        Alertas []models.AlertaClinica `json:"alertas"`
+    }{h.mascotaView(r, m), alertas})
 }
 
 func (h *Handlers) UpdateMascota(w http.ResponseWriter, r *http.Request) {
-    id, err := idFromPath(r.URL.Path)
+    id, err := pathID(r, "id", "mascota")
     if err != nil {
-        http.Error(w, "invalid id", http.StatusBadRequest)
+        writeError(w, err)
         return
     }
-    var in struct {
-        Nombre          string `json:"nombre" validate:"required,min=2,max=100"`
-        Especie         string `json:"especie" validate:"required,oneof=Perro Gato Conejo"`
-        Raza            string `json:"raza" validate:"required,min=2,max=100"`
-        FechaNacimiento string `json:"fecha_nacimiento" validate:"required,datetime=2006-01-02"`
-        Sexo            string `json:"sexo" validate:"required,oneof=Macho Hembra"`
-    }
-    if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-        writeError(w, NewBadRequest("invalid_json", "JSON inválido"))
+    var in mascotaInput
+    if err := readJSON(w, r, &in); err != nil {
+        writeError(w, err)
         return
     }
-    if err := h.validate.Struct(in); err != nil {
-        if verrs, ok := err.(validator.ValidationErrors); ok {
-            writeError(w, AppError{Code: "validation_error", Status: http.StatusBadRequest, Msg: "Datos inválidos", Fields: mapFieldErrors(verrs)})
-        } else {
-            writeError(w, NewBadRequest("validation_error", "Datos inválidos"))
-        }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    primero, err := h.primerCuidado(ctx, id)
+    if err != nil {
+        writeError(w, err)
         return
     }
-    dob, err := parseDate(in.FechaNacimiento)
+    m, err := h.mascotaFromInput(in, rules.MascotaCheck{Hoy: dayOf(h.now(r).In(h.Location)), PrimerCuidado: primero})
     if err != nil {
-        writeError(w, NewBadRequest("invalid_date", "fecha_nacimiento debe ser YYYY-MM-DD"))
+        writeError(w, err)
         return
     }
-    m := &models.Mascota{ID: id, Nombre: in.Nombre, Especie: in.Especie, Raza: in.Raza, FechaNacimiento: dob, Sexo: in.Sexo}
-    if err := (models.MascotaStore{DB: h.DB}).Update(r.Context(), m); err != nil {
+    m.ID = id
+    if err := h.Mascotas.Update(ctx, m); err != nil {
         writeError(w, err)
         return
     }
-    respondJSON(w, http.StatusOK, m)
+    respondJSON(w, http.StatusOK, h.mascotaView(r, m))
 }
 
 func (h *Handlers) DeleteMascota(w http.ResponseWriter, r *http.Request) {
-    id, err := idFromPath(r.URL.Path)
+    id, err := pathID(r, "id", "mascota")
     if err != nil {
-        http.Error(w, "invalid id", http.StatusBadRequest)
+        writeError(w, err)
         return
     }
-    if err := (models.MascotaStore{DB: h.DB}).Delete(r.Context(), id); err != nil {
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Mascotas.Delete(ctx, id); err != nil {
         writeError(w, err)
         return
     }
+    h.sweepAdjuntos(r)
     w.WriteHeader(http.StatusNoContent)
 }
 
 // Cuidados
 
 func (h *Handlers) ListCuidadosByMascota(w http.ResponseWriter, r *http.Request) {
-    mascotaID, err := idFromNested(r.URL.Path)
+    mascotaID, err := pathID(r, "id", "mascota")
     if err != nil {
-        writeError(w, NewBadRequest("invalid_id", "ID de mascota inválido"))
+        writeError(w, err)
         return
     }
-    list, err := (models.CuidadoStore{DB: h.DB}).ListByMascota(r.Context(), mascotaID)
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    list, err := h.Cuidados.ListByMascota(ctx, mascotaID)
     if err != nil {
         writeError(w, err)
         return
@@ -161,26 +385,21 @@ func (h *Handlers) ListCuidadosByMascota(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *Handlers) CreateCuidadoForMascota(w http.ResponseWriter, r *http.Request) {
-    mascotaID, err := idFromNested(r.URL.Path)
+    mascotaID, err := pathID(r, "id", "mascota")
     if err != nil {
-        writeError(w, NewBadRequest("invalid_id", "ID de mascota inválido"))
+        writeError(w, err)
         return
     }
     var in struct {
         TipoCuidado  string `json:"tipo_cuidado" validate:"required,oneof=Vacunacion Desparasitacion 'Consulta Veterinaria' Bano"`
         Descripcion  string `json:"descripcion" validate:"required,min=2,max=500"`
         FechaCuidado string `json:"fecha_cuidado" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+        // ConfirmarAlergia acknowledges a conflict with a recorded allergy.
+        ConfirmarAlergia bool `json:"confirmar_alergia"`
+        VeterinarioID    int64 `json:"veterinario_id" validate:"gte=0"`
     }
-    if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-        writeError(w, NewBadRequest("invalid_json", "JSON inválido"))
-        return
-    }
-    if err := h.validate.Struct(in); err != nil {
-        if verrs, ok := err.(validator.ValidationErrors); ok {
-            writeError(w, AppError{Code: "validation_error", Status: http.StatusBadRequest, Msg: "Datos inválidos", Fields: mapFieldErrors(verrs)})
-        } else {
-            writeError(w, NewBadRequest("validation_error", "Datos inválidos"))
-        }
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        writeError(w, err)
         return
     }
     t, err := time.Parse(time.RFC3339, in.FechaCuidado)
@@ -188,25 +407,56 @@ func (h *Handlers) CreateCuidadoForMascota(w http.ResponseWriter, r *http.Reques
         writeError(w, NewBadRequest("invalid_datetime", "fecha_cuidado debe ser RFC3339"))
         return
     }
-    if appErr := validateCareSchedule(t); appErr != nil {
+    if appErr := h.validateSchedule(r, t); appErr != nil {
         writeError(w, appErr)
         return
     }
-    c := &models.Cuidado{TipoCuidado: in.TipoCuidado, Descripcion: in.Descripcion, FechaCuidado: t, MascotaID: mascotaID}
-    if err := (models.CuidadoStore{DB: h.DB}).Create(r.Context(), c); err != nil {
+    c := &models.Cuidado{TipoCuidado: in.TipoCuidado, Descripcion: in.Descripcion, FechaCuidado: t, MascotaID: mascotaID, VeterinarioID: in.VeterinarioID}
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if c.VeterinarioID != 0 {
+        if err := h.checkVeterinario(ctx, c.VeterinarioID, t, c.TipoCuidado, 0); err != nil {
+            writeError(w, err)
+            return
+        }
+    }
+    if err := h.checkCuidadoRules(ctx, c); err != nil {
         writeError(w, err)
         return
     }
-    respondJSON(w, http.StatusCreated, c)
+    alertas, err := h.activeAlertas(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    warnings := cuidadoWarnings(alertas, c.TipoCuidado, c.Descripcion)
+    if err := allergyConflict(warnings, in.ConfirmarAlergia); err != nil {
+        writeError(w, err)
+        return
+    }
+    if err := h.Cuidados.Create(ctx, c); err != nil {
+        writeError(w, h.bookingError(ctx, err, c.VeterinarioID))
+        return
+    }
+    respondJSON(w, http.StatusCreated, cuidadoResponse{c, warnings})
+}
+
+// cuidadoResponse is a cuidado with the allergy conflicts acknowledged
+// when it was scheduled.
+type cuidadoResponse struct {
+    *models.Cuidado
+    Advertencias []models.Advertencia `json:"advertencias,omitempty"`
 }
 
 func (h *Handlers) GetCuidado(w http.ResponseWriter, r *http.Request) {
-    id, err := idFromPath(r.URL.Path)
+    id, err := pathID(r, "id", "cuidado")
     if err != nil {
-        http.Error(w, "invalid id", http.StatusBadRequest)
+        writeError(w, err)
         return
     }
-    c, err := (models.CuidadoStore{DB: h.DB}).Get(r.Context(), id)
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    c, err := h.Cuidados.Get(ctx, id)
     if err != nil {
         writeError(w, err)
         return
@@ -215,9 +465,9 @@ func (h *Handlers) GetCuidado(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) UpdateCuidado(w http.ResponseWriter, r *http.Request) {
-    id, err := idFromPath(r.URL.Path)
+    id, err := pathID(r, "id", "cuidado")
     if err != nil {
-        http.Error(w, "invalid id", http.StatusBadRequest)
+        writeError(w, err)
         return
     }
     var in struct {
@@ -225,17 +475,15 @@ func (h *Handlers) UpdateCuidado(w http.ResponseWriter, r *http.Request) {
         Descripcion  string `json:"descripcion" validate:"required,min=2,max=500"`
         FechaCuidado string `json:"fecha_cuidado" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
         MascotaID    int64  `json:"mascota_id" validate:"required,gt=0"`
-    }
-    if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-        writeError(w, NewBadRequest("invalid_json", "JSON inválido"))
-        return
-    }
-    if err := h.validate.Struct(in); err != nil {
-        if verrs, ok := err.(validator.ValidationErrors); ok {
-            writeError(w, AppError{Code: "validation_error", Status: http.StatusBadRequest, Msg: "Datos inválidos", Fields: mapFieldErrors(verrs)})
-        } else {
-            writeError(w, NewBadRequest("validation_error", "Datos inválidos"))
-        }
+        // Estado is optional; when omitted the stored one is kept.
+        Estado       string `json:"estado" validate:"omitempty,oneof=Programado Completado Cancelado"`
+        ConfirmarAlergia bool `json:"confirmar_alergia"`
+        // VeterinarioID is optional too: omitted keeps the assigned one
+        // and 0 unassigns it.
+        VeterinarioID *int64 `json:"veterinario_id" validate:"omitempty,gte=0"`
+    }
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        writeError(w, err)
         return
     }
     t, err := time.Parse(time.RFC3339, in.FechaCuidado)
@@ -243,55 +491,140 @@ func (h *Handlers) UpdateCuidado(w http.ResponseWriter, r *http.Request) {
         writeError(w, NewBadRequest("invalid_datetime", "fecha_cuidado debe ser RFC3339"))
         return
     }
-    if appErr := validateCareSchedule(t); appErr != nil {
-        writeError(w, appErr)
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    current, err := h.Cuidados.Get(ctx, id)
+    if err != nil {
+        writeError(w, err)
         return
     }
-    c := &models.Cuidado{ID: id, TipoCuidado: in.TipoCuidado, Descripcion: in.Descripcion, FechaCuidado: t, MascotaID: in.MascotaID}
-    if err := (models.CuidadoStore{DB: h.DB}).Update(r.Context(), c); err != nil {
-        writeError(w, err)
+    // Only a new date has to satisfy the scheduling rules; otherwise a past
+    // cuidado could never be marked Completado.
+    if !t.Equal(current.FechaCuidado) {
+        if appErr := h.validateSchedule(r, t); appErr != nil {
+            writeError(w, appErr)
+            return
+        }
+    }
+    c := &models.Cuidado{ID: id, TipoCuidado: in.TipoCuidado, Descripcion: in.Descripcion, FechaCuidado: t, MascotaID: in.MascotaID, Estado: in.Estado,
+        VeterinarioID: current.VeterinarioID}
+    if in.VeterinarioID != nil {
+        c.VeterinarioID = *in.VeterinarioID
+    }
+    if c.MascotaID != current.MascotaID || !t.Equal(current.FechaCuidado) {
+        if err := h.checkCuidadoRules(ctx, c); err != nil {
+            writeError(w, err)
+            return
+        }
+    }
+    // The veterinario must still be free when the booking moves, gets
+    // longer, goes to someone else or stops being cancelled.
+    estado := in.Estado
+    if estado == "" {
+        estado = current.Estado
+    }
+    if c.VeterinarioID != 0 && estado != models.CuidadoCancelado && (c.VeterinarioID != current.VeterinarioID ||
+        !t.Equal(current.FechaCuidado) || c.TipoCuidado != current.TipoCuidado || current.Estado == models.CuidadoCancelado) {
+        if err := h.checkVeterinario(ctx, c.VeterinarioID, t, c.TipoCuidado, id); err != nil {
+            writeError(w, err)
+            return
+        }
+    }
+    // A cuidado is checked against the allergies again when what is done,
+    // to whom or when changes, unless it is being cancelled.
+    var warnings []models.Advertencia
+    if in.Estado != models.CuidadoCancelado && (c.TipoCuidado != current.TipoCuidado || c.Descripcion != current.Descripcion ||
+        c.MascotaID != current.MascotaID || !t.Equal(current.FechaCuidado)) {
+        alertas, err := h.activeAlertas(ctx, c.MascotaID)
+        if err != nil {
+            writeError(w, err)
+            return
+        }
+        warnings = cuidadoWarnings(alertas, c.TipoCuidado, c.Descripcion)
+        if err := allergyConflict(warnings, in.ConfirmarAlergia); err != nil {
+            writeError(w, err)
+            return
+        }
+    }
+    if err := h.Cuidados.Update(ctx, c); err != nil {
+        writeError(w, h.bookingError(ctx, err, c.VeterinarioID))
         return
     }
-    respondJSON(w, http.StatusOK, c)
+    respondJSON(w, http.StatusOK, cuidadoResponse{c, warnings})
 }
 
 func (h *Handlers) DeleteCuidado(w http.ResponseWriter, r *http.Request) {
-    id, err := idFromPath(r.URL.Path)
+    id, err := pathID(r, "id", "cuidado")
     if err != nil {
-        http.Error(w, "invalid id", http.StatusBadRequest)
+        writeError(w, err)
         return
     }
-    if err := (models.CuidadoStore{DB: h.DB}).Delete(r.Context(), id); err != nil {
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Cuidados.Delete(ctx, id); err != nil {
         writeError(w, err)
         return
     }
+    h.sweepAdjuntos(r)
     w.WriteHeader(http.StatusNoContent)
 }
 
+// ListNotificaciones shows the reminders queued for a cuidado and every
+// delivery attempt made for them.
+func (h *Handlers) ListNotificaciones(w http.ResponseWriter, r *http.Request) {
+    id, err := pathID(r, "id", "cuidado")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    if h.Notificaciones == nil {
+        writeError(w, NewNotFound("notifications_disabled", "las notificaciones no están habilitadas"))
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if _, err := h.Cuidados.Get(ctx, id); err != nil {
+        writeError(w, err)
+        return
+    }
+    list, err := h.Notificaciones.ListByCuidado(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, list)
+}
+
 // helpers
 
+// now is the current time for r, honouring a per-request clock override.
+func (h *Handlers) now(r *http.Request) time.Time {
+    return clock.FromContext(r.Context(), h.Clock).Now()
+}
+
+// dbContext derives the context for the request's database work so a slow
+// query cannot hold a connection longer than QueryTimeout.
+func (h *Handlers) dbContext(r *http.Request) (context.Context, context.CancelFunc) {
+    return context.WithTimeout(r.Context(), h.QueryTimeout)
+}
+
 func respondJSON(w http.ResponseWriter, status int, v any) {
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(status)
     _ = json.NewEncoder(w).Encode(v)
 }
 
-func idFromPath(path string) (int64, error) {
-    // expect .../{id}
-    idx := strings.LastIndexByte(path, '/')
-    if idx < 0 || idx+1 >= len(path) {
-        return 0, errors.New("missing id")
-    }
-    return strconv.ParseInt(path[idx+1:], 10, 64)
-}
-
-func idFromNested(path string) (int64, error) {
-    // expect /mascotas/{id}/cuidados
-    parts := strings.Split(strings.Trim(path, "/"), "/")
-    if len(parts) < 3 {
-        return 0, errors.New("missing nested id")
+// pathID parses the {name} wildcard of the route as an id; what names the
+// resource in the 400 for anything that is not a positive integer.
+func pathID(r *http.Request, name, what string) (int64, error) {
+    id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+    if err != nil || id <= 0 {
+        if what == "" {
+            return 0, NewBadRequest("invalid_id", "ID inválido")
+        }
+        return 0, NewBadRequest("invalid_id", "ID de "+what+" inválido")
     }
-    return strconv.ParseInt(parts[1], 10, 64)
+    return id, nil
 }
 
 func parseDate(s string) (time.Time, error) {
@@ -318,64 +651,3 @@ func parsePagination(q url.Values) (int64, int64, error) {
     }
     return limit, offset, nil
 }
-
-func mapFieldErrors(verrs validator.ValidationErrors) []FieldError {
-    out := make([]FieldError, 0, len(verrs))
-    for _, ve := range verrs {
-        out = append(out, FieldError{Field: ve.Field(), Message: ve.Error()})
-    }
-    return out
-}
-
-// validateCareSchedule aplica las reglas del negocio:
-// - Solo programar a partir del día siguiente (no pasado ni mismo día)
-// - Solo lunes a sábado (no domingos)
-// - Horario permitido 08:00 a 18:00 (inclusive)
-func validateCareSchedule(t time.Time) error {
-    loc := time.Now().Location()
-    now := time.Now().In(loc)
-    tt := t.In(loc)
-
-    ny, nm, nd := now.Date()
-    ty, tm, td := tt.Date()
-
-    // Fecha pasada
-    if ty < ny || (ty == ny && (tm < nm || (tm == nm && td < nd))) {
-        return NewBadRequest("past_date", "No es posible registrar cuidados en fechas anteriores a la actual.")
-    }
-    // Mismo día no permitido
-    if ty == ny && tm == nm && td == nd {
-        return NewBadRequest("same_day", "Solo puede programar cuidados a partir del día siguiente.")
-    }
-    // Domingo no permitido
-    if tt.Weekday() == time.Sunday {
-        return NewBadRequest("sunday_not_allowed", "No es posible registrar cuidados los días domingo. Por favor seleccione un día entre lunes y sábado.")
-    }
-    return nil
-}
-
-// validateFutureDateTime aplica las reglas de negocio para fecha/hora:
-// - Fecha no puede ser anterior al día actual (según hora del servidor)
-// - Si la fecha es hoy, la hora debe ser al menos +1 hora respecto a ahora
-func validateFutureDateTime(t time.Time) error {
-    now := time.Now().UTC()
-    tt := t.UTC()
-
-    ny, nm, nd := now.Date()
-    ty, tm, td := tt.Date()
-
-    // Fecha pasada
-    if ty < ny || (ty == ny && (tm < nm || (tm == nm && td < nd))) {
-        return NewBadRequest("past_date", "No es posible registrar cuidados con una fecha anterior al día actual.")
-    }
-
-    // Si es hoy, exigir +1 hora de tolerancia
-    if ty == ny && tm == nm && td == nd {
-        min := now.Add(1 * time.Hour)
-        if tt.Before(min) {
-            return NewBadRequest("past_time", "Debe seleccionar una hora al menos una hora después de la hora actual.")
-        }
-    }
-    return nil
-}
-
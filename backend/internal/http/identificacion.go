@@ -0,0 +1,62 @@
+package http
+
+import (
+    "net/http"
+    "strings"
+
+    "mascotas/internal/models"
+)
+
+// propietarioContacto is how staff reach the owner of a found animal.
+type propietarioContacto struct {
+    Nombre   string `json:"nombre"`
+    Email    string `json:"email"`
+    Telefono string `json:"telefono"`
+}
+
+// BuscarMascota serves GET /mascotas/buscar with exactly one of
+// ?microchip=, ?tatuaje= or ?licencia=, for the staff that scan lost
+// animals. It answers the mascota and the owner's contact, so it needs the
+// staff or admin bearer token.
+func (h *Handlers) BuscarMascota(w http.ResponseWriter, r *http.Request) {
+    if !h.isStaff(r) {
+        writeError(w, NewForbidden("staff_required", "la búsqueda por identificación solo está permitida al personal autorizado"))
+        return
+    }
+    q := r.URL.Query()
+    var field, value string
+    for _, f := range []string{models.IdentMicrochip, models.IdentTatuaje, models.IdentLicencia} {
+        if v := strings.TrimSpace(q.Get(f)); v != "" {
+            if field != "" {
+                field = ""
+                break
+            }
+            field, value = f, v
+        }
+    }
+    if field == "" {
+        writeError(w, NewBadRequest("invalid_query", "indique uno solo de microchip, tatuaje o licencia"))
+        return
+    }
+    if field == models.IdentMicrochip {
+        chip, err := models.NormalizeMicrochip(value)
+        if err != nil {
+            writeError(w, NewBadRequest("invalid_microchip", "número de microchip inválido"))
+            return
+        }
+        value = chip
+    } else {
+        value = models.NormalizeTag(value)
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    m, err := h.Mascotas.FindByIdentificacion(ctx, field, value)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, map[string]any{
+        "mascota":     h.mascotaView(r, m),
+        "propietario": propietarioContacto{Nombre: m.PropietarioNombre, Email: m.PropietarioEmail, Telefono: m.PropietarioTelefono},
+    })
+}
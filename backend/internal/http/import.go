@@ -0,0 +1,416 @@
+package http
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "slices"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+    "unicode"
+
+    "mascotas/internal/models"
+    "mascotas/internal/rules"
+    "mascotas/internal/tabular"
+)
+
+const (
+    maxImportBytes = 10 << 20
+    maxImportRows  = 5000
+    // importTimeout replaces QueryTimeout for the insert of a whole file.
+    importTimeout = time.Minute
+
+    importAllOrNothing = "todo_o_nada"
+    importBestEffort   = "parcial"
+)
+
+// importFields lists the columns of an import in mascotaInput order;
+// the first five are required.
+var importFields = []string{
+    "nombre", "especie", "raza", "fecha_nacimiento", "sexo",
+    "propietario_nombre", "propietario_email", "propietario_telefono",
+    "microchip", "tatuaje", "licencia", "tenant",
+}
+
+const requiredImportFields = 5
+
+// importAliases maps normalized header names (see normalizeHeader) to the
+// field they fill, so the usual spreadsheet headings work without a
+// mapping. Every field also matches its own name.
+var importAliases = map[string]string{
+    "name":                "nombre",
+    "species":             "especie",
+    "breed":               "raza",
+    "fecha_de_nacimiento": "fecha_nacimiento",
+    "nacimiento":          "fecha_nacimiento",
+    "birth_date":          "fecha_nacimiento",
+    "sex":                 "sexo",
+    "propietario":         "propietario_nombre",
+    "dueno":               "propietario_nombre",
+    "nombre_propietario":  "propietario_nombre",
+    "email":               "propietario_email",
+    "e_mail":              "propietario_email",
+    "correo":              "propietario_email",
+    "correo_electronico":  "propietario_email",
+    "telefono":            "propietario_telefono",
+    "celular":             "propietario_telefono",
+    "chip":                "microchip",
+    "numero_de_microchip": "microchip",
+    "tattoo":              "tatuaje",
+    "placa":               "licencia",
+    "license":             "licencia",
+    "clinica":             "tenant",
+    "sede":                "tenant",
+}
+
+type importRowError struct {
+    // Fila is the spreadsheet row number; the header is row 1.
+    Fila int `json:"fila"`
+    // DuplicadoDe is the existing mascota the row duplicates, and
+    // DuplicadoFila the earlier row of the same file.
+    DuplicadoDe   int64        `json:"duplicado_de,omitempty"`
+    DuplicadoFila int          `json:"duplicado_fila,omitempty"`
+    Fields        []FieldError `json:"fields"`
+}
+
+type importResult struct {
+    DryRun bool   `json:"dry_run"`
+    Modo   string `json:"modo"`
+    // Columnas tells which header was read for every field.
+    Columnas   map[string]string `json:"columnas"`
+    Total      int               `json:"total"`
+    Validas    int               `json:"validas"`
+    Importadas int               `json:"importadas"`
+    Errores    []importRowError  `json:"errores"`
+    // Mascotas are the created mascotas, or with dry_run the ones that
+    // would be created, as GET /mascotas/{id} shows them.
+    Mascotas []mascotaView `json:"mascotas"`
+}
+
+// ImportMascotas handles POST /mascotas/import. The file (CSV or XLSX,
+// told apart by content) is sent as the "archivo" part of a multipart form
+// or as the whole body. Options, as form fields or query parameters:
+//
+//   - dry_run: validate and report without writing anything.
+//   - modo: todo_o_nada (default) imports nothing when any row fails;
+//     parcial imports the valid rows and reports the rest.
+//   - columnas: JSON object from field name to header, for headers that
+//     are not recognised automatically.
+//
+// Every row goes through the same validation as POST /mascotas, and rows
+// that repeat an existing mascota or an earlier row (see
+// models.Mascota.DuplicateKey) are rejected as duplicates. The valid rows
+// are created in a single transaction, which fails with 409 like POST
+// /mascotas when a microchip, tatuaje or licencia is already registered.
+// The response is 200 for a dry run, 201 after importing and 422 when
+// todo_o_nada rejected the file; all of them carry the per-row report.
+func (h *Handlers) ImportMascotas(w http.ResponseWriter, r *http.Request) {
+    data, err := readImportFile(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    res := importResult{Modo: importAllOrNothing, Errores: make([]importRowError, 0), Mascotas: make([]mascotaView, 0)}
+    if v := r.FormValue("dry_run"); v != "" {
+        if res.DryRun, err = strconv.ParseBool(v); err != nil {
+            writeError(w, NewBadRequest("invalid_dry_run", "dry_run debe ser true o false"))
+            return
+        }
+    }
+    if v := r.FormValue("modo"); v != "" {
+        if v != importAllOrNothing && v != importBestEffort {
+            writeError(w, NewBadRequest("invalid_mode", "modo debe ser todo_o_nada o parcial"))
+            return
+        }
+        res.Modo = v
+    }
+    var mapping map[string]string
+    if v := r.FormValue("columnas"); v != "" {
+        if err := json.Unmarshal([]byte(v), &mapping); err != nil {
+            writeError(w, NewBadRequest("invalid_mapping", "columnas debe ser un objeto JSON {campo: encabezado}"))
+            return
+        }
+    }
+
+    rows, err := tabular.Read(data)
+    if err != nil {
+        writeError(w, NewBadRequest("invalid_file", "el archivo no es un CSV o XLSX válido"))
+        return
+    }
+    if len(rows) == 0 {
+        writeError(w, NewBadRequest("empty_file", "el archivo está vacío"))
+        return
+    }
+    cols, err := importColumns(rows[0], mapping)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    res.Columnas = make(map[string]string, len(cols))
+    for field, i := range cols {
+        res.Columnas[field] = strings.TrimSpace(rows[0][i])
+    }
+
+    hoy := dayOf(h.now(r).In(h.Location))
+    staff := h.isStaff(r)
+    var (
+        valid []*models.Mascota
+        filas []int
+        seen  = make(map[string]int)
+    )
+    for i, row := range rows[1:] {
+        fila := i + 2
+        if blankRow(row) {
+            continue
+        }
+        res.Total++
+        if res.Total > maxImportRows {
+            writeError(w, NewBadRequest("too_many_rows", fmt.Sprintf("el archivo supera el máximo de %d filas", maxImportRows)))
+            return
+        }
+        m, err := h.mascotaFromInput(importInput(row, cols), rules.MascotaCheck{Hoy: hoy})
+        if err != nil {
+            res.Errores = append(res.Errores, importRowError{Fila: fila, Fields: rowFieldErrors(err)})
+            continue
+        }
+        key := m.DuplicateKey()
+        if first, ok := seen[key]; ok {
+            res.Errores = append(res.Errores, importRowError{Fila: fila, DuplicadoFila: first, Fields: []FieldError{
+                fieldError("nombre", "duplicate_row", strconv.Itoa(first)),
+            }})
+            continue
+        }
+        seen[key] = fila
+        valid = append(valid, m)
+        filas = append(filas, fila)
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), importTimeout)
+    defer cancel()
+    candidates := make([]models.Mascota, len(valid))
+    for i, m := range valid {
+        candidates[i] = *m
+    }
+    dups, err := h.Mascotas.FindDuplicates(ctx, candidates)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    fresh := valid[:0]
+    for i, m := range valid {
+        if dups[i] != 0 {
+            res.Errores = append(res.Errores, importRowError{Fila: filas[i], DuplicadoDe: dups[i], Fields: []FieldError{
+                fieldError("nombre", "duplicate_mascota", strconv.FormatInt(dups[i], 10)),
+            }})
+            continue
+        }
+        fresh = append(fresh, m)
+    }
+    res.Validas = len(fresh)
+    sort.Slice(res.Errores, func(i, j int) bool { return res.Errores[i].Fila < res.Errores[j].Fila })
+    for i := range res.Errores {
+        res.Errores[i].Fields = localizeFields(res.Errores[i].Fields, responseLang(w))
+    }
+
+    switch {
+    case res.DryRun:
+        for _, m := range fresh {
+            res.Mascotas = append(res.Mascotas, newMascotaView(m, hoy, staff))
+        }
+        respondJSON(w, http.StatusOK, res)
+        return
+    case res.Modo == importAllOrNothing && len(res.Errores) > 0:
+        respondJSON(w, http.StatusUnprocessableEntity, res)
+        return
+    }
+    if len(fresh) > 0 {
+        if err := h.Mascotas.CreateMany(ctx, fresh); err != nil {
+            writeError(w, err)
+            return
+        }
+    }
+    for _, m := range fresh {
+        res.Mascotas = append(res.Mascotas, newMascotaView(m, hoy, staff))
+    }
+    res.Importadas = len(fresh)
+    respondJSON(w, http.StatusCreated, res)
+}
+
+// readImportFile returns the uploaded file, from the "archivo" part of a
+// multipart form or from the raw body.
+func readImportFile(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+    data, _, err := readUpload(w, r, maxImportBytes)
+    return data, err
+}
+
+// readUpload returns a file of at most limit bytes and its name: the
+// "archivo" part of a multipart form, or the raw body named by ?nombre=.
+func readUpload(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, string, error) {
+    // Leave room for the multipart framing and the option fields.
+    r.Body = http.MaxBytesReader(w, r.Body, limit+1<<20)
+    var (
+        data []byte
+        name string
+        err  error
+    )
+    if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "multipart/form-data" {
+        if err = r.ParseMultipartForm(limit); err == nil {
+            f, fh, ferr := r.FormFile("archivo")
+            if ferr != nil {
+                return nil, "", NewBadRequest("missing_file", "falta el archivo (campo archivo)")
+            }
+            defer f.Close()
+            name = fh.Filename
+            data, err = io.ReadAll(io.LimitReader(f, limit+1))
+        }
+    } else {
+        name = r.URL.Query().Get("nombre")
+        data, err = io.ReadAll(r.Body)
+    }
+    var tooLarge *http.MaxBytesError
+    switch {
+    case errors.As(err, &tooLarge) || int64(len(data)) > limit:
+        return nil, "", AppError{Code: "file_too_large", Status: http.StatusRequestEntityTooLarge,
+            Msg: fmt.Sprintf("el archivo supera el máximo de %d MB", limit>>20)}
+    case err != nil:
+        return nil, "", NewBadRequest("invalid_upload", "no se pudo leer el archivo")
+    case len(data) == 0:
+        return nil, "", NewBadRequest("missing_file", "falta el archivo (campo archivo)")
+    }
+    return data, name, nil
+}
+
+// importColumns resolves the column of every field from the header row:
+// mapping first, then field names and importAliases. A missing required
+// column fails the whole import.
+func importColumns(header []string, mapping map[string]string) (map[string]int, error) {
+    names := make([]string, len(header))
+    for i, h := range header {
+        names[i] = normalizeHeader(h)
+    }
+    cols := make(map[string]int)
+    for field, h := range mapping {
+        if !isImportField(field) {
+            return nil, NewBadRequest("invalid_mapping", fmt.Sprintf("columnas: campo desconocido %q", field))
+        }
+        i := slices.Index(names, normalizeHeader(h))
+        if i < 0 {
+            return nil, NewBadRequest("invalid_mapping", fmt.Sprintf("columnas: el archivo no tiene la columna %q", h))
+        }
+        cols[field] = i
+    }
+    // Exact field names win over aliases; otherwise the leftmost column.
+    for _, alias := range []bool{false, true} {
+        for i, n := range names {
+            field := n
+            if alias {
+                field = importAliases[n]
+            }
+            if _, set := cols[field]; !set && isImportField(field) {
+                cols[field] = i
+            }
+        }
+    }
+    var missing []FieldError
+    for _, field := range importFields[:requiredImportFields] {
+        if _, ok := cols[field]; !ok {
+            missing = append(missing, fieldError(field, "missing_column", ""))
+        }
+    }
+    if len(missing) > 0 {
+        return nil, AppError{Code: "missing_columns", Status: http.StatusBadRequest, Msg: "Faltan columnas obligatorias", Fields: missing}
+    }
+    return cols, nil
+}
+
+func isImportField(field string) bool {
+    return slices.Contains(importFields, field)
+}
+
+func importInput(row []string, cols map[string]int) mascotaInput {
+    cell := func(field string) string {
+        if i, ok := cols[field]; ok && i < len(row) {
+            return strings.TrimSpace(row[i])
+        }
+        return ""
+    }
+    return mascotaInput{
+        Nombre:              cell("nombre"),
+        Especie:             cell("especie"),
+        Raza:                cell("raza"),
+        FechaNacimiento:     importDate(cell("fecha_nacimiento")),
+        Sexo:                cell("sexo"),
+        PropietarioNombre:   cell("propietario_nombre"),
+        PropietarioEmail:    cell("propietario_email"),
+        PropietarioTelefono: cell("propietario_telefono"),
+        Microchip:           cell("microchip"),
+        Tatuaje:             cell("tatuaje"),
+        Licencia:            cell("licencia"),
+        Tenant:              cell("tenant"),
+    }
+}
+
+// importDate accepts what spreadsheets produce for a date besides
+// YYYY-MM-DD, YYYY-MM and YYYY: DD/MM/YYYY and XLSX serial day numbers.
+// Anything else is passed through for validation to reject.
+func importDate(v string) string {
+    // A bare year would otherwise pass for a serial day number.
+    if _, _, err := models.ParseFechaNacimiento(v); err == nil {
+        return v
+    }
+    if t, err := time.Parse("02/01/2006", v); err == nil {
+        return t.Format("2006-01-02")
+    }
+    if t, ok := tabular.ExcelDate(v); ok {
+        return t.Format("2006-01-02")
+    }
+    return v
+}
+
+func rowFieldErrors(err error) []FieldError {
+    var app AppError
+    if errors.As(err, &app) && len(app.Fields) > 0 {
+        return app.Fields
+    }
+    if errors.As(err, &app) && app.Code == "invalid_date" {
+        return []FieldError{fieldError("fecha_nacimiento", "fecha_nacimiento", "")}
+    }
+    return []FieldError{{Field: "", Message: err.Error()}}
+}
+
+func blankRow(row []string) bool {
+    for _, c := range row {
+        if strings.TrimSpace(c) != "" {
+            return false
+        }
+    }
+    return true
+}
+
+var headerAccents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n")
+
+// normalizeHeader folds a header for matching: "Fecha de Nacimiento" and
+// "fecha_nacimiento " become fecha_de_nacimiento and fecha_nacimiento.
+func normalizeHeader(h string) string {
+    h = headerAccents.Replace(strings.ToLower(strings.TrimSpace(h)))
+    var b strings.Builder
+    sep := false
+    for _, r := range h {
+        if unicode.IsLetter(r) || unicode.IsDigit(r) {
+            if sep && b.Len() > 0 {
+                b.WriteByte('_')
+            }
+            b.WriteRune(r)
+            sep = false
+        } else {
+            sep = true
+        }
+    }
+    return b.String()
+}
@@ -0,0 +1,69 @@
+package http_test
+
+import (
+    "bytes"
+    "context"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "mascotas/internal/models/memory"
+)
+
+func multipartImport(t *testing.T, fields map[string]string, file []byte) *http.Request {
+    t.Helper()
+    var body bytes.Buffer
+    mw := multipart.NewWriter(&body)
+    for k, v := range fields {
+        mw.WriteField(k, v)
+    }
+    fw, err := mw.CreateFormFile("archivo", "mascotas.csv")
+    if err != nil {
+        t.Fatal(err)
+    }
+    fw.Write(file)
+    mw.Close()
+    req := httptest.NewRequest("POST", "/mascotas/import", &body)
+    req.Header.Set("Content-Type", mw.FormDataContentType())
+    return req
+}
+
+// TestImportMultipart uploads the file as a form, the way the browser
+// does, and checks that a rejected all-or-nothing import writes nothing.
+func TestImportMultipart(t *testing.T) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    srv := newServer(r)
+
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, multipartImport(t, nil, []byte(importCSV)))
+    if rec.Code != http.StatusUnprocessableEntity {
+        t.Fatalf("all or nothing: status = %d, body %s", rec.Code, rec.Body)
+    }
+    if all, _ := r.mascotas.List(context.Background()); len(all) != 2 {
+        t.Fatalf("rejected import wrote mascotas: %+v", all)
+    }
+
+    rec = httptest.NewRecorder()
+    srv.ServeHTTP(rec, multipartImport(t, map[string]string{"modo": "parcial"}, []byte(importCSV)))
+    if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"importadas":1`) {
+        t.Fatalf("parcial: status = %d, body %s", rec.Code, rec.Body)
+    }
+    if all, _ := r.mascotas.List(context.Background()); len(all) != 3 {
+        t.Fatalf("after import: %d mascotas, want 3", len(all))
+    }
+}
+
+func TestImportTooLarge(t *testing.T) {
+    s := memory.New()
+    srv := newServer(repos{s.Mascotas(), s.Cuidados()})
+    big := bytes.Repeat([]byte("x"), 11<<20)
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, multipartImport(t, nil, big))
+    if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "file_too_large") {
+        t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+    }
+}
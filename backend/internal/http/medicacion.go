@@ -0,0 +1,498 @@
+package http
+
+import (
+    "errors"
+    "net/http"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+
+    "mascotas/internal/medication"
+    "mascotas/internal/models"
+)
+
+type medicamentoInput struct {
+    Nombre                  string   `json:"nombre" validate:"required,min=2,max=100"`
+    PrincipioActivo         string   `json:"principio_activo" validate:"required,min=2,max=100"`
+    Grupo                   string   `json:"grupo" validate:"max=100"`
+    Presentacion            string   `json:"presentacion" validate:"max=200"`
+    ConcentracionMg         *float64 `json:"concentracion_mg" validate:"omitempty,gt=0"`
+    Unidad                  string   `json:"unidad" validate:"max=30"`
+    DosisMinMgKg            float64  `json:"dosis_min_mg_kg" validate:"gte=0"`
+    DosisMaxMgKg            float64  `json:"dosis_max_mg_kg" validate:"gt=0,gtefield=DosisMinMgKg"`
+    Vias                    []string `json:"vias" validate:"dive,oneof=oral subcutanea intramuscular intravenosa topica oftalmica otica"`
+    EspeciesContraindicadas []string `json:"especies_contraindicadas" validate:"dive,oneof=Perro Gato Conejo"`
+    Interacciones           []string `json:"interacciones" validate:"dive,required,max=100"`
+}
+
+func (h *Handlers) decodeMedicamento(w http.ResponseWriter, r *http.Request) (*models.Medicamento, error) {
+    var in medicamentoInput
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        return nil, err
+    }
+    orEmpty := func(s []string) []string {
+        if s == nil {
+            return []string{}
+        }
+        return s
+    }
+    return &models.Medicamento{Nombre: in.Nombre, PrincipioActivo: in.PrincipioActivo, Grupo: in.Grupo, Presentacion: in.Presentacion,
+        ConcentracionMg: in.ConcentracionMg, Unidad: in.Unidad, DosisMinMgKg: in.DosisMinMgKg, DosisMaxMgKg: in.DosisMaxMgKg,
+        Vias: orEmpty(in.Vias), EspeciesContraindicadas: orEmpty(in.EspeciesContraindicadas), Interacciones: orEmpty(in.Interacciones)}, nil
+}
+
+// medicacionEnabled writes a 404 when the backend has no medication
+// records.
+func (h *Handlers) medicacionEnabled(w http.ResponseWriter) bool {
+    if h.Medicacion == nil {
+        writeError(w, NewNotFound("medications_disabled", "la medicación no está habilitada"))
+        return false
+    }
+    return true
+}
+
+func (h *Handlers) pesosEnabled(w http.ResponseWriter) bool {
+    if h.Pesos == nil {
+        writeError(w, NewNotFound("weights_disabled", "el registro de pesos no está habilitado"))
+        return false
+    }
+    return true
+}
+
+func duplicateMedicamento(err error) error {
+    if errors.Is(err, models.ErrDuplicateName) {
+        return NewConflict("duplicate_name", "ya existe un medicamento con ese nombre")
+    }
+    return err
+}
+
+// Medicamentos
+
+func (h *Handlers) ListMedicamentos(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    list, err := h.Medicacion.ListMedicamentos(ctx)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, list)
+}
+
+func (h *Handlers) CreateMedicamento(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    m, err := h.decodeMedicamento(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Medicacion.CreateMedicamento(ctx, m); err != nil {
+        writeError(w, duplicateMedicamento(err))
+        return
+    }
+    respondJSON(w, http.StatusCreated, m)
+}
+
+func (h *Handlers) GetMedicamento(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "medicamento")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    m, err := h.Medicacion.GetMedicamento(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, m)
+}
+
+func (h *Handlers) UpdateMedicamento(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "medicamento")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    m, err := h.decodeMedicamento(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    m.ID = id
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Medicacion.UpdateMedicamento(ctx, m); err != nil {
+        writeError(w, duplicateMedicamento(err))
+        return
+    }
+    respondJSON(w, http.StatusOK, m)
+}
+
+// Pesos
+
+// ListPesos serves GET /mascotas/{id}/pesos, newest first.
+func (h *Handlers) ListPesos(w http.ResponseWriter, r *http.Request) {
+    if !h.pesosEnabled(w) {
+        return
+    }
+    mascotaID, err := pathID(r, "id", "mascota")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if _, err := h.Mascotas.Get(ctx, mascotaID); err != nil {
+        writeError(w, err)
+        return
+    }
+    list, err := h.Pesos.ListByMascota(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, list)
+}
+
+// CreatePeso serves POST /mascotas/{id}/pesos; fecha defaults to now and
+// cannot be in the future.
+func (h *Handlers) CreatePeso(w http.ResponseWriter, r *http.Request) {
+    if !h.pesosEnabled(w) {
+        return
+    }
+    mascotaID, err := pathID(r, "id", "mascota")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    var in struct {
+        PesoKg float64 `json:"peso_kg" validate:"gt=0,lte=9999"`
+        Fecha  string  `json:"fecha" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+        Nota   string  `json:"nota" validate:"max=500"`
+    }
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        writeError(w, err)
+        return
+    }
+    now := h.now(r)
+    fecha := now
+    if in.Fecha != "" {
+        if fecha, err = time.Parse(time.RFC3339, in.Fecha); err != nil {
+            writeError(w, NewBadRequest("invalid_datetime", "fecha debe ser RFC3339"))
+            return
+        }
+        if fecha.After(now) {
+            writeError(w, NewBadRequest("future_date", "fecha no puede ser posterior a la actual"))
+            return
+        }
+    }
+    p := &models.Peso{MascotaID: mascotaID, PesoKg: in.PesoKg, Fecha: fecha, Nota: in.Nota}
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Pesos.Create(ctx, p); err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusCreated, p)
+}
+
+// CalcularDosis serves GET /mascotas/{id}/dosis?medicamento={id}: the
+// dose range of the medicamento for the mascota's latest weight.
+func (h *Handlers) CalcularDosis(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) || !h.pesosEnabled(w) {
+        return
+    }
+    mascotaID, err := pathID(r, "id", "mascota")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    medID, err := strconv.ParseInt(r.URL.Query().Get("medicamento"), 10, 64)
+    if err != nil {
+        writeError(w, NewBadRequest("invalid_medicamento", "medicamento debe ser el ID de un medicamento del catálogo"))
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if _, err := h.Mascotas.Get(ctx, mascotaID); err != nil {
+        writeError(w, err)
+        return
+    }
+    m, err := h.Medicacion.GetMedicamento(ctx, medID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    peso, err := h.Pesos.Latest(ctx, mascotaID)
+    if errors.Is(err, models.ErrNotFound) {
+        writeError(w, AppError{Code: "weight_required", Status: http.StatusUnprocessableEntity,
+            Msg: "registre el peso de la mascota para calcular la dosis"})
+        return
+    }
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, struct {
+        medication.Dose
+        PesadoEn time.Time `json:"pesado_en"`
+    }{medication.DoseFor(*m, peso.PesoKg), peso.Fecha})
+}
+
+// Prescripciones
+
+// clinicDay is the clinic's calendar day of t, at midnight UTC like the
+// DATE columns.
+func (h *Handlers) clinicDay(t time.Time) time.Time {
+    y, m, d := t.In(h.Location).Date()
+    return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// latestWeight returns the mascota's current weight, or 0 when it is
+// unknown.
+func (h *Handlers) latestWeight(r *http.Request, mascotaID int64) (float64, error) {
+    if h.Pesos == nil {
+        return 0, nil
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    p, err := h.Pesos.Latest(ctx, mascotaID)
+    if errors.Is(err, models.ErrNotFound) {
+        return 0, nil
+    }
+    if err != nil {
+        return 0, err
+    }
+    return p.PesoKg, nil
+}
+
+func (h *Handlers) ListPrescripcionesByCuidado(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "cuidado")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if _, err := h.Cuidados.Get(ctx, id); err != nil {
+        writeError(w, err)
+        return
+    }
+    list, err := h.Medicacion.ListPrescripcionesByCuidado(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, list)
+}
+
+// CreatePrescripcion serves POST /cuidados/{id}/prescripciones. The
+// prescription starts on fecha_inicio, by default the day of the cuidado,
+// and lasts duracion_dias. Warnings (see medication.Check) are returned
+// with it; an allergy among them rejects it with 409 unless
+// confirmar_alergia is set.
+func (h *Handlers) CreatePrescripcion(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    cuidadoID, err := pathID(r, "id", "cuidado")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    var in struct {
+        MedicamentoID   int64   `json:"medicamento_id" validate:"required,gt=0"`
+        DosisMg         float64 `json:"dosis_mg" validate:"gt=0"`
+        FrecuenciaHoras int     `json:"frecuencia_horas" validate:"required,gt=0,lte=720"`
+        Via             string  `json:"via" validate:"required,oneof=oral subcutanea intramuscular intravenosa topica oftalmica otica"`
+        DuracionDias    int     `json:"duracion_dias" validate:"required,gt=0,lte=365"`
+        FechaInicio     string  `json:"fecha_inicio" validate:"omitempty,datetime=2006-01-02"`
+        Indicaciones    string  `json:"indicaciones" validate:"max=1000"`
+        // ConfirmarAlergia acknowledges a conflict with a recorded allergy
+        // or an earlier adverse reaction.
+        ConfirmarAlergia bool `json:"confirmar_alergia"`
+    }
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    c, err := h.Cuidados.Get(ctx, cuidadoID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    mascota, err := h.Mascotas.Get(ctx, c.MascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    med, err := h.Medicacion.GetMedicamento(ctx, in.MedicamentoID)
+    if errors.Is(err, models.ErrNotFound) {
+        writeError(w, NewBadRequest("unknown_medicamento", "el medicamento no existe en el catálogo"))
+        return
+    }
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    if len(med.Vias) > 0 && !slices.Contains(med.Vias, in.Via) {
+        writeError(w, AppError{Code: "invalid_route", Status: http.StatusBadRequest, Msg: "vía no admitida para " + med.Nombre,
+            Fields: []FieldError{fieldError("via", "not_allowed", strings.Join(med.Vias, ", "))}})
+        return
+    }
+    inicio := h.clinicDay(c.FechaCuidado)
+    if in.FechaInicio != "" {
+        if inicio, err = parseDate(in.FechaInicio); err != nil {
+            writeError(w, NewBadRequest("invalid_date", "fecha_inicio debe ser YYYY-MM-DD"))
+            return
+        }
+    }
+    p := &models.Prescripcion{MascotaID: c.MascotaID, CuidadoID: c.ID, MedicamentoID: med.ID, DosisMg: in.DosisMg,
+        FrecuenciaHoras: in.FrecuenciaHoras, Via: in.Via, DuracionDias: in.DuracionDias, FechaInicio: inicio,
+        FechaFin: inicio.AddDate(0, 0, in.DuracionDias-1), Indicaciones: in.Indicaciones}
+    others, err := h.Medicacion.ListPrescripcionesByMascota(ctx, c.MascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    peso, err := h.latestWeight(r, c.MascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    alertas, err := h.activeAlertas(ctx, c.MascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    p.Medicamento = med
+    p.Advertencias = medication.Check(mascota.Especie, *p, others, alertas, peso, p.FechaInicio, p.FechaFin)
+    if err := allergyConflict(p.Advertencias, in.ConfirmarAlergia); err != nil {
+        writeError(w, err)
+        return
+    }
+    if err := h.Medicacion.CreatePrescripcion(ctx, p); err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusCreated, p)
+}
+
+func (h *Handlers) GetPrescripcion(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "prescripción")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    p, err := h.Medicacion.GetPrescripcion(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, p)
+}
+
+// SuspenderPrescripcion serves POST /prescripciones/{id}/suspender. A
+// suspension for an adverse reaction warns on later prescriptions of the
+// same principio activo or grupo.
+func (h *Handlers) SuspenderPrescripcion(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "prescripción")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    var in struct {
+        Motivo          string `json:"motivo" validate:"required,min=2,max=500"`
+        ReaccionAdversa bool   `json:"reaccion_adversa"`
+    }
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Medicacion.SuspendPrescripcion(ctx, id, h.now(r), in.Motivo, in.ReaccionAdversa); err != nil {
+        writeError(w, err)
+        return
+    }
+    p, err := h.Medicacion.GetPrescripcion(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, p)
+}
+
+// ListMedicacionActiva serves GET /mascotas/{id}/medicacion: the
+// prescriptions being taken today, each with its warnings against the
+// others.
+func (h *Handlers) ListMedicacionActiva(w http.ResponseWriter, r *http.Request) {
+    if !h.medicacionEnabled(w) {
+        return
+    }
+    mascotaID, err := pathID(r, "id", "mascota")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    mascota, err := h.Mascotas.Get(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    all, err := h.Medicacion.ListPrescripcionesByMascota(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    peso, err := h.latestWeight(r, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    alertas, err := h.activeAlertas(ctx, mascotaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    today := h.clinicDay(h.now(r))
+    activas := make([]models.Prescripcion, 0)
+    for _, p := range all {
+        if p.Activa(today) {
+            p.Advertencias = medication.Check(mascota.Especie, p, all, alertas, peso, today, today)
+            activas = append(activas, p)
+        }
+    }
+    respondJSON(w, http.StatusOK, activas)
+}
@@ -0,0 +1,277 @@
+package http_test
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+
+    "mascotas/internal/clock"
+    apphttp "mascotas/internal/http"
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+)
+
+// medicacionLog is an in-memory models.MedicacionRepository; prescriptions
+// are read back with their medicamento, as the join does in Postgres.
+type medicacionLog struct {
+    meds  map[int64]models.Medicamento
+    presc map[int64]models.Prescripcion
+}
+
+func (l *medicacionLog) CreateMedicamento(ctx context.Context, m *models.Medicamento) error {
+    for _, o := range l.meds {
+        if o.Nombre == m.Nombre {
+            return models.ErrDuplicateName
+        }
+    }
+    m.ID = int64(len(l.meds) + 1)
+    l.meds[m.ID] = *m
+    return nil
+}
+
+func (l *medicacionLog) GetMedicamento(ctx context.Context, id int64) (*models.Medicamento, error) {
+    m, ok := l.meds[id]
+    if !ok {
+        return nil, models.ErrNotFound
+    }
+    return &m, nil
+}
+
+func (l *medicacionLog) ListMedicamentos(ctx context.Context) ([]models.Medicamento, error) {
+    out := make([]models.Medicamento, 0)
+    for _, m := range l.meds {
+        out = append(out, m)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Nombre < out[j].Nombre })
+    return out, nil
+}
+
+func (l *medicacionLog) UpdateMedicamento(ctx context.Context, m *models.Medicamento) error {
+    if _, ok := l.meds[m.ID]; !ok {
+        return models.ErrNotFound
+    }
+    l.meds[m.ID] = *m
+    return nil
+}
+
+func (l *medicacionLog) CreatePrescripcion(ctx context.Context, p *models.Prescripcion) error {
+    p.ID = int64(len(l.presc) + 1)
+    p.CreadoEn = fixedNow
+    stored := *p
+    stored.Medicamento, stored.Advertencias = nil, nil
+    l.presc[p.ID] = stored
+    return nil
+}
+
+func (l *medicacionLog) read(p models.Prescripcion) models.Prescripcion {
+    m := l.meds[p.MedicamentoID]
+    p.Medicamento = &m
+    return p
+}
+
+func (l *medicacionLog) GetPrescripcion(ctx context.Context, id int64) (*models.Prescripcion, error) {
+    p, ok := l.presc[id]
+    if !ok {
+        return nil, models.ErrNotFound
+    }
+    p = l.read(p)
+    return &p, nil
+}
+
+func (l *medicacionLog) list(match func(models.Prescripcion) bool) []models.Prescripcion {
+    out := make([]models.Prescripcion, 0)
+    for _, p := range l.presc {
+        if match(p) {
+            out = append(out, l.read(p))
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+    return out
+}
+
+func (l *medicacionLog) ListPrescripcionesByCuidado(ctx context.Context, id int64) ([]models.Prescripcion, error) {
+    return l.list(func(p models.Prescripcion) bool { return p.CuidadoID == id }), nil
+}
+
+func (l *medicacionLog) ListPrescripcionesByMascota(ctx context.Context, id int64) ([]models.Prescripcion, error) {
+    return l.list(func(p models.Prescripcion) bool { return p.MascotaID == id }), nil
+}
+
+func (l *medicacionLog) SuspendPrescripcion(ctx context.Context, id int64, at time.Time, motivo string, reaccion bool) error {
+    p, ok := l.presc[id]
+    if !ok {
+        return models.ErrNotFound
+    }
+    if p.SuspendidaEn == nil {
+        p.SuspendidaEn, p.MotivoSuspension = &at, motivo
+    }
+    p.ReaccionAdversa = p.ReaccionAdversa || reaccion
+    l.presc[id] = p
+    return nil
+}
+
+// pesoLog is an in-memory models.PesoRepository.
+type pesoLog []models.Peso
+
+func (l *pesoLog) Create(ctx context.Context, p *models.Peso) error {
+    p.ID = int64(len(*l) + 1)
+    *l = append(*l, *p)
+    return nil
+}
+
+func (l *pesoLog) ListByMascota(ctx context.Context, id int64) ([]models.Peso, error) {
+    out := make([]models.Peso, 0)
+    for _, p := range *l {
+        if p.MascotaID == id {
+            out = append(out, p)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Fecha.After(out[j].Fecha) })
+    return out, nil
+}
+
+func (l *pesoLog) Latest(ctx context.Context, id int64) (*models.Peso, error) {
+    list, _ := l.ListByMascota(ctx, id)
+    if len(list) == 0 {
+        return nil, models.ErrNotFound
+    }
+    return &list[0], nil
+}
+
+func newMedicationServer(t *testing.T) http.Handler {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    h := apphttp.NewHandlers(r.mascotas, r.cuidados)
+    h.Clock = clock.Fixed(fixedNow)
+    h.Medicacion = &medicacionLog{meds: make(map[int64]models.Medicamento), presc: make(map[int64]models.Prescripcion)}
+    h.Pesos = &pesoLog{}
+    return apphttp.NewRouter(h, testConfig())
+}
+
+func send(t *testing.T, srv http.Handler, method, target, body string, want int, out any) {
+    t.Helper()
+    req := httptest.NewRequest(method, target, strings.NewReader(body))
+    if body != "" {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    rec := httptest.NewRecorder()
+    srv.ServeHTTP(rec, req)
+    if rec.Code != want {
+        t.Fatalf("%s %s: status = %d, want %d; body %s", method, target, rec.Code, want, rec.Body)
+    }
+    if out != nil {
+        // Start from the zero value: fields left out by omitempty must not
+        // keep what an earlier response put there.
+        reflect.ValueOf(out).Elem().SetZero()
+        if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+func kinds(p models.Prescripcion) []string {
+    out := make([]string, 0, len(p.Advertencias))
+    for _, a := range p.Advertencias {
+        out = append(out, a.Tipo)
+    }
+    return out
+}
+
+func TestDosisFromLatestWeight(t *testing.T) {
+    srv := newMedicationServer(t)
+    send(t, srv, "POST", "/medicamentos", `{"nombre":"Amoxicilina 250","principio_activo":"amoxicilina","grupo":"penicilinas",
+        "concentracion_mg":250,"unidad":"comprimido","dosis_min_mg_kg":10,"dosis_max_mg_kg":20,"vias":["oral"]}`, 201, nil)
+    send(t, srv, "POST", "/medicamentos", `{"nombre":"Amoxicilina 250","principio_activo":"amoxicilina","dosis_max_mg_kg":20}`, 409, nil)
+    send(t, srv, "POST", "/medicamentos", `{"nombre":"Otro","principio_activo":"otro","dosis_min_mg_kg":5,"dosis_max_mg_kg":2}`, 400, nil)
+
+    send(t, srv, "GET", "/mascotas/1/dosis?medicamento=1", "", 422, nil)
+    send(t, srv, "POST", "/mascotas/1/pesos", `{"peso_kg":11,"fecha":"2030-01-10T09:00:00Z"}`, 201, nil)
+    send(t, srv, "POST", "/mascotas/1/pesos", `{"peso_kg":12.5}`, 201, nil)
+    send(t, srv, "POST", "/mascotas/1/pesos", `{"peso_kg":13,"fecha":"2030-07-01T09:00:00Z"}`, 400, nil)
+
+    var dose struct {
+        PesoKg      float64   `json:"peso_kg"`
+        MinMg       float64   `json:"dosis_min_mg"`
+        MaxMg       float64   `json:"dosis_max_mg"`
+        CantidadMin float64   `json:"cantidad_min"`
+        CantidadMax float64   `json:"cantidad_max"`
+        Unidad      string    `json:"unidad"`
+        PesadoEn    time.Time `json:"pesado_en"`
+    }
+    send(t, srv, "GET", "/mascotas/1/dosis?medicamento=1", "", 200, &dose)
+    if dose.PesoKg != 12.5 || dose.MinMg != 125 || dose.MaxMg != 250 || dose.CantidadMin != 0.5 || dose.CantidadMax != 1 ||
+        dose.Unidad != "comprimido" || !dose.PesadoEn.Equal(fixedNow) {
+        t.Fatalf("dose = %+v", dose)
+    }
+    send(t, srv, "GET", "/mascotas/1/dosis?medicamento=9", "", 404, nil)
+}
+
+// TestPrescripcionWarnings prescribes interacting medicamentos, suspends
+// one for an adverse reaction and checks the warnings along the way.
+func TestPrescripcionWarnings(t *testing.T) {
+    srv := newMedicationServer(t)
+    send(t, srv, "POST", "/medicamentos", `{"nombre":"Meloxicam","principio_activo":"meloxicam","grupo":"AINE",
+        "dosis_min_mg_kg":0.1,"dosis_max_mg_kg":0.2,"vias":["oral","subcutanea"],"interacciones":["corticoides"]}`, 201, nil)
+    send(t, srv, "POST", "/medicamentos", `{"nombre":"Prednisona","principio_activo":"prednisona","grupo":"corticoides",
+        "dosis_min_mg_kg":0.5,"dosis_max_mg_kg":1}`, 201, nil)
+    send(t, srv, "POST", "/mascotas/1/pesos", `{"peso_kg":10}`, 201, nil)
+
+    var p models.Prescripcion
+    send(t, srv, "POST", "/cuidados/1/prescripciones", `{"medicamento_id":1,"dosis_mg":1.5,"frecuencia_horas":24,"via":"intramuscular",
+        "duracion_dias":5,"fecha_inicio":"2030-06-04"}`, 400, nil)
+    send(t, srv, "POST", "/cuidados/1/prescripciones", `{"medicamento_id":1,"dosis_mg":1.5,"frecuencia_horas":24,"via":"oral",
+        "duracion_dias":5,"fecha_inicio":"2030-06-04"}`, 201, &p)
+    if got := p.FechaFin.Format("2006-01-02"); got != "2030-06-08" || len(p.Advertencias) != 0 {
+        t.Fatalf("meloxicam: fecha_fin %s, advertencias %+v", got, p.Advertencias)
+    }
+    send(t, srv, "POST", "/cuidados/1/prescripciones", `{"medicamento_id":2,"dosis_mg":20,"frecuencia_horas":12,"via":"oral",
+        "duracion_dias":3,"fecha_inicio":"2030-06-05"}`, 201, &p)
+    if got := kinds(p); strings.Join(got, ",") != "dosis,interaccion" {
+        t.Fatalf("prednisona warnings = %v", got)
+    }
+    // Without fecha_inicio the prescription starts on the day of the
+    // cuidado, here in the future.
+    send(t, srv, "POST", "/cuidados/2/prescripciones", `{"medicamento_id":1,"dosis_mg":1.5,"frecuencia_horas":24,"via":"oral",
+        "duracion_dias":2}`, 201, &p)
+    if got := p.FechaInicio.Format("2006-01-02"); got != "2030-06-20" || len(p.Advertencias) != 0 {
+        t.Fatalf("future prescription: fecha_inicio %s, advertencias %+v", got, p.Advertencias)
+    }
+
+    var activas []models.Prescripcion
+    send(t, srv, "GET", "/mascotas/1/medicacion", "", 200, &activas)
+    if len(activas) != 2 || strings.Join(kinds(activas[0]), ",") != "dosis,interaccion" || strings.Join(kinds(activas[1]), ",") != "interaccion" {
+        t.Fatalf("activas = %+v", activas)
+    }
+
+    send(t, srv, "POST", "/prescripciones/2/suspender", `{"motivo":"Vómitos y urticaria","reaccion_adversa":true}`, 200, &p)
+    if p.SuspendidaEn == nil || !p.ReaccionAdversa {
+        t.Fatalf("suspended = %+v", p)
+    }
+    send(t, srv, "GET", "/mascotas/1/medicacion", "", 200, &activas)
+    if len(activas) != 1 || activas[0].ID != 1 || len(activas[0].Advertencias) != 0 {
+        t.Fatalf("activas after suspension = %+v", activas)
+    }
+    body := `{"medicamento_id":2,"dosis_mg":7,"frecuencia_horas":24,"via":"oral","duracion_dias":3}`
+    send(t, srv, "POST", "/cuidados/2/prescripciones", body, 409, nil)
+    send(t, srv, "POST", "/cuidados/2/prescripciones", strings.Replace(body, "{", `{"confirmar_alergia":true,`, 1), 201, &p)
+    // It overlaps the future meloxicam and repeats the suspended one.
+    if got := kinds(p); strings.Join(got, ",") != "interaccion,alergia" {
+        t.Fatalf("after adverse reaction: warnings = %v", got)
+    }
+    // Starting today, it repeats the first meloxicam and runs into the
+    // meloxicam and prednisona that start on the 20th.
+    send(t, srv, "POST", "/cuidados/1/prescripciones", `{"medicamento_id":1,"dosis_mg":1.5,"frecuencia_horas":24,"via":"oral",
+        "duracion_dias":20,"fecha_inicio":"2030-06-05"}`, 201, &p)
+    if got := kinds(p); strings.Join(got, ",") != "interaccion,duplicado,duplicado" {
+        t.Fatalf("overlapping later prescriptions: warnings = %v", got)
+    }
+    send(t, srv, "POST", "/cuidados/99/prescripciones", `{"medicamento_id":2,"dosis_mg":7,"frecuencia_horas":24,"via":"oral",
+        "duracion_dias":3}`, 404, nil)
+}
@@ -0,0 +1,342 @@
+package http
+
+import (
+    "net/http"
+    "reflect"
+    "strconv"
+    "strings"
+    "unicode"
+
+    "github.com/go-playground/validator/v10"
+)
+
+// Languages of the API messages; Spanish, the one the handlers write in,
+// is the default.
+const (
+    langES = "es"
+    langEN = "en"
+)
+
+// message is an entry of the catalog. {param} stands for the parameter of
+// the field error: the 2 of min=2, the allowed values of oneof, a row...
+type message struct {
+    es string
+    en string
+}
+
+// catalog holds the messages by code, both those of AppError and those of
+// FieldError. Handlers already write the Spanish message of an AppError,
+// often with details such as the offending value, so those entries only
+// need the other languages. Field messages are built from the catalog in
+// every language; the min, max and len tags have a variant per kind, under
+// "tag.number" and "tag.items".
+var catalog = map[string]message{
+    // Field errors: validation tags.
+    "required":          {"es obligatorio", "is required"},
+    "required_if":       {"es obligatorio", "is required"},
+    "min":               {"debe tener al menos {param} caracteres", "must be at least {param} characters long"},
+    "min.number":        {"debe ser mayor o igual que {param}", "must be {param} or greater"},
+    "min.items":         {"debe tener al menos {param} elementos", "must have at least {param} items"},
+    "max":               {"debe tener como máximo {param} caracteres", "must be at most {param} characters long"},
+    "max.number":        {"debe ser menor o igual que {param}", "must be {param} or less"},
+    "max.items":         {"debe tener como máximo {param} elementos", "must have at most {param} items"},
+    "len":               {"debe tener {param} caracteres", "must be {param} characters long"},
+    "len.number":        {"debe ser igual a {param}", "must be {param}"},
+    "len.items":         {"debe tener {param} elementos", "must have {param} items"},
+    "gt":                {"debe ser mayor que {param}", "must be greater than {param}"},
+    "gte":               {"debe ser mayor o igual que {param}", "must be {param} or greater"},
+    "lt":                {"debe ser menor que {param}", "must be less than {param}"},
+    "lte":               {"debe ser menor o igual que {param}", "must be {param} or less"},
+    "gtefield":          {"debe ser mayor o igual que {param}", "must not be less than {param}"},
+    "oneof":             {"debe ser uno de: {param}", "must be one of: {param}"},
+    "email":             {"debe ser un e-mail válido", "must be a valid e-mail address"},
+    "url":               {"debe ser una URL válida", "must be a valid URL"},
+    "datetime":          {"debe tener el formato {param}", "must have the format {param}"},
+    "fecha_nacimiento":  {"debe ser YYYY-MM-DD, YYYY-MM o YYYY", "must be YYYY-MM-DD, YYYY-MM or YYYY"},
+    "tenant":            {"debe tener hasta 40 minúsculas, dígitos, '-' o '_'", "must be up to 40 lowercase letters, digits, '-' or '_'"},
+    "invalid":           {"no es válido", "is not valid"},
+
+    // Field errors of the JSON decoder.
+    "type":              {"debe ser de tipo {param}", "must be of type {param}"},
+    "unknown_field":     {"no es un campo admitido", "is not an accepted field"},
+
+    // Field errors written by the handlers.
+    "missing_column":    {"falta la columna", "the column is missing"},
+    "unknown":           {"no existe", "does not exist"},
+    "not_allowed":       {"valores permitidos: {param}", "allowed values: {param}"},
+    "invalid_turno":     {"hasta debe ser posterior a desde", "hasta must be later than desde"},
+    "iso11784":          {"debe ser un número ISO 11784 de 15 dígitos con un código de país o fabricante válido",
+        "must be a 15-digit ISO 11784 number with a valid country or manufacturer code"},
+    "taken":             {"ya está registrado en otra mascota", "is already registered to another mascota"},
+    "duplicate_row":     {"repite la mascota de la fila {param}", "repeats the mascota of row {param}"},
+    "duplicate_mascota": {"ya existe la mascota {param} con el mismo nombre, especie, fecha de nacimiento y e-mail de propietario",
+        "mascota {param} already has the same nombre, especie, fecha de nacimiento and owner e-mail"},
+
+    // Field errors of the business rules (see package rules).
+    "fecha_nacimiento_future":       {"no puede ser posterior a hoy", "cannot be later than today"},
+    "fecha_nacimiento_implausible":  {"supera la edad máxima de la especie", "exceeds the maximum age of the especie"},
+    "fecha_nacimiento_after_cuidado": {"es posterior a su primer cuidado", "is later than its first cuidado"},
+    "fecha_cuidado_before_birth":    {"es anterior al nacimiento de la mascota", "is earlier than the mascota's birth"},
+
+    // AppError codes. Those of readJSON and validateStruct start in
+    // lowercase, like their Spanish messages.
+    "validation_error":       {en: "invalid data"},
+    "invalid_json":           {en: "the body is not valid JSON"},
+    "json_syntax_error":      {en: "the JSON is malformed"},
+    "json_type_mismatch":     {en: "wrong data type"},
+    "json_unknown_field":     {en: "unknown field"},
+    "json_trailing_data":     {en: "the body must hold a single JSON object"},
+    "json_not_object":        {en: "the body must be a JSON object"},
+    "empty_body":             {en: "the body is empty"},
+    "body_too_large":         {en: "the body is too large"},
+    "rule_violation":         {en: "The data breaks the clinic's rules"},
+    "invalid_id":             {en: "Invalid ID"},
+    "invalid_date":           {en: "Invalid date; use YYYY-MM-DD"},
+    "invalid_datetime":       {en: "Invalid date and time; use RFC3339"},
+    "invalid_range":          {en: "hasta must be later than desde and within the allowed range"},
+    "invalid_limit":          {en: "limit must be 1..200"},
+    "invalid_offset":         {en: "offset must be >= 0"},
+    "invalid_mascota_id":     {en: "mascota_id must be a positive integer"},
+    "invalid_veterinario_id": {en: "veterinario must be a positive integer"},
+    "invalid_veterinario":    {en: "Invalid veterinario"},
+    "invalid_horario":        {en: "Invalid horario"},
+    "invalid_microchip":      {en: "Invalid microchip number"},
+    "invalid_query":          {en: "Give exactly one of microchip, tatuaje or licencia"},
+    "invalid_etapa":          {en: "etapa must be cachorro, adulto or senior"},
+    "invalid_agrupar":        {en: "agrupar takes especie, sexo or both separated by a comma"},
+    "invalid_periodo":        {en: "periodo must be mes or semana"},
+    "invalid_dias":           {en: "dias is out of range"},
+    "invalid_vencidos":       {en: "vencidos must be true or false"},
+    "invalid_tipo":           {en: "tipo must be a preventive cuidado: Vacunacion or Desparasitacion"},
+    "invalid_slot":           {en: "slot must be 30 or 60"},
+    "invalid_format":         {en: "format must be csv, xlsx or jsonl"},
+    "invalid_event":          {en: "Unknown event type"},
+    "invalid_last_event_id":  {en: "Last-Event-ID must be an event id"},
+    "invalid_tenant":         {en: "Invalid tenant"},
+    "invalid_fake_now":       {en: "X-Fake-Now must be RFC3339"},
+    "invalid_url":            {en: "url must be an absolute http or https URL"},
+    "private_url":            {en: "url must point to a public address, not localhost or a private network"},
+    "invalid_route":          {en: "Route not allowed for this medicamento"},
+    "invalid_medicamento":    {en: "medicamento must be the ID of a medicamento of the catalog"},
+    "unknown_medicamento":    {en: "The medicamento is not in the catalog"},
+    "invalid_code":           {en: "Invalid verification code"},
+    "invalid_upload":         {en: "The file could not be read"},
+    "invalid_file":           {en: "The file is not a valid CSV or XLSX"},
+    "invalid_mapping":        {en: "Invalid columnas mapping"},
+    "invalid_mode":           {en: "modo must be todo_o_nada or parcial"},
+    "invalid_dry_run":        {en: "dry_run must be true or false"},
+    "missing_file":           {en: "The file is missing (field archivo)"},
+    "missing_columns":        {en: "Required columns are missing"},
+    "empty_file":             {en: "The file is empty"},
+    "too_many_rows":          {en: "The file has too many rows"},
+    "file_too_large":         {en: "The file is too large"},
+    "unsupported_media_type": {en: "unsupported content type"},
+    "not_acceptable":         {en: "None of the accepted formats can be produced"},
+    "past_date":              {en: "Cuidados cannot be scheduled in the past."},
+    "past_time":              {en: "Pick a time at least one hour from now."},
+    "same_day":               {en: "Cuidados can only be scheduled from tomorrow on."},
+    "sunday_not_allowed":     {en: "Cuidados cannot be scheduled on Sundays. Please pick a day from Monday to Saturday."},
+    "future_date":            {en: "fecha cannot be later than now"},
+    "weight_required":        {en: "A recent weight is required to compute the dose"},
+    "allergy_conflict":       {en: "The cuidado conflicts with a recorded allergy; confirm it with confirmar_alergia"},
+    "admin_required":         {en: "Only administrators may do this"},
+    "staff_required":         {en: "Only authorized staff may do this"},
+    "tenant_forbidden":       {en: "The token only allows the events of its tenant"},
+    "invalid_token":          {en: "Invalid calendar token"},
+    "invalid_signature":      {en: "The link is invalid or has expired"},
+    "not_found":              {en: "Resource not found"},
+    "route_not_found":        {en: "The route does not exist"},
+    "method_not_allowed":     {en: "Method not allowed for this route"},
+    "document_not_found":     {en: "No document has that code"},
+    "file_not_found":         {en: "The attachment file does not exist"},
+    "thumbnail_not_found":    {en: "The attachment has no thumbnail"},
+    "duplicate_microchip":    {en: "The identification is already registered to another mascota"},
+    "duplicate_tatuaje":      {en: "The identification is already registered to another mascota"},
+    "duplicate_licencia":     {en: "The identification is already registered to another mascota"},
+    "duplicate_name":         {en: "A medicamento with that name already exists"},
+    "veterinario_busy":       {en: "The veterinario already has a cuidado at that time."},
+    "veterinario_inactive":   {en: "The veterinario is not active."},
+    "veterinario_unavailable": {en: "The veterinario does not work at that time according to their horario."},
+    "alerts_disabled":        {en: "Clinical alerts are not enabled"},
+    "attachments_disabled":   {en: "Attachments are not available on this server"},
+    "calendar_disabled":      {en: "The clinic calendar is not enabled"},
+    "documents_disabled":     {en: "Documents are not available on this server"},
+    "events_disabled":        {en: "The event stream is not enabled"},
+    "medications_disabled":   {en: "Medication is not enabled"},
+    "notifications_disabled": {en: "Notifications are not enabled"},
+    "reportes_disabled":      {en: "Reports are not enabled"},
+    "veterinarios_disabled":  {en: "The veterinario directory is not enabled"},
+    "webhooks_disabled":      {en: "Webhooks are not enabled"},
+    "weights_disabled":       {en: "Weight records are not enabled"},
+    "db_timeout":             {en: "The database took too long to answer"},
+    "internal_error":         {en: "Internal server error"},
+    "panic":                  {en: "Internal server error"},
+}
+
+// text is the message in lang with {param} replaced, "" when the catalog
+// does not have it in that language.
+func (m message) text(lang, param string) string {
+    s := m.es
+    if lang == langEN {
+        s = m.en
+    }
+    return strings.ReplaceAll(s, "{param}", param)
+}
+
+// fieldError builds the error of a field from the catalog, in Spanish.
+func fieldError(field, code, param string) FieldError {
+    return FieldError{Field: field, Code: code, Message: catalog[code].text(langES, param), param: param}
+}
+
+// negotiateLang picks the language of the response from Accept-Language:
+// the one with the highest weight among those supported, Spanish when none
+// is.
+func negotiateLang(header string) string {
+    best, bestQ := langES, 0.0
+    for _, part := range strings.Split(header, ",") {
+        tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+        q := 1.0
+        if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+            var err error
+            if q, err = strconv.ParseFloat(v, 64); err != nil {
+                continue
+            }
+        }
+        primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+        if (primary == langES || primary == langEN) && q > bestQ {
+            best, bestQ = primary, q
+        }
+    }
+    return best
+}
+
+// responseLang is the language appHandler negotiated for the response,
+// which it announces in Content-Language.
+func responseLang(w http.ResponseWriter) string {
+    if w.Header().Get("Content-Language") == langEN {
+        return langEN
+    }
+    return langES
+}
+
+// localize translates e into lang. The Spanish message of the handler is
+// kept when the catalog has no translation, e.g. for a code it does not
+// know.
+func localize(e AppError, lang string) AppError {
+    if lang == langES {
+        return e
+    }
+    if m := catalog[e.Code].text(lang, ""); m != "" {
+        e.Msg = m
+    }
+    e.Fields = localizeFields(e.Fields, lang)
+    return e
+}
+
+func localizeFields(fields []FieldError, lang string) []FieldError {
+    if lang == langES || len(fields) == 0 {
+        return fields
+    }
+    out := make([]FieldError, len(fields))
+    for i, f := range fields {
+        key := f.key
+        if key == "" {
+            key = f.Code
+        }
+        if m := catalog[key].text(lang, f.param); m != "" {
+            f.Message = m
+        }
+        out[i] = f
+    }
+    return out
+}
+
+// jsonFieldName makes the validator name fields as their JSON keys.
+func jsonFieldName(f reflect.StructField) string {
+    name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+    if name == "-" {
+        return ""
+    }
+    return name
+}
+
+func mapFieldErrors(verrs validator.ValidationErrors) []FieldError {
+    out := make([]FieldError, 0, len(verrs))
+    for _, ve := range verrs {
+        // The namespace starts with the type of the validated struct
+        // unless it is unnamed (see mascotaInput).
+        field := ve.Namespace()
+        if root, rest, ok := strings.Cut(field, "."); ok && strings.HasPrefix(ve.StructNamespace(), root+".") {
+            field = rest
+        }
+        code, key, param := ve.Tag(), ve.Tag(), ve.Param()
+        switch code {
+        case "min", "max", "len":
+            switch ve.Kind() {
+            case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8,
+                reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+                key += ".number"
+            case reflect.Slice, reflect.Array, reflect.Map:
+                key += ".items"
+            }
+        case "oneof":
+            param = strings.Join(oneofValues(param), ", ")
+        case "datetime":
+            param = layoutName(param)
+        case "gtefield":
+            param = snakeCase(param)
+        }
+        if _, ok := catalog[key]; !ok {
+            key = "invalid"
+        }
+        out = append(out, FieldError{Field: field, Code: code, Message: catalog[key].text(langES, param), key: key, param: param})
+    }
+    return out
+}
+
+// oneofValues splits the parameter of oneof, where values with spaces are
+// quoted.
+func oneofValues(param string) []string {
+    var out []string
+    for param = strings.TrimSpace(param); param != ""; param = strings.TrimSpace(param) {
+        if param[0] == '\'' {
+            if v, rest, ok := strings.Cut(param[1:], "'"); ok {
+                out, param = append(out, v), rest
+                continue
+            }
+        }
+        v, rest, _ := strings.Cut(param, " ")
+        out, param = append(out, v), rest
+    }
+    return out
+}
+
+// layoutName shows a time layout of the datetime tag the way the API
+// documents it.
+func layoutName(layout string) string {
+    switch layout {
+    case "2006-01-02T15:04:05Z07:00":
+        return "RFC3339"
+    case "2006-01-02":
+        return "YYYY-MM-DD"
+    case "15:04":
+        return "HH:MM"
+    }
+    return layout
+}
+
+// snakeCase turns the Go name of a field into its JSON key, for the tags
+// that name another field.
+func snakeCase(name string) string {
+    var b strings.Builder
+    for i, r := range name {
+        if unicode.IsUpper(r) {
+            if i > 0 {
+                b.WriteByte('_')
+            }
+            r = unicode.ToLower(r)
+        }
+        b.WriteRune(r)
+    }
+    return b.String()
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+    "reflect"
+    "testing"
+
+    "github.com/go-playground/validator/v10"
+)
+
+func TestNegotiateLang(t *testing.T) {
+    for header, want := range map[string]string{
+        "":                          langES,
+        "en":                        langEN,
+        "en-GB":                     langEN,
+        "es-CO,es;q=0.9,en;q=0.8":   langES,
+        "fr;q=1, en;q=0.5":          langEN,
+        "es;q=0.3, EN-us;q=0.7":     langEN,
+        "de, fr":                    langES,
+        "en;q=0":                    langES,
+        "en;q=abc":                  langES,
+    } {
+        if got := negotiateLang(header); got != want {
+            t.Errorf("negotiateLang(%q) = %q, want %q", header, got, want)
+        }
+    }
+}
+
+func TestOneofValues(t *testing.T) {
+    got := oneofValues("Vacunacion Desparasitacion 'Consulta Veterinaria' Bano")
+    want := []string{"Vacunacion", "Desparasitacion", "Consulta Veterinaria", "Bano"}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("oneofValues = %q, want %q", got, want)
+    }
+}
+
+func TestMapFieldErrorsNested(t *testing.T) {
+    h := NewHandlers(nil, nil)
+    in := veterinarioInput{Nombre: "Ana", Especialidades: []string{"x"}, Horario: []turnoInput{{Dia: "lunes", Desde: "9", Hasta: "12:00"}}}
+    fields := mapFieldErrors(h.validate.Struct(in).(validator.ValidationErrors))
+    if len(fields) != 2 || fields[0].Field != "especialidades[0]" || fields[1].Field != "horario[0].desde" || fields[1].Message != "debe tener el formato HH:MM" {
+        t.Fatalf("fields = %+v", fields)
+    }
+    en := localizeFields(fields, langEN)
+    if en[0].Message != "must be at least 2 characters long" || fields[0].Message != "debe tener al menos 2 caracteres" {
+        t.Errorf("localized = %+v, original = %+v", en, fields)
+    }
+}
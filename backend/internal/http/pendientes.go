@@ -0,0 +1,148 @@
+package http
+
+import (
+    "math"
+    "net/http"
+    "slices"
+    "sort"
+    "strconv"
+    "time"
+
+    "mascotas/internal/careplan"
+    "mascotas/internal/models"
+)
+
+// maxPendientesDias bounds ?dias= of GET /pendientes.
+const maxPendientesDias = 365
+
+type pendiente struct {
+    MascotaID     int64               `json:"mascota_id"`
+    MascotaNombre string              `json:"mascota_nombre"`
+    Especie       string              `json:"especie"`
+    Propietario   propietarioContacto `json:"propietario"`
+    TipoCuidado   string              `json:"tipo_cuidado"`
+    // Ultimo is the day of the last completed cuidado of the tipo.
+    Ultimo  *string `json:"ultimo"`
+    Vence   string  `json:"vence"`
+    // Dias counts from today to Vence; negative when overdue.
+    Dias    int    `json:"dias"`
+    Vencido bool   `json:"vencido"`
+    Motivo  string `json:"motivo"`
+}
+
+// Pendientes serves GET /pendientes: the vaccines and dewormings every
+// mascota is due for within ?dias= days (30 by default) according to the
+// careplan schedule, soonest first, with the owner's contact and why. The
+// overdue ones are included unless ?vencidos=false; a mascota that already
+// has a Programado cuidado of the tipo ahead is left out. especie and tipo
+// filter. Being the owners' contact, it needs the staff or admin bearer
+// token.
+func (h *Handlers) Pendientes(w http.ResponseWriter, r *http.Request) {
+    if !h.isStaff(r) {
+        writeError(w, NewForbidden("staff_required", "la lista de pendientes solo está permitida al personal autorizado"))
+        return
+    }
+    q := r.URL.Query()
+    dias := 30
+    if v := q.Get("dias"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 0 || n > maxPendientesDias {
+            writeError(w, NewBadRequest("invalid_dias", "dias debe ser un número entre 0 y "+strconv.Itoa(maxPendientesDias)))
+            return
+        }
+        dias = n
+    }
+    vencidos := true
+    if v := q.Get("vencidos"); v != "" {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            writeError(w, NewBadRequest("invalid_vencidos", "vencidos debe ser true o false"))
+            return
+        }
+        vencidos = b
+    }
+    tipos := careplan.Tipos()
+    if v := q.Get("tipo"); v != "" {
+        if !slices.Contains(tipos, v) {
+            writeError(w, NewBadRequest("invalid_tipo", "tipo debe ser uno de los cuidados preventivos: Vacunacion o Desparasitacion"))
+            return
+        }
+        tipos = []string{v}
+    }
+    now := h.now(r)
+    hoy := dayOf(now.In(h.Location))
+    limite := hoy.AddDate(0, 0, dias)
+
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    var mascotas []models.Mascota
+    err := h.Mascotas.Stream(ctx, models.MascotaFilter{Especie: q.Get("especie")}, func(m models.Mascota) error {
+        mascotas = append(mascotas, m)
+        return nil
+    })
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    out := make([]pendiente, 0)
+    for _, tipo := range tipos {
+        ultimo := make(map[int64]time.Time)
+        agendado := make(map[int64]bool)
+        err := h.Cuidados.Stream(ctx, models.CuidadoFilter{Tipo: tipo}, func(c models.Cuidado) error {
+            switch {
+            case c.Estado == models.CuidadoCompletado:
+                if d := dayOf(c.FechaCuidado.In(h.Location)); d.After(ultimo[c.MascotaID]) {
+                    ultimo[c.MascotaID] = d
+                }
+            case c.Estado == models.CuidadoProgramado && !c.FechaCuidado.Before(now):
+                agendado[c.MascotaID] = true
+            }
+            return nil
+        })
+        if err != nil {
+            writeError(w, err)
+            return
+        }
+        for _, m := range mascotas {
+            if agendado[m.ID] {
+                continue
+            }
+            p, ok := careplan.Next(m, tipo, ultimo[m.ID], h.Location)
+            if !ok || p.Vence.After(limite) {
+                continue
+            }
+            vencido := p.Vence.Before(hoy)
+            if vencido && !vencidos {
+                continue
+            }
+            item := pendiente{
+                MascotaID:     m.ID,
+                MascotaNombre: m.Nombre,
+                Especie:       m.Especie,
+                Propietario:   propietarioContacto{Nombre: m.PropietarioNombre, Email: m.PropietarioEmail, Telefono: m.PropietarioTelefono},
+                TipoCuidado:   tipo,
+                Vence:         p.Vence.Format("2006-01-02"),
+                Dias:          int(math.Round(p.Vence.Sub(hoy).Hours() / 24)),
+                Vencido:       vencido,
+                Motivo:        p.Motivo,
+            }
+            if !p.Ultimo.IsZero() {
+                u := p.Ultimo.Format("2006-01-02")
+                item.Ultimo = &u
+            }
+            out = append(out, item)
+        }
+    }
+    sort.SliceStable(out, func(i, j int) bool {
+        if out[i].Vence != out[j].Vence {
+            return out[i].Vence < out[j].Vence
+        }
+        return out[i].MascotaID < out[j].MascotaID
+    })
+    respondJSON(w, http.StatusOK, map[string]any{
+        "fecha":      hoy.Format("2006-01-02"),
+        "hasta":      limite.Format("2006-01-02"),
+        "total":      len(out),
+        "pendientes": out,
+    })
+}
@@ -0,0 +1,54 @@
+package http_test
+
+import (
+    "testing"
+
+    "mascotas/internal/models/memory"
+)
+
+// TestPendientes completes and books cuidados and checks what is left on
+// the worklist.
+func TestPendientes(t *testing.T) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    srv := newServer(r)
+    send(t, srv, "GET", "/pendientes", "", 403, nil)
+    send(t, withToken(srv, "wrong-token-00000001"), "GET", "/pendientes", "", 403, nil)
+    srv = withToken(srv, staffToken)
+    send(t, srv, "PUT", "/cuidados/1", `{"tipo_cuidado":"Vacunacion","descripcion":"Antirrábica anual","fecha_cuidado":"2030-05-20T15:00:00Z","mascota_id":1,"estado":"Completado"}`, 200, nil)
+    send(t, srv, "POST", "/mascotas/2/cuidados", `{"tipo_cuidado":"Vacunacion","descripcion":"Refuerzo","fecha_cuidado":"2030-06-06T09:00:00Z"}`, 201, nil)
+
+    type item struct {
+        MascotaID   int64   `json:"mascota_id"`
+        TipoCuidado string  `json:"tipo_cuidado"`
+        Ultimo      *string `json:"ultimo"`
+        Vence       string  `json:"vence"`
+        Dias        int     `json:"dias"`
+        Vencido     bool    `json:"vencido"`
+    }
+    var res struct {
+        Total      int    `json:"total"`
+        Pendientes []item `json:"pendientes"`
+    }
+    // Misu's vaccine is booked and Firulais' is not due for a year.
+    send(t, srv, "GET", "/pendientes?tipo=Vacunacion", "", 200, &res)
+    if res.Total != 0 {
+        t.Fatalf("vacunas = %+v", res)
+    }
+    send(t, srv, "GET", "/pendientes?tipo=Vacunacion&dias=365", "", 200, &res)
+    if res.Total != 1 {
+        t.Fatalf("vacunas en un año = %+v", res)
+    }
+    if p := res.Pendientes[0]; p.MascotaID != 1 || p.Ultimo == nil || *p.Ultimo != "2030-05-20" || p.Vence != "2031-05-20" || p.Dias != 349 || p.Vencido {
+        t.Fatalf("Firulais = %+v", p)
+    }
+    send(t, srv, "GET", "/pendientes?especie=Gato", "", 200, &res)
+    if res.Total != 1 || res.Pendientes[0].TipoCuidado != "Desparasitacion" || !res.Pendientes[0].Vencido {
+        t.Fatalf("gatos = %+v", res)
+    }
+    send(t, srv, "GET", "/pendientes?vencidos=false", "", 200, &res)
+    if res.Total != 0 {
+        t.Fatalf("sin vencidos = %+v", res)
+    }
+}
@@ -0,0 +1,249 @@
+package http
+
+import (
+    "net/http"
+    "strings"
+
+    "mascotas/internal/models"
+)
+
+// rangoEdad is a bucket of GET /reportes/edades: from Desde full years up
+// to, not including, Hasta (0 = no upper bound).
+type rangoEdad struct {
+    Rango string `json:"rango"`
+    Desde int    `json:"desde"`
+    Hasta int    `json:"hasta,omitempty"`
+    Total int    `json:"total"`
+}
+
+var rangosEdad = []rangoEdad{
+    {Rango: "0-1", Desde: 0, Hasta: 1},
+    {Rango: "1-3", Desde: 1, Hasta: 3},
+    {Rango: "3-7", Desde: 3, Hasta: 7},
+    {Rango: "7-10", Desde: 7, Hasta: 10},
+    {Rango: "10+", Desde: 10},
+}
+
+// cumplimiento is a row of GET /reportes/cumplimiento. The rates are
+// fractions in [0, 1], null when nothing they divide by happened yet.
+type cumplimiento struct {
+    models.CumplimientoTipo
+    TasaCumplimiento   *float64 `json:"tasa_cumplimiento"`
+    TasaNoPresentacion *float64 `json:"tasa_no_presentacion"`
+    TasaCancelacion    *float64 `json:"tasa_cancelacion"`
+}
+
+func ratio(n, d int) *float64 {
+    if d == 0 {
+        return nil
+    }
+    r := float64(n) / float64(d)
+    return &r
+}
+
+func newCumplimiento(c models.CumplimientoTipo) cumplimiento {
+    atendibles := c.Completados + c.NoPresentados
+    return cumplimiento{
+        CumplimientoTipo:   c,
+        TasaCumplimiento:   ratio(c.Completados, atendibles),
+        TasaNoPresentacion: ratio(c.NoPresentados, atendibles),
+        TasaCancelacion:    ratio(c.Cancelados, atendibles+c.Cancelados),
+    }
+}
+
+type conteoDia struct {
+    Dia   string `json:"dia"`
+    Total int    `json:"total"`
+}
+
+// reportesEnabled writes a 404 when the backend cannot aggregate.
+func (h *Handlers) reportesEnabled(w http.ResponseWriter) bool {
+    if h.Reportes == nil {
+        writeError(w, NewNotFound("reportes_disabled", "los reportes no están habilitados"))
+        return false
+    }
+    return true
+}
+
+// reporteFiltro reads desde, hasta (YYYY-MM-DD, inclusive) and tipo.
+func (h *Handlers) reporteFiltro(r *http.Request) (models.ReporteFiltro, error) {
+    f, err := h.cuidadoFilter(r.URL.Query())
+    if err != nil {
+        return models.ReporteFiltro{}, err
+    }
+    if !f.Desde.IsZero() && !f.Hasta.IsZero() && !f.Hasta.After(f.Desde) {
+        return models.ReporteFiltro{}, NewBadRequest("invalid_range", "hasta debe ser posterior a desde")
+    }
+    return models.ReporteFiltro{Desde: f.Desde, Hasta: f.Hasta, Tipo: f.Tipo, Location: h.Location}, nil
+}
+
+// ReporteMascotas serves GET /reportes/mascotas: how many mascotas there
+// are by ?agrupar= especie (the default), sexo or especie,sexo.
+func (h *Handlers) ReporteMascotas(w http.ResponseWriter, r *http.Request) {
+    if !h.reportesEnabled(w) {
+        return
+    }
+    porEspecie, porSexo := true, false
+    if v := r.URL.Query().Get("agrupar"); v != "" {
+        porEspecie = false
+        for _, g := range strings.Split(v, ",") {
+            switch strings.TrimSpace(g) {
+            case "especie":
+                porEspecie = true
+            case "sexo":
+                porSexo = true
+            default:
+                writeError(w, NewBadRequest("invalid_agrupar", "agrupar admite especie, sexo o ambos separados por coma"))
+                return
+            }
+        }
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    grupos, err := h.Reportes.MascotasPorGrupo(ctx, porEspecie, porSexo)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    total := 0
+    for _, g := range grupos {
+        total += g.Total
+    }
+    respondJSON(w, http.StatusOK, map[string]any{"total": total, "grupos": grupos})
+}
+
+// ReporteEdades serves GET /reportes/edades: the ages of the mascotas (of
+// ?especie=, when given) today, in full years and in the rangos of
+// rangosEdad.
+func (h *Handlers) ReporteEdades(w http.ResponseWriter, r *http.Request) {
+    if !h.reportesEnabled(w) {
+        return
+    }
+    especie := r.URL.Query().Get("especie")
+    hoy := dayOf(h.now(r).In(h.Location))
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    edades, err := h.Reportes.Edades(ctx, hoy, especie)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    rangos := make([]rangoEdad, len(rangosEdad))
+    copy(rangos, rangosEdad)
+    total := 0
+    for _, e := range edades {
+        total += e.Total
+        for i := len(rangos) - 1; i >= 0; i-- {
+            if e.Anios >= rangos[i].Desde {
+                rangos[i].Total += e.Total
+                break
+            }
+        }
+    }
+    respondJSON(w, http.StatusOK, map[string]any{
+        "fecha":    hoy.Format("2006-01-02"),
+        "especie":  especie,
+        "total":    total,
+        "rangos":   rangos,
+        "por_anio": edades,
+    })
+}
+
+// ReporteCuidados serves GET /reportes/cuidados: the cuidados per
+// ?periodo= (mes, the default, or semana) and tipo, by estado. desde,
+// hasta and tipo filter them.
+func (h *Handlers) ReporteCuidados(w http.ResponseWriter, r *http.Request) {
+    if !h.reportesEnabled(w) {
+        return
+    }
+    f, err := h.reporteFiltro(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    periodo := r.URL.Query().Get("periodo")
+    switch periodo {
+    case "":
+        periodo = models.PeriodoMes
+    case models.PeriodoMes, models.PeriodoSemana:
+    default:
+        writeError(w, NewBadRequest("invalid_periodo", "periodo debe ser mes o semana"))
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    rows, err := h.Reportes.CuidadosPorPeriodo(ctx, f, periodo)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, map[string]any{"periodo": periodo, "filas": rows})
+}
+
+// ReporteCumplimiento serves GET /reportes/cumplimiento: per tipo and in
+// total, how the cuidados dated before today ended. A cuidado still
+// Programado is a no-show. desde, hasta and tipo filter them.
+func (h *Handlers) ReporteCumplimiento(w http.ResponseWriter, r *http.Request) {
+    if !h.reportesEnabled(w) {
+        return
+    }
+    f, err := h.reporteFiltro(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    hoy := dayOf(h.now(r).In(h.Location))
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    rows, err := h.Reportes.Cumplimiento(ctx, f, hoy)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    tipos := make([]cumplimiento, 0, len(rows))
+    var total models.CumplimientoTipo
+    for _, c := range rows {
+        tipos = append(tipos, newCumplimiento(c))
+        total.Completados += c.Completados
+        total.Cancelados += c.Cancelados
+        total.NoPresentados += c.NoPresentados
+        total.Pendientes += c.Pendientes
+    }
+    respondJSON(w, http.StatusOK, map[string]any{
+        "fecha": hoy.Format("2006-01-02"),
+        "tipos": tipos,
+        "total": newCumplimiento(total),
+    })
+}
+
+// ReporteDiasSemana serves GET /reportes/dias-semana: the cuidados that
+// were not cancelled per weekday, Monday first, busiest marked. desde,
+// hasta and tipo filter them.
+func (h *Handlers) ReporteDiasSemana(w http.ResponseWriter, r *http.Request) {
+    if !h.reportesEnabled(w) {
+        return
+    }
+    f, err := h.reporteFiltro(r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    counts, err := h.Reportes.PorDiaSemana(ctx, f)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    dias := make([]conteoDia, 0, 7)
+    masOcupado := ""
+    best := 0
+    for i := 1; i <= 7; i++ {
+        d := i % 7
+        dias = append(dias, conteoDia{Dia: models.Dias[d], Total: counts[d]})
+        if counts[d] > best {
+            masOcupado, best = models.Dias[d], counts[d]
+        }
+    }
+    respondJSON(w, http.StatusOK, map[string]any{"dias": dias, "mas_ocupado": masOcupado})
+}
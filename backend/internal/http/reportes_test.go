@@ -0,0 +1,116 @@
+package http_test
+
+import (
+    "net/http"
+    "testing"
+
+    "mascotas/internal/clock"
+    apphttp "mascotas/internal/http"
+    "mascotas/internal/models/memory"
+)
+
+func newReportesServer(t *testing.T) http.Handler {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    h := apphttp.NewHandlers(r.mascotas, r.cuidados)
+    h.Clock = clock.Fixed(fixedNow)
+    h.Reportes = s.Reportes()
+    return apphttp.NewRouter(h, testConfig())
+}
+
+// TestReportes reads every report over the seed plus a few cuidados.
+func TestReportes(t *testing.T) {
+    srv := newReportesServer(t)
+    send(t, srv, "POST", "/mascotas", validMascota, 201, nil) // 3, Luna
+    for _, b := range []struct{ mascota, body string }{
+        {"2", `{"tipo_cuidado":"Vacunacion","descripcion":"Refuerzo","fecha_cuidado":"2030-06-06T09:00:00Z"}`}, // 3, Thursday
+        {"3", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-13T09:00:00Z"}`},          // 4, Thursday
+    } {
+        send(t, srv, "POST", "/mascotas/"+b.mascota+"/cuidados", b.body, 201, nil)
+    }
+    send(t, srv, "PUT", "/cuidados/2", `{"tipo_cuidado":"Bano","descripcion":"Baño medicado","fecha_cuidado":"2030-06-20T14:00:00Z","mascota_id":1,"estado":"Cancelado"}`, 200, nil)
+
+    var mascotas struct {
+        Total  int `json:"total"`
+        Grupos []struct {
+            Especie string `json:"especie"`
+            Sexo    string `json:"sexo"`
+            Total   int    `json:"total"`
+        } `json:"grupos"`
+    }
+    send(t, srv, "GET", "/reportes/mascotas?agrupar=especie,sexo", "", 200, &mascotas)
+    if mascotas.Total != 3 || len(mascotas.Grupos) != 3 || mascotas.Grupos[0].Especie != "Conejo" || mascotas.Grupos[2].Sexo != "Macho" {
+        t.Fatalf("mascotas = %+v", mascotas)
+    }
+    send(t, srv, "GET", "/reportes/mascotas?agrupar=raza", "", 400, nil)
+
+    var edades struct {
+        Total  int `json:"total"`
+        Rangos []struct {
+            Rango string `json:"rango"`
+            Total int    `json:"total"`
+        } `json:"rangos"`
+    }
+    send(t, srv, "GET", "/reportes/edades", "", 200, &edades)
+    // On 2030-06-05 Firulais is 11, Misu 8 and Luna 8.
+    if edades.Total != 3 || edades.Rangos[3].Total != 2 || edades.Rangos[4].Total != 1 {
+        t.Fatalf("edades = %+v", edades)
+    }
+
+    var cuidados struct {
+        Filas []struct {
+            Periodo    string `json:"periodo"`
+            Tipo       string `json:"tipo"`
+            Total      int    `json:"total"`
+            Cancelados int    `json:"cancelados"`
+        } `json:"filas"`
+    }
+    send(t, srv, "GET", "/reportes/cuidados", "", 200, &cuidados)
+    if f := cuidados.Filas; len(f) != 3 || f[0].Periodo != "2030-05" || f[1].Tipo != "Bano" || f[1].Total != 2 || f[1].Cancelados != 1 {
+        t.Fatalf("por mes = %+v", f)
+    }
+    send(t, srv, "GET", "/reportes/cuidados?periodo=semana&desde=2030-06-01&tipo=Bano", "", 200, &cuidados)
+    if f := cuidados.Filas; len(f) != 2 || f[0].Periodo != "2030-W24" || f[1].Periodo != "2030-W25" {
+        t.Fatalf("por semana = %+v", f)
+    }
+    send(t, srv, "GET", "/reportes/cuidados?periodo=anio", "", 400, nil)
+
+    var cumplimiento struct {
+        Tipos []struct {
+            Tipo             string   `json:"tipo"`
+            Completados      int      `json:"completados"`
+            NoPresentados    int      `json:"no_presentados"`
+            Pendientes       int      `json:"pendientes"`
+            TasaCumplimiento *float64 `json:"tasa_cumplimiento"`
+        } `json:"tipos"`
+        Total struct {
+            NoPresentados      int      `json:"no_presentados"`
+            Pendientes         int      `json:"pendientes"`
+            TasaNoPresentacion *float64 `json:"tasa_no_presentacion"`
+        } `json:"total"`
+    }
+    send(t, srv, "GET", "/reportes/cumplimiento", "", 200, &cumplimiento)
+    // The seeded vaccination on May 20th was never completed: a no-show.
+    tipos := cumplimiento.Tipos
+    if len(tipos) != 2 || tipos[0].Tipo != "Bano" || tipos[0].TasaCumplimiento != nil || tipos[0].Pendientes != 1 ||
+        tipos[1].NoPresentados != 1 || *tipos[1].TasaCumplimiento != 0 {
+        t.Fatalf("cumplimiento = %+v", tipos)
+    }
+    if tot := cumplimiento.Total; tot.NoPresentados != 1 || tot.Pendientes != 2 || *tot.TasaNoPresentacion != 1 {
+        t.Fatalf("total = %+v", tot)
+    }
+
+    var semana struct {
+        Dias []struct {
+            Dia   string `json:"dia"`
+            Total int    `json:"total"`
+        } `json:"dias"`
+        MasOcupado string `json:"mas_ocupado"`
+    }
+    send(t, srv, "GET", "/reportes/dias-semana", "", 200, &semana)
+    if len(semana.Dias) != 7 || semana.Dias[0].Dia != "lunes" || semana.Dias[3].Total != 2 || semana.MasOcupado != "jueves" {
+        t.Fatalf("dias-semana = %+v", semana)
+    }
+    send(t, srv, "GET", "/reportes/dias-semana?desde=2030-06-10&hasta=2030-06-01", "", 400, nil)
+}
@@ -1,134 +1,215 @@
 package http
 
 import (
-    "database/sql"
+    "crypto/subtle"
     "log"
     "net/http"
-    "os"
+    "net/url"
+    "sort"
     "strings"
+    "time"
+
+    "mascotas/internal/clock"
+    "mascotas/internal/config"
 )
 
 // NewRouter builds the application's HTTP handler with a simple
 // ServeMux and inlined cross-cutting concerns (CORS, logging, recovery)
 // to keep things straightforward and student-friendly.
-func NewRouter(db *sql.DB) http.Handler {
-    h := NewHandlers(db)
+func NewRouter(h *Handlers, cfg config.Config) http.Handler {
+    h.Location = cfg.Location()
+    h.QueryTimeout = cfg.DB.QueryTimeout
+    h.AllowSameDayCare = cfg.Features.SameDayCare
+    h.CalendarToken = cfg.Calendar.FeedToken
+    h.CalendarDomain = cfg.Calendar.UIDDomain
+    h.ClinicName = cfg.Clinic.Name
+    h.ClinicContact = cfg.Clinic.Contact
+    h.PublicURL = cfg.Clinic.PublicURL
+    h.AdminToken = cfg.Admin.Token
+    h.StaffToken = cfg.Admin.StaffToken
+    h.TenantTokens = cfg.Admin.TenantTokens
+    h.WebhookPrivateTargets = cfg.Webhooks.AllowPrivate
+    h.AttachmentURLTTL = cfg.Attachments.URLTTL
+    h.MaxAttachmentBytes = int64(cfg.Attachments.MaxSizeMB) << 20
+    if cfg.Attachments.SigningKey != "" {
+        h.AttachmentSigner.Key = []byte(cfg.Attachments.SigningKey)
+    }
+    // Routes use Go 1.22 patterns: method, path and {wildcards} read by
+    // pathID. Anything that matches none of them gets a JSON 404 or 405
+    // from jsonMux.
     mux := http.NewServeMux()
 
-    mux.HandleFunc("/health", h.Health)
-    mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
-        if err := db.Ping(); err != nil {
-            http.Error(w, "not ready", http.StatusServiceUnavailable)
-            return
-        }
-        w.WriteHeader(http.StatusNoContent)
-    })
-
-    // Mascotas collection
-    mux.HandleFunc("/mascotas", func(w http.ResponseWriter, r *http.Request) {
-        switch r.Method {
-        case http.MethodGet:
-            h.ListMascotas(w, r)
-        case http.MethodPost:
-            h.CreateMascota(w, r)
-        default:
-            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-        }
-    })
-
-    // Mascotas item and nested cuidados
-    mux.HandleFunc("/mascotas/", func(w http.ResponseWriter, r *http.Request) {
-        path := r.URL.Path
-        // /mascotas/{id} or /mascotas/{id}/cuidados
-        if hasSuffix(path, "/cuidados") || hasSegment(path, "/cuidados/") {
-            switch r.Method {
-            case http.MethodGet:
-                h.ListCuidadosByMascota(w, r)
-            case http.MethodPost:
-                h.CreateCuidadoForMascota(w, r)
-            default:
-                http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-            }
-            return
-        }
-        switch r.Method {
-        case http.MethodGet:
-            h.GetMascota(w, r)
-        case http.MethodPut:
-            h.UpdateMascota(w, r)
-        case http.MethodDelete:
-            h.DeleteMascota(w, r)
-        default:
-            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-        }
-    })
-
-    // Cuidados by id
-    mux.HandleFunc("/cuidados/", func(w http.ResponseWriter, r *http.Request) {
-        switch r.Method {
-        case http.MethodGet:
-            h.GetCuidado(w, r)
-        case http.MethodPut:
-            h.UpdateCuidado(w, r)
-        case http.MethodDelete:
-            h.DeleteCuidado(w, r)
-        default:
-            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-        }
-    })
+    mux.HandleFunc("GET /health", h.Health)
+    mux.HandleFunc("GET /ready", h.Ready)
+
+    // Mascotas
+    mux.HandleFunc("GET /mascotas", h.ListMascotas)
+    mux.HandleFunc("POST /mascotas", h.CreateMascota)
+    mux.HandleFunc("GET /mascotas/export", h.ExportMascotas)
+    mux.HandleFunc("POST /mascotas/import", h.ImportMascotas)
+    mux.HandleFunc("GET /mascotas/buscar", h.BuscarMascota)
+    mux.HandleFunc("GET /mascotas/{id}", h.GetMascota)
+    mux.HandleFunc("PUT /mascotas/{id}", h.UpdateMascota)
+    mux.HandleFunc("DELETE /mascotas/{id}", h.DeleteMascota)
+    mux.HandleFunc("GET /mascotas/{id}/cuidados", h.ListCuidadosByMascota)
+    mux.HandleFunc("POST /mascotas/{id}/cuidados", h.CreateCuidadoForMascota)
+    mux.HandleFunc("GET /mascotas/{id}/cuidados.ics", h.MascotaCalendar)
+    mux.HandleFunc("GET /mascotas/{id}/historial.pdf", h.HistorialPDF)
+    mux.HandleFunc("GET /mascotas/{id}/certificado-vacunacion.pdf", h.CertificadoVacunacionPDF)
+    mux.HandleFunc("GET /mascotas/{id}/adjuntos", h.ListMascotaAdjuntos)
+    mux.HandleFunc("POST /mascotas/{id}/adjuntos", h.UploadMascotaAdjunto)
+    mux.HandleFunc("GET /mascotas/{id}/alertas", h.ListAlertas)
+    mux.HandleFunc("POST /mascotas/{id}/alertas", h.CreateAlerta)
+    mux.HandleFunc("GET /mascotas/{id}/pesos", h.ListPesos)
+    mux.HandleFunc("POST /mascotas/{id}/pesos", h.CreatePeso)
+    // /mascotas/{id}/dosis?medicamento={id}
+    mux.HandleFunc("GET /mascotas/{id}/dosis", h.CalcularDosis)
+    mux.HandleFunc("GET /mascotas/{id}/medicacion", h.ListMedicacionActiva)
+
+    // Cuidados
+    mux.HandleFunc("GET /cuidados/export", h.ExportCuidados)
+    mux.HandleFunc("GET /cuidados/{id}", h.GetCuidado)
+    mux.HandleFunc("PUT /cuidados/{id}", h.UpdateCuidado)
+    mux.HandleFunc("DELETE /cuidados/{id}", h.DeleteCuidado)
+    mux.HandleFunc("GET /cuidados/{id}/adjuntos", h.ListCuidadoAdjuntos)
+    mux.HandleFunc("POST /cuidados/{id}/adjuntos", h.UploadCuidadoAdjunto)
+    mux.HandleFunc("GET /cuidados/{id}/prescripciones", h.ListPrescripcionesByCuidado)
+    mux.HandleFunc("POST /cuidados/{id}/prescripciones", h.CreatePrescripcion)
+    mux.HandleFunc("GET /cuidados/{id}/notificaciones", h.ListNotificaciones)
+
+    // Medicamento catalog and prescriptions
+    mux.HandleFunc("GET /medicamentos", h.ListMedicamentos)
+    mux.HandleFunc("POST /medicamentos", h.CreateMedicamento)
+    mux.HandleFunc("GET /medicamentos/{id}", h.GetMedicamento)
+    mux.HandleFunc("PUT /medicamentos/{id}", h.UpdateMedicamento)
+    mux.HandleFunc("GET /prescripciones/{id}", h.GetPrescripcion)
+    mux.HandleFunc("POST /prescripciones/{id}/suspender", h.SuspenderPrescripcion)
+
+    // Veterinarios
+    mux.HandleFunc("GET /veterinarios", h.ListVeterinarios)
+    mux.HandleFunc("POST /veterinarios", h.CreateVeterinario)
+    mux.HandleFunc("GET /veterinarios/{id}", h.GetVeterinario)
+    mux.HandleFunc("PUT /veterinarios/{id}", h.UpdateVeterinario)
+    mux.HandleFunc("GET /veterinarios/{id}/agenda", h.VeterinarioAgenda)
+
+    // Clinical alerts
+    mux.HandleFunc("GET /alertas/{id}", h.GetAlerta)
+    mux.HandleFunc("PUT /alertas/{id}", h.UpdateAlerta)
+    mux.HandleFunc("DELETE /alertas/{id}", h.DeleteAlerta)
+
+    // Clinic agenda across all mascotas, preventive care worklist and
+    // statistics
+    mux.HandleFunc("GET /agenda", h.Agenda)
+    mux.HandleFunc("GET /agenda/semana", h.AgendaSemana)
+    mux.HandleFunc("GET /agenda.ics", h.AgendaCalendar)
+    mux.HandleFunc("GET /pendientes", h.Pendientes)
+    mux.HandleFunc("GET /reportes/mascotas", h.ReporteMascotas)
+    mux.HandleFunc("GET /reportes/edades", h.ReporteEdades)
+    mux.HandleFunc("GET /reportes/cuidados", h.ReporteCuidados)
+    mux.HandleFunc("GET /reportes/cumplimiento", h.ReporteCumplimiento)
+    mux.HandleFunc("GET /reportes/dias-semana", h.ReporteDiasSemana)
+
+    // Attachments; contenido is the signed download link
+    mux.HandleFunc("GET /adjuntos/{id}", h.GetAdjunto)
+    mux.HandleFunc("DELETE /adjuntos/{id}", h.DeleteAdjunto)
+    mux.HandleFunc("GET /adjuntos/{id}/contenido", h.DownloadAdjunto)
+
+    // Public verification of issued documents
+    mux.HandleFunc("GET /verificar/{codigo}", h.VerificarDocumento)
+
+    // Live changes (Server-Sent Events) and webhook subscriptions
+    mux.HandleFunc("GET /eventos", h.StreamEventos)
+    mux.HandleFunc("GET /webhooks", h.ListWebhooks)
+    mux.HandleFunc("POST /webhooks", h.CreateWebhook)
+    mux.HandleFunc("GET /webhooks/{id}", h.GetWebhook)
+    mux.HandleFunc("PUT /webhooks/{id}", h.UpdateWebhook)
+    mux.HandleFunc("DELETE /webhooks/{id}", h.DeleteWebhook)
+    mux.HandleFunc("GET /webhooks/{id}/entregas", h.ListWebhookEntregas)
+    mux.HandleFunc("POST /webhooks/{id}/entregas/{entregaId}/reenviar", h.RedeliverWebhookEntrega)
+
+    shieldLiterals(mux, "/mascotas/export", "/mascotas/import", "/mascotas/buscar", "/cuidados/export")
 
     // Wrap mux with simple handler that adds CORS, logging and recovery.
-    return &appHandler{mux: mux}
+    return &appHandler{
+        mux:        jsonMux{mux},
+        origins:    cfg.CORS.AllowedOrigins,
+        requestLog: cfg.Features.RequestLog,
+        fakeNow:    cfg.Features.FakeNow,
+        adminToken: cfg.Admin.Token,
+    }
 }
 
 // appHandler is a minimal wrapper that applies CORS headers,
 // recovers from panics and logs requests.
 type appHandler struct {
-    mux *http.ServeMux
+    mux        http.Handler
+    origins    []string
+    requestLog bool
+    fakeNow    bool
+    adminToken string
 }
 
 func (a *appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-    // CORS (very permissive for dev; restrict via ALLOWED_ORIGINS)
+    // CORS (very permissive for dev when no origins or "*" are configured)
     origin := r.Header.Get("Origin")
-    allowed := os.Getenv("ALLOWED_ORIGINS")
-    if allowed == "" {
+    if len(a.origins) == 0 {
         if origin == "" {
             origin = "*"
         }
         w.Header().Set("Access-Control-Allow-Origin", origin)
     } else {
-        ok := false
-        for _, o := range strings.Split(allowed, ",") {
-            if strings.TrimSpace(o) == origin {
-                ok = true
+        for _, o := range a.origins {
+            if o == origin || (o == "*" && origin != "") {
+                w.Header().Set("Access-Control-Allow-Origin", origin)
                 break
             }
         }
-        if ok {
-            w.Header().Set("Access-Control-Allow-Origin", origin)
-        }
     }
-    w.Header().Set("Vary", "Origin")
+    w.Header().Set("Vary", "Origin, Accept-Language")
     w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-    w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+    w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Fake-Now, Last-Event-ID, Accept-Language")
     w.Header().Set("Access-Control-Allow-Credentials", "false")
     if r.Method == http.MethodOptions {
         w.WriteHeader(http.StatusNoContent)
         return
     }
 
+    // Error messages follow Accept-Language (see respondErrorJSON).
+    w.Header().Set("Content-Language", negotiateLang(r.Header.Get("Accept-Language")))
+
     // Recovery
     defer func() {
         if rec := recover(); rec != nil {
+            // Handlers abort a response already under way this way; let
+            // net/http drop the connection.
+            if rec == http.ErrAbortHandler {
+                panic(rec)
+            }
             log.Printf("panic: %v", rec)
             writeError(w, NewInternal("panic", "internal server error"))
         }
     }()
 
+    // Simulated clock for staging: only admins may move "now".
+    if v := r.Header.Get("X-Fake-Now"); v != "" && a.fakeNow {
+        if !isAdmin(r, a.adminToken) {
+            writeError(w, NewForbidden("admin_required", "X-Fake-Now solo está permitido para administradores"))
+            return
+        }
+        t, err := time.Parse(time.RFC3339, v)
+        if err != nil {
+            writeError(w, NewBadRequest("invalid_fake_now", "X-Fake-Now debe ser RFC3339"))
+            return
+        }
+        r = r.WithContext(clock.WithContext(r.Context(), clock.Fixed(t)))
+    }
+
     // Logging (capture status)
     lrw := &loggingResponseWriter{ResponseWriter: w, status: http.StatusOK}
     a.mux.ServeHTTP(lrw, r)
-    log.Printf("%s %s -> %d", r.Method, r.URL.Path, lrw.status)
+    if a.requestLog {
+        log.Printf("%s %s -> %d", r.Method, r.URL.Path, lrw.status)
+    }
 }
 
 type loggingResponseWriter struct {
@@ -141,18 +222,104 @@ func (l *loggingResponseWriter) WriteHeader(code int) {
     l.ResponseWriter.WriteHeader(code)
 }
 
-func hasSuffix(s, suf string) bool {
-    if len(s) < len(suf) {
-        return false
+// Unwrap lets http.ResponseController reach Flush and SetWriteDeadline.
+func (l *loggingResponseWriter) Unwrap() http.ResponseWriter {
+    return l.ResponseWriter
+}
+
+// isAdmin reports whether r carries the configured admin bearer token.
+func isAdmin(r *http.Request, token string) bool {
+    got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+    return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// isStaff reports whether r carries the staff or the admin token, which
+// may read the owners' contact and the identification of the mascotas.
+func (h *Handlers) isStaff(r *http.Request) bool {
+    return isAdmin(r, h.StaffToken) || isAdmin(r, h.AdminToken)
+}
+
+// tenantOf returns the tenant whose token r carries.
+func (h *Handlers) tenantOf(r *http.Request) (string, bool) {
+    for tenant, token := range h.TenantTokens {
+        if isAdmin(r, token) {
+            return tenant, true
+        }
     }
-    return s[len(s)-len(suf):] == suf
+    return "", false
 }
 
-func hasSegment(s, seg string) bool {
-    for i := 0; i+len(seg) <= len(s); i++ {
-        if s[i:i+len(seg)] == seg {
-            return true
+// jsonMux answers the requests that match no route like the handlers
+// answer errors, with a JSON AppError: 404 when no route has the path and
+// 405, with Allow, when none of its routes takes the method.
+type jsonMux struct {
+    *http.ServeMux
+}
+
+func (m jsonMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    h, pattern := m.Handler(r)
+    if pattern != "" {
+        m.ServeMux.ServeHTTP(w, r)
+        return
+    }
+    // The handler ServeMux answers with otherwise: a plain-text error or a
+    // redirect to the clean path.
+    rec := &headerRecorder{header: make(http.Header), status: http.StatusOK}
+    h.ServeHTTP(rec, r)
+    switch rec.status {
+    case http.StatusNotFound:
+        writeError(w, NewNotFound("route_not_found", "la ruta no existe"))
+    case http.StatusMethodNotAllowed:
+        writeMethodNotAllowed(w, rec.header.Get("Allow"))
+    default:
+        for k, v := range rec.header {
+            w.Header()[k] = v
         }
+        w.WriteHeader(rec.status)
     }
-    return false
 }
+
+func writeMethodNotAllowed(w http.ResponseWriter, allow string) {
+    w.Header().Set("Allow", allow)
+    writeError(w, AppError{Code: "method_not_allowed", Status: http.StatusMethodNotAllowed, Msg: "método no permitido para esta ruta"})
+}
+
+// shieldLiterals makes the fixed paths that sit beside a wildcard, such as
+// /mascotas/export next to /mascotas/{id}, answer 405 to the methods they
+// do not take instead of handing those to the wildcard route as a bad id.
+// It must run after every route is registered.
+func shieldLiterals(mux *http.ServeMux, paths ...string) {
+    methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+    for _, path := range paths {
+        var allow, shadowed []string
+        for _, m := range methods {
+            _, pattern := mux.Handler(&http.Request{Method: m, URL: &url.URL{Path: path}})
+            switch {
+            case pattern == m+" "+path:
+                allow = append(allow, m)
+                if m == http.MethodGet {
+                    allow = append(allow, http.MethodHead)
+                }
+            case strings.Contains(pattern, "{"):
+                shadowed = append(shadowed, m)
+            }
+        }
+        sort.Strings(allow)
+        for _, m := range shadowed {
+            mux.HandleFunc(m+" "+path, func(w http.ResponseWriter, r *http.Request) {
+                writeMethodNotAllowed(w, strings.Join(allow, ", "))
+            })
+        }
+    }
+}
+
+// headerRecorder keeps the status and headers of a response and drops its
+// body.
+type headerRecorder struct {
+    header http.Header
+    status int
+}
+
+func (r *headerRecorder) Header() http.Header         { return r.header }
+func (r *headerRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (r *headerRecorder) WriteHeader(code int)        { r.status = code }
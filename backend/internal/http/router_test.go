@@ -0,0 +1,426 @@
+package http_test
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "flag"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "mascotas/internal/clock"
+    "mascotas/internal/config"
+    "mascotas/internal/database"
+    apphttp "mascotas/internal/http"
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+    "mascotas/internal/testutil/pgtest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// fixedNow is a Wednesday; the scheduling rules are evaluated against it.
+var fixedNow = time.Date(2030, 6, 5, 10, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+    // Postgres returns TIMESTAMPTZ values in the local zone; pin it so the
+    // golden files do not depend on the machine running the tests.
+    time.Local = time.UTC
+    os.Exit(pgtest.Main(m))
+}
+
+type repos struct {
+    mascotas models.MascotaRepository
+    cuidados models.CuidadoRepository
+}
+
+var backends = []struct {
+    name string
+    open func(t *testing.T) repos
+}{
+    {"memory", func(t *testing.T) repos {
+        s := memory.New()
+        return repos{s.Mascotas(), s.Cuidados()}
+    }},
+    {"postgres", func(t *testing.T) repos {
+        db := pgtest.NewDB(t)
+        return repos{models.MascotaStore{DB: db.DB}, models.CuidadoStore{DB: db.DB}}
+    }},
+}
+
+func newServer(r repos) http.Handler {
+    h := apphttp.NewHandlers(r.mascotas, r.cuidados)
+    h.Clock = clock.Fixed(fixedNow)
+    return apphttp.NewRouter(h, testConfig())
+}
+
+func testConfig() config.Config {
+    cfg := config.Defaults()
+    cfg.Timezone = "UTC"
+    cfg.Features.RequestLog = false
+    cfg.Features.FakeNow = true
+    cfg.Admin.Token = adminToken
+    cfg.Admin.StaffToken = staffToken
+    cfg.Admin.TenantTokens = map[string]string{"norte": norteToken}
+    cfg.Calendar.FeedToken = calendarToken
+    cfg.Calendar.UIDDomain = "test.example"
+    return cfg
+}
+
+// withToken sends every request to h with the bearer token.
+func withToken(h http.Handler, token string) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        r.Header.Set("Authorization", "Bearer "+token)
+        h.ServeHTTP(w, r)
+    })
+}
+
+// seed loads the fixtures every golden case starts from.
+func seed(t *testing.T, r repos) {
+    t.Helper()
+    ctx := context.Background()
+    for _, m := range []*models.Mascota{
+        {Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC), Sexo: "Macho",
+            Microchip: "985112000123456"},
+        {Nombre: "Misu", Especie: "Gato", Raza: "Siames", FechaNacimiento: time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC), Sexo: "Hembra"},
+    } {
+        if err := r.mascotas.Create(ctx, m); err != nil {
+            t.Fatalf("seed mascota: %v", err)
+        }
+    }
+    for _, c := range []*models.Cuidado{
+        {TipoCuidado: "Vacunacion", Descripcion: "Antirrábica anual", FechaCuidado: time.Date(2030, 5, 20, 15, 0, 0, 0, time.UTC), MascotaID: 1},
+        {TipoCuidado: "Bano", Descripcion: "Baño medicado", FechaCuidado: time.Date(2030, 6, 20, 14, 0, 0, 0, time.UTC), MascotaID: 1},
+    } {
+        if err := r.cuidados.Create(ctx, c); err != nil {
+            t.Fatalf("seed cuidado: %v", err)
+        }
+    }
+}
+
+// importCSV has one new mascota, one invalid row, a repeat of the
+// previous row and a repeat of the seeded Firulais.
+const importCSV = "Nombre,Especie,Raza,Fecha de nacimiento,Sexo,Email\n" +
+    "Luna,Conejo,Cabeza de león,15/01/2022,Hembra,ana@example.com\n" +
+    "X,Pez,Dorado,2022-01-15,Hembra,\n" +
+    "\n" +
+    "luna ,Conejo,Belier,2022-01-15,Hembra,ANA@example.com\n" +
+    "Firulais,Perro,Criollo,2019-03-10,Macho,\n"
+
+const (
+    validMascota = `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra"}`
+    validCuidado = `{"tipo_cuidado":"Consulta Veterinaria","descripcion":"Control general","fecha_cuidado":"2030-06-06T09:00:00Z"}`
+)
+
+const (
+    adminToken    = "test-admin-token-0001"
+    staffToken    = "test-staff-token-0001"
+    norteToken    = "test-norte-token-0001"
+    calendarToken = "test-calendar-token-01"
+)
+
+var goldenCases = []struct {
+    name   string
+    method string
+    path   string
+    body   string
+    header map[string]string
+}{
+    {"health", "GET", "/health", "", nil},
+    {"ready", "GET", "/ready", "", nil},
+
+    {"list_mascotas", "GET", "/mascotas", "", nil},
+    {"list_mascotas_staff", "GET", "/mascotas", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"list_mascotas_senior", "GET", "/mascotas?etapa=senior", "", nil},
+    {"list_mascotas_adulto_gato", "GET", "/mascotas?etapa=adulto&especie=Gato", "", nil},
+    {"list_mascotas_invalid_etapa", "GET", "/mascotas?etapa=anciano", "", nil},
+    {"list_mascotas_paged", "GET", "/mascotas?limit=1&offset=1", "", nil},
+    {"list_mascotas_invalid_limit", "GET", "/mascotas?limit=500", "", nil},
+    {"list_mascotas_invalid_offset", "GET", "/mascotas?offset=-1", "", nil},
+    {"list_mascotas_filtered", "GET", "/mascotas?especie=Gato&nombre=MI", "", nil},
+    {"create_mascota", "POST", "/mascotas", validMascota, nil},
+    {"create_mascota_with_owner", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra","propietario_nombre":"Ana Pérez","propietario_email":"ana@example.com","propietario_telefono":"+57 300 000 0000"}`, map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"create_mascota_anio_nacimiento", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022","sexo":"Hembra"}`, nil},
+    {"create_mascota_mes_nacimiento", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2030-01","sexo":"Hembra"}`, nil},
+    {"create_mascota_invalid_fecha_nacimiento", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-1","sexo":"Hembra"}`, nil},
+    {"create_mascota_future_birth", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2030-06-06","sexo":"Hembra"}`, nil},
+    {"create_mascota_implausible_age", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"1995","sexo":"Hembra"}`, nil},
+    {"update_mascota_birth_after_cuidado", "PUT", "/mascotas/1", `{"nombre":"Firulais","especie":"Perro","raza":"Criollo","fecha_nacimiento":"2030-06-01","sexo":"Macho"}`, nil},
+    {"create_mascota_invalid_en", "POST", "/mascotas", `{"nombre":"L","especie":"Pez","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra"}`, map[string]string{"Accept-Language": "en-US,en;q=0.9,es;q=0.5"}},
+    {"create_mascota_invalid_es_default", "POST", "/mascotas", `{"nombre":"L","especie":"Pez","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra"}`, map[string]string{"Accept-Language": "fr-FR,fr"}},
+    {"create_mascota_future_birth_en", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2030-06-06","sexo":"Hembra"}`, map[string]string{"Accept-Language": "en"}},
+    {"create_mascota_identificacion", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra","microchip":"250 26 9604 123456","tatuaje":" ab 12 ","licencia":"bog-15"}`, map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"create_mascota_tenant", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra","tenant":"norte"}`, nil},
+    {"create_mascota_invalid_tenant", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra","tenant":"Sede Norte"}`, nil},
+    {"create_mascota_invalid_microchip", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra","microchip":"999000000000001"}`, nil},
+    {"create_mascota_duplicate_microchip", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra","microchip":"985-112-000-123-456"}`, nil},
+    {"update_mascota_duplicate_microchip", "PUT", "/mascotas/2", `{"nombre":"Misu","especie":"Gato","raza":"Siames","fecha_nacimiento":"2021-11-02","sexo":"Hembra","microchip":"985112000123456"}`, nil},
+    {"buscar_mascota_microchip", "GET", "/mascotas/buscar?microchip=985+112+000+123+456", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"buscar_mascota_admin_not_found", "GET", "/mascotas/buscar?microchip=985112000999999", "", map[string]string{"Authorization": "Bearer " + adminToken}},
+    {"buscar_mascota_requires_staff", "GET", "/mascotas/buscar?microchip=985112000123456", "", nil},
+    {"buscar_mascota_two_fields", "GET", "/mascotas/buscar?microchip=985112000123456&tatuaje=AB", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"buscar_mascota_invalid_microchip", "GET", "/mascotas/buscar?microchip=12345", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"create_mascota_invalid_owner_email", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra","propietario_email":"ana"}`, nil},
+    {"create_mascota_invalid_json", "POST", "/mascotas", `{"nombre":`, nil},
+    {"create_mascota_unknown_field", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra","color":"gris"}`, nil},
+    {"create_mascota_type_mismatch", "POST", "/mascotas", `{"nombre":5,"especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra"}`, nil},
+    {"create_mascota_two_objects", "POST", "/mascotas", `{"nombre":"Luna","especie":"Conejo","raza":"Cabeza de león","fecha_nacimiento":"2022-01-15","sexo":"Hembra"} {}`, nil},
+    {"create_mascota_array_body", "POST", "/mascotas", `[{"nombre":"Luna"}]`, nil},
+    {"create_mascota_form_body", "POST", "/mascotas", `nombre=Luna`, map[string]string{"Content-Type": "application/x-www-form-urlencoded"}},
+    {"create_cuidado_type_mismatch", "POST", "/mascotas/1/cuidados", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-07T11:00:00Z","veterinario_id":"dos"}`, nil},
+    {"create_mascota_validation_error", "POST", "/mascotas", `{"nombre":"L","especie":"Pez","raza":"Dorado","fecha_nacimiento":"15/01/2022","sexo":"Hembra"}`, nil},
+    {"mascotas_method_not_allowed", "PATCH", "/mascotas", "", nil},
+    {"import_mascotas_dry_run", "POST", "/mascotas/import?dry_run=true", importCSV, nil},
+    {"import_mascotas_all_or_nothing", "POST", "/mascotas/import", importCSV, nil},
+    {"import_mascotas_best_effort", "POST", "/mascotas/import?modo=parcial", importCSV, nil},
+    {"import_mascotas_mapping", "POST", `/mascotas/import?columnas={"nombre":"Animal","raza":"Tipo"}`, "Animal;Especie;Tipo;Fecha_Nacimiento;Sexo\nNube;Gato;Persa;2023-02-01;Hembra\n", nil},
+    {"import_mascotas_identificacion", "POST", "/mascotas/import", "Nombre,Especie,Raza,Fecha de nacimiento,Sexo,Chip,Tatuaje,Licencia\nNube,Gato,Persa,2023-02-01,Hembra,250 26 9604 123456, ab 12 ,bog-15\n", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"import_mascotas_invalid_microchip", "POST", "/mascotas/import", "Nombre,Especie,Raza,Fecha de nacimiento,Sexo,Microchip\nNube,Gato,Persa,2023-02-01,Hembra,999000000000001\n", nil},
+    {"import_mascotas_duplicate_microchip", "POST", "/mascotas/import", "Nombre,Especie,Raza,Fecha de nacimiento,Sexo,Microchip\nNube,Gato,Persa,2023-02-01,Hembra,985-112-000-123-456\n", nil},
+    {"import_mascotas_missing_columns", "POST", "/mascotas/import", "nombre,especie\nLuna,Conejo\n", nil},
+    {"import_mascotas_invalid_mode", "POST", "/mascotas/import?modo=todo", importCSV, nil},
+    {"import_mascotas_empty", "POST", "/mascotas/import", "", nil},
+    {"import_mascotas_method_not_allowed", "GET", "/mascotas/import", "", nil},
+    {"export_mascotas_csv", "GET", "/mascotas/export", "", nil},
+    {"export_mascotas_csv_staff", "GET", "/mascotas/export", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"export_mascotas_jsonl", "GET", "/mascotas/export?especie=Perro", "", map[string]string{"Accept": "application/x-ndjson"}},
+    {"export_mascotas_invalid_format", "GET", "/mascotas/export?format=pdf", "", nil},
+    {"export_mascotas_not_acceptable", "GET", "/mascotas/export", "", map[string]string{"Accept": "application/pdf"}},
+    {"export_cuidados_csv", "GET", "/cuidados/export?mascota_id=1&desde=2030-06-01&hasta=2030-06-20", "", map[string]string{"Accept": "text/csv"}},
+    {"export_cuidados_jsonl", "GET", "/cuidados/export?format=jsonl", "", nil},
+    {"export_cuidados_invalid_date", "GET", "/cuidados/export?desde=ayer", "", nil},
+    {"get_mascota", "GET", "/mascotas/1", "", nil},
+    {"get_mascota_admin", "GET", "/mascotas/1", "", map[string]string{"Authorization": "Bearer " + adminToken}},
+    {"get_mascota_not_found", "GET", "/mascotas/99", "", nil},
+    {"get_mascota_not_found_en", "GET", "/mascotas/99", "", map[string]string{"Accept-Language": "es;q=0.4, en;q=0.8"}},
+    {"get_mascota_invalid_id", "GET", "/mascotas/abc", "", nil},
+    {"update_mascota", "PUT", "/mascotas/2", validMascota, nil},
+    {"update_mascota_not_found", "PUT", "/mascotas/99", validMascota, nil},
+    {"delete_mascota", "DELETE", "/mascotas/2", "", nil},
+    {"delete_mascota_not_found", "DELETE", "/mascotas/99", "", nil},
+
+    {"list_cuidados", "GET", "/mascotas/1/cuidados", "", nil},
+    {"list_cuidados_invalid_id", "GET", "/mascotas/x/cuidados", "", nil},
+    {"list_cuidados_trailing_segment", "GET", "/mascotas/5/cuidados/xyz", "", nil},
+    {"get_mascota_zero_id", "GET", "/mascotas/0", "", nil},
+    {"get_cuidado_invalid_id", "GET", "/cuidados/1x", "", nil},
+    {"unknown_route", "GET", "/propietarios", "", nil},
+    {"create_cuidado", "POST", "/mascotas/1/cuidados", validCuidado, nil},
+    {"create_cuidado_mascota_not_found", "POST", "/mascotas/99/cuidados", validCuidado, nil},
+    {"create_cuidado_validation_error", "POST", "/mascotas/1/cuidados", `{"tipo_cuidado":"Peluqueria","descripcion":"x","fecha_cuidado":"mañana"}`, nil},
+    {"create_cuidado_past_date", "POST", "/mascotas/1/cuidados", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-04T09:00:00Z"}`, nil},
+    {"create_cuidado_same_day", "POST", "/mascotas/1/cuidados", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-05T16:00:00Z"}`, nil},
+    {"create_cuidado_sunday", "POST", "/mascotas/1/cuidados", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-09T10:00:00Z"}`, nil},
+    {"cuidados_method_not_allowed", "PATCH", "/mascotas/1/cuidados", "", nil},
+    {"get_cuidado", "GET", "/cuidados/1", "", nil},
+    {"get_cuidado_not_found", "GET", "/cuidados/99", "", nil},
+    {"update_cuidado", "PUT", "/cuidados/2", `{"tipo_cuidado":"Desparasitacion","descripcion":"Dosis oral","fecha_cuidado":"2030-06-07T11:00:00Z","mascota_id":2}`, nil},
+    {"update_cuidado_sunday", "PUT", "/cuidados/2", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-09T11:00:00Z","mascota_id":1}`, nil},
+    {"update_cuidado_not_found", "PUT", "/cuidados/99", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-07T11:00:00Z","mascota_id":1}`, nil},
+    {"list_notificaciones_disabled", "GET", "/cuidados/1/notificaciones", "", nil},
+    {"complete_past_cuidado", "PUT", "/cuidados/1", `{"tipo_cuidado":"Vacunacion","descripcion":"Antirrábica anual","fecha_cuidado":"2030-05-20T15:00:00Z","mascota_id":1,"estado":"Completado"}`, nil},
+    {"update_cuidado_invalid_estado", "PUT", "/cuidados/2", `{"tipo_cuidado":"Bano","descripcion":"Baño","fecha_cuidado":"2030-06-20T14:00:00Z","mascota_id":1,"estado":"Hecho"}`, nil},
+    {"list_webhooks_disabled", "GET", "/webhooks", "", nil},
+    {"eventos_disabled", "GET", "/eventos", "", nil},
+    {"mascota_calendar", "GET", "/mascotas/1/cuidados.ics", "", nil},
+    {"mascota_calendar_empty", "GET", "/mascotas/2/cuidados.ics", "", nil},
+    {"mascota_calendar_not_found", "GET", "/mascotas/99/cuidados.ics", "", nil},
+    {"agenda_calendar", "GET", "/agenda.ics?token=" + calendarToken, "", nil},
+    {"agenda_calendar_invalid_token", "GET", "/agenda.ics?token=nope", "", nil},
+    {"historial_pdf_disabled", "GET", "/mascotas/1/historial.pdf", "", nil},
+    {"verificar_disabled", "GET", "/verificar/ABCD-EFGH-JK", "", nil},
+    {"mascota_adjuntos_disabled", "GET", "/mascotas/1/adjuntos", "", nil},
+    {"adjunto_contenido_disabled", "GET", "/adjuntos/1/contenido?expira=0&firma=x", "", nil},
+    {"medicamentos_disabled", "GET", "/medicamentos", "", nil},
+    {"mascota_medicacion_disabled", "GET", "/mascotas/1/medicacion", "", nil},
+    {"agenda_default_today", "GET", "/agenda", "", nil},
+    {"agenda_range", "GET", "/agenda?desde=2030-05-20&hasta=2030-05-22", "", nil},
+    {"agenda_filtered", "GET", "/agenda?desde=2030-05-01&hasta=2030-05-31&tipo=Bano", "", nil},
+    {"agenda_range_too_large", "GET", "/agenda?desde=2030-05-01&hasta=2030-07-31", "", nil},
+    {"agenda_invalid_veterinario", "GET", "/agenda?veterinario=x", "", nil},
+    {"agenda_semana", "GET", "/agenda/semana?fecha=2030-06-20&slot=30", "", nil},
+    {"agenda_semana_invalid_slot", "GET", "/agenda/semana?slot=45", "", nil},
+    {"veterinarios_disabled", "GET", "/veterinarios", "", nil},
+    {"veterinario_agenda_disabled", "GET", "/veterinarios/1/agenda", "", nil},
+    {"create_cuidado_veterinarios_disabled", "POST", "/mascotas/1/cuidados", `{"tipo_cuidado":"Consulta Veterinaria","descripcion":"Control general","fecha_cuidado":"2030-06-06T09:00:00Z","veterinario_id":1}`, nil},
+    {"mascota_alertas_disabled", "GET", "/mascotas/1/alertas", "", nil},
+    {"reportes_disabled", "GET", "/reportes/cumplimiento", "", nil},
+    {"pendientes", "GET", "/pendientes?dias=60", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"pendientes_vencidos_false", "GET", "/pendientes?vencidos=false&dias=365&tipo=Vacunacion", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"pendientes_invalid_dias", "GET", "/pendientes?dias=-1", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"pendientes_forbidden", "GET", "/pendientes", "", nil},
+    {"pendientes_invalid_tipo", "GET", "/pendientes?tipo=Bano", "", map[string]string{"Authorization": "Bearer " + staffToken}},
+    {"reportes_unknown", "GET", "/reportes/ventas", "", nil},
+    {"mascota_pesos_disabled", "POST", "/mascotas/1/pesos", `{"peso_kg":12.5}`, nil},
+    {"delete_cuidado", "DELETE", "/cuidados/1", "", nil},
+    {"delete_cuidado_not_found", "DELETE", "/cuidados/99", "", nil},
+
+    {"fake_now_moves_schedule", "POST", "/mascotas/1/cuidados", validCuidado, map[string]string{"Authorization": "Bearer " + adminToken, "X-Fake-Now": "2030-06-08T10:00:00Z"}},
+    {"fake_now_requires_admin", "POST", "/mascotas/1/cuidados", validCuidado, map[string]string{"X-Fake-Now": "2030-06-08T10:00:00Z"}},
+    {"fake_now_invalid", "POST", "/mascotas/1/cuidados", validCuidado, map[string]string{"Authorization": "Bearer " + adminToken, "X-Fake-Now": "mañana"}},
+}
+
+// TestGolden drives the router end to end for every endpoint and compares
+// status and body with testdata/golden/<case>.json. Both backends must
+// produce exactly the same responses. Run with -update to regenerate.
+func TestGolden(t *testing.T) {
+    for _, b := range backends {
+        t.Run(b.name, func(t *testing.T) {
+            for _, tc := range goldenCases {
+                t.Run(tc.name, func(t *testing.T) {
+                    r := b.open(t)
+                    seed(t, r)
+                    req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+                    if tc.body != "" {
+                        req.Header.Set("Content-Type", "application/json")
+                    }
+                    for k, v := range tc.header {
+                        req.Header.Set(k, v)
+                    }
+                    rec := httptest.NewRecorder()
+                    newServer(r).ServeHTTP(rec, req)
+                    checkGolden(t, tc.name, rec)
+                })
+            }
+        })
+    }
+}
+
+func checkGolden(t *testing.T, name string, rec *httptest.ResponseRecorder) {
+    t.Helper()
+    got := struct {
+        Status int `json:"status"`
+        Body   any `json:"body"`
+    }{Status: rec.Code}
+    if raw := rec.Body.Bytes(); len(raw) > 0 {
+        if err := json.Unmarshal(raw, &got.Body); err != nil {
+            got.Body = string(raw)
+        }
+    }
+    out, err := json.MarshalIndent(got, "", "  ")
+    if err != nil {
+        t.Fatal(err)
+    }
+    out = append(out, '\n')
+
+    path := filepath.Join("testdata", "golden", name+".json")
+    if *update {
+        if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(path, out, 0o644); err != nil {
+            t.Fatal(err)
+        }
+        return
+    }
+    want, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatalf("missing golden file (run go test -update): %v", err)
+    }
+    if !bytes.Equal(out, want) {
+        t.Errorf("response differs from %s\n--- got\n%s--- want\n%s", path, out, want)
+    }
+}
+
+// TestMethodNotAllowed checks the Allow header of the 405s, including the
+// fixed paths next to an {id} route.
+func TestMethodNotAllowed(t *testing.T) {
+    s := memory.New()
+    srv := newServer(repos{s.Mascotas(), s.Cuidados()})
+    for _, tc := range []struct {
+        method, path, allow string
+    }{
+        {"PATCH", "/mascotas", "GET, HEAD, POST"},
+        {"POST", "/mascotas/1", "DELETE, GET, HEAD, PUT"},
+        {"GET", "/mascotas/import", "POST"},
+        {"DELETE", "/mascotas/export", "GET, HEAD"},
+        {"PUT", "/cuidados/export", "GET, HEAD"},
+        {"GET", "/prescripciones/1/suspender", "POST"},
+    } {
+        rec := httptest.NewRecorder()
+        srv.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+        if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != tc.allow || rec.Header().Get("Content-Type") != "application/json" {
+            t.Errorf("%s %s: %d, Allow %q, %s; want 405, Allow %q", tc.method, tc.path, rec.Code, rec.Header().Get("Allow"), rec.Header().Get("Content-Type"), tc.allow)
+        }
+    }
+}
+
+// fakePool's ping waits for a connection when the pool is saturated, as
+// a real one does.
+type fakePool struct {
+    stats  database.PoolStats
+    pinged bool
+}
+
+func (p *fakePool) PingContext(ctx context.Context) error {
+    p.pinged = true
+    if p.stats.Saturated {
+        <-ctx.Done()
+        return ctx.Err()
+    }
+    return nil
+}
+
+func (p *fakePool) Stats() database.PoolStats { return p.stats }
+
+func TestReady(t *testing.T) {
+    for _, tc := range []struct {
+        saturated bool
+        code      int
+        status    string
+        pinged    bool
+    }{
+        {false, http.StatusOK, "ready", true},
+        {true, http.StatusServiceUnavailable, "pool_saturated", false},
+    } {
+        s := memory.New()
+        h := apphttp.NewHandlers(s.Mascotas(), s.Cuidados())
+        pool := &fakePool{stats: database.PoolStats{MaxOpen: 2, Open: 2, InUse: 2, Saturated: tc.saturated}}
+        h.Pool = pool
+        rec := httptest.NewRecorder()
+        apphttp.NewRouter(h, testConfig()).ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+        var body struct {
+            Status string `json:"status"`
+        }
+        json.Unmarshal(rec.Body.Bytes(), &body)
+        if rec.Code != tc.code || body.Status != tc.status || pool.pinged != tc.pinged {
+            t.Errorf("saturated %v: %d %q, pinged %v; want %d %q, pinged %v", tc.saturated, rec.Code, body.Status, pool.pinged, tc.code, tc.status, tc.pinged)
+        }
+    }
+}
+
+// TestMascotaPrivateFields checks that the owner's contact and the
+// identification of a mascota are only shown to the staff.
+func TestMascotaPrivateFields(t *testing.T) {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    srv := newServer(r)
+    private := []string{"propietario_nombre", "propietario_email", "propietario_telefono", "microchip", "tatuaje", "licencia"}
+    for _, token := range []string{"", "wrong-token-00000001", staffToken, adminToken} {
+        staff := token == staffToken || token == adminToken
+        for _, path := range []string{"/mascotas/1", "/mascotas?nombre=fir", "/mascotas/export?nombre=fir&format=jsonl"} {
+            req := httptest.NewRequest("GET", path, nil)
+            if token != "" {
+                req.Header.Set("Authorization", "Bearer "+token)
+            }
+            rec := httptest.NewRecorder()
+            srv.ServeHTTP(rec, req)
+            body := strings.TrimPrefix(strings.TrimSuffix(strings.TrimSpace(rec.Body.String()), "]"), "[")
+            var m map[string]any
+            if err := json.Unmarshal([]byte(body), &m); err != nil {
+                t.Fatalf("%s: %v: %s", path, err, rec.Body)
+            }
+            for _, f := range private {
+                if _, ok := m[f]; ok != staff {
+                    t.Errorf("%s with token %q: %s present %v, want %v", path, token, f, ok, staff)
+                }
+            }
+        }
+    }
+}
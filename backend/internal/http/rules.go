@@ -0,0 +1,52 @@
+package http
+
+import (
+    "context"
+    "net/http"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/rules"
+)
+
+// ruleError reports the violations of the business rules as a 422 whose
+// fields carry the codes of the rules; it is nil when there are none.
+func ruleError(viols []rules.Violation) error {
+    if len(viols) == 0 {
+        return nil
+    }
+    fields := make([]FieldError, 0, len(viols))
+    for _, v := range viols {
+        fields = append(fields, FieldError{Field: v.Field, Code: v.Code, Message: v.Message})
+    }
+    return AppError{Code: "rule_violation", Status: http.StatusUnprocessableEntity, Msg: "Los datos no cumplen las reglas de la clínica", Fields: fields}
+}
+
+// primerCuidado is the day, in the clinic's time zone, of the earliest
+// cuidado of the mascota that is not cancelled; zero when it has none.
+func (h *Handlers) primerCuidado(ctx context.Context, mascotaID int64) (time.Time, error) {
+    list, err := h.Cuidados.ListByMascota(ctx, mascotaID)
+    if err != nil {
+        return time.Time{}, err
+    }
+    var first time.Time
+    for _, c := range list {
+        if c.Estado != models.CuidadoCancelado && (first.IsZero() || c.FechaCuidado.Before(first)) {
+            first = c.FechaCuidado
+        }
+    }
+    if first.IsZero() {
+        return first, nil
+    }
+    return dayOf(first.In(h.Location)), nil
+}
+
+// checkCuidadoRules loads the mascota of c and checks c against
+// CuidadoRules.
+func (h *Handlers) checkCuidadoRules(ctx context.Context, c *models.Cuidado) error {
+    m, err := h.Mascotas.Get(ctx, c.MascotaID)
+    if err != nil {
+        return err
+    }
+    return ruleError(h.CuidadoRules.Check(rules.CuidadoCheck{Cuidado: *c, Mascota: *m, Dia: dayOf(c.FechaCuidado.In(h.Location))}))
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+    "net/http"
+    "time"
+)
+
+// minLeadTime is how far ahead a same-day cuidado must be scheduled.
+const minLeadTime = time.Hour
+
+// scheduleRule checks a requested care time t against now. Both are
+// already expressed in the clinic's time zone.
+type scheduleRule func(t, now time.Time) error
+
+// scheduleRules devuelve las reglas de negocio de agenda, en orden:
+// - No se permiten fechas anteriores al día actual.
+// - El mismo día solo se permite si la clínica lo habilita, y con al
+//   menos una hora de anticipación; si no, se agenda desde mañana.
+// - Solo lunes a sábado (no domingos).
+func scheduleRules(allowSameDay bool) []scheduleRule {
+    return []scheduleRule{
+        func(t, now time.Time) error {
+            if dayOf(t).Before(dayOf(now)) {
+                return NewBadRequest("past_date", "No es posible registrar cuidados en fechas anteriores a la actual.")
+            }
+            return nil
+        },
+        func(t, now time.Time) error {
+            if !dayOf(t).Equal(dayOf(now)) {
+                return nil
+            }
+            if !allowSameDay {
+                return NewBadRequest("same_day", "Solo puede programar cuidados a partir del día siguiente.")
+            }
+            if t.Before(now.Add(minLeadTime)) {
+                return NewBadRequest("past_time", "Debe seleccionar una hora al menos una hora después de la hora actual.")
+            }
+            return nil
+        },
+        func(t, now time.Time) error {
+            if t.Weekday() == time.Sunday {
+                return NewBadRequest("sunday_not_allowed", "No es posible registrar cuidados los días domingo. Por favor seleccione un día entre lunes y sábado.")
+            }
+            return nil
+        },
+    }
+}
+
+// validateCareSchedule returns the first rule violated by t, if any.
+func validateCareSchedule(t, now time.Time, loc *time.Location, allowSameDay bool) error {
+    t, now = t.In(loc), now.In(loc)
+    for _, rule := range scheduleRules(allowSameDay) {
+        if err := rule(t, now); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (h *Handlers) validateSchedule(r *http.Request, t time.Time) error {
+    return validateCareSchedule(t, h.now(r), h.Location, h.AllowSameDayCare)
+}
+
+// dayOf truncates t to midnight in its own location.
+func dayOf(t time.Time) time.Time {
+    y, m, d := t.Date()
+    return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
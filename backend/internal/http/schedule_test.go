@@ -0,0 +1,50 @@
+package http
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestValidateCareSchedule(t *testing.T) {
+    bogota, err := time.LoadLocation("America/Bogota")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Wednesday 2030-06-05 10:00 in Bogotá.
+    now := time.Date(2030, 6, 5, 10, 0, 0, 0, bogota)
+
+    tests := []struct {
+        name         string
+        t            time.Time
+        allowSameDay bool
+        want         string
+    }{
+        {"tomorrow", time.Date(2030, 6, 6, 9, 0, 0, 0, bogota), false, ""},
+        {"yesterday", time.Date(2030, 6, 4, 9, 0, 0, 0, bogota), false, "past_date"},
+        {"yesterday with same day allowed", time.Date(2030, 6, 4, 9, 0, 0, 0, bogota), true, "past_date"},
+        {"later today", time.Date(2030, 6, 5, 15, 0, 0, 0, bogota), false, "same_day"},
+        {"later today allowed", time.Date(2030, 6, 5, 15, 0, 0, 0, bogota), true, ""},
+        {"exactly one hour ahead", time.Date(2030, 6, 5, 11, 0, 0, 0, bogota), true, ""},
+        {"less than one hour ahead", time.Date(2030, 6, 5, 10, 59, 0, 0, bogota), true, "past_time"},
+        {"earlier today", time.Date(2030, 6, 5, 8, 0, 0, 0, bogota), true, "past_time"},
+        {"sunday", time.Date(2030, 6, 9, 10, 0, 0, 0, bogota), false, "sunday_not_allowed"},
+        // 2030-06-06 02:00 UTC is still Wednesday evening in Bogotá.
+        {"same day in clinic zone", time.Date(2030, 6, 6, 2, 0, 0, 0, time.UTC), false, "same_day"},
+    }
+    for _, tc := range tests {
+        t.Run(tc.name, func(t *testing.T) {
+            err := validateCareSchedule(tc.t, now, bogota, tc.allowSameDay)
+            if tc.want == "" {
+                if err != nil {
+                    t.Fatalf("unexpected error %v", err)
+                }
+                return
+            }
+            var app AppError
+            if !errors.As(err, &app) || app.Code != tc.want {
+                t.Fatalf("got %v, want %s", err, tc.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,274 @@
+package http
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// maxAgendaDays bounds the range of GET /veterinarios/{id}/agenda.
+const maxAgendaDays = 93
+
+type turnoInput struct {
+    Dia   string `json:"dia" validate:"required,oneof=domingo lunes martes miercoles jueves viernes sabado"`
+    Desde string `json:"desde" validate:"required,datetime=15:04"`
+    Hasta string `json:"hasta" validate:"required,datetime=15:04"`
+}
+
+type veterinarioInput struct {
+    Nombre         string       `json:"nombre" validate:"required,min=2,max=100"`
+    Email          string       `json:"email" validate:"omitempty,email,max=254"`
+    Telefono       string       `json:"telefono" validate:"omitempty,max=30"`
+    Especialidades []string     `json:"especialidades" validate:"dive,min=2,max=60"`
+    Horario        []turnoInput `json:"horario" validate:"dive"`
+    Activo         *bool        `json:"activo"`
+}
+
+func (h *Handlers) decodeVeterinario(w http.ResponseWriter, r *http.Request) (*models.Veterinario, error) {
+    var in veterinarioInput
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        return nil, err
+    }
+    v := &models.Veterinario{Nombre: in.Nombre, Email: in.Email, Telefono: in.Telefono, Especialidades: in.Especialidades,
+        Horario: make([]models.Turno, 0, len(in.Horario)), Activo: true}
+    if v.Especialidades == nil {
+        v.Especialidades = []string{}
+    }
+    var fields []FieldError
+    for i, t := range in.Horario {
+        turno := models.Turno{Dia: t.Dia, Desde: t.Desde, Hasta: t.Hasta}
+        if !turno.Valid() {
+            fields = append(fields, fieldError(fmt.Sprintf("horario[%d]", i), "invalid_turno", ""))
+        }
+        v.Horario = append(v.Horario, turno)
+    }
+    if len(fields) > 0 {
+        return nil, AppError{Code: "invalid_horario", Status: http.StatusBadRequest, Msg: "Horario inválido", Fields: fields}
+    }
+    if in.Activo != nil {
+        v.Activo = *in.Activo
+    }
+    return v, nil
+}
+
+// veterinariosEnabled writes a 404 when the backend has no staff
+// directory.
+func (h *Handlers) veterinariosEnabled(w http.ResponseWriter) bool {
+    if h.Veterinarios == nil {
+        writeError(w, NewNotFound("veterinarios_disabled", "el directorio de veterinarios no está habilitado"))
+        return false
+    }
+    return true
+}
+
+// checkVeterinario verifies that the veterinario can take a cuidado of
+// tipo at t: it exists, is active, works then according to its horario
+// and has no other booking that overlaps. exclude is the cuidado being
+// rescheduled, 0 for a new one.
+func (h *Handlers) checkVeterinario(ctx context.Context, id int64, t time.Time, tipo string, exclude int64) error {
+    if h.Veterinarios == nil {
+        return NewNotFound("veterinarios_disabled", "el directorio de veterinarios no está habilitado")
+    }
+    v, err := h.Veterinarios.Get(ctx, id)
+    if errors.Is(err, models.ErrNotFound) {
+        return AppError{Code: "invalid_veterinario", Status: http.StatusBadRequest, Msg: "Veterinario inválido",
+            Fields: []FieldError{fieldError("veterinario_id", "unknown", "")}}
+    }
+    if err != nil {
+        return err
+    }
+    if !v.Activo {
+        return NewConflict("veterinario_inactive", fmt.Sprintf("%s no está activo.", v.Nombre))
+    }
+    d := models.DuracionCuidado(tipo)
+    local := t.In(h.Location)
+    if !v.Atiende(local, d) {
+        return NewConflict("veterinario_unavailable", fmt.Sprintf("%s no atiende el %s a las %s según su horario.",
+            v.Nombre, models.Dias[local.Weekday()], local.Format("15:04")))
+    }
+    // No cuidado lasts a day, so earlier ones cannot reach t. The store
+    // checks again, under a lock, when the cuidado is written.
+    f := models.CuidadoFilter{VeterinarioID: id, Desde: t.Add(-24 * time.Hour), Hasta: t.Add(d)}
+    nuevo := models.Cuidado{TipoCuidado: tipo, FechaCuidado: t}
+    var busy *models.Cuidado
+    err = h.Cuidados.Stream(ctx, f, func(c models.Cuidado) error {
+        if c.ID != exclude && c.Estado != models.CuidadoCancelado && c.Overlaps(nuevo) {
+            busy = &c
+            return errStopStream
+        }
+        return nil
+    })
+    if err != nil && !errors.Is(err, errStopStream) {
+        return err
+    }
+    if busy != nil {
+        return h.veterinarioBusy(v, *busy)
+    }
+    return nil
+}
+
+func (h *Handlers) veterinarioBusy(v *models.Veterinario, busy models.Cuidado) error {
+    return NewConflict("veterinario_busy", fmt.Sprintf("%s ya tiene el cuidado %d (%s) a las %s.",
+        v.Nombre, busy.ID, models.NombreCuidado(busy.TipoCuidado), busy.FechaCuidado.In(h.Location).Format("15:04")))
+}
+
+// bookingError explains a *models.BookingConflict, the veterinario taken
+// by a concurrent request between checkVeterinario and the write, like
+// checkVeterinario does; other errors are returned as they are.
+func (h *Handlers) bookingError(ctx context.Context, err error, veterinarioID int64) error {
+    var conflict *models.BookingConflict
+    if !errors.As(err, &conflict) || h.Veterinarios == nil {
+        return err
+    }
+    v, getErr := h.Veterinarios.Get(ctx, veterinarioID)
+    if getErr != nil {
+        return getErr
+    }
+    return h.veterinarioBusy(v, conflict.Cuidado)
+}
+
+var errStopStream = errors.New("stop")
+
+func (h *Handlers) ListVeterinarios(w http.ResponseWriter, r *http.Request) {
+    if !h.veterinariosEnabled(w) {
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    list, err := h.Veterinarios.List(ctx)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, list)
+}
+
+func (h *Handlers) CreateVeterinario(w http.ResponseWriter, r *http.Request) {
+    if !h.veterinariosEnabled(w) {
+        return
+    }
+    v, err := h.decodeVeterinario(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Veterinarios.Create(ctx, v); err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusCreated, v)
+}
+
+func (h *Handlers) GetVeterinario(w http.ResponseWriter, r *http.Request) {
+    if !h.veterinariosEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "veterinario")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    v, err := h.Veterinarios.Get(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, v)
+}
+
+// UpdateVeterinario serves PUT /veterinarios/{id}. A new horario applies
+// to later bookings; the cuidados already assigned are kept.
+func (h *Handlers) UpdateVeterinario(w http.ResponseWriter, r *http.Request) {
+    if !h.veterinariosEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "veterinario")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    v, err := h.decodeVeterinario(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    v.ID = id
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Veterinarios.Update(ctx, v); err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, v)
+}
+
+// agendaItem is a booking with the time its slot ends.
+type agendaItem struct {
+    models.Cuidado
+    Fin time.Time `json:"fin"`
+}
+
+// VeterinarioAgenda serves GET /veterinarios/{id}/agenda: the cuidados
+// assigned between the desde and hasta days (YYYY-MM-DD, inclusive; from
+// today for a week by default), oldest first. Cancelled ones are left out
+// unless ?estado= asks for them; ?tipo= filters too.
+func (h *Handlers) VeterinarioAgenda(w http.ResponseWriter, r *http.Request) {
+    if !h.veterinariosEnabled(w) {
+        return
+    }
+    id, err := pathID(r, "id", "veterinario")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    f, err := h.cuidadoFilter(r.URL.Query())
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    f.VeterinarioID = id
+    if f.Desde.IsZero() {
+        f.Desde = dayOf(h.now(r).In(h.Location))
+    }
+    if f.Hasta.IsZero() {
+        f.Hasta = f.Desde.AddDate(0, 0, 7)
+    }
+    if !f.Hasta.After(f.Desde) || f.Hasta.After(f.Desde.AddDate(0, 0, maxAgendaDays)) {
+        writeError(w, NewBadRequest("invalid_range", "hasta debe ser posterior a desde y el rango no puede superar "+strconv.Itoa(maxAgendaDays)+" días"))
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    v, err := h.Veterinarios.Get(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    items := make([]agendaItem, 0)
+    err = h.Cuidados.Stream(ctx, f, func(c models.Cuidado) error {
+        if f.Estado == "" && c.Estado == models.CuidadoCancelado {
+            return nil
+        }
+        items = append(items, agendaItem{c, c.FechaCuidado.Add(models.DuracionCuidado(c.TipoCuidado))})
+        return nil
+    })
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, map[string]any{
+        "veterinario": v,
+        "desde":       f.Desde.Format("2006-01-02"),
+        "hasta":       f.Hasta.AddDate(0, 0, -1).Format("2006-01-02"),
+        "cuidados":    items,
+    })
+}
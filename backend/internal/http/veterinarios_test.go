@@ -0,0 +1,120 @@
+package http_test
+
+import (
+    "context"
+    "net/http"
+    "strconv"
+    "testing"
+
+    "mascotas/internal/clock"
+    apphttp "mascotas/internal/http"
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+)
+
+// veterinarioLog is an in-memory models.VeterinarioRepository.
+type veterinarioLog map[int64]models.Veterinario
+
+func (l veterinarioLog) Create(ctx context.Context, v *models.Veterinario) error {
+    v.ID = int64(len(l) + 1)
+    v.CreadoEn = fixedNow
+    l[v.ID] = *v
+    return nil
+}
+
+func (l veterinarioLog) Get(ctx context.Context, id int64) (*models.Veterinario, error) {
+    v, ok := l[id]
+    if !ok {
+        return nil, models.ErrNotFound
+    }
+    return &v, nil
+}
+
+func (l veterinarioLog) List(ctx context.Context) ([]models.Veterinario, error) {
+    out := make([]models.Veterinario, 0, len(l))
+    for id := int64(1); id <= int64(len(l)); id++ {
+        out = append(out, l[id])
+    }
+    return out, nil
+}
+
+func (l veterinarioLog) Update(ctx context.Context, v *models.Veterinario) error {
+    old, ok := l[v.ID]
+    if !ok {
+        return models.ErrNotFound
+    }
+    v.CreadoEn = old.CreadoEn
+    l[v.ID] = *v
+    return nil
+}
+
+func newVetServer(t *testing.T) http.Handler {
+    s := memory.New()
+    r := repos{s.Mascotas(), s.Cuidados()}
+    seed(t, r)
+    h := apphttp.NewHandlers(r.mascotas, r.cuidados)
+    h.Clock = clock.Fixed(fixedNow)
+    h.Veterinarios = veterinarioLog{}
+    return apphttp.NewRouter(h, testConfig())
+}
+
+// TestVeterinarioAvailability books cuidados against a morning schedule
+// and checks the per-vet agenda.
+func TestVeterinarioAvailability(t *testing.T) {
+    srv := newVetServer(t)
+    send(t, srv, "POST", "/veterinarios", `{"nombre":"Dra. Gómez","especialidades":["dermatología"],
+        "horario":[{"dia":"jueves","desde":"09:00","hasta":"13:00"},{"dia":"viernes","desde":"14:00","hasta":"18:00"}]}`, 201, nil)
+    send(t, srv, "POST", "/veterinarios", `{"nombre":"Dr. Ruiz","horario":[{"dia":"jueves","desde":"12:00","hasta":"09:00"}]}`, 400, nil)
+    send(t, srv, "POST", "/veterinarios", `{"nombre":"Dr. Ruiz","activo":false,"horario":[{"dia":"jueves","desde":"09:00","hasta":"18:00"}]}`, 201, nil)
+
+    book := func(fecha, tipo string, vet, status int) {
+        t.Helper()
+        body := `{"tipo_cuidado":"` + tipo + `","descripcion":"Control","fecha_cuidado":"` + fecha + `","veterinario_id":` + strconv.Itoa(vet) + `}`
+        send(t, srv, "POST", "/mascotas/2/cuidados", body, status, nil)
+    }
+    book("2030-06-06T09:00:00Z", "Consulta Veterinaria", 1, 201) // cuidado 3
+    book("2030-06-06T09:15:00Z", "Vacunacion", 1, 409)           // overlaps 09:00-09:30
+    book("2030-06-06T09:30:00Z", "Vacunacion", 1, 201)           // cuidado 4
+    book("2030-06-06T12:30:00Z", "Bano", 1, 409)                 // ends after 13:00
+    book("2030-06-07T10:00:00Z", "Vacunacion", 1, 409)           // friday morning
+    book("2030-06-06T10:00:00Z", "Vacunacion", 2, 409)           // inactive
+    book("2030-06-06T10:00:00Z", "Vacunacion", 9, 400)
+
+    // Cancelling frees the slot, and rescheduling checks the vet again.
+    send(t, srv, "PUT", "/cuidados/3", `{"tipo_cuidado":"Consulta Veterinaria","descripcion":"Control","fecha_cuidado":"2030-06-06T09:00:00Z","mascota_id":2,"estado":"Cancelado"}`, 200, nil)
+    book("2030-06-06T09:00:00Z", "Vacunacion", 1, 201) // cuidado 5
+    send(t, srv, "PUT", "/cuidados/5", `{"tipo_cuidado":"Vacunacion","descripcion":"Control","fecha_cuidado":"2030-06-06T09:20:00Z","mascota_id":2}`, 409, nil)
+    // Reactivating the cancelled one, unchanged otherwise, takes its slot
+    // back, which is now cuidado 5's.
+    send(t, srv, "PUT", "/cuidados/3", `{"tipo_cuidado":"Consulta Veterinaria","descripcion":"Control","fecha_cuidado":"2030-06-06T09:00:00Z","mascota_id":2,"estado":"Programado"}`, 409, nil)
+    send(t, srv, "PUT", "/cuidados/1", `{"tipo_cuidado":"Vacunacion","descripcion":"Antirrábica","fecha_cuidado":"2030-06-06T11:00:00Z","mascota_id":1,"veterinario_id":1}`, 200, nil)
+
+    var agenda struct {
+        Veterinario models.Veterinario `json:"veterinario"`
+        Desde       string             `json:"desde"`
+        Hasta       string             `json:"hasta"`
+        Cuidados    []struct {
+            ID            int64  `json:"id"`
+            VeterinarioID int64  `json:"veterinario_id"`
+            Fin           string `json:"fin"`
+        } `json:"cuidados"`
+    }
+    send(t, srv, "GET", "/veterinarios/1/agenda?desde=2030-06-06&hasta=2030-06-06", "", 200, &agenda)
+    if agenda.Veterinario.Nombre != "Dra. Gómez" || agenda.Desde != "2030-06-06" || agenda.Hasta != "2030-06-06" || len(agenda.Cuidados) != 3 {
+        t.Fatalf("agenda = %+v", agenda)
+    }
+    for i, want := range []int64{5, 4, 1} {
+        if c := agenda.Cuidados[i]; c.ID != want || c.VeterinarioID != 1 {
+            t.Fatalf("agenda[%d] = %+v, want cuidado %d", i, c, want)
+        }
+    }
+    if agenda.Cuidados[1].Fin != "2030-06-06T09:50:00Z" {
+        t.Fatalf("fin = %s", agenda.Cuidados[1].Fin)
+    }
+    send(t, srv, "GET", "/veterinarios/1/agenda?desde=2030-06-06&hasta=2030-06-06&estado=Cancelado", "", 200, &agenda)
+    if len(agenda.Cuidados) != 1 || agenda.Cuidados[0].ID != 3 {
+        t.Fatalf("cancelled = %+v", agenda.Cuidados)
+    }
+    send(t, srv, "GET", "/veterinarios/1/agenda?desde=2030-06-06&hasta=2031-06-06", "", 400, nil)
+    send(t, srv, "GET", "/veterinarios/9/agenda", "", 404, nil)
+}
@@ -0,0 +1,219 @@
+package http
+
+import (
+    "net/http"
+    "net/url"
+    "slices"
+    "strings"
+
+    "mascotas/internal/models"
+    "mascotas/internal/webhook"
+)
+
+type webhookInput struct {
+    URL         string   `json:"url" validate:"required,url,max=2000"`
+    Eventos     []string `json:"eventos" validate:"required,min=1,dive,required"`
+    Descripcion string   `json:"descripcion" validate:"max=200"`
+    // Secreto is optional: a random one is generated on create and the
+    // current one is kept on update.
+    Secreto string `json:"secreto" validate:"omitempty,min=16,max=200"`
+    Activo  *bool  `json:"activo"`
+}
+
+// decodeWebhook reads and validates the request body into a Webhook.
+func (h *Handlers) decodeWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, error) {
+    var in webhookInput
+    if err := h.decodeJSON(w, r, &in); err != nil {
+        return nil, err
+    }
+    u, err := url.Parse(in.URL)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return nil, NewBadRequest("invalid_url", "url debe ser una URL http o https absoluta")
+    }
+    if !h.WebhookPrivateTargets && webhook.CheckTarget(u) != nil {
+        return nil, NewBadRequest("private_url", "url debe apuntar a una dirección pública, no a localhost ni a una red privada")
+    }
+    eventos := make([]string, 0, len(in.Eventos))
+    for _, e := range in.Eventos {
+        if e != "*" && !slices.Contains(models.EventTypes, e) {
+            return nil, AppError{Code: "invalid_event", Status: http.StatusBadRequest, Msg: "evento desconocido: " + e,
+                Fields: []FieldError{fieldError("eventos", "not_allowed", "* o uno de "+strings.Join(models.EventTypes, ", "))}}
+        }
+        if !slices.Contains(eventos, e) {
+            eventos = append(eventos, e)
+        }
+    }
+    activo := true
+    if in.Activo != nil {
+        activo = *in.Activo
+    }
+    return &models.Webhook{URL: in.URL, Eventos: eventos, Descripcion: in.Descripcion, Secreto: in.Secreto, Activo: activo}, nil
+}
+
+// webhooksAllowed writes a 404 when the backend does not support webhooks
+// and a 403 when r does not carry the admin token: a subscription receives
+// the owners' data and makes the API call any URL.
+func (h *Handlers) webhooksAllowed(w http.ResponseWriter, r *http.Request) bool {
+    if h.Webhooks == nil {
+        writeError(w, NewNotFound("webhooks_disabled", "los webhooks no están habilitados"))
+        return false
+    }
+    if !isAdmin(r, h.AdminToken) {
+        writeError(w, NewForbidden("admin_required", "los webhooks solo están permitidos a los administradores"))
+        return false
+    }
+    return true
+}
+
+func (h *Handlers) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+    if !h.webhooksAllowed(w, r) {
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    list, err := h.Webhooks.List(ctx)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, list)
+}
+
+// CreateWebhook returns the signing secret; it is never shown again.
+func (h *Handlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+    if !h.webhooksAllowed(w, r) {
+        return
+    }
+    hook, err := h.decodeWebhook(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    if hook.Secreto == "" {
+        if hook.Secreto, err = webhook.NewSecret(); err != nil {
+            writeError(w, err)
+            return
+        }
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Webhooks.Create(ctx, hook); err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusCreated, hook)
+}
+
+func (h *Handlers) GetWebhook(w http.ResponseWriter, r *http.Request) {
+    if !h.webhooksAllowed(w, r) {
+        return
+    }
+    id, err := pathID(r, "id", "webhook")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    hook, err := h.Webhooks.Get(ctx, id)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, hook)
+}
+
+func (h *Handlers) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+    if !h.webhooksAllowed(w, r) {
+        return
+    }
+    id, err := pathID(r, "id", "webhook")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    hook, err := h.decodeWebhook(w, r)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    hook.ID = id
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Webhooks.Update(ctx, hook); err != nil {
+        writeError(w, err)
+        return
+    }
+    hook.Secreto = ""
+    respondJSON(w, http.StatusOK, hook)
+}
+
+func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+    if !h.webhooksAllowed(w, r) {
+        return
+    }
+    id, err := pathID(r, "id", "webhook")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    if err := h.Webhooks.Delete(ctx, id); err != nil {
+        writeError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookEntregas is the delivery history, newest first, with every
+// attempt of each entrega.
+func (h *Handlers) ListWebhookEntregas(w http.ResponseWriter, r *http.Request) {
+    if !h.webhooksAllowed(w, r) {
+        return
+    }
+    id, err := pathID(r, "id", "webhook")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    limit, offset, err := parsePagination(r.URL.Query())
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    list, err := h.Webhooks.ListEntregas(ctx, id, limit, offset)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusOK, list)
+}
+
+// RedeliverWebhookEntrega queues the entrega's event again and answers
+// 202 with the new entrega.
+func (h *Handlers) RedeliverWebhookEntrega(w http.ResponseWriter, r *http.Request) {
+    if !h.webhooksAllowed(w, r) {
+        return
+    }
+    id, err := pathID(r, "id", "webhook")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    entregaID, err := pathID(r, "entregaId", "entrega")
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    ctx, cancel := h.dbContext(r)
+    defer cancel()
+    e, err := h.Webhooks.Redeliver(ctx, id, entregaID)
+    if err != nil {
+        writeError(w, err)
+        return
+    }
+    respondJSON(w, http.StatusAccepted, e)
+}
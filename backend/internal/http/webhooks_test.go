@@ -0,0 +1,66 @@
+package http_test
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    apphttp "mascotas/internal/http"
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+)
+
+// webhookRepo is an in-memory models.WebhookRepository with just what the
+// tests call.
+type webhookRepo struct {
+    models.WebhookRepository
+    hooks []models.Webhook
+}
+
+func (r *webhookRepo) Create(ctx context.Context, w *models.Webhook) error {
+    w.ID = int64(len(r.hooks) + 1)
+    r.hooks = append(r.hooks, *w)
+    return nil
+}
+
+func (r *webhookRepo) List(ctx context.Context) ([]models.Webhook, error) {
+    return r.hooks, nil
+}
+
+func TestWebhooksRequireAdminAndPublicURL(t *testing.T) {
+    s := memory.New()
+    h := apphttp.NewHandlers(s.Mascotas(), s.Cuidados())
+    repo := &webhookRepo{}
+    h.Webhooks = repo
+    srv := apphttp.NewRouter(h, testConfig())
+
+    for _, tc := range []struct {
+        name, method, body, token string
+        status                    int
+    }{
+        {"list without token", "GET", "", "", http.StatusForbidden},
+        {"list with staff token", "GET", "", staffToken, http.StatusForbidden},
+        {"create without token", "POST", `{"url":"https://crm.example.com/hook","eventos":["*"]}`, "", http.StatusForbidden},
+        {"create to loopback", "POST", `{"url":"http://127.0.0.1:5432/","eventos":["*"]}`, adminToken, http.StatusBadRequest},
+        {"create to metadata", "POST", `{"url":"http://169.254.169.254/latest/meta-data","eventos":["*"]}`, adminToken, http.StatusBadRequest},
+        {"create to localhost", "POST", `{"url":"http://localhost/hook","eventos":["*"]}`, adminToken, http.StatusBadRequest},
+        {"create", "POST", `{"url":"https://crm.example.com/hook","eventos":["*"]}`, adminToken, http.StatusCreated},
+        {"list", "GET", "", adminToken, http.StatusOK},
+    } {
+        req := httptest.NewRequest(tc.method, "/webhooks", strings.NewReader(tc.body))
+        req.Header.Set("Content-Type", "application/json")
+        if tc.token != "" {
+            req.Header.Set("Authorization", "Bearer "+tc.token)
+        }
+        rec := httptest.NewRecorder()
+        srv.ServeHTTP(rec, req)
+        if rec.Code != tc.status {
+            t.Errorf("%s: status %d, want %d: %s", tc.name, rec.Code, tc.status, rec.Body)
+        }
+    }
+    if len(repo.hooks) != 1 {
+        t.Errorf("stored %d webhooks, want 1", len(repo.hooks))
+    }
+}
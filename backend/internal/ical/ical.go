@@ -0,0 +1,153 @@
+// Package ical writes RFC 5545 iCalendar feeds.
+package ical
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// Event statuses (RFC 5545 §3.8.1.11).
+const (
+    StatusConfirmed = "CONFIRMED"
+    StatusCancelled = "CANCELLED"
+)
+
+// Calendar is a VCALENDAR with its VEVENTs.
+type Calendar struct {
+    ProdID string
+    // Name is shown by clients as the subscription title (X-WR-CALNAME).
+    Name string
+    // Refresh is the polling interval suggested to subscribed clients.
+    Refresh time.Duration
+    Events  []Event
+}
+
+// Event is one VEVENT. UID must be stable across exports and Sequence
+// must grow on every change, or clients will duplicate or ignore updates.
+type Event struct {
+    UID         string
+    Stamp       time.Time
+    Start       time.Time
+    Duration    time.Duration
+    Summary     string
+    Description string
+    Sequence    int
+    Status      string
+}
+
+// Write encodes c with CRLF line endings and lines folded at 75 octets.
+func (c Calendar) Write(w io.Writer) error {
+    lw := &lineWriter{w: bufio.NewWriter(w)}
+    lw.line("BEGIN:VCALENDAR")
+    lw.line("VERSION:2.0")
+    lw.line("PRODID:" + c.ProdID)
+    lw.line("CALSCALE:GREGORIAN")
+    lw.line("METHOD:PUBLISH")
+    if c.Name != "" {
+        lw.line("X-WR-CALNAME:" + Escape(c.Name))
+    }
+    if c.Refresh > 0 {
+        d := Duration(c.Refresh)
+        lw.line("REFRESH-INTERVAL;VALUE=DURATION:" + d)
+        lw.line("X-PUBLISHED-TTL:" + d)
+    }
+    for _, e := range c.Events {
+        lw.line("BEGIN:VEVENT")
+        lw.line("UID:" + e.UID)
+        lw.line("DTSTAMP:" + DateTime(e.Stamp))
+        lw.line("DTSTART:" + DateTime(e.Start))
+        if e.Duration > 0 {
+            lw.line("DURATION:" + Duration(e.Duration))
+        }
+        lw.line("SUMMARY:" + Escape(e.Summary))
+        if e.Description != "" {
+            lw.line("DESCRIPTION:" + Escape(e.Description))
+        }
+        lw.line(fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+        if e.Status != "" {
+            lw.line("STATUS:" + e.Status)
+        }
+        lw.line("END:VEVENT")
+    }
+    lw.line("END:VCALENDAR")
+    if lw.err != nil {
+        return lw.err
+    }
+    return lw.w.Flush()
+}
+
+// DateTime formats t as a UTC DATE-TIME ("20300520T150000Z").
+func DateTime(t time.Time) string {
+    return t.UTC().Format("20060102T150405Z")
+}
+
+// Duration formats d as a DURATION value ("PT1H30M"). Seconds are
+// dropped; cuidados are scheduled to the minute.
+func Duration(d time.Duration) string {
+    d = d.Truncate(time.Minute)
+    var b strings.Builder
+    b.WriteString("P")
+    if days := d / (24 * time.Hour); days > 0 {
+        fmt.Fprintf(&b, "%dD", days)
+        d -= days * 24 * time.Hour
+    }
+    if d == 0 {
+        if b.Len() == 1 {
+            return "PT0M"
+        }
+        return b.String()
+    }
+    b.WriteString("T")
+    if h := d / time.Hour; h > 0 {
+        fmt.Fprintf(&b, "%dH", h)
+        d -= h * time.Hour
+    }
+    if m := d / time.Minute; m > 0 {
+        fmt.Fprintf(&b, "%dM", m)
+    }
+    return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// Escape escapes a TEXT value (RFC 5545 §3.3.11).
+func Escape(s string) string {
+    return escaper.Replace(s)
+}
+
+const maxLine = 75
+
+type lineWriter struct {
+    w   *bufio.Writer
+    err error
+}
+
+// line writes one content line, folding it into 75-octet chunks without
+// splitting a UTF-8 sequence. Continuation lines start with a space,
+// which counts towards their length.
+func (lw *lineWriter) line(s string) {
+    if lw.err != nil {
+        return
+    }
+    limit := maxLine
+    for len(s) > limit {
+        cut := limit
+        for cut > 0 && !utf8.RuneStart(s[cut]) {
+            cut--
+        }
+        lw.write(s[:cut] + "\r\n ")
+        s = s[cut:]
+        limit = maxLine - 1
+    }
+    lw.write(s + "\r\n")
+}
+
+func (lw *lineWriter) write(s string) {
+    if lw.err == nil {
+        _, lw.err = lw.w.WriteString(s)
+    }
+}
@@ -0,0 +1,104 @@
+package ical
+
+import (
+    "strings"
+    "testing"
+    "time"
+    "unicode/utf8"
+)
+
+func TestWrite(t *testing.T) {
+    start := time.Date(2030, 5, 20, 10, 0, 0, 0, time.FixedZone("COT", -5*3600))
+    c := Calendar{
+        ProdID:  "-//Mascotas//Cuidados//ES",
+        Name:    "Firulais",
+        Refresh: time.Hour,
+        Events: []Event{{
+            UID:         "cuidado-1@example.org",
+            Stamp:       time.Date(2030, 5, 1, 0, 0, 0, 0, time.UTC),
+            Start:       start,
+            Duration:    20 * time.Minute,
+            Summary:     "Vacunación — Firulais",
+            Description: "Rabia; refuerzo, anual\nTraer carnet",
+            Sequence:    2,
+            Status:      StatusCancelled,
+        }},
+    }
+    var b strings.Builder
+    if err := c.Write(&b); err != nil {
+        t.Fatal(err)
+    }
+    got := b.String()
+    want := strings.Join([]string{
+        "BEGIN:VCALENDAR",
+        "VERSION:2.0",
+        "PRODID:-//Mascotas//Cuidados//ES",
+        "CALSCALE:GREGORIAN",
+        "METHOD:PUBLISH",
+        "X-WR-CALNAME:Firulais",
+        "REFRESH-INTERVAL;VALUE=DURATION:PT1H",
+        "X-PUBLISHED-TTL:PT1H",
+        "BEGIN:VEVENT",
+        "UID:cuidado-1@example.org",
+        "DTSTAMP:20300501T000000Z",
+        "DTSTART:20300520T150000Z",
+        "DURATION:PT20M",
+        "SUMMARY:Vacunación — Firulais",
+        `DESCRIPTION:Rabia\; refuerzo\, anual\nTraer carnet`,
+        "SEQUENCE:2",
+        "STATUS:CANCELLED",
+        "END:VEVENT",
+        "END:VCALENDAR",
+        "",
+    }, "\r\n")
+    if got != want {
+        t.Fatalf("got:\n%q\nwant:\n%q", got, want)
+    }
+}
+
+func TestFolding(t *testing.T) {
+    summary := strings.Repeat("ñ", 100)
+    c := Calendar{ProdID: "x", Events: []Event{{UID: "u", Summary: summary}}}
+    var b strings.Builder
+    if err := c.Write(&b); err != nil {
+        t.Fatal(err)
+    }
+    var unfolded []string
+    for _, l := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+        if len(l) > 75 {
+            t.Errorf("line of %d octets: %q", len(l), l)
+        }
+        if !utf8.ValidString(l) {
+            t.Errorf("line splits a UTF-8 sequence: %q", l)
+        }
+        if strings.HasPrefix(l, " ") {
+            unfolded[len(unfolded)-1] += l[1:]
+            continue
+        }
+        unfolded = append(unfolded, l)
+    }
+    found := false
+    for _, l := range unfolded {
+        if l == "SUMMARY:"+summary {
+            found = true
+        }
+    }
+    if !found {
+        t.Fatalf("unfolded output lost the summary: %q", unfolded)
+    }
+}
+
+func TestDuration(t *testing.T) {
+    for d, want := range map[time.Duration]string{
+        0:                             "PT0M",
+        15 * time.Minute:              "PT15M",
+        time.Hour:                     "PT1H",
+        90 * time.Minute:              "PT1H30M",
+        24 * time.Hour:                "P1D",
+        26*time.Hour + 5*time.Minute:  "P1DT2H5M",
+    } {
+        if got := Duration(d); got != want {
+            t.Errorf("Duration(%s) = %q, want %q", d, got, want)
+        }
+    }
+}
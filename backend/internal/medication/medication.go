@@ -0,0 +1,124 @@
+// Package medication holds the clinical rules of prescriptions: the dose
+// range for a weight and the warnings a new or active prescription raises
+// against the others of the same mascota.
+package medication
+
+import (
+    "fmt"
+    "math"
+    "slices"
+    "strings"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// Kinds of models.Advertencia.
+const (
+    WarningInteraction = "interaccion"
+    WarningAllergy     = "alergia"
+    WarningSpecies     = "especie"
+    WarningDuplicate   = "duplicado"
+    WarningDose        = "dosis"
+)
+
+// Dose is the range of one administration of a medicamento for a weight.
+// Cantidad is the same range in units of the presentation, when the
+// medicamento has a concentration.
+type Dose struct {
+    MedicamentoID int64    `json:"medicamento_id"`
+    PesoKg        float64  `json:"peso_kg"`
+    MinMg         float64  `json:"dosis_min_mg"`
+    MaxMg         float64  `json:"dosis_max_mg"`
+    CantidadMin   *float64 `json:"cantidad_min,omitempty"`
+    CantidadMax   *float64 `json:"cantidad_max,omitempty"`
+    Unidad        string   `json:"unidad,omitempty"`
+}
+
+// DoseFor computes the dose range of m for a mascota weighing pesoKg.
+// Amounts are rounded to hundredths.
+func DoseFor(m models.Medicamento, pesoKg float64) Dose {
+    d := Dose{MedicamentoID: m.ID, PesoKg: pesoKg, MinMg: round(m.DosisMinMgKg * pesoKg), MaxMg: round(m.DosisMaxMgKg * pesoKg)}
+    if m.ConcentracionMg != nil && *m.ConcentracionMg > 0 {
+        lo, hi := round(d.MinMg / *m.ConcentracionMg), round(d.MaxMg / *m.ConcentracionMg)
+        d.CantidadMin, d.CantidadMax, d.Unidad = &lo, &hi, m.Unidad
+    }
+    return d
+}
+
+func round(v float64) float64 {
+    return math.Round(v*100) / 100
+}
+
+// Check returns the warnings for p, a prescription for a mascota of
+// especie, taken from desde to hasta:
+//   - an active clinical alert records an allergy to it;
+//   - the medicamento is contraindicated for the especie;
+//   - the dose is outside the range for pesoKg (skipped when pesoKg is 0);
+//   - another prescription of the same principio activo or grupo was
+//     suspended because of an adverse reaction;
+//   - another prescription taken on any of those days has the same
+//     principio activo, or interacts with it.
+//
+// others may include p itself, which is ignored. Every prescription must
+// carry its Medicamento.
+func Check(especie string, p models.Prescripcion, others []models.Prescripcion, alertas []models.AlertaClinica, pesoKg float64, desde, hasta time.Time) []models.Advertencia {
+    m := p.Medicamento
+    out := make([]models.Advertencia, 0)
+    for _, a := range alertas {
+        if a.AfectaMedicamento(*m) {
+            id := a.ID
+            out = append(out, models.Advertencia{Tipo: WarningAllergy, AlertaID: &id,
+                Mensaje: fmt.Sprintf("Alergia registrada (%s) a %s: %s", a.Severidad, a.Alergeno, a.Descripcion)})
+        }
+    }
+    if containsFold(m.EspeciesContraindicadas, especie) {
+        out = append(out, models.Advertencia{Tipo: WarningSpecies,
+            Mensaje: fmt.Sprintf("%s está contraindicado en la especie %s.", m.Nombre, especie)})
+    }
+    if pesoKg > 0 {
+        d := DoseFor(*m, pesoKg)
+        if p.DosisMg < d.MinMg || p.DosisMg > d.MaxMg {
+            out = append(out, models.Advertencia{Tipo: WarningDose,
+                Mensaje: fmt.Sprintf("La dosis de %g mg está fuera del rango de %g a %g mg para %g kg.", p.DosisMg, d.MinMg, d.MaxMg, pesoKg)})
+        }
+    }
+    for _, o := range others {
+        if o.ID == p.ID {
+            continue
+        }
+        id := o.ID
+        om := o.Medicamento
+        if o.ReaccionAdversa && related(*m, *om) {
+            out = append(out, models.Advertencia{Tipo: WarningAllergy, PrescripcionID: &id,
+                Mensaje: fmt.Sprintf("La mascota tuvo una reacción adversa a %s (%s).", om.Nombre, om.PrincipioActivo)})
+        }
+        if !o.Solapa(desde, hasta) {
+            continue
+        }
+        switch {
+        case strings.EqualFold(m.PrincipioActivo, om.PrincipioActivo):
+            out = append(out, models.Advertencia{Tipo: WarningDuplicate, PrescripcionID: &id,
+                Mensaje: fmt.Sprintf("Ya recibe %s en %s.", m.PrincipioActivo, om.Nombre)})
+        case interacts(*m, *om) || interacts(*om, *m):
+            out = append(out, models.Advertencia{Tipo: WarningInteraction, PrescripcionID: &id,
+                Mensaje: fmt.Sprintf("%s interactúa con %s.", m.Nombre, om.Nombre)})
+        }
+    }
+    return out
+}
+
+// related reports whether a and b share the principio activo or grupo.
+func related(a, b models.Medicamento) bool {
+    return strings.EqualFold(a.PrincipioActivo, b.PrincipioActivo) ||
+        (a.Grupo != "" && strings.EqualFold(a.Grupo, b.Grupo))
+}
+
+// interacts reports whether a lists the principio activo or grupo of b.
+func interacts(a, b models.Medicamento) bool {
+    return containsFold(a.Interacciones, b.PrincipioActivo) || (b.Grupo != "" && containsFold(a.Interacciones, b.Grupo))
+}
+
+func containsFold(list []string, s string) bool {
+    return slices.ContainsFunc(list, func(v string) bool { return strings.EqualFold(v, s) })
+}
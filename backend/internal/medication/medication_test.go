@@ -0,0 +1,96 @@
+package medication
+
+import (
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+func TestDoseFor(t *testing.T) {
+    conc := 50.0
+    m := models.Medicamento{ID: 3, DosisMinMgKg: 10, DosisMaxMgKg: 12.5, ConcentracionMg: &conc, Unidad: "comprimido"}
+    d := DoseFor(m, 12.3)
+    if d.MinMg != 123 || d.MaxMg != 153.75 {
+        t.Fatalf("mg = %g..%g, want 123..153.75", d.MinMg, d.MaxMg)
+    }
+    if d.CantidadMin == nil || *d.CantidadMin != 2.46 || *d.CantidadMax != 3.08 || d.Unidad != "comprimido" {
+        t.Fatalf("cantidad = %+v", d)
+    }
+    m.ConcentracionMg = nil
+    if d := DoseFor(m, 4); d.CantidadMin != nil || d.Unidad != "" {
+        t.Fatalf("without concentration: %+v", d)
+    }
+}
+
+func TestCheck(t *testing.T) {
+    day := time.Date(2030, 6, 5, 0, 0, 0, 0, time.UTC)
+    amoxi := &models.Medicamento{ID: 1, Nombre: "Amoxicilina 250", PrincipioActivo: "amoxicilina", Grupo: "penicilinas",
+        DosisMinMgKg: 10, DosisMaxMgKg: 20}
+    penicilina := &models.Medicamento{ID: 2, Nombre: "Penicilina G", PrincipioActivo: "bencilpenicilina", Grupo: "Penicilinas"}
+    meloxicam := &models.Medicamento{ID: 3, Nombre: "Meloxicam", PrincipioActivo: "meloxicam", Grupo: "AINE",
+        Interacciones: []string{"corticoides", "AINE"}, EspeciesContraindicadas: []string{"Conejo"}}
+    prednisona := &models.Medicamento{ID: 4, Nombre: "Prednisona", PrincipioActivo: "prednisona", Grupo: "corticoides"}
+
+    activa := func(id int64, m *models.Medicamento) models.Prescripcion {
+        return models.Prescripcion{ID: id, Medicamento: m, FechaInicio: day.AddDate(0, 0, -2), FechaFin: day.AddDate(0, 0, 3)}
+    }
+    suspended := day.AddDate(0, -1, 0)
+    reaccion := models.Prescripcion{ID: 10, Medicamento: penicilina, FechaInicio: day.AddDate(0, -1, -5), FechaFin: day.AddDate(0, -1, 5),
+        SuspendidaEn: &suspended, ReaccionAdversa: true}
+    terminada := models.Prescripcion{ID: 11, Medicamento: prednisona, FechaInicio: day.AddDate(0, 0, -10), FechaFin: day.AddDate(0, 0, -1)}
+
+    p := models.Prescripcion{ID: 20, Medicamento: amoxi, DosisMg: 300}
+    got := Check("Perro", p, []models.Prescripcion{p, reaccion, activa(12, amoxi), terminada}, nil, 10, day, day)
+    want := []string{WarningDose, WarningAllergy, WarningDuplicate}
+    if len(got) != len(want) {
+        t.Fatalf("warnings = %+v, want kinds %v", got, want)
+    }
+    for i, w := range got {
+        if w.Tipo != want[i] {
+            t.Fatalf("warning %d = %+v, want %s", i, w, want[i])
+        }
+    }
+    if *got[1].PrescripcionID != 10 || *got[2].PrescripcionID != 12 {
+        t.Fatalf("warnings point to the wrong prescriptions: %+v", got)
+    }
+
+    // Interactions are found from either side, and finished prescriptions
+    // do not interact.
+    p = models.Prescripcion{ID: 21, Medicamento: prednisona}
+    got = Check("Conejo", p, []models.Prescripcion{activa(13, meloxicam)}, nil, 0, day, day)
+    if len(got) != 1 || got[0].Tipo != WarningInteraction {
+        t.Fatalf("interaction = %+v", got)
+    }
+    p = models.Prescripcion{ID: 22, Medicamento: meloxicam}
+    got = Check("Conejo", p, []models.Prescripcion{terminada}, nil, 0, day, day)
+    if len(got) != 1 || got[0].Tipo != WarningSpecies {
+        t.Fatalf("species = %+v", got)
+    }
+
+    // A prescription that starts later in the course is checked too.
+    p = models.Prescripcion{ID: 24, Medicamento: prednisona}
+    siguiente := models.Prescripcion{ID: 14, Medicamento: meloxicam, FechaInicio: day.AddDate(0, 0, 7), FechaFin: day.AddDate(0, 0, 9)}
+    if got = Check("Perro", p, []models.Prescripcion{siguiente}, nil, 0, day, day.AddDate(0, 0, 6)); len(got) != 0 {
+        t.Fatalf("before the other starts = %+v", got)
+    }
+    got = Check("Perro", p, []models.Prescripcion{siguiente}, nil, 0, day, day.AddDate(0, 0, 7))
+    if len(got) != 1 || got[0].Tipo != WarningInteraction || *got[0].PrescripcionID != 14 {
+        t.Fatalf("overlapping a later prescription = %+v", got)
+    }
+
+    // A recorded allergy to the grupo, ignored once it is no longer active.
+    alertas := []models.AlertaClinica{
+        {ID: 5, Tipo: models.AlertaAlergia, Severidad: models.SeveridadGrave, Alergeno: "Penicilinas", Descripcion: "Edema facial", Activa: true},
+        {ID: 6, Tipo: models.AlertaComportamiento, Severidad: models.SeveridadModerada, Descripcion: "Muerde", Activa: true},
+    }
+    p = models.Prescripcion{ID: 23, Medicamento: amoxi, DosisMg: 150}
+    got = Check("Perro", p, nil, alertas, 10, day, day)
+    if len(got) != 1 || got[0].Tipo != WarningAllergy || got[0].AlertaID == nil || *got[0].AlertaID != 5 {
+        t.Fatalf("allergy alert = %+v", got)
+    }
+    alertas[0].Activa = false
+    if got = Check("Perro", p, nil, alertas, 10, day, day); len(got) != 0 {
+        t.Fatalf("inactive alert = %+v", got)
+    }
+}
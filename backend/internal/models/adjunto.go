@@ -0,0 +1,122 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgconn"
+)
+
+// Adjunto is a file attached to a mascota or to a cuidado (exactly one of
+// MascotaID and CuidadoID is set). The bytes live in the blob store under
+// Clave, and under ClaveMiniatura for the thumbnail of an image. URL and
+// MiniaturaURL are signed download links filled in by the API.
+type Adjunto struct {
+    ID             int64     `json:"id"`
+    MascotaID      *int64    `json:"mascota_id,omitempty"`
+    CuidadoID      *int64    `json:"cuidado_id,omitempty"`
+    Nombre         string    `json:"nombre"`
+    ContentType    string    `json:"content_type"`
+    Tamano         int64     `json:"tamano"`
+    SHA256         string    `json:"sha256"`
+    Clave          string    `json:"-"`
+    ClaveMiniatura string    `json:"-"`
+    CreadoEn       time.Time `json:"creado_en"`
+    URL            string    `json:"url,omitempty"`
+    MiniaturaURL   string    `json:"miniatura_url,omitempty"`
+}
+
+// AdjuntoRepository stores attachment metadata. Deleting the mascota or
+// cuidado an adjunto belongs to leaves it orphaned: Get and the lists no
+// longer return it, and ListOrphans hands it to the cleanup.
+type AdjuntoRepository interface {
+    // Create fails with ErrNotFound when the mascota or cuidado is missing.
+    Create(ctx context.Context, a *Adjunto) error
+    Get(ctx context.Context, id int64) (*Adjunto, error)
+    ListByMascota(ctx context.Context, mascotaID int64) ([]Adjunto, error)
+    ListByCuidado(ctx context.Context, cuidadoID int64) ([]Adjunto, error)
+    // Delete removes the record, orphaned or not; the caller deletes the
+    // files.
+    Delete(ctx context.Context, id int64) error
+    ListOrphans(ctx context.Context, limit int) ([]Adjunto, error)
+}
+
+type AdjuntoStore struct{ DB *sql.DB }
+
+var _ AdjuntoRepository = AdjuntoStore{}
+
+const adjuntoColumns = `id, mascota_id, cuidado_id, nombre, content_type, tamano, sha256, clave, clave_miniatura, creado_en`
+
+func scanAdjunto(row rowScanner, a *Adjunto) error {
+    var mascotaID, cuidadoID sql.NullInt64
+    if err := row.Scan(&a.ID, &mascotaID, &cuidadoID, &a.Nombre, &a.ContentType, &a.Tamano, &a.SHA256,
+        &a.Clave, &a.ClaveMiniatura, &a.CreadoEn); err != nil {
+        return err
+    }
+    if mascotaID.Valid {
+        a.MascotaID = &mascotaID.Int64
+    }
+    if cuidadoID.Valid {
+        a.CuidadoID = &cuidadoID.Int64
+    }
+    return nil
+}
+
+func (s AdjuntoStore) Create(ctx context.Context, a *Adjunto) error {
+    q := `INSERT INTO adjuntos(mascota_id, cuidado_id, nombre, content_type, tamano, sha256, clave, clave_miniatura)
+          VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id, creado_en`
+    err := s.DB.QueryRowContext(ctx, q, a.MascotaID, a.CuidadoID, a.Nombre, a.ContentType, a.Tamano, a.SHA256,
+        a.Clave, a.ClaveMiniatura).Scan(&a.ID, &a.CreadoEn)
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+        return ErrNotFound
+    }
+    return err
+}
+
+func (s AdjuntoStore) Get(ctx context.Context, id int64) (*Adjunto, error) {
+    q := `SELECT ` + adjuntoColumns + ` FROM adjuntos
+          WHERE id=$1 AND (mascota_id IS NOT NULL OR cuidado_id IS NOT NULL)`
+    var a Adjunto
+    if err := scanAdjunto(s.DB.QueryRowContext(ctx, q, id), &a); err != nil {
+        return nil, notFound(err)
+    }
+    return &a, nil
+}
+
+func (s AdjuntoStore) ListByMascota(ctx context.Context, mascotaID int64) ([]Adjunto, error) {
+    return s.list(ctx, `SELECT `+adjuntoColumns+` FROM adjuntos WHERE mascota_id=$1 ORDER BY id`, mascotaID)
+}
+
+func (s AdjuntoStore) ListByCuidado(ctx context.Context, cuidadoID int64) ([]Adjunto, error) {
+    return s.list(ctx, `SELECT `+adjuntoColumns+` FROM adjuntos WHERE cuidado_id=$1 ORDER BY id`, cuidadoID)
+}
+
+func (s AdjuntoStore) ListOrphans(ctx context.Context, limit int) ([]Adjunto, error) {
+    return s.list(ctx, `SELECT `+adjuntoColumns+` FROM adjuntos
+          WHERE mascota_id IS NULL AND cuidado_id IS NULL ORDER BY id LIMIT $1`, limit)
+}
+
+func (s AdjuntoStore) list(ctx context.Context, q string, args ...any) ([]Adjunto, error) {
+    rows, err := s.DB.QueryContext(ctx, q, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Adjunto, 0)
+    for rows.Next() {
+        var a Adjunto
+        if err := scanAdjunto(rows, &a); err != nil {
+            return nil, err
+        }
+        out = append(out, a)
+    }
+    return out, rows.Err()
+}
+
+func (s AdjuntoStore) Delete(ctx context.Context, id int64) error {
+    res, err := s.DB.ExecContext(ctx, `DELETE FROM adjuntos WHERE id=$1`, id)
+    return affectedOne(res, err)
+}
@@ -0,0 +1,69 @@
+package models_test
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/testutil/pgtest"
+)
+
+// TestAdjuntoOrphans checks that deleting a mascota orphans its adjuntos
+// and those of its cuidados, and nothing else.
+func TestAdjuntoOrphans(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    adjuntos := models.AdjuntoStore{DB: db.DB}
+    mascotas := models.MascotaStore{DB: db.DB}
+    cuidados := models.CuidadoStore{DB: db.DB}
+
+    var ms []*models.Mascota
+    for _, nombre := range []string{"Firulais", "Misu"} {
+        m := &models.Mascota{Nombre: nombre, Especie: "Perro", Raza: "Criollo", Sexo: "Macho", FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)}
+        if err := mascotas.Create(ctx, m); err != nil {
+            t.Fatal(err)
+        }
+        ms = append(ms, m)
+    }
+    c := &models.Cuidado{TipoCuidado: "Consulta Veterinaria", Descripcion: "Análisis", FechaCuidado: time.Date(2030, 6, 6, 9, 0, 0, 0, time.UTC), MascotaID: ms[0].ID}
+    if err := cuidados.Create(ctx, c); err != nil {
+        t.Fatal(err)
+    }
+    foto := &models.Adjunto{MascotaID: &ms[0].ID, Nombre: "foto.png", ContentType: "image/png", Tamano: 10, SHA256: "aa", Clave: "k/1", ClaveMiniatura: "k/1-miniatura.jpg"}
+    lab := &models.Adjunto{CuidadoID: &c.ID, Nombre: "lab.pdf", ContentType: "application/pdf", Tamano: 20, SHA256: "bb", Clave: "k/2"}
+    otra := &models.Adjunto{MascotaID: &ms[1].ID, Nombre: "misu.jpg", ContentType: "image/jpeg", Tamano: 30, SHA256: "cc", Clave: "k/3"}
+    for _, a := range []*models.Adjunto{foto, lab, otra} {
+        if err := adjuntos.Create(ctx, a); err != nil {
+            t.Fatal(err)
+        }
+    }
+    missing := int64(999)
+    if err := adjuntos.Create(ctx, &models.Adjunto{MascotaID: &missing, Nombre: "x", ContentType: "image/png", Clave: "k/4"}); !errors.Is(err, models.ErrNotFound) {
+        t.Fatalf("unknown mascota: err = %v, want ErrNotFound", err)
+    }
+    if list, err := adjuntos.ListByCuidado(ctx, c.ID); err != nil || len(list) != 1 || list[0].Clave != "k/2" {
+        t.Fatalf("ListByCuidado = %+v, %v", list, err)
+    }
+
+    if err := mascotas.Delete(ctx, ms[0].ID); err != nil {
+        t.Fatal(err)
+    }
+    orphans, err := adjuntos.ListOrphans(ctx, 10)
+    if err != nil || len(orphans) != 2 || orphans[0].ID != foto.ID || orphans[1].ID != lab.ID {
+        t.Fatalf("ListOrphans = %+v, %v; want foto and lab", orphans, err)
+    }
+    if orphans[0].ClaveMiniatura != "k/1-miniatura.jpg" {
+        t.Fatalf("orphan lost its thumbnail key: %+v", orphans[0])
+    }
+    if _, err := adjuntos.Get(ctx, foto.ID); !errors.Is(err, models.ErrNotFound) {
+        t.Fatalf("Get orphan: err = %v, want ErrNotFound", err)
+    }
+    if err := adjuntos.Delete(ctx, foto.ID); err != nil {
+        t.Fatal(err)
+    }
+    if list, err := adjuntos.ListByMascota(ctx, ms[1].ID); err != nil || len(list) != 1 {
+        t.Fatalf("other mascota's adjuntos = %+v, %v", list, err)
+    }
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "slices"
+    "strings"
+    "time"
+)
+
+const (
+    AlertaAlergia          = "alergia"
+    AlertaCondicionCronica = "condicion_cronica"
+    AlertaComportamiento   = "comportamiento"
+
+    SeveridadLeve     = "leve"
+    SeveridadModerada = "moderada"
+    SeveridadGrave    = "grave"
+)
+
+// AlertaClinica is something every vet must know before handling a
+// mascota: an allergy, a chronic condition or a behavioral warning. For
+// an allergy Alergeno names the substance, principio activo or grupo, and
+// TiposCuidado the tipos de cuidado it rules out.
+type AlertaClinica struct {
+    ID           int64     `json:"id"`
+    MascotaID    int64     `json:"mascota_id"`
+    Tipo         string    `json:"tipo"`
+    Severidad    string    `json:"severidad"`
+    Descripcion  string    `json:"descripcion"`
+    Alergeno     string    `json:"alergeno,omitempty"`
+    TiposCuidado []string  `json:"tipos_cuidado"`
+    Activa       bool      `json:"activa"`
+    CreadoEn     time.Time `json:"creado_en"`
+}
+
+var textAccents = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u")
+
+// foldText lowercases s and drops its accents for loose matching.
+func foldText(s string) string {
+    return textAccents.Replace(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// AfectaCuidado reports whether a is an active allergy that rules out a
+// cuidado of the given tipo, or whose alergeno the description mentions.
+func (a AlertaClinica) AfectaCuidado(tipo, descripcion string) bool {
+    if !a.Activa || a.Tipo != AlertaAlergia {
+        return false
+    }
+    return slices.Contains(a.TiposCuidado, tipo) || (a.Alergeno != "" && strings.Contains(foldText(descripcion), foldText(a.Alergeno)))
+}
+
+// AfectaMedicamento reports whether a is an active allergy to the
+// principio activo or grupo of m, or to m by name.
+func (a AlertaClinica) AfectaMedicamento(m Medicamento) bool {
+    if !a.Activa || a.Tipo != AlertaAlergia {
+        return false
+    }
+    al := foldText(a.Alergeno)
+    if al == "" {
+        return false
+    }
+    return al == foldText(m.PrincipioActivo) || (m.Grupo != "" && al == foldText(m.Grupo)) || strings.Contains(foldText(m.Nombre), al)
+}
+
+// AlertaRepository stores clinical alerts. Lists put the most severe
+// first, then the oldest.
+type AlertaRepository interface {
+    // Create fails with ErrNotFound when the mascota is missing.
+    Create(ctx context.Context, a *AlertaClinica) error
+    Get(ctx context.Context, id int64) (*AlertaClinica, error)
+    ListByMascota(ctx context.Context, mascotaID int64) ([]AlertaClinica, error)
+    // Update changes everything but the mascota.
+    Update(ctx context.Context, a *AlertaClinica) error
+    Delete(ctx context.Context, id int64) error
+}
+
+type AlertaStore struct{ DB *sql.DB }
+
+var _ AlertaRepository = AlertaStore{}
+
+// tipos_cuidado is read back as JSON: database/sql cannot scan a TEXT[]
+// into a []string.
+const alertaColumns = `id, mascota_id, tipo, severidad, descripcion, alergeno, to_json(tipos_cuidado), activa, creado_en`
+
+func scanAlerta(row rowScanner, a *AlertaClinica) error {
+    var tipos []byte
+    if err := row.Scan(&a.ID, &a.MascotaID, &a.Tipo, &a.Severidad, &a.Descripcion, &a.Alergeno, &tipos, &a.Activa, &a.CreadoEn); err != nil {
+        return err
+    }
+    return json.Unmarshal(tipos, &a.TiposCuidado)
+}
+
+func (s AlertaStore) Create(ctx context.Context, a *AlertaClinica) error {
+    q := `INSERT INTO alertas_clinicas(mascota_id, tipo, severidad, descripcion, alergeno, tipos_cuidado, activa)
+          VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id, creado_en`
+    err := s.DB.QueryRowContext(ctx, q, a.MascotaID, a.Tipo, a.Severidad, a.Descripcion, a.Alergeno, a.TiposCuidado, a.Activa).Scan(&a.ID, &a.CreadoEn)
+    return mascotaRef(err, a.MascotaID)
+}
+
+func (s AlertaStore) Get(ctx context.Context, id int64) (*AlertaClinica, error) {
+    var a AlertaClinica
+    if err := scanAlerta(s.DB.QueryRowContext(ctx, `SELECT `+alertaColumns+` FROM alertas_clinicas WHERE id=$1`, id), &a); err != nil {
+        return nil, notFound(err)
+    }
+    return &a, nil
+}
+
+func (s AlertaStore) ListByMascota(ctx context.Context, mascotaID int64) ([]AlertaClinica, error) {
+    q := `SELECT ` + alertaColumns + ` FROM alertas_clinicas WHERE mascota_id=$1
+          ORDER BY CASE severidad WHEN 'grave' THEN 0 WHEN 'moderada' THEN 1 ELSE 2 END, id`
+    rows, err := s.DB.QueryContext(ctx, q, mascotaID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]AlertaClinica, 0)
+    for rows.Next() {
+        var a AlertaClinica
+        if err := scanAlerta(rows, &a); err != nil {
+            return nil, err
+        }
+        out = append(out, a)
+    }
+    return out, rows.Err()
+}
+
+func (s AlertaStore) Update(ctx context.Context, a *AlertaClinica) error {
+    q := `UPDATE alertas_clinicas SET tipo=$1, severidad=$2, descripcion=$3, alergeno=$4, tipos_cuidado=$5, activa=$6
+          WHERE id=$7 RETURNING mascota_id, creado_en`
+    err := s.DB.QueryRowContext(ctx, q, a.Tipo, a.Severidad, a.Descripcion, a.Alergeno, a.TiposCuidado, a.Activa, a.ID).Scan(&a.MascotaID, &a.CreadoEn)
+    return notFound(err)
+}
+
+func (s AlertaStore) Delete(ctx context.Context, id int64) error {
+    res, err := s.DB.ExecContext(ctx, `DELETE FROM alertas_clinicas WHERE id=$1`, id)
+    return affectedOne(res, err)
+}
@@ -3,6 +3,9 @@ package models
 import (
     "context"
     "database/sql"
+    "errors"
+    "strconv"
+    "strings"
     "time"
 )
 
@@ -12,29 +15,268 @@ type Cuidado struct {
     Descripcion  string    `json:"descripcion"`
     FechaCuidado time.Time `json:"fecha_cuidado"`
     MascotaID    int64     `json:"mascota_id"`
+    Estado       string    `json:"estado"`
+    // Secuencia counts the updates of the cuidado; calendar clients use it
+    // (as the iCalendar SEQUENCE) to tell a changed event from a stale one.
+    Secuencia    int       `json:"secuencia"`
+    // VeterinarioID is who performs the cuidado; 0 when nobody is
+    // assigned.
+    VeterinarioID int64    `json:"veterinario_id,omitempty"`
 }
 
-type CuidadoStore struct{ DB *sql.DB }
+// tiposCuidado holds the display name and the time slot of every
+// tipo_cuidado; the stored values carry no accents.
+var tiposCuidado = map[string]struct {
+    nombre   string
+    duracion time.Duration
+}{
+    "Vacunacion":           {"Vacunación", 20 * time.Minute},
+    "Desparasitacion":      {"Desparasitación", 15 * time.Minute},
+    "Consulta Veterinaria": {"Consulta veterinaria", 30 * time.Minute},
+    "Bano":                 {"Baño", time.Hour},
+}
+
+// NombreCuidado returns the human-readable name of a tipo_cuidado.
+func NombreCuidado(tipo string) string {
+    if t, ok := tiposCuidado[tipo]; ok {
+        return t.nombre
+    }
+    return tipo
+}
+
+// DuracionCuidado is how long a cuidado of the given tipo takes; unknown
+// tipos get 30 minutes.
+func DuracionCuidado(tipo string) time.Duration {
+    if t, ok := tiposCuidado[tipo]; ok {
+        return t.duracion
+    }
+    return 30 * time.Minute
+}
 
+// Overlaps reports whether c and o take up some of the same time.
+func (c Cuidado) Overlaps(o Cuidado) bool {
+    return c.FechaCuidado.Before(o.FechaCuidado.Add(DuracionCuidado(o.TipoCuidado))) &&
+        o.FechaCuidado.Before(c.FechaCuidado.Add(DuracionCuidado(c.TipoCuidado)))
+}
+
+// BookingConflict is returned by the cuidado writes when the veterinario
+// already has Cuidado, not cancelled, at the same time.
+type BookingConflict struct {
+    Cuidado Cuidado
+}
+
+func (e *BookingConflict) Error() string {
+    return "veterinario already booked by cuidado " + strconv.FormatInt(e.Cuidado.ID, 10)
+}
+
+const (
+    CuidadoProgramado = "Programado"
+    CuidadoCompletado = "Completado"
+    CuidadoCancelado  = "Cancelado"
+)
+
+// AgendaCuidado is a cuidado with the mascota it is for, as the clinic
+// agenda shows it.
+type AgendaCuidado struct {
+    Cuidado
+    MascotaNombre  string `json:"mascota_nombre"`
+    MascotaEspecie string `json:"mascota_especie"`
+}
+
+// CuidadoFilter selects cuidados for exports and agendas. Zero values match
+// everything; Desde is inclusive and Hasta exclusive.
+type CuidadoFilter struct {
+    MascotaID int64
+    VeterinarioID int64
+    Tipo      string
+    Estado    string
+    Desde     time.Time
+    Hasta     time.Time
+}
+
+// Match reports whether c passes the filter.
+func (f CuidadoFilter) Match(c Cuidado) bool {
+    return (f.MascotaID == 0 || c.MascotaID == f.MascotaID) &&
+        (f.VeterinarioID == 0 || c.VeterinarioID == f.VeterinarioID) &&
+        (f.Tipo == "" || c.TipoCuidado == f.Tipo) &&
+        (f.Estado == "" || c.Estado == f.Estado) &&
+        (f.Desde.IsZero() || !c.FechaCuidado.Before(f.Desde)) &&
+        (f.Hasta.IsZero() || c.FechaCuidado.Before(f.Hasta))
+}
+
+// CuidadoStore persists cuidados. When ReminderLead is positive every new
+// cuidado also queues a reminder in the notificaciones outbox, due
+// ReminderLead before fecha_cuidado, within the same transaction.
+type CuidadoStore struct {
+    DB           *sql.DB
+    ReminderLead time.Duration
+}
+
+const cuidadoColumns = `id, tipo_cuidado, descripcion, fecha_cuidado, mascota_id, estado, secuencia, COALESCE(veterinario_id, 0)`
+
+func scanCuidado(row rowScanner, c *Cuidado) error {
+    return row.Scan(&c.ID, &c.TipoCuidado, &c.Descripcion, &c.FechaCuidado, &c.MascotaID, &c.Estado, &c.Secuencia, &c.VeterinarioID)
+}
+
+// Create stores c as Programado unless another estado is given.
 func (s CuidadoStore) Create(ctx context.Context, c *Cuidado) error {
-    q := `INSERT INTO cuidados(tipo_cuidado, descripcion, fecha_cuidado, mascota_id)
-          VALUES ($1,$2,$3,$4) RETURNING id`
-    return s.DB.QueryRowContext(ctx, q, c.TipoCuidado, c.Descripcion, c.FechaCuidado, c.MascotaID).Scan(&c.ID)
+    if c.Estado == "" {
+        c.Estado = CuidadoProgramado
+    }
+    c.Secuencia = 0
+    return withTx(ctx, s.DB, func(tx *sql.Tx) error {
+        if err := checkBooking(ctx, tx, c); err != nil {
+            return err
+        }
+        q := `INSERT INTO cuidados(tipo_cuidado, descripcion, fecha_cuidado, mascota_id, estado, veterinario_id)
+              VALUES ($1,$2,$3,$4,$5,NULLIF($6, 0)) RETURNING id`
+        err := tx.QueryRowContext(ctx, q, c.TipoCuidado, c.Descripcion, c.FechaCuidado, c.MascotaID, c.Estado, c.VeterinarioID).Scan(&c.ID)
+        if err != nil {
+            return mascotaRef(err, c.MascotaID)
+        }
+        if err := recordEvent(ctx, tx, EventCuidadoCreated, c.ID, c.MascotaID, c); err != nil {
+            return err
+        }
+        if s.ReminderLead <= 0 {
+            return nil
+        }
+        return enqueueReminder(ctx, tx, c.ID, c.FechaCuidado.Add(-s.ReminderLead))
+    })
 }
 
 func (s CuidadoStore) Get(ctx context.Context, id int64) (*Cuidado, error) {
-    q := `SELECT id, tipo_cuidado, descripcion, fecha_cuidado, mascota_id FROM cuidados WHERE id=$1`
+    q := `SELECT ` + cuidadoColumns + ` FROM cuidados WHERE id=$1`
     var c Cuidado
-    err := s.DB.QueryRowContext(ctx, q, id).Scan(&c.ID, &c.TipoCuidado, &c.Descripcion, &c.FechaCuidado, &c.MascotaID)
-    if err != nil {
-        return nil, err
+    if err := scanCuidado(s.DB.QueryRowContext(ctx, q, id), &c); err != nil {
+        return nil, notFound(err)
     }
     return &c, nil
 }
 
 func (s CuidadoStore) ListByMascota(ctx context.Context, mascotaID int64) ([]Cuidado, error) {
-    q := `SELECT id, tipo_cuidado, descripcion, fecha_cuidado, mascota_id FROM cuidados WHERE mascota_id=$1 ORDER BY fecha_cuidado DESC`
-    rows, err := s.DB.QueryContext(ctx, q, mascotaID)
+    q := `SELECT ` + cuidadoColumns + ` FROM cuidados WHERE mascota_id=$1 ORDER BY fecha_cuidado DESC, id DESC`
+    return s.query(ctx, q, mascotaID)
+}
+
+func (s CuidadoStore) ListBetween(ctx context.Context, desde, hasta time.Time) ([]Cuidado, error) {
+    q := `SELECT ` + cuidadoColumns + ` FROM cuidados WHERE fecha_cuidado >= $1 AND fecha_cuidado < $2 ORDER BY fecha_cuidado, id`
+    return s.query(ctx, q, desde, hasta)
+}
+
+func (s CuidadoStore) Stream(ctx context.Context, f CuidadoFilter, fn func(Cuidado) error) error {
+    where, args := f.sql("")
+    q := `SELECT ` + cuidadoColumns + ` FROM cuidados` + where + ` ORDER BY fecha_cuidado, id`
+    return withStreamTx(ctx, s.DB, func(tx *sql.Tx) error {
+        rows, err := tx.QueryContext(ctx, q, args...)
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            var c Cuidado
+            if err := scanCuidado(rows, &c); err != nil {
+                return err
+            }
+            if err := fn(c); err != nil {
+                return err
+            }
+        }
+        return rows.Err()
+    })
+}
+
+// sql renders f as a WHERE clause (empty when f matches everything) on
+// the columns of the cuidados table aliased as alias, if any.
+func (f CuidadoFilter) sql(alias string) (string, []any) {
+    var where []string
+    var args []any
+    cond := func(col, op string, v any) {
+        if alias != "" {
+            col = alias + "." + col
+        }
+        args = append(args, v)
+        where = append(where, col+" "+op+" $"+strconv.Itoa(len(args)))
+    }
+    if f.MascotaID != 0 {
+        cond("mascota_id", "=", f.MascotaID)
+    }
+    if f.VeterinarioID != 0 {
+        cond("veterinario_id", "=", f.VeterinarioID)
+    }
+    if f.Tipo != "" {
+        cond("tipo_cuidado", "=", f.Tipo)
+    }
+    if f.Estado != "" {
+        cond("estado", "=", f.Estado)
+    }
+    if !f.Desde.IsZero() {
+        cond("fecha_cuidado", ">=", f.Desde)
+    }
+    if !f.Hasta.IsZero() {
+        cond("fecha_cuidado", "<", f.Hasta)
+    }
+    if len(where) == 0 {
+        return "", nil
+    }
+    return ` WHERE ` + strings.Join(where, " AND "), args
+}
+
+// ListAgenda returns the cuidados matching f with the name and especie of
+// their mascota, ordered by fecha_cuidado, then id. A desde/hasta range is
+// served by idx_cuidados_fecha.
+func (s CuidadoStore) ListAgenda(ctx context.Context, f CuidadoFilter) ([]AgendaCuidado, error) {
+    where, args := f.sql("c")
+    q := `SELECT c.id, c.tipo_cuidado, c.descripcion, c.fecha_cuidado, c.mascota_id, c.estado, c.secuencia, COALESCE(c.veterinario_id, 0),
+                 m.nombre, m.especie
+          FROM cuidados c JOIN mascotas m ON m.id = c.mascota_id` + where + `
+          ORDER BY c.fecha_cuidado, c.id`
+    rows, err := s.DB.QueryContext(ctx, q, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]AgendaCuidado, 0)
+    for rows.Next() {
+        var a AgendaCuidado
+        c := &a.Cuidado
+        if err := rows.Scan(&c.ID, &c.TipoCuidado, &c.Descripcion, &c.FechaCuidado, &c.MascotaID, &c.Estado, &c.Secuencia, &c.VeterinarioID,
+            &a.MascotaNombre, &a.MascotaEspecie); err != nil {
+            return nil, err
+        }
+        out = append(out, a)
+    }
+    return out, rows.Err()
+}
+
+// ListBorrados reads the tombstones the cuidados_borrados trigger writes
+// on every delete, including the cascade of a deleted mascota. f.Estado
+// is ignored: every tombstone is Cancelado.
+func (s CuidadoStore) ListBorrados(ctx context.Context, f CuidadoFilter) ([]AgendaCuidado, error) {
+    f.Estado = ""
+    where, args := f.sql("")
+    q := `SELECT id, tipo_cuidado, descripcion, fecha_cuidado, mascota_id, secuencia, COALESCE(veterinario_id, 0), mascota_nombre, mascota_especie
+          FROM cuidados_borrados` + where + `
+          ORDER BY fecha_cuidado, id`
+    rows, err := s.DB.QueryContext(ctx, q, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]AgendaCuidado, 0)
+    for rows.Next() {
+        a := AgendaCuidado{Cuidado: Cuidado{Estado: CuidadoCancelado}}
+        c := &a.Cuidado
+        if err := rows.Scan(&c.ID, &c.TipoCuidado, &c.Descripcion, &c.FechaCuidado, &c.MascotaID, &c.Secuencia, &c.VeterinarioID,
+            &a.MascotaNombre, &a.MascotaEspecie); err != nil {
+            return nil, err
+        }
+        out = append(out, a)
+    }
+    return out, rows.Err()
+}
+
+func (s CuidadoStore) query(ctx context.Context, q string, args ...any) ([]Cuidado, error) {
+    rows, err := s.DB.QueryContext(ctx, q, args...)
     if err != nil {
         return nil, err
     }
@@ -42,7 +284,7 @@ func (s CuidadoStore) ListByMascota(ctx context.Context, mascotaID int64) ([]Cui
     out := make([]Cuidado, 0)
     for rows.Next() {
         var c Cuidado
-        if err := rows.Scan(&c.ID, &c.TipoCuidado, &c.Descripcion, &c.FechaCuidado, &c.MascotaID); err != nil {
+        if err := scanCuidado(rows, &c); err != nil {
             return nil, err
         }
         out = append(out, c)
@@ -53,13 +295,120 @@ func (s CuidadoStore) ListByMascota(ctx context.Context, mascotaID int64) ([]Cui
     return out, nil
 }
 
+// Update bumps Secuencia and also moves any reminder still pending to the new fecha_cuidado.
+// An empty Estado keeps the stored one; moving to Completado additionally
+// emits cuidado.completed.
 func (s CuidadoStore) Update(ctx context.Context, c *Cuidado) error {
-    q := `UPDATE cuidados SET tipo_cuidado=$1, descripcion=$2, fecha_cuidado=$3, mascota_id=$4 WHERE id=$5`
-    _, err := s.DB.ExecContext(ctx, q, c.TipoCuidado, c.Descripcion, c.FechaCuidado, c.MascotaID, c.ID)
-    return err
+    return withTx(ctx, s.DB, func(tx *sql.Tx) error {
+        var prev string
+        if err := tx.QueryRowContext(ctx, `SELECT estado FROM cuidados WHERE id=$1 FOR UPDATE`, c.ID).Scan(&prev); err != nil {
+            return notFound(err)
+        }
+        if c.Estado == "" {
+            c.Estado = prev
+        }
+        if err := checkBooking(ctx, tx, c); err != nil {
+            return err
+        }
+        q := `UPDATE cuidados SET tipo_cuidado=$1, descripcion=$2, fecha_cuidado=$3, mascota_id=$4, estado=$5,
+              veterinario_id=NULLIF($6, 0), secuencia=secuencia+1 WHERE id=$7 RETURNING secuencia`
+        err := tx.QueryRowContext(ctx, q, c.TipoCuidado, c.Descripcion, c.FechaCuidado, c.MascotaID, c.Estado, c.VeterinarioID, c.ID).Scan(&c.Secuencia)
+        if err != nil {
+            return notFound(mascotaRef(err, c.MascotaID))
+        }
+        if err := recordEvent(ctx, tx, EventCuidadoUpdated, c.ID, c.MascotaID, c); err != nil {
+            return err
+        }
+        if c.Estado == CuidadoCompletado && prev != CuidadoCompletado {
+            if err := recordEvent(ctx, tx, EventCuidadoCompleted, c.ID, c.MascotaID, c); err != nil {
+                return err
+            }
+        }
+        if s.ReminderLead <= 0 {
+            return nil
+        }
+        return rescheduleReminders(ctx, tx, c.ID, c.FechaCuidado.Add(-s.ReminderLead))
+    })
+}
+
+// checkBooking locks the veterinario of c until tx ends, then returns a
+// *BookingConflict if another of its cuidados overlaps c. The lock makes
+// concurrent bookings of the same veterinario wait for each other, so
+// they cannot all see the slot free.
+func checkBooking(ctx context.Context, tx *sql.Tx, c *Cuidado) error {
+    if c.VeterinarioID == 0 || c.Estado == CuidadoCancelado {
+        return nil
+    }
+    var id int64
+    err := tx.QueryRowContext(ctx, `SELECT id FROM veterinarios WHERE id=$1 FOR UPDATE`, c.VeterinarioID).Scan(&id)
+    if errors.Is(err, sql.ErrNoRows) {
+        // The foreign key rejects the write.
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    // No cuidado lasts a day, so earlier ones cannot reach c.
+    q := `SELECT ` + cuidadoColumns + ` FROM cuidados
+          WHERE veterinario_id=$1 AND id<>$2 AND estado<>'Cancelado' AND fecha_cuidado > $3 AND fecha_cuidado < $4
+          ORDER BY fecha_cuidado, id`
+    rows, err := tx.QueryContext(ctx, q, c.VeterinarioID, c.ID, c.FechaCuidado.Add(-24*time.Hour), c.FechaCuidado.Add(DuracionCuidado(c.TipoCuidado)))
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var o Cuidado
+        if err := scanCuidado(rows, &o); err != nil {
+            return err
+        }
+        if o.Overlaps(*c) {
+            return &BookingConflict{Cuidado: o}
+        }
+    }
+    return rows.Err()
 }
 
 func (s CuidadoStore) Delete(ctx context.Context, id int64) error {
-    _, err := s.DB.ExecContext(ctx, `DELETE FROM cuidados WHERE id=$1`, id)
-    return err
+    return withTx(ctx, s.DB, func(tx *sql.Tx) error {
+        var mascotaID int64
+        if err := tx.QueryRowContext(ctx, `DELETE FROM cuidados WHERE id=$1 RETURNING mascota_id`, id).Scan(&mascotaID); err != nil {
+            return notFound(err)
+        }
+        return recordEvent(ctx, tx, EventCuidadoDeleted, id, mascotaID, cuidadoRef{ID: id, MascotaID: mascotaID})
+    })
+}
+
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+    tx, err := db.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    if err := fn(tx); err != nil {
+        tx.Rollback()
+        return err
+    }
+    return tx.Commit()
+}
+
+// withStreamTx runs fn in a read-only transaction whose statement_timeout
+// is the time left until the deadline of ctx, if it has one, instead of
+// the session's: a Stream may take as long as its caller allows, which
+// for an export of the whole table is well past DB_STATEMENT_TIMEOUT.
+func withStreamTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+    tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+    if dl, ok := ctx.Deadline(); ok {
+        ms := max(time.Until(dl).Milliseconds(), 1)
+        if _, err := tx.ExecContext(ctx, `SELECT set_config('statement_timeout', $1, true)`, strconv.FormatInt(ms, 10)); err != nil {
+            return err
+        }
+    }
+    if err := fn(tx); err != nil {
+        return err
+    }
+    return tx.Commit()
 }
@@ -0,0 +1,122 @@
+package models
+
+import (
+    "context"
+    "crypto/rand"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+    DocumentoHistorial             = "historial"
+    DocumentoCertificadoVacunacion = "certificado_vacunacion"
+)
+
+// ErrDuplicateCode is returned by DocumentoRepository.Create when the
+// verification code is already taken; the caller draws a new one.
+var ErrDuplicateCode = errors.New("duplicate verification code")
+
+// Documento records an issued PDF. Contenido is the data printed on it, so
+// a verification shows what the document said even after the mascota
+// changed or was deleted (MascotaID is then nil); SHA256 is the digest of
+// the PDF file as delivered.
+type Documento struct {
+    ID        int64           `json:"-"`
+    Codigo    string          `json:"codigo"`
+    Tipo      string          `json:"tipo"`
+    MascotaID *int64          `json:"mascota_id"`
+    Contenido json.RawMessage `json:"contenido"`
+    SHA256    string          `json:"sha256"`
+    EmitidoEn time.Time       `json:"emitido_en"`
+}
+
+type DocumentoRepository interface {
+    Create(ctx context.Context, d *Documento) error
+    // Get looks a document up by its normalized code.
+    Get(ctx context.Context, codigo string) (*Documento, error)
+}
+
+// codeAlphabet leaves out 0/O, 1/I/L and U so a code read aloud or typed
+// from paper is not ambiguous.
+const codeAlphabet = "23456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// codeLength gives 30^10 (about 2^49) codes.
+const codeLength = 10
+
+// NewCodigo draws a random verification code, normalized (see
+// NormalizeCodigo). FormatCodigo groups it for printing.
+func NewCodigo() (string, error) {
+    buf := make([]byte, codeLength)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    // 256 is not a multiple of 30: the slight bias is irrelevant for a code
+    // that is looked up, not guessed offline.
+    for i, b := range buf {
+        buf[i] = codeAlphabet[int(b)%len(codeAlphabet)]
+    }
+    return string(buf), nil
+}
+
+// NormalizeCodigo upper-cases a code as typed and drops separators and
+// spaces, so "abcd-efgh-jk" finds "ABCDEFGHJK".
+func NormalizeCodigo(s string) string {
+    var b strings.Builder
+    for _, r := range strings.ToUpper(s) {
+        if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+            b.WriteRune(r)
+        }
+    }
+    return b.String()
+}
+
+// FormatCodigo prints a normalized code in groups of four: "ABCD-EFGH-JK".
+func FormatCodigo(codigo string) string {
+    var parts []string
+    for len(codigo) > 4 {
+        parts = append(parts, codigo[:4])
+        codigo = codigo[4:]
+    }
+    return strings.Join(append(parts, codigo), "-")
+}
+
+type DocumentoStore struct{ DB *sql.DB }
+
+var _ DocumentoRepository = DocumentoStore{}
+
+func (s DocumentoStore) Create(ctx context.Context, d *Documento) error {
+    q := `INSERT INTO documentos(codigo, tipo, mascota_id, contenido, sha256, emitido_en)
+          VALUES ($1,$2,$3,$4,$5,$6) RETURNING id`
+    err := s.DB.QueryRowContext(ctx, q, d.Codigo, d.Tipo, d.MascotaID, []byte(d.Contenido), d.SHA256, d.EmitidoEn).Scan(&d.ID)
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) {
+        switch pgErr.Code {
+        case "23505":
+            return ErrDuplicateCode
+        case "23503":
+            return ErrNotFound
+        }
+    }
+    return err
+}
+
+func (s DocumentoStore) Get(ctx context.Context, codigo string) (*Documento, error) {
+    q := `SELECT id, codigo, tipo, mascota_id, contenido, sha256, emitido_en FROM documentos WHERE codigo=$1`
+    var d Documento
+    var mascotaID sql.NullInt64
+    var contenido []byte
+    err := s.DB.QueryRowContext(ctx, q, codigo).Scan(&d.ID, &d.Codigo, &d.Tipo, &mascotaID, &contenido, &d.SHA256, &d.EmitidoEn)
+    if err != nil {
+        return nil, notFound(err)
+    }
+    if mascotaID.Valid {
+        d.MascotaID = &mascotaID.Int64
+    }
+    d.Contenido = contenido
+    return &d, nil
+}
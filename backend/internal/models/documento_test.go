@@ -0,0 +1,66 @@
+package models_test
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/testutil/pgtest"
+)
+
+func TestCodigo(t *testing.T) {
+    c, err := models.NewCodigo()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(c) != 10 || models.NormalizeCodigo(c) != c {
+        t.Fatalf("NewCodigo() = %q, want 10 normalized characters", c)
+    }
+    printed := models.FormatCodigo("ABCDEFGHJK")
+    if printed != "ABCD-EFGH-JK" {
+        t.Fatalf("FormatCodigo = %q", printed)
+    }
+    if got := models.NormalizeCodigo(" abcd-efgh jk "); got != "ABCDEFGHJK" {
+        t.Fatalf("NormalizeCodigo = %q", got)
+    }
+}
+
+// TestDocumentoStore checks that codes are unique and that a documento
+// outlives its mascota.
+func TestDocumentoStore(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    docs := models.DocumentoStore{DB: db.DB}
+    mascotas := models.MascotaStore{DB: db.DB}
+
+    m := &models.Mascota{Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", Sexo: "Macho", FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)}
+    if err := mascotas.Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+    emitido := time.Date(2030, 6, 5, 10, 0, 0, 0, time.UTC)
+    d := &models.Documento{Codigo: "ABCDEFGHJK", Tipo: models.DocumentoHistorial, MascotaID: &m.ID,
+        Contenido: []byte(`{"titulo":"Historial clínico"}`), SHA256: "00ff", EmitidoEn: emitido}
+    if err := docs.Create(ctx, d); err != nil {
+        t.Fatal(err)
+    }
+    again := *d
+    if err := docs.Create(ctx, &again); !errors.Is(err, models.ErrDuplicateCode) {
+        t.Fatalf("duplicate code: err = %v, want ErrDuplicateCode", err)
+    }
+
+    if err := mascotas.Delete(ctx, m.ID); err != nil {
+        t.Fatal(err)
+    }
+    got, err := docs.Get(ctx, "ABCDEFGHJK")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.MascotaID != nil || got.Tipo != models.DocumentoHistorial || !got.EmitidoEn.Equal(emitido) || string(got.Contenido) != `{"titulo": "Historial clínico"}` {
+        t.Fatalf("get = %+v (%s)", got, got.Contenido)
+    }
+    if _, err := docs.Get(ctx, "ZZZZZZZZZZ"); !errors.Is(err, models.ErrNotFound) {
+        t.Fatalf("unknown code: err = %v, want ErrNotFound", err)
+    }
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+    "fmt"
+    "time"
+)
+
+// Precisions of Mascota.FechaNacimiento: when only the year or the month
+// of birth is known, the date stored is the first day of it.
+const (
+    PrecisionDia  = "dia"
+    PrecisionMes  = "mes"
+    PrecisionAnio = "anio"
+)
+
+// ParseFechaNacimiento reads a birth date as YYYY-MM-DD, or YYYY-MM or
+// YYYY when the day or the month are not known, and returns its first
+// day and the precision.
+func ParseFechaNacimiento(s string) (time.Time, string, error) {
+    for _, f := range []struct{ layout, precision string }{
+        {"2006-01-02", PrecisionDia},
+        {"2006-01", PrecisionMes},
+        {"2006", PrecisionAnio},
+    } {
+        if len(s) == len(f.layout) {
+            if t, err := time.Parse(f.layout, s); err == nil {
+                return t, f.precision, nil
+            }
+        }
+    }
+    return time.Time{}, "", fmt.Errorf("fecha de nacimiento %q: debe ser YYYY-MM-DD, YYYY-MM o YYYY", s)
+}
+
+// FormatFechaNacimiento writes t as ParseFechaNacimiento reads it.
+func FormatFechaNacimiento(t time.Time, precision string) string {
+    switch precision {
+    case PrecisionAnio:
+        return t.Format("2006")
+    case PrecisionMes:
+        return t.Format("2006-01")
+    }
+    return t.Format("2006-01-02")
+}
+
+// MesesCumplidos is how many full months old an animal born on
+// nacimiento is on dia; negative before it was born.
+func MesesCumplidos(nacimiento, dia time.Time) int {
+    y1, m1, d1 := nacimiento.Date()
+    y2, m2, d2 := dia.Date()
+    months := (y2-y1)*12 + int(m2-m1)
+    if d2 < d1 {
+        months--
+    }
+    return months
+}
+
+// mesesAntes is the latest birth day for which an animal is n months old
+// on dia: dia n months earlier, on the last day of that month when it is
+// shorter.
+func mesesAntes(dia time.Time, n int) time.Time {
+    y, m, d := dia.Date()
+    first := time.Date(y, m-time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+    if last := first.AddDate(0, 1, -1).Day(); d > last {
+        d = last
+    }
+    return time.Date(first.Year(), first.Month(), d, 0, 0, 0, 0, time.UTC)
+}
+
+// Edad is the age of a mascota. When the birth date is approximate it is
+// counted from the first day of the month or year known, and Meses is 0
+// for a year-only date.
+type Edad struct {
+    Anios      int  `json:"anios"`
+    Meses      int  `json:"meses"`
+    Aproximada bool `json:"aproximada"`
+}
+
+// Edad computes the age of m on dia; zero before it was born.
+func (m Mascota) Edad(dia time.Time) Edad {
+    months := MesesCumplidos(m.FechaNacimiento, dia)
+    if months < 0 {
+        months = 0
+    }
+    e := Edad{Anios: months / 12, Meses: months % 12, Aproximada: m.precision() != PrecisionDia}
+    if m.precision() == PrecisionAnio {
+        e.Meses = 0
+    }
+    return e
+}
+
+func (m Mascota) precision() string {
+    if m.FechaNacimientoPrecision == "" {
+        return PrecisionDia
+    }
+    return m.FechaNacimientoPrecision
+}
+
+// Life stages.
+const (
+    EtapaCachorro = "cachorro"
+    EtapaAdulto   = "adulto"
+    EtapaSenior   = "senior"
+)
+
+// Etapas lists the life stages, youngest first.
+var Etapas = []string{EtapaCachorro, EtapaAdulto, EtapaSenior}
+
+// etapaLimites is, per especie, the age in months at which a mascota
+// becomes adulto and senior.
+var etapaLimites = map[string]struct{ adulto, senior int }{
+    "Perro":  {12, 7 * 12},
+    "Gato":   {12, 11 * 12},
+    "Conejo": {6, 5 * 12},
+}
+
+// Etapa is the life stage of m on dia, or "" for an especie without
+// thresholds.
+func (m Mascota) Etapa(dia time.Time) string {
+    l, ok := etapaLimites[m.Especie]
+    if !ok {
+        return ""
+    }
+    switch months := MesesCumplidos(m.FechaNacimiento, dia); {
+    case months >= l.senior:
+        return EtapaSenior
+    case months >= l.adulto:
+        return EtapaAdulto
+    }
+    return EtapaCachorro
+}
+
+// NacimientoEtapa is the range of birth dates, nacido in (despues, hasta],
+// of the mascotas of especie that are in etapa on dia; a zero bound is
+// open. ok is false for an especie without thresholds.
+func NacimientoEtapa(especie, etapa string, dia time.Time) (despues, hasta time.Time, ok bool) {
+    l, ok := etapaLimites[especie]
+    if !ok {
+        return despues, hasta, false
+    }
+    switch etapa {
+    case EtapaCachorro:
+        despues = mesesAntes(dia, l.adulto)
+    case EtapaAdulto:
+        despues, hasta = mesesAntes(dia, l.senior), mesesAntes(dia, l.adulto)
+    case EtapaSenior:
+        hasta = mesesAntes(dia, l.senior)
+    default:
+        return despues, hasta, false
+    }
+    return despues, hasta, true
+}
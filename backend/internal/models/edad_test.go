@@ -0,0 +1,99 @@
+package models_test
+
+import (
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+func TestParseFechaNacimiento(t *testing.T) {
+    for _, tc := range []struct {
+        in, want, precision string
+    }{
+        {"2019-03-10", "2019-03-10", models.PrecisionDia},
+        {"2019-03", "2019-03-01", models.PrecisionMes},
+        {"2019", "2019-01-01", models.PrecisionAnio},
+    } {
+        got, precision, err := models.ParseFechaNacimiento(tc.in)
+        if err != nil || got.Format("2006-01-02") != tc.want || precision != tc.precision {
+            t.Errorf("ParseFechaNacimiento(%q) = %s, %q, %v", tc.in, got, precision, err)
+        }
+        if out := models.FormatFechaNacimiento(got, precision); out != tc.in {
+            t.Errorf("FormatFechaNacimiento = %q, want %q", out, tc.in)
+        }
+    }
+    for _, in := range []string{"", "19", "2019-3", "2019-13", "10/03/2019", "2019-03-10T00:00:00Z"} {
+        if _, _, err := models.ParseFechaNacimiento(in); err == nil {
+            t.Errorf("ParseFechaNacimiento(%q) accepted", in)
+        }
+    }
+}
+
+func TestEdad(t *testing.T) {
+    day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+    hoy := day(2030, 6, 5)
+    for _, tc := range []struct {
+        m     models.Mascota
+        edad  models.Edad
+        etapa string
+    }{
+        {models.Mascota{Especie: "Perro", FechaNacimiento: day(2019, 3, 10)}, models.Edad{Anios: 11, Meses: 2}, models.EtapaSenior},
+        {models.Mascota{Especie: "Gato", FechaNacimiento: day(2021, 11, 2)}, models.Edad{Anios: 8, Meses: 7}, models.EtapaAdulto},
+        {models.Mascota{Especie: "Perro", FechaNacimiento: day(2029, 6, 6)}, models.Edad{Meses: 11}, models.EtapaCachorro},
+        {models.Mascota{Especie: "Conejo", FechaNacimiento: day(2029, 12, 1), FechaNacimientoPrecision: models.PrecisionMes},
+            models.Edad{Meses: 6, Aproximada: true}, models.EtapaAdulto},
+        {models.Mascota{Especie: "Gato", FechaNacimiento: day(2018, 1, 1), FechaNacimientoPrecision: models.PrecisionAnio},
+            models.Edad{Anios: 12, Aproximada: true}, models.EtapaSenior},
+        {models.Mascota{Especie: "Perro", FechaNacimiento: day(2030, 7, 1)}, models.Edad{}, models.EtapaCachorro},
+    } {
+        if got := tc.m.Edad(hoy); got != tc.edad {
+            t.Errorf("%s born %s: Edad = %+v, want %+v", tc.m.Especie, tc.m.FechaNacimiento.Format("2006-01-02"), got, tc.edad)
+        }
+        if got := tc.m.Etapa(hoy); got != tc.etapa {
+            t.Errorf("%s born %s: Etapa = %q, want %q", tc.m.Especie, tc.m.FechaNacimiento.Format("2006-01-02"), got, tc.etapa)
+        }
+    }
+}
+
+func TestMesesCumplidos(t *testing.T) {
+    born := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+    for _, tc := range []struct {
+        dia  time.Time
+        want int
+    }{
+        {born, 0},
+        {time.Date(2030, 2, 28, 0, 0, 0, 0, time.UTC), 0},
+        {time.Date(2030, 3, 31, 0, 0, 0, 0, time.UTC), 2},
+        {time.Date(2031, 1, 30, 0, 0, 0, 0, time.UTC), 11},
+    } {
+        if got := models.MesesCumplidos(born, tc.dia); got != tc.want {
+            t.Errorf("MesesCumplidos(%s) = %d, want %d", tc.dia.Format("2006-01-02"), got, tc.want)
+        }
+    }
+}
+
+// TestNacimientoEtapa checks that the birth ranges the stores filter on
+// agree with Etapa on every birthday around the thresholds, month ends
+// included.
+func TestNacimientoEtapa(t *testing.T) {
+    for _, hoy := range []time.Time{
+        time.Date(2030, 6, 5, 0, 0, 0, 0, time.UTC),
+        time.Date(2030, 3, 31, 0, 0, 0, 0, time.UTC),
+        time.Date(2032, 2, 29, 0, 0, 0, 0, time.UTC),
+    } {
+        for _, especie := range []string{"Perro", "Gato", "Conejo"} {
+            for born := hoy.AddDate(-15, 0, 0); born.Before(hoy.AddDate(0, 1, 0)); born = born.AddDate(0, 0, 1) {
+                m := models.Mascota{Especie: especie, FechaNacimiento: born}
+                for _, etapa := range models.Etapas {
+                    despues, hasta, ok := models.NacimientoEtapa(especie, etapa, hoy)
+                    in := ok && (despues.IsZero() || born.After(despues)) && (hasta.IsZero() || !born.After(hasta))
+                    if in != (m.Etapa(hoy) == etapa) {
+                        t.Fatalf("%s born %s on %s: in %s range = %v, Etapa = %s", especie, born.Format("2006-01-02"),
+                            hoy.Format("2006-01-02"), etapa, in, m.Etapa(hoy))
+                    }
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// EventosChannel is the Postgres NOTIFY channel carrying the id of every
+// committed event.
+const EventosChannel = "eventos"
+
+// EventosLock is the transaction-level advisory lock held from the moment
+// an event is recorded until its transaction ends. A serial id is taken
+// when the row is inserted, not when it commits; without the lock an event
+// could commit after one with a higher id, and a client resuming from that
+// id, or an /eventos stream that has sent it, would never see the first.
+const EventosLock int64 = 0x6576656e746f73 // "eventos"
+
+// Domain event types, as used in webhook subscriptions.
+const (
+    EventMascotaCreated   = "mascota.created"
+    EventMascotaUpdated   = "mascota.updated"
+    EventMascotaDeleted   = "mascota.deleted"
+    EventCuidadoCreated   = "cuidado.created"
+    EventCuidadoUpdated   = "cuidado.updated"
+    EventCuidadoCompleted = "cuidado.completed"
+    EventCuidadoDeleted   = "cuidado.deleted"
+)
+
+var EventTypes = []string{
+    EventMascotaCreated, EventMascotaUpdated, EventMascotaDeleted,
+    EventCuidadoCreated, EventCuidadoUpdated, EventCuidadoCompleted, EventCuidadoDeleted,
+}
+
+// Evento is one entry of the event log.
+type Evento struct {
+    ID        int64           `json:"id"`
+    Tipo      string          `json:"tipo"`
+    RecursoID int64           `json:"recurso_id"`
+    MascotaID int64           `json:"mascota_id"`
+    Tenant    string          `json:"tenant,omitempty"`
+    Data      json.RawMessage `json:"data"`
+    CreadoEn  time.Time       `json:"creado_en"`
+}
+
+// EventoFilter selects events. Tipos holds exact types or a resource
+// prefix ("cuidado" matches every cuidado.* event); zero values match all.
+type EventoFilter struct {
+    MascotaID int64
+    Tipos     []string
+    // Tenant keeps the events of the mascotas of that tenant.
+    Tenant string
+}
+
+func (f EventoFilter) Match(e Evento) bool {
+    if f.MascotaID != 0 && e.MascotaID != f.MascotaID {
+        return false
+    }
+    if f.Tenant != "" && e.Tenant != f.Tenant {
+        return false
+    }
+    if len(f.Tipos) == 0 {
+        return true
+    }
+    resource, _, _ := strings.Cut(e.Tipo, ".")
+    for _, t := range f.Tipos {
+        if t == e.Tipo || t == resource {
+            return true
+        }
+    }
+    return false
+}
+
+type EventoRepository interface {
+    // ListSince returns up to limit matching events with id > afterID, in
+    // id order, which is the order they were committed in (see
+    // EventosLock).
+    ListSince(ctx context.Context, afterID int64, f EventoFilter, limit int) ([]Evento, error)
+    Get(ctx context.Context, id int64) (*Evento, error)
+}
+
+type EventoStore struct{ DB *sql.DB }
+
+var _ EventoRepository = EventoStore{}
+
+const eventoColumns = `id, tipo, recurso_id, COALESCE(mascota_id, 0), tenant, payload, creado_en`
+
+func scanEvento(row rowScanner, e *Evento) error {
+    var data []byte
+    if err := row.Scan(&e.ID, &e.Tipo, &e.RecursoID, &e.MascotaID, &e.Tenant, &data, &e.CreadoEn); err != nil {
+        return err
+    }
+    e.Data = data
+    return nil
+}
+
+func (s EventoStore) ListSince(ctx context.Context, afterID int64, f EventoFilter, limit int) ([]Evento, error) {
+    q := `SELECT ` + eventoColumns + ` FROM eventos
+          WHERE id > $1 AND ($2 = 0 OR mascota_id = $2)
+            AND (cardinality($3::text[]) = 0 OR tipo = ANY($3) OR split_part(tipo, '.', 1) = ANY($3))
+            AND ($5 = '' OR tenant = $5)
+          ORDER BY id LIMIT $4`
+    tipos := f.Tipos
+    if tipos == nil {
+        tipos = []string{}
+    }
+    rows, err := s.DB.QueryContext(ctx, q, afterID, f.MascotaID, tipos, limit, f.Tenant)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Evento, 0)
+    for rows.Next() {
+        var e Evento
+        if err := scanEvento(rows, &e); err != nil {
+            return nil, err
+        }
+        out = append(out, e)
+    }
+    return out, rows.Err()
+}
+
+func (s EventoStore) Get(ctx context.Context, id int64) (*Evento, error) {
+    var e Evento
+    if err := scanEvento(s.DB.QueryRowContext(ctx, `SELECT `+eventoColumns+` FROM eventos WHERE id=$1`, id), &e); err != nil {
+        return nil, notFound(err)
+    }
+    return &e, nil
+}
+
+// recordEvent appends an event to the log, queues one delivery for every
+// active webhook subscribed to it and announces it on EventosChannel. It
+// runs inside the transaction of the change, so an event exists (and is
+// announced, since NOTIFY is delivered on commit) exactly when the change
+// was committed.
+//
+// The event takes the tenant of the mascota, or of the payload once the
+// mascota is deleted. It takes EventosLock, so the rest of tx should not
+// wait on other transactions: record the events after the changes they
+// describe.
+func recordEvent(ctx context.Context, tx *sql.Tx, tipo string, recursoID, mascotaID int64, data any) error {
+    payload, err := json.Marshal(data)
+    if err != nil {
+        return err
+    }
+    if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, EventosLock); err != nil {
+        return err
+    }
+    var id int64
+    q := `INSERT INTO eventos(tipo, recurso_id, mascota_id, payload, tenant)
+          VALUES ($1,$2,$3,$4, COALESCE((SELECT tenant FROM mascotas WHERE id=$3), $4::jsonb->>'tenant', '')) RETURNING id`
+    if err := tx.QueryRowContext(ctx, q, tipo, recursoID, mascotaID, payload).Scan(&id); err != nil {
+        return err
+    }
+    q = `INSERT INTO webhook_entregas(webhook_id, evento_id)
+         SELECT id, $1 FROM webhooks WHERE activo AND ($2 = ANY(eventos) OR '*' = ANY(eventos))`
+    if _, err := tx.ExecContext(ctx, q, id, tipo); err != nil {
+        return err
+    }
+    _, err = tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, EventosChannel, strconv.FormatInt(id, 10))
+    return err
+}
+
+// deletedRef and cuidadoRef are the payloads of *.deleted events.
+type deletedRef struct {
+    ID     int64  `json:"id"`
+    Tenant string `json:"tenant,omitempty"`
+}
+
+type cuidadoRef struct {
+    ID        int64 `json:"id"`
+    MascotaID int64 `json:"mascota_id"`
+}
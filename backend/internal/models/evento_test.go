@@ -0,0 +1,65 @@
+package models_test
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/testutil/pgtest"
+)
+
+// TestEventosCommitOrder holds a transaction open after it recorded an
+// event and checks that a later change waits for it, so the event it
+// records gets the higher id and a client that saw it has seen the first.
+func TestEventosCommitOrder(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    mascotas := models.MascotaStore{DB: db.DB}
+    m := &models.Mascota{Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", Sexo: "Macho", FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)}
+    if err := mascotas.Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+
+    // A slow writer, recording its event as recordEvent does.
+    tx, err := db.DB.BeginTx(ctx, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer tx.Rollback()
+    var slow int64
+    if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, models.EventosLock); err != nil {
+        t.Fatal(err)
+    }
+    err = tx.QueryRowContext(ctx, `INSERT INTO eventos(tipo, recurso_id, mascota_id, payload) VALUES ($1,$2,$2,'{}') RETURNING id`,
+        models.EventMascotaUpdated, m.ID).Scan(&slow)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    done := make(chan error, 1)
+    go func() {
+        m := *m
+        m.Raza = "Labrador"
+        done <- mascotas.Update(ctx, &m)
+    }()
+    select {
+    case err := <-done:
+        t.Fatalf("Update recorded its event while an earlier one was uncommitted: %v", err)
+    case <-time.After(200 * time.Millisecond):
+    }
+    if err := tx.Commit(); err != nil {
+        t.Fatal(err)
+    }
+    if err := <-done; err != nil {
+        t.Fatal(err)
+    }
+
+    list, err := models.EventoStore{DB: db.DB}.ListSince(ctx, slow-1, models.EventoFilter{}, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(list) != 2 || list[0].ID != slow || list[1].ID <= slow || list[1].Tipo != models.EventMascotaUpdated {
+        t.Fatalf("events after %d = %+v", slow-1, list)
+    }
+}
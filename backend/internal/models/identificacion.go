@@ -0,0 +1,71 @@
+package models
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+)
+
+// Identification fields of a mascota; each is unique among the mascotas
+// that have it.
+const (
+    IdentMicrochip = "microchip"
+    IdentTatuaje   = "tatuaje"
+    IdentLicencia  = "licencia"
+)
+
+// DuplicateError is returned by MascotaRepository writes when another
+// mascota already has the same value in the identification Field.
+type DuplicateError struct {
+    Field string
+}
+
+func (e *DuplicateError) Error() string {
+    return "duplicate " + e.Field
+}
+
+// ErrInvalidMicrochip is returned by NormalizeMicrochip.
+var ErrInvalidMicrochip = errors.New("invalid microchip")
+
+// maxNationalID is the largest national identification code of ISO 11784,
+// which has 38 bits.
+const maxNationalID = 1<<38 - 1
+
+// NormalizeMicrochip checks an ISO 11784/11785 (FDX-B) microchip number
+// and returns it without the spaces, dots or dashes readers print it
+// with.
+//
+// The number has 15 digits: a 3-digit country code (ISO 3166 numeric,
+// 001-899) or manufacturer code (900-998), then a 12-digit national code
+// that must fit in 38 bits. The printed number carries no check digit (the
+// transponder's CRC protects only the radio frame), so these structural
+// rules are the whole check; 999, reserved for test transponders, and the
+// all-zero code are rejected.
+func NormalizeMicrochip(s string) (string, error) {
+    v := strings.Map(func(r rune) rune {
+        if r == ' ' || r == '.' || r == '-' {
+            return -1
+        }
+        return r
+    }, s)
+    if len(v) != 15 {
+        return "", ErrInvalidMicrochip
+    }
+    for _, r := range v {
+        if r < '0' || r > '9' {
+            return "", ErrInvalidMicrochip
+        }
+    }
+    code, _ := strconv.Atoi(v[:3])
+    national, _ := strconv.ParseInt(v[3:], 10, 64)
+    if code == 0 || code == 999 || national == 0 || national > maxNationalID {
+        return "", ErrInvalidMicrochip
+    }
+    return v, nil
+}
+
+// NormalizeTag trims a tattoo or license tag and upper-cases it, so the
+// same tag written differently is found and kept unique.
+func NormalizeTag(s string) string {
+    return strings.ToUpper(strings.Join(strings.Fields(s), " "))
+}
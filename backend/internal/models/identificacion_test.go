@@ -0,0 +1,36 @@
+package models_test
+
+import (
+    "errors"
+    "testing"
+
+    "mascotas/internal/models"
+)
+
+func TestNormalizeMicrochip(t *testing.T) {
+    valid := map[string]string{
+        "985112000123456":     "985112000123456",
+        "985 112 000 123 456": "985112000123456",
+        "250-26-9604-123456":  "250269604123456",
+        "900274877906943":     "900274877906943",
+    }
+    for in, want := range valid {
+        if got, err := models.NormalizeMicrochip(in); err != nil || got != want {
+            t.Errorf("NormalizeMicrochip(%q) = %q, %v; want %q", in, got, err, want)
+        }
+    }
+    for _, in := range []string{
+        "",
+        "98511200012345",   // 14 digits
+        "9851120001234567", // 16 digits
+        "98511200012345A",
+        "999000000000001", // test transponder
+        "000123456789012", // no country or manufacturer
+        "250000000000000", // no national code
+        "250274877906944", // national code over 38 bits
+    } {
+        if _, err := models.NormalizeMicrochip(in); !errors.Is(err, models.ErrInvalidMicrochip) {
+            t.Errorf("NormalizeMicrochip(%q): err = %v, want ErrInvalidMicrochip", in, err)
+        }
+    }
+}
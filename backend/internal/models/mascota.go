@@ -3,60 +3,295 @@ package models
 import (
     "context"
     "database/sql"
+    "errors"
+    "regexp"
+    "strconv"
+    "strings"
     "time"
+
+    "github.com/jackc/pgx/v5/pgconn"
 )
 
 type Mascota struct {
-    ID              int64     `json:"id"`
-    Nombre          string    `json:"nombre"`
-    Especie         string    `json:"especie"`
-    Raza            string    `json:"raza"`
-    FechaNacimiento time.Time `json:"fecha_nacimiento"`
-    Sexo            string    `json:"sexo"`
+    ID                  int64     `json:"id"`
+    Nombre              string    `json:"nombre"`
+    Especie             string    `json:"especie"`
+    Raza                string    `json:"raza"`
+    FechaNacimiento     time.Time `json:"fecha_nacimiento"`
+    // FechaNacimientoPrecision tells whether the whole FechaNacimiento is
+    // known (PrecisionDia, the default) or only its month or year.
+    FechaNacimientoPrecision string `json:"fecha_nacimiento_precision"`
+    Sexo                string    `json:"sexo"`
+    PropietarioNombre   string    `json:"propietario_nombre"`
+    PropietarioEmail    string    `json:"propietario_email"`
+    PropietarioTelefono string    `json:"propietario_telefono"`
+    // Microchip, Tatuaje and Licencia identify the animal; empty when not
+    // registered, unique otherwise.
+    Microchip           string    `json:"microchip"`
+    Tatuaje             string    `json:"tatuaje"`
+    Licencia            string    `json:"licencia"`
+    // Tenant is the clinic or branch the mascota belongs to, empty in a
+    // single-clinic installation. Its events carry it (see EventoFilter).
+    Tenant string `json:"tenant,omitempty"`
+}
+
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+// ValidTenant reports whether s can name a tenant: up to 40 lowercase
+// letters, digits, '-' and '_', starting with a letter or digit.
+func ValidTenant(s string) bool {
+    return tenantName.MatchString(s)
+}
+
+// DuplicateKey identifies a mascota when importing: the same name
+// (ignoring case and surrounding spaces), especie, birth date and owner
+// e-mail are taken to be the same animal.
+func (m Mascota) DuplicateKey() string {
+    return strings.Join([]string{
+        strings.ToLower(strings.TrimSpace(m.Nombre)),
+        m.Especie,
+        m.FechaNacimiento.Format("2006-01-02"),
+        strings.ToLower(strings.TrimSpace(m.PropietarioEmail)),
+    }, "\x00")
+}
+
+// MascotaFilter selects mascotas for listings and exports. Zero values
+// match everything; Limit 0 means no limit.
+type MascotaFilter struct {
+    Especie string
+    Sexo    string
+    // Nombre matches a case-insensitive part of the name.
+    Nombre string
+    // Etapa keeps the mascotas in that life stage on Dia.
+    Etapa  string
+    Dia    time.Time
+    Limit  int64
+    Offset int64
+}
+
+// Match reports whether m passes the filter, ignoring Limit and Offset.
+func (f MascotaFilter) Match(m Mascota) bool {
+    return (f.Especie == "" || m.Especie == f.Especie) &&
+        (f.Sexo == "" || m.Sexo == f.Sexo) &&
+        (f.Nombre == "" || strings.Contains(strings.ToLower(m.Nombre), strings.ToLower(f.Nombre))) &&
+        (f.Etapa == "" || m.Etapa(f.Dia) == f.Etapa)
 }
 
 type MascotaStore struct{ DB *sql.DB }
 
-func (s MascotaStore) Create(ctx context.Context, m *Mascota) error {
-    q := `INSERT INTO mascotas(nombre, especie, raza, fecha_nacimiento, sexo)
-          VALUES ($1,$2,$3,$4,$5) RETURNING id`
-    return s.DB.QueryRowContext(ctx, q, m.Nombre, m.Especie, m.Raza, m.FechaNacimiento, m.Sexo).Scan(&m.ID)
+const mascotaColumns = `id, nombre, especie, raza, fecha_nacimiento, sexo, propietario_nombre, propietario_email, propietario_telefono, microchip, tatuaje, licencia,
+    fecha_nacimiento_precision, tenant`
+
+type rowScanner interface {
+    Scan(dest ...any) error
 }
 
-func (s MascotaStore) Get(ctx context.Context, id int64) (*Mascota, error) {
-    q := `SELECT id, nombre, especie, raza, fecha_nacimiento, sexo FROM mascotas WHERE id=$1`
-    var m Mascota
-    err := s.DB.QueryRowContext(ctx, q, id).Scan(&m.ID, &m.Nombre, &m.Especie, &m.Raza, &m.FechaNacimiento, &m.Sexo)
-    if err != nil {
-        return nil, err
+func scanMascota(row rowScanner, m *Mascota) error {
+    return row.Scan(&m.ID, &m.Nombre, &m.Especie, &m.Raza, &m.FechaNacimiento, &m.Sexo, &m.PropietarioNombre, &m.PropietarioEmail, &m.PropietarioTelefono,
+        &m.Microchip, &m.Tatuaje, &m.Licencia, &m.FechaNacimientoPrecision, &m.Tenant)
+}
+
+// defaultPrecision marks a birth date without precision as exact.
+func defaultPrecision(m *Mascota) {
+    if m.FechaNacimientoPrecision == "" {
+        m.FechaNacimientoPrecision = PrecisionDia
     }
-    return &m, nil
 }
 
-func (s MascotaStore) List(ctx context.Context) ([]Mascota, error) {
-    q := `SELECT id, nombre, especie, raza, fecha_nacimiento, sexo FROM mascotas ORDER BY id`
-    rows, err := s.DB.QueryContext(ctx, q)
+func (s MascotaStore) Create(ctx context.Context, m *Mascota) error {
+    defaultPrecision(m)
+    return withTx(ctx, s.DB, func(tx *sql.Tx) error {
+        q := `INSERT INTO mascotas(nombre, especie, raza, fecha_nacimiento, sexo, propietario_nombre, propietario_email, propietario_telefono,
+              microchip, tatuaje, licencia, fecha_nacimiento_precision, tenant)
+              VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING id`
+        err := tx.QueryRowContext(ctx, q, m.Nombre, m.Especie, m.Raza, m.FechaNacimiento, m.Sexo, m.PropietarioNombre, m.PropietarioEmail, m.PropietarioTelefono,
+            m.Microchip, m.Tatuaje, m.Licencia, m.FechaNacimientoPrecision, m.Tenant).Scan(&m.ID)
+        if err != nil {
+            return duplicateIdent(err)
+        }
+        return recordEvent(ctx, tx, EventMascotaCreated, m.ID, m.ID, m)
+    })
+}
+
+func (s MascotaStore) CreateMany(ctx context.Context, ms []*Mascota) error {
+    return withTx(ctx, s.DB, func(tx *sql.Tx) error {
+        stmt, err := tx.PrepareContext(ctx, `INSERT INTO mascotas(nombre, especie, raza, fecha_nacimiento, sexo, propietario_nombre, propietario_email, propietario_telefono,
+              microchip, tatuaje, licencia, fecha_nacimiento_precision, tenant)
+              VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING id`)
+        if err != nil {
+            return err
+        }
+        defer stmt.Close()
+        for _, m := range ms {
+            defaultPrecision(m)
+            err := stmt.QueryRowContext(ctx, m.Nombre, m.Especie, m.Raza, m.FechaNacimiento, m.Sexo, m.PropietarioNombre, m.PropietarioEmail, m.PropietarioTelefono,
+                m.Microchip, m.Tatuaje, m.Licencia, m.FechaNacimientoPrecision, m.Tenant).Scan(&m.ID)
+            if err != nil {
+                return duplicateIdent(err)
+            }
+        }
+        for _, m := range ms {
+            if err := recordEvent(ctx, tx, EventMascotaCreated, m.ID, m.ID, m); err != nil {
+                return err
+            }
+        }
+        return nil
+    })
+}
+
+// FindDuplicates matches every candidate against the table in one query;
+// the comparison mirrors Mascota.DuplicateKey.
+func (s MascotaStore) FindDuplicates(ctx context.Context, ms []Mascota) ([]int64, error) {
+    out := make([]int64, len(ms))
+    if len(ms) == 0 {
+        return out, nil
+    }
+    nombres := make([]string, len(ms))
+    especies := make([]string, len(ms))
+    fechas := make([]string, len(ms))
+    emails := make([]string, len(ms))
+    for i, m := range ms {
+        nombres[i], especies[i], emails[i] = m.Nombre, m.Especie, m.PropietarioEmail
+        fechas[i] = m.FechaNacimiento.Format("2006-01-02")
+    }
+    q := `SELECT k.i, min(m.id)
+          FROM unnest($1::text[], $2::text[], $3::date[], $4::text[]) WITH ORDINALITY AS k(nombre, especie, fecha, email, i)
+          JOIN mascotas m ON lower(trim(m.nombre)) = lower(trim(k.nombre)) AND m.especie = k.especie
+            AND m.fecha_nacimiento = k.fecha AND lower(trim(m.propietario_email)) = lower(trim(k.email))
+          GROUP BY k.i`
+    rows, err := s.DB.QueryContext(ctx, q, nombres, especies, fechas, emails)
     if err != nil {
         return nil, err
     }
     defer rows.Close()
-    out := make([]Mascota, 0)
     for rows.Next() {
-        var m Mascota
-        if err := rows.Scan(&m.ID, &m.Nombre, &m.Especie, &m.Raza, &m.FechaNacimiento, &m.Sexo); err != nil {
+        var i, id int64
+        if err := rows.Scan(&i, &id); err != nil {
             return nil, err
         }
-        out = append(out, m)
+        out[i-1] = id
     }
-    if err := rows.Err(); err != nil {
-        return nil, err
+    return out, rows.Err()
+}
+
+func (s MascotaStore) Get(ctx context.Context, id int64) (*Mascota, error) {
+    q := `SELECT ` + mascotaColumns + ` FROM mascotas WHERE id=$1`
+    var m Mascota
+    if err := scanMascota(s.DB.QueryRowContext(ctx, q, id), &m); err != nil {
+        return nil, notFound(err)
     }
-    return out, nil
+    return &m, nil
+}
+
+// FindByIdentificacion looks a mascota up by one of the Ident* fields.
+func (s MascotaStore) FindByIdentificacion(ctx context.Context, field, value string) (*Mascota, error) {
+    if !identColumn(field) || value == "" {
+        return nil, ErrNotFound
+    }
+    q := `SELECT ` + mascotaColumns + ` FROM mascotas WHERE ` + field + `=$1`
+    var m Mascota
+    if err := scanMascota(s.DB.QueryRowContext(ctx, q, value), &m); err != nil {
+        return nil, notFound(err)
+    }
+    return &m, nil
+}
+
+func identColumn(field string) bool {
+    return field == IdentMicrochip || field == IdentTatuaje || field == IdentLicencia
+}
+
+// duplicateIdent maps a violation of the uq_mascotas_* unique indexes to a
+// *DuplicateError.
+func duplicateIdent(err error) error {
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+        if field, ok := strings.CutPrefix(pgErr.ConstraintName, "uq_mascotas_"); ok {
+            return &DuplicateError{Field: field}
+        }
+    }
+    return err
+}
+
+func (s MascotaStore) List(ctx context.Context) ([]Mascota, error) {
+    q := `SELECT ` + mascotaColumns + ` FROM mascotas ORDER BY id`
+    return s.query(ctx, q)
 }
 
 func (s MascotaStore) ListPaged(ctx context.Context, limit, offset int64) ([]Mascota, error) {
-    q := `SELECT id, nombre, especie, raza, fecha_nacimiento, sexo FROM mascotas ORDER BY id LIMIT $1 OFFSET $2`
-    rows, err := s.DB.QueryContext(ctx, q, limit, offset)
+    q := `SELECT ` + mascotaColumns + ` FROM mascotas ORDER BY id LIMIT $1 OFFSET $2`
+    return s.query(ctx, q, limit, offset)
+}
+
+func (s MascotaStore) Stream(ctx context.Context, f MascotaFilter, fn func(Mascota) error) error {
+    var where []string
+    var args []any
+    arg := func(v any) string {
+        args = append(args, v)
+        return "$" + strconv.Itoa(len(args))
+    }
+    if f.Especie != "" {
+        where = append(where, "especie = "+arg(f.Especie))
+    }
+    if f.Sexo != "" {
+        where = append(where, "sexo = "+arg(f.Sexo))
+    }
+    if f.Nombre != "" {
+        where = append(where, "strpos(lower(nombre), lower("+arg(f.Nombre)+")) > 0")
+    }
+    if f.Etapa != "" {
+        // The same birth ranges as Mascota.Etapa, one per especie.
+        var etapas []string
+        for _, especie := range []string{"Perro", "Gato", "Conejo"} {
+            despues, hasta, ok := NacimientoEtapa(especie, f.Etapa, f.Dia)
+            if !ok {
+                continue
+            }
+            cond := "especie = " + arg(especie)
+            if !despues.IsZero() {
+                cond += " AND fecha_nacimiento > " + arg(despues)
+            }
+            if !hasta.IsZero() {
+                cond += " AND fecha_nacimiento <= " + arg(hasta)
+            }
+            etapas = append(etapas, "("+cond+")")
+        }
+        if len(etapas) == 0 {
+            etapas = []string{"false"}
+        }
+        where = append(where, "("+strings.Join(etapas, " OR ")+")")
+    }
+    q := `SELECT ` + mascotaColumns + ` FROM mascotas`
+    if len(where) > 0 {
+        q += ` WHERE ` + strings.Join(where, " AND ")
+    }
+    q += ` ORDER BY id`
+    if f.Limit > 0 {
+        q += ` LIMIT ` + arg(f.Limit)
+    }
+    if f.Offset > 0 {
+        q += ` OFFSET ` + arg(f.Offset)
+    }
+    return withStreamTx(ctx, s.DB, func(tx *sql.Tx) error {
+        rows, err := tx.QueryContext(ctx, q, args...)
+        if err != nil {
+            return err
+        }
+        defer rows.Close()
+        for rows.Next() {
+            var m Mascota
+            if err := scanMascota(rows, &m); err != nil {
+                return err
+            }
+            if err := fn(m); err != nil {
+                return err
+            }
+        }
+        return rows.Err()
+    })
+}
+
+func (s MascotaStore) query(ctx context.Context, q string, args ...any) ([]Mascota, error) {
+    rows, err := s.DB.QueryContext(ctx, q, args...)
     if err != nil {
         return nil, err
     }
@@ -64,7 +299,7 @@ func (s MascotaStore) ListPaged(ctx context.Context, limit, offset int64) ([]Mas
     out := make([]Mascota, 0)
     for rows.Next() {
         var m Mascota
-        if err := rows.Scan(&m.ID, &m.Nombre, &m.Especie, &m.Raza, &m.FechaNacimiento, &m.Sexo); err != nil {
+        if err := scanMascota(rows, &m); err != nil {
             return nil, err
         }
         out = append(out, m)
@@ -76,12 +311,28 @@ func (s MascotaStore) ListPaged(ctx context.Context, limit, offset int64) ([]Mas
 }
 
 func (s MascotaStore) Update(ctx context.Context, m *Mascota) error {
-    q := `UPDATE mascotas SET nombre=$1, especie=$2, raza=$3, fecha_nacimiento=$4, sexo=$5 WHERE id=$6`
-    _, err := s.DB.ExecContext(ctx, q, m.Nombre, m.Especie, m.Raza, m.FechaNacimiento, m.Sexo, m.ID)
-    return err
+    defaultPrecision(m)
+    return withTx(ctx, s.DB, func(tx *sql.Tx) error {
+        q := `UPDATE mascotas SET nombre=$1, especie=$2, raza=$3, fecha_nacimiento=$4, sexo=$5,
+              propietario_nombre=$6, propietario_email=$7, propietario_telefono=$8, microchip=$9, tatuaje=$10, licencia=$11,
+              fecha_nacimiento_precision=$12, tenant=$13 WHERE id=$14`
+        res, err := tx.ExecContext(ctx, q, m.Nombre, m.Especie, m.Raza, m.FechaNacimiento, m.Sexo, m.PropietarioNombre, m.PropietarioEmail, m.PropietarioTelefono,
+            m.Microchip, m.Tatuaje, m.Licencia, m.FechaNacimientoPrecision, m.Tenant, m.ID)
+        if err := affectedOne(res, duplicateIdent(err)); err != nil {
+            return err
+        }
+        return recordEvent(ctx, tx, EventMascotaUpdated, m.ID, m.ID, m)
+    })
 }
 
+// Delete emits only mascota.deleted; the cuidados removed by the cascade do
+// not get events of their own.
 func (s MascotaStore) Delete(ctx context.Context, id int64) error {
-    _, err := s.DB.ExecContext(ctx, `DELETE FROM mascotas WHERE id=$1`, id)
-    return err
+    return withTx(ctx, s.DB, func(tx *sql.Tx) error {
+        var tenant string
+        if err := tx.QueryRowContext(ctx, `DELETE FROM mascotas WHERE id=$1 RETURNING tenant`, id).Scan(&tenant); err != nil {
+            return notFound(err)
+        }
+        return recordEvent(ctx, tx, EventMascotaDeleted, id, id, deletedRef{ID: id, Tenant: tenant})
+    })
 }
@@ -0,0 +1,285 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrDuplicateName is returned when a medicamento with the same name
+// already exists in the catalog.
+var ErrDuplicateName = errors.New("duplicate name")
+
+// Medicamento is an entry of the catalog. Doses are in mg per kg of body
+// weight; ConcentracionMg is how many mg one Unidad of the presentation
+// holds (a ml, a tablet), when it is known. Interacciones names the
+// principios activos or grupos it should not be combined with.
+type Medicamento struct {
+    ID                      int64    `json:"id"`
+    Nombre                  string   `json:"nombre"`
+    PrincipioActivo         string   `json:"principio_activo"`
+    Grupo                   string   `json:"grupo"`
+    Presentacion            string   `json:"presentacion"`
+    ConcentracionMg         *float64 `json:"concentracion_mg,omitempty"`
+    Unidad                  string   `json:"unidad"`
+    DosisMinMgKg            float64  `json:"dosis_min_mg_kg"`
+    DosisMaxMgKg            float64  `json:"dosis_max_mg_kg"`
+    Vias                    []string `json:"vias"`
+    EspeciesContraindicadas []string `json:"especies_contraindicadas"`
+    Interacciones           []string `json:"interacciones"`
+}
+
+// Prescripcion is a medicamento indicated in a cuidado, taken every
+// FrecuenciaHoras from FechaInicio to FechaFin, both included. Medicamento
+// is the catalog entry, read along with it. Advertencias are filled in by
+// the API.
+type Prescripcion struct {
+    ID               int64         `json:"id"`
+    MascotaID        int64         `json:"mascota_id"`
+    CuidadoID        int64         `json:"cuidado_id"`
+    MedicamentoID    int64         `json:"medicamento_id"`
+    Medicamento      *Medicamento  `json:"medicamento,omitempty"`
+    DosisMg          float64       `json:"dosis_mg"`
+    FrecuenciaHoras  int           `json:"frecuencia_horas"`
+    Via              string        `json:"via"`
+    DuracionDias     int           `json:"duracion_dias"`
+    FechaInicio      time.Time     `json:"fecha_inicio"`
+    FechaFin         time.Time     `json:"fecha_fin"`
+    Indicaciones     string        `json:"indicaciones"`
+    SuspendidaEn     *time.Time    `json:"suspendida_en,omitempty"`
+    MotivoSuspension string        `json:"motivo_suspension,omitempty"`
+    ReaccionAdversa  bool          `json:"reaccion_adversa"`
+    CreadoEn         time.Time     `json:"creado_en"`
+    Advertencias     []Advertencia `json:"advertencias,omitempty"`
+}
+
+// Activa reports whether the prescription is being taken on day, a date
+// at midnight UTC like FechaInicio and FechaFin.
+func (p Prescripcion) Activa(day time.Time) bool {
+    return p.Solapa(day, day)
+}
+
+// Solapa reports whether the prescription is taken on any day from desde
+// to hasta, both included.
+func (p Prescripcion) Solapa(desde, hasta time.Time) bool {
+    return p.SuspendidaEn == nil && !hasta.Before(p.FechaInicio) && !desde.After(p.FechaFin)
+}
+
+// Advertencia is a warning about a prescription or a cuidado.
+// PrescripcionID is the other prescription involved and AlertaID the
+// clinical alert, if any.
+type Advertencia struct {
+    Tipo           string `json:"tipo"`
+    Mensaje        string `json:"mensaje"`
+    PrescripcionID *int64 `json:"prescripcion_id,omitempty"`
+    AlertaID       *int64 `json:"alerta_id,omitempty"`
+}
+
+// MedicacionRepository stores the medicamento catalog and the
+// prescriptions. Lists of prescriptions are newest first (fecha_inicio,
+// then id descending).
+type MedicacionRepository interface {
+    // CreateMedicamento and UpdateMedicamento fail with ErrDuplicateName
+    // when the name is taken.
+    CreateMedicamento(ctx context.Context, m *Medicamento) error
+    GetMedicamento(ctx context.Context, id int64) (*Medicamento, error)
+    // ListMedicamentos is ordered by name.
+    ListMedicamentos(ctx context.Context) ([]Medicamento, error)
+    UpdateMedicamento(ctx context.Context, m *Medicamento) error
+    // CreatePrescripcion fails with ErrNotFound when the mascota, cuidado
+    // or medicamento is missing.
+    CreatePrescripcion(ctx context.Context, p *Prescripcion) error
+    GetPrescripcion(ctx context.Context, id int64) (*Prescripcion, error)
+    ListPrescripcionesByCuidado(ctx context.Context, cuidadoID int64) ([]Prescripcion, error)
+    ListPrescripcionesByMascota(ctx context.Context, mascotaID int64) ([]Prescripcion, error)
+    // SuspendPrescripcion stops a prescription at the given time; one
+    // already suspended keeps its first suspension.
+    SuspendPrescripcion(ctx context.Context, id int64, at time.Time, motivo string, reaccionAdversa bool) error
+}
+
+type MedicacionStore struct{ DB *sql.DB }
+
+var _ MedicacionRepository = MedicacionStore{}
+
+// The arrays are read back as JSON: database/sql cannot scan a TEXT[]
+// into a []string.
+const medicamentoColumns = `m.id, m.nombre, m.principio_activo, m.grupo, m.presentacion, m.concentracion_mg::float8, m.unidad,
+    m.dosis_min_mg_kg::float8, m.dosis_max_mg_kg::float8, to_json(m.vias), to_json(m.especies_contraindicadas), to_json(m.interacciones)`
+
+// medicamentoRow receives a row of medicamentoColumns until the arrays
+// are decoded.
+type medicamentoRow struct {
+    m                             Medicamento
+    concentracion                 sql.NullFloat64
+    vias, especies, interacciones []byte
+}
+
+func (r *medicamentoRow) dest() []any {
+    return []any{&r.m.ID, &r.m.Nombre, &r.m.PrincipioActivo, &r.m.Grupo, &r.m.Presentacion, &r.concentracion, &r.m.Unidad,
+        &r.m.DosisMinMgKg, &r.m.DosisMaxMgKg, &r.vias, &r.especies, &r.interacciones}
+}
+
+func (r *medicamentoRow) medicamento() (Medicamento, error) {
+    m := r.m
+    if r.concentracion.Valid {
+        v := r.concentracion.Float64
+        m.ConcentracionMg = &v
+    }
+    for _, f := range []struct {
+        raw []byte
+        v   *[]string
+    }{{r.vias, &m.Vias}, {r.especies, &m.EspeciesContraindicadas}, {r.interacciones, &m.Interacciones}} {
+        if err := json.Unmarshal(f.raw, f.v); err != nil {
+            return m, err
+        }
+    }
+    return m, nil
+}
+
+// medicamentoNameTaken maps a unique violation on the name.
+func medicamentoNameTaken(err error) error {
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+        return ErrDuplicateName
+    }
+    return err
+}
+
+func (s MedicacionStore) CreateMedicamento(ctx context.Context, m *Medicamento) error {
+    q := `INSERT INTO medicamentos(nombre, principio_activo, grupo, presentacion, concentracion_mg, unidad,
+              dosis_min_mg_kg, dosis_max_mg_kg, vias, especies_contraindicadas, interacciones)
+          VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING id`
+    err := s.DB.QueryRowContext(ctx, q, m.Nombre, m.PrincipioActivo, m.Grupo, m.Presentacion, m.ConcentracionMg, m.Unidad,
+        m.DosisMinMgKg, m.DosisMaxMgKg, m.Vias, m.EspeciesContraindicadas, m.Interacciones).Scan(&m.ID)
+    return medicamentoNameTaken(err)
+}
+
+func (s MedicacionStore) GetMedicamento(ctx context.Context, id int64) (*Medicamento, error) {
+    var row medicamentoRow
+    if err := s.DB.QueryRowContext(ctx, `SELECT `+medicamentoColumns+` FROM medicamentos m WHERE m.id=$1`, id).Scan(row.dest()...); err != nil {
+        return nil, notFound(err)
+    }
+    m, err := row.medicamento()
+    if err != nil {
+        return nil, err
+    }
+    return &m, nil
+}
+
+func (s MedicacionStore) ListMedicamentos(ctx context.Context) ([]Medicamento, error) {
+    rows, err := s.DB.QueryContext(ctx, `SELECT `+medicamentoColumns+` FROM medicamentos m ORDER BY m.nombre, m.id`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Medicamento, 0)
+    for rows.Next() {
+        var row medicamentoRow
+        if err := rows.Scan(row.dest()...); err != nil {
+            return nil, err
+        }
+        m, err := row.medicamento()
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, m)
+    }
+    return out, rows.Err()
+}
+
+func (s MedicacionStore) UpdateMedicamento(ctx context.Context, m *Medicamento) error {
+    q := `UPDATE medicamentos SET nombre=$1, principio_activo=$2, grupo=$3, presentacion=$4, concentracion_mg=$5, unidad=$6,
+              dosis_min_mg_kg=$7, dosis_max_mg_kg=$8, vias=$9, especies_contraindicadas=$10, interacciones=$11
+          WHERE id=$12`
+    res, err := s.DB.ExecContext(ctx, q, m.Nombre, m.PrincipioActivo, m.Grupo, m.Presentacion, m.ConcentracionMg, m.Unidad,
+        m.DosisMinMgKg, m.DosisMaxMgKg, m.Vias, m.EspeciesContraindicadas, m.Interacciones, m.ID)
+    return affectedOne(res, medicamentoNameTaken(err))
+}
+
+// Prescriptions are read joined with their medicamento.
+const prescripcionColumns = `p.id, p.mascota_id, p.cuidado_id, p.medicamento_id, p.dosis_mg::float8, p.frecuencia_horas, p.via,
+    p.duracion_dias, p.fecha_inicio, p.fecha_fin, p.indicaciones, p.suspendida_en, p.motivo_suspension, p.reaccion_adversa,
+    p.creado_en, ` + medicamentoColumns
+
+const prescripcionFrom = ` FROM prescripciones p JOIN medicamentos m ON m.id = p.medicamento_id`
+
+func scanPrescripcion(row rowScanner, p *Prescripcion) error {
+    var suspendida sql.NullTime
+    var med medicamentoRow
+    dest := append([]any{&p.ID, &p.MascotaID, &p.CuidadoID, &p.MedicamentoID, &p.DosisMg, &p.FrecuenciaHoras, &p.Via,
+        &p.DuracionDias, &p.FechaInicio, &p.FechaFin, &p.Indicaciones, &suspendida, &p.MotivoSuspension, &p.ReaccionAdversa,
+        &p.CreadoEn}, med.dest()...)
+    if err := row.Scan(dest...); err != nil {
+        return err
+    }
+    if suspendida.Valid {
+        p.SuspendidaEn = &suspendida.Time
+    }
+    m, err := med.medicamento()
+    if err != nil {
+        return err
+    }
+    p.Medicamento = &m
+    return nil
+}
+
+func (s MedicacionStore) CreatePrescripcion(ctx context.Context, p *Prescripcion) error {
+    q := `INSERT INTO prescripciones(mascota_id, cuidado_id, medicamento_id, dosis_mg, frecuencia_horas, via, duracion_dias,
+              fecha_inicio, fecha_fin, indicaciones)
+          VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id, creado_en`
+    err := s.DB.QueryRowContext(ctx, q, p.MascotaID, p.CuidadoID, p.MedicamentoID, p.DosisMg, p.FrecuenciaHoras, p.Via,
+        p.DuracionDias, p.FechaInicio, p.FechaFin, p.Indicaciones).Scan(&p.ID, &p.CreadoEn)
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+        return ErrNotFound
+    }
+    return err
+}
+
+func (s MedicacionStore) GetPrescripcion(ctx context.Context, id int64) (*Prescripcion, error) {
+    var p Prescripcion
+    if err := scanPrescripcion(s.DB.QueryRowContext(ctx, `SELECT `+prescripcionColumns+prescripcionFrom+` WHERE p.id=$1`, id), &p); err != nil {
+        return nil, notFound(err)
+    }
+    return &p, nil
+}
+
+func (s MedicacionStore) ListPrescripcionesByCuidado(ctx context.Context, cuidadoID int64) ([]Prescripcion, error) {
+    return s.listPrescripciones(ctx, `SELECT `+prescripcionColumns+prescripcionFrom+`
+          WHERE p.cuidado_id=$1 ORDER BY p.fecha_inicio DESC, p.id DESC`, cuidadoID)
+}
+
+func (s MedicacionStore) ListPrescripcionesByMascota(ctx context.Context, mascotaID int64) ([]Prescripcion, error) {
+    return s.listPrescripciones(ctx, `SELECT `+prescripcionColumns+prescripcionFrom+`
+          WHERE p.mascota_id=$1 ORDER BY p.fecha_inicio DESC, p.id DESC`, mascotaID)
+}
+
+func (s MedicacionStore) listPrescripciones(ctx context.Context, q string, args ...any) ([]Prescripcion, error) {
+    rows, err := s.DB.QueryContext(ctx, q, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Prescripcion, 0)
+    for rows.Next() {
+        var p Prescripcion
+        if err := scanPrescripcion(rows, &p); err != nil {
+            return nil, err
+        }
+        out = append(out, p)
+    }
+    return out, rows.Err()
+}
+
+func (s MedicacionStore) SuspendPrescripcion(ctx context.Context, id int64, at time.Time, motivo string, reaccionAdversa bool) error {
+    q := `UPDATE prescripciones SET suspendida_en=COALESCE(suspendida_en, $2),
+              motivo_suspension=CASE WHEN suspendida_en IS NULL THEN $3 ELSE motivo_suspension END,
+              reaccion_adversa=reaccion_adversa OR $4
+          WHERE id=$1`
+    res, err := s.DB.ExecContext(ctx, q, id, at, motivo, reaccionAdversa)
+    return affectedOne(res, err)
+}
@@ -0,0 +1,129 @@
+package models_test
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/testutil/pgtest"
+)
+
+// TestMedicacionStore round-trips the catalog, a prescription with its
+// medicamento and a suspension, and the latest weight.
+func TestMedicacionStore(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    store := models.MedicacionStore{DB: db.DB}
+    pesos := models.PesoStore{DB: db.DB}
+    m := &models.Mascota{Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", Sexo: "Macho", FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)}
+    if err := (models.MascotaStore{DB: db.DB}).Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+    c := &models.Cuidado{TipoCuidado: "Consulta Veterinaria", Descripcion: "Otitis", FechaCuidado: time.Date(2030, 6, 6, 9, 0, 0, 0, time.UTC), MascotaID: m.ID}
+    if err := (models.CuidadoStore{DB: db.DB}).Create(ctx, c); err != nil {
+        t.Fatal(err)
+    }
+
+    conc := 250.0
+    med := &models.Medicamento{Nombre: "Amoxicilina 250", PrincipioActivo: "amoxicilina", Grupo: "penicilinas", ConcentracionMg: &conc,
+        Unidad: "comprimido", DosisMinMgKg: 10, DosisMaxMgKg: 20, Vias: []string{"oral"}, EspeciesContraindicadas: []string{},
+        Interacciones: []string{"tetraciclinas"}}
+    if err := store.CreateMedicamento(ctx, med); err != nil {
+        t.Fatal(err)
+    }
+    dup := *med
+    if err := store.CreateMedicamento(ctx, &dup); !errors.Is(err, models.ErrDuplicateName) {
+        t.Fatalf("duplicate name: err = %v", err)
+    }
+    got, err := store.GetMedicamento(ctx, med.ID)
+    if err != nil || got.ConcentracionMg == nil || *got.ConcentracionMg != 250 || len(got.Vias) != 1 || got.Interacciones[0] != "tetraciclinas" {
+        t.Fatalf("GetMedicamento = %+v, %v", got, err)
+    }
+
+    inicio := time.Date(2030, 6, 6, 0, 0, 0, 0, time.UTC)
+    p := &models.Prescripcion{MascotaID: m.ID, CuidadoID: c.ID, MedicamentoID: med.ID, DosisMg: 125, FrecuenciaHoras: 12, Via: "oral",
+        DuracionDias: 7, FechaInicio: inicio, FechaFin: inicio.AddDate(0, 0, 6)}
+    if err := store.CreatePrescripcion(ctx, p); err != nil {
+        t.Fatal(err)
+    }
+    bad := *p
+    bad.MedicamentoID = 999
+    if err := store.CreatePrescripcion(ctx, &bad); !errors.Is(err, models.ErrNotFound) {
+        t.Fatalf("unknown medicamento: err = %v", err)
+    }
+    at := time.Date(2030, 6, 8, 10, 0, 0, 0, time.UTC)
+    if err := store.SuspendPrescripcion(ctx, p.ID, at, "Vómitos", true); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.SuspendPrescripcion(ctx, p.ID, at.Add(time.Hour), "Otra", false); err != nil {
+        t.Fatal(err)
+    }
+    list, err := store.ListPrescripcionesByMascota(ctx, m.ID)
+    if err != nil || len(list) != 1 {
+        t.Fatalf("ListPrescripcionesByMascota = %+v, %v", list, err)
+    }
+    if l := list[0]; l.Medicamento == nil || l.Medicamento.Nombre != "Amoxicilina 250" || !l.ReaccionAdversa ||
+        l.SuspendidaEn == nil || !l.SuspendidaEn.Equal(at) || l.MotivoSuspension != "Vómitos" || !l.FechaFin.Equal(inicio.AddDate(0, 0, 6)) {
+        t.Fatalf("prescripcion = %+v", l)
+    }
+
+    if _, err := pesos.Latest(ctx, m.ID); !errors.Is(err, models.ErrNotFound) {
+        t.Fatalf("Latest without weighings: err = %v", err)
+    }
+    for _, w := range []models.Peso{{PesoKg: 12.5, Fecha: at}, {PesoKg: 11.8, Fecha: at.AddDate(0, -2, 0)}} {
+        w.MascotaID = m.ID
+        if err := pesos.Create(ctx, &w); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if latest, err := pesos.Latest(ctx, m.ID); err != nil || latest.PesoKg != 12.5 {
+        t.Fatalf("Latest = %+v, %v", latest, err)
+    }
+    if err := pesos.Create(ctx, &models.Peso{MascotaID: 999, PesoKg: 3, Fecha: at}); !errors.Is(err, models.ErrNotFound) {
+        t.Fatalf("unknown mascota: err = %v", err)
+    }
+}
+
+// TestAlertaStore checks the order of the alerts and that Update keeps
+// the mascota.
+func TestAlertaStore(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    alertas := models.AlertaStore{DB: db.DB}
+    m := &models.Mascota{Nombre: "Misu", Especie: "Gato", Raza: "Siames", Sexo: "Hembra", FechaNacimiento: time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC)}
+    if err := (models.MascotaStore{DB: db.DB}).Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+    for _, a := range []*models.AlertaClinica{
+        {Tipo: models.AlertaComportamiento, Severidad: models.SeveridadLeve, Descripcion: "Muerde", TiposCuidado: []string{}},
+        {Tipo: models.AlertaAlergia, Severidad: models.SeveridadGrave, Descripcion: "Anafilaxia", Alergeno: "penicilinas", TiposCuidado: []string{"Vacunacion"}},
+        {Tipo: models.AlertaCondicionCronica, Severidad: models.SeveridadModerada, Descripcion: "Insuficiencia renal", TiposCuidado: []string{}},
+    } {
+        a.MascotaID, a.Activa = m.ID, true
+        if err := alertas.Create(ctx, a); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := alertas.Create(ctx, &models.AlertaClinica{MascotaID: m.ID, Tipo: models.AlertaAlergia, Severidad: models.SeveridadLeve,
+        Descripcion: "Sin alérgeno", TiposCuidado: []string{}}); err == nil {
+        t.Fatal("allergy without alergeno was stored")
+    }
+    list, err := alertas.ListByMascota(ctx, m.ID)
+    if err != nil || len(list) != 3 || list[0].Severidad != "grave" || list[1].Severidad != "moderada" || list[2].Severidad != "leve" {
+        t.Fatalf("ListByMascota = %+v, %v", list, err)
+    }
+    if len(list[0].TiposCuidado) != 1 || list[0].TiposCuidado[0] != "Vacunacion" {
+        t.Fatalf("tipos_cuidado = %v", list[0].TiposCuidado)
+    }
+    upd := list[2]
+    upd.MascotaID, upd.Activa = 0, false
+    if err := alertas.Update(ctx, &upd); err != nil || upd.MascotaID != m.ID {
+        t.Fatalf("Update = %+v, %v", upd, err)
+    }
+    if err := alertas.Create(ctx, &models.AlertaClinica{MascotaID: 999, Tipo: models.AlertaComportamiento, Severidad: models.SeveridadLeve,
+        Descripcion: "x", TiposCuidado: []string{}}); !errors.Is(err, models.ErrNotFound) {
+        t.Fatalf("unknown mascota: err = %v", err)
+    }
+}
@@ -0,0 +1,432 @@
+// Package memory provides in-memory repositories with the same observable
+// behaviour as the Postgres stores, for tests and local experiments.
+package memory
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// Store holds mascotas and cuidados together so deletes can cascade.
+type Store struct {
+    mu          sync.RWMutex
+    mascotas    map[int64]models.Mascota
+    cuidados    map[int64]models.Cuidado
+    // borrados are the tombstones of deleted cuidados (see ListBorrados).
+    borrados    map[int64]models.AgendaCuidado
+    lastMascota int64
+    lastCuidado int64
+}
+
+func New() *Store {
+    return &Store{
+        mascotas: make(map[int64]models.Mascota),
+        cuidados: make(map[int64]models.Cuidado),
+        borrados: make(map[int64]models.AgendaCuidado),
+    }
+}
+
+func (s *Store) Mascotas() models.MascotaRepository { return mascotaRepo{s} }
+func (s *Store) Cuidados() models.CuidadoRepository { return cuidadoRepo{s} }
+
+// Values come back the way Postgres returns them: DATE columns at UTC
+// midnight and TIMESTAMPTZ columns in the local zone at microsecond precision.
+
+// defaultPrecision marks a birth date without precision as exact, as
+// MascotaStore does.
+func defaultPrecision(m *models.Mascota) {
+    if m.FechaNacimientoPrecision == "" {
+        m.FechaNacimientoPrecision = models.PrecisionDia
+    }
+}
+
+func storedMascota(m models.Mascota) models.Mascota {
+    y, mo, d := m.FechaNacimiento.Date()
+    m.FechaNacimiento = time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+    return m
+}
+
+func storedCuidado(c models.Cuidado) models.Cuidado {
+    c.FechaCuidado = c.FechaCuidado.Round(time.Microsecond).In(time.Local)
+    return c
+}
+
+type mascotaRepo struct{ s *Store }
+
+// identField returns the value of an identification field of m.
+func identField(m models.Mascota, field string) string {
+    switch field {
+    case models.IdentMicrochip:
+        return m.Microchip
+    case models.IdentTatuaje:
+        return m.Tatuaje
+    case models.IdentLicencia:
+        return m.Licencia
+    }
+    return ""
+}
+
+var identFields = []string{models.IdentMicrochip, models.IdentTatuaje, models.IdentLicencia}
+
+// checkIdent mirrors the unique indexes on the identification fields: m
+// may not repeat a value of another stored mascota or of the ones in
+// pending.
+func (s *Store) checkIdent(m models.Mascota, pending []*models.Mascota) error {
+    for _, field := range identFields {
+        v := identField(m, field)
+        if v == "" {
+            continue
+        }
+        for id, o := range s.mascotas {
+            if id != m.ID && identField(o, field) == v {
+                return &models.DuplicateError{Field: field}
+            }
+        }
+        for _, o := range pending {
+            if identField(*o, field) == v {
+                return &models.DuplicateError{Field: field}
+            }
+        }
+    }
+    return nil
+}
+
+func (r mascotaRepo) Create(ctx context.Context, m *models.Mascota) error {
+    r.s.mu.Lock()
+    defer r.s.mu.Unlock()
+    if err := r.s.checkIdent(*m, nil); err != nil {
+        return err
+    }
+    defaultPrecision(m)
+    r.s.lastMascota++
+    m.ID = r.s.lastMascota
+    r.s.mascotas[m.ID] = storedMascota(*m)
+    return nil
+}
+
+func (r mascotaRepo) CreateMany(ctx context.Context, ms []*models.Mascota) error {
+    r.s.mu.Lock()
+    defer r.s.mu.Unlock()
+    for i, m := range ms {
+        n := *m
+        n.ID = 0
+        if err := r.s.checkIdent(n, ms[:i]); err != nil {
+            return err
+        }
+    }
+    for _, m := range ms {
+        defaultPrecision(m)
+        r.s.lastMascota++
+        m.ID = r.s.lastMascota
+        r.s.mascotas[m.ID] = storedMascota(*m)
+    }
+    return nil
+}
+
+func (r mascotaRepo) FindDuplicates(ctx context.Context, ms []models.Mascota) ([]int64, error) {
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    existing := make(map[string]int64, len(r.s.mascotas))
+    for id, m := range r.s.mascotas {
+        k := m.DuplicateKey()
+        if prev, ok := existing[k]; !ok || id < prev {
+            existing[k] = id
+        }
+    }
+    out := make([]int64, len(ms))
+    for i, m := range ms {
+        out[i] = existing[storedMascota(m).DuplicateKey()]
+    }
+    return out, nil
+}
+
+func (r mascotaRepo) Get(ctx context.Context, id int64) (*models.Mascota, error) {
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    m, ok := r.s.mascotas[id]
+    if !ok {
+        return nil, models.ErrNotFound
+    }
+    return &m, nil
+}
+
+func (r mascotaRepo) FindByIdentificacion(ctx context.Context, field, value string) (*models.Mascota, error) {
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    if value != "" {
+        for _, m := range r.s.mascotas {
+            if identField(m, field) == value {
+                return &m, nil
+            }
+        }
+    }
+    return nil, models.ErrNotFound
+}
+
+func (r mascotaRepo) List(ctx context.Context) ([]models.Mascota, error) {
+    return r.ListPaged(ctx, -1, 0)
+}
+
+// ListPaged treats a negative limit as "no limit".
+func (r mascotaRepo) ListPaged(ctx context.Context, limit, offset int64) ([]models.Mascota, error) {
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    all := make([]models.Mascota, 0, len(r.s.mascotas))
+    for _, m := range r.s.mascotas {
+        all = append(all, m)
+    }
+    sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+    return page(all, limit, offset), nil
+}
+
+// Stream works on a snapshot, so fn may call back into the store.
+func (r mascotaRepo) Stream(ctx context.Context, f models.MascotaFilter, fn func(models.Mascota) error) error {
+    r.s.mu.RLock()
+    all := make([]models.Mascota, 0)
+    for _, m := range r.s.mascotas {
+        if f.Match(m) {
+            all = append(all, m)
+        }
+    }
+    r.s.mu.RUnlock()
+    sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+    limit := f.Limit
+    if limit <= 0 {
+        limit = -1
+    }
+    for _, m := range page(all, limit, f.Offset) {
+        if err := fn(m); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (r mascotaRepo) Update(ctx context.Context, m *models.Mascota) error {
+    r.s.mu.Lock()
+    defer r.s.mu.Unlock()
+    if _, ok := r.s.mascotas[m.ID]; !ok {
+        return models.ErrNotFound
+    }
+    if err := r.s.checkIdent(*m, nil); err != nil {
+        return err
+    }
+    defaultPrecision(m)
+    r.s.mascotas[m.ID] = storedMascota(*m)
+    return nil
+}
+
+func (r mascotaRepo) Delete(ctx context.Context, id int64) error {
+    r.s.mu.Lock()
+    defer r.s.mu.Unlock()
+    if _, ok := r.s.mascotas[id]; !ok {
+        return models.ErrNotFound
+    }
+    delete(r.s.mascotas, id)
+    for cid, c := range r.s.cuidados {
+        if c.MascotaID == id {
+            r.s.deleteCuidado(cid)
+        }
+    }
+    return nil
+}
+
+type cuidadoRepo struct{ s *Store }
+
+func (r cuidadoRepo) Create(ctx context.Context, c *models.Cuidado) error {
+    r.s.mu.Lock()
+    defer r.s.mu.Unlock()
+    if _, ok := r.s.mascotas[c.MascotaID]; !ok {
+        return fmt.Errorf("mascota %d: %w", c.MascotaID, models.ErrNotFound)
+    }
+    if c.Estado == "" {
+        c.Estado = models.CuidadoProgramado
+    }
+    if err := r.checkBooking(c); err != nil {
+        return err
+    }
+    c.Secuencia = 0
+    r.s.lastCuidado++
+    c.ID = r.s.lastCuidado
+    r.s.cuidados[c.ID] = storedCuidado(*c)
+    return nil
+}
+
+func (r cuidadoRepo) Get(ctx context.Context, id int64) (*models.Cuidado, error) {
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    c, ok := r.s.cuidados[id]
+    if !ok {
+        return nil, models.ErrNotFound
+    }
+    return &c, nil
+}
+
+func (r cuidadoRepo) ListByMascota(ctx context.Context, mascotaID int64) ([]models.Cuidado, error) {
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    out := make([]models.Cuidado, 0)
+    for _, c := range r.s.cuidados {
+        if c.MascotaID == mascotaID {
+            out = append(out, c)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if !out[i].FechaCuidado.Equal(out[j].FechaCuidado) {
+            return out[i].FechaCuidado.After(out[j].FechaCuidado)
+        }
+        return out[i].ID > out[j].ID
+    })
+    return out, nil
+}
+
+func (r cuidadoRepo) ListBetween(ctx context.Context, desde, hasta time.Time) ([]models.Cuidado, error) {
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    out := make([]models.Cuidado, 0)
+    for _, c := range r.s.cuidados {
+        if !c.FechaCuidado.Before(desde) && c.FechaCuidado.Before(hasta) {
+            out = append(out, c)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if !out[i].FechaCuidado.Equal(out[j].FechaCuidado) {
+            return out[i].FechaCuidado.Before(out[j].FechaCuidado)
+        }
+        return out[i].ID < out[j].ID
+    })
+    return out, nil
+}
+
+// Stream works on a snapshot, so fn may call back into the store.
+func (r cuidadoRepo) Stream(ctx context.Context, f models.CuidadoFilter, fn func(models.Cuidado) error) error {
+    r.s.mu.RLock()
+    out := make([]models.Cuidado, 0)
+    for _, c := range r.s.cuidados {
+        if f.Match(c) {
+            out = append(out, c)
+        }
+    }
+    r.s.mu.RUnlock()
+    sort.Slice(out, func(i, j int) bool {
+        if !out[i].FechaCuidado.Equal(out[j].FechaCuidado) {
+            return out[i].FechaCuidado.Before(out[j].FechaCuidado)
+        }
+        return out[i].ID < out[j].ID
+    })
+    for _, c := range out {
+        if err := fn(c); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (r cuidadoRepo) ListAgenda(ctx context.Context, f models.CuidadoFilter) ([]models.AgendaCuidado, error) {
+    out := make([]models.AgendaCuidado, 0)
+    err := r.Stream(ctx, f, func(c models.Cuidado) error {
+        r.s.mu.RLock()
+        m := r.s.mascotas[c.MascotaID]
+        r.s.mu.RUnlock()
+        out = append(out, models.AgendaCuidado{Cuidado: c, MascotaNombre: m.Nombre, MascotaEspecie: m.Especie})
+        return nil
+    })
+    return out, err
+}
+
+func (r cuidadoRepo) Update(ctx context.Context, c *models.Cuidado) error {
+    r.s.mu.Lock()
+    defer r.s.mu.Unlock()
+    prev, ok := r.s.cuidados[c.ID]
+    if !ok {
+        return models.ErrNotFound
+    }
+    if _, ok := r.s.mascotas[c.MascotaID]; !ok {
+        return fmt.Errorf("mascota %d: %w", c.MascotaID, models.ErrNotFound)
+    }
+    if c.Estado == "" {
+        c.Estado = prev.Estado
+    }
+    if err := r.checkBooking(c); err != nil {
+        return err
+    }
+    c.Secuencia = prev.Secuencia + 1
+    r.s.cuidados[c.ID] = storedCuidado(*c)
+    return nil
+}
+
+// checkBooking returns a *models.BookingConflict if another cuidado of the
+// veterinario of c overlaps it. The caller holds the write lock.
+func (r cuidadoRepo) checkBooking(c *models.Cuidado) error {
+    if c.VeterinarioID == 0 || c.Estado == models.CuidadoCancelado {
+        return nil
+    }
+    var busy *models.Cuidado
+    for _, o := range r.s.cuidados {
+        if o.ID != c.ID && o.VeterinarioID == c.VeterinarioID && o.Estado != models.CuidadoCancelado && o.Overlaps(*c) &&
+            (busy == nil || o.FechaCuidado.Before(busy.FechaCuidado) || o.FechaCuidado.Equal(busy.FechaCuidado) && o.ID < busy.ID) {
+            busy = &o
+        }
+    }
+    if busy != nil {
+        return &models.BookingConflict{Cuidado: *busy}
+    }
+    return nil
+}
+
+func (r cuidadoRepo) Delete(ctx context.Context, id int64) error {
+    r.s.mu.Lock()
+    defer r.s.mu.Unlock()
+    if _, ok := r.s.cuidados[id]; !ok {
+        return models.ErrNotFound
+    }
+    r.s.deleteCuidado(id)
+    return nil
+}
+
+// deleteCuidado removes cuidado id and leaves its tombstone, like the
+// trigger of the Postgres store: after a deleted mascota, without its
+// name. The caller holds mu.
+func (s *Store) deleteCuidado(id int64) {
+    c := s.cuidados[id]
+    c.Estado = models.CuidadoCancelado
+    c.Secuencia++
+    m := s.mascotas[c.MascotaID]
+    s.borrados[id] = models.AgendaCuidado{Cuidado: c, MascotaNombre: m.Nombre, MascotaEspecie: m.Especie}
+    delete(s.cuidados, id)
+}
+
+func (r cuidadoRepo) ListBorrados(ctx context.Context, f models.CuidadoFilter) ([]models.AgendaCuidado, error) {
+    f.Estado = ""
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    out := make([]models.AgendaCuidado, 0)
+    for _, a := range r.s.borrados {
+        if f.Match(a.Cuidado) {
+            out = append(out, a)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if !out[i].FechaCuidado.Equal(out[j].FechaCuidado) {
+            return out[i].FechaCuidado.Before(out[j].FechaCuidado)
+        }
+        return out[i].ID < out[j].ID
+    })
+    return out, nil
+}
+
+func page[T any](all []T, limit, offset int64) []T {
+    if offset >= int64(len(all)) {
+        return make([]T, 0)
+    }
+    all = all[offset:]
+    if limit >= 0 && limit < int64(len(all)) {
+        all = all[:limit]
+    }
+    return all
+}
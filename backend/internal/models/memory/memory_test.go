@@ -0,0 +1,54 @@
+package memory_test
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+    "mascotas/internal/models/repotest"
+)
+
+func TestConformance(t *testing.T) {
+    repotest.Run(t, func(t *testing.T) repotest.Repos {
+        s := memory.New()
+        return repotest.Repos{Mascotas: s.Mascotas(), Cuidados: s.Cuidados()}
+    })
+}
+
+func TestCuidadoBookingConflict(t *testing.T) {
+    ctx := context.Background()
+    s := memory.New()
+    m := &models.Mascota{Nombre: "Misu", Especie: "Gato", Raza: "Siames", Sexo: "Hembra", FechaNacimiento: time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC)}
+    if err := s.Mascotas().Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+    fecha := time.Date(2030, 6, 6, 9, 0, 0, 0, time.UTC)
+    first := &models.Cuidado{TipoCuidado: "Vacunacion", Descripcion: "Refuerzo", FechaCuidado: fecha, MascotaID: m.ID, VeterinarioID: 1}
+    if err := s.Cuidados().Create(ctx, first); err != nil {
+        t.Fatal(err)
+    }
+    var conflict *models.BookingConflict
+    second := &models.Cuidado{TipoCuidado: "Bano", Descripcion: "Baño", FechaCuidado: fecha.Add(-30 * time.Minute), MascotaID: m.ID, VeterinarioID: 1}
+    if err := s.Cuidados().Create(ctx, second); !errors.As(err, &conflict) || conflict.Cuidado.ID != first.ID {
+        t.Fatalf("overlapping Create = %v", err)
+    }
+    second.FechaCuidado = fecha.Add(20 * time.Minute)
+    if err := s.Cuidados().Create(ctx, second); err != nil {
+        t.Fatalf("Create right after = %v", err)
+    }
+    first.Estado = models.CuidadoCancelado
+    if err := s.Cuidados().Update(ctx, first); err != nil {
+        t.Fatal(err)
+    }
+    second.FechaCuidado = fecha
+    if err := s.Cuidados().Update(ctx, second); err != nil {
+        t.Fatalf("Update into a cancelled slot = %v", err)
+    }
+    first.Estado = models.CuidadoProgramado
+    if err := s.Cuidados().Update(ctx, first); !errors.As(err, &conflict) || conflict.Cuidado.ID != second.ID {
+        t.Fatalf("reactivating Update = %v", err)
+    }
+}
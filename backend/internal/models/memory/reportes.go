@@ -0,0 +1,154 @@
+package memory
+
+import (
+    "context"
+    "sort"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// Reportes computes the statistics over the stored mascotas and cuidados.
+func (s *Store) Reportes() models.ReporteRepository { return reporteRepo{s} }
+
+type reporteRepo struct{ s *Store }
+
+// cuidados returns the cuidados in the range and of the tipo of f.
+func (r reporteRepo) cuidados(f models.ReporteFiltro) []models.Cuidado {
+    r.s.mu.RLock()
+    defer r.s.mu.RUnlock()
+    cf := models.CuidadoFilter{Desde: f.Desde, Hasta: f.Hasta, Tipo: f.Tipo}
+    out := make([]models.Cuidado, 0)
+    for _, c := range r.s.cuidados {
+        if cf.Match(c) {
+            out = append(out, c)
+        }
+    }
+    return out
+}
+
+func (r reporteRepo) MascotasPorGrupo(ctx context.Context, porEspecie, porSexo bool) ([]models.GrupoMascotas, error) {
+    r.s.mu.RLock()
+    counts := make(map[models.GrupoMascotas]int)
+    for _, m := range r.s.mascotas {
+        var g models.GrupoMascotas
+        if porEspecie {
+            g.Especie = m.Especie
+        }
+        if porSexo {
+            g.Sexo = m.Sexo
+        }
+        counts[g]++
+    }
+    r.s.mu.RUnlock()
+    out := make([]models.GrupoMascotas, 0, len(counts))
+    for g, n := range counts {
+        g.Total = n
+        out = append(out, g)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Especie != out[j].Especie {
+            return out[i].Especie < out[j].Especie
+        }
+        return out[i].Sexo < out[j].Sexo
+    })
+    return out, nil
+}
+
+func (r reporteRepo) Edades(ctx context.Context, dia time.Time, especie string) ([]models.ConteoEdad, error) {
+    r.s.mu.RLock()
+    counts := make(map[int]int)
+    for _, m := range r.s.mascotas {
+        if especie != "" && m.Especie != especie {
+            continue
+        }
+        born := m.FechaNacimiento
+        if born.After(dia) {
+            continue
+        }
+        years := dia.Year() - born.Year()
+        if dia.Month() < born.Month() || (dia.Month() == born.Month() && dia.Day() < born.Day()) {
+            years--
+        }
+        counts[years]++
+    }
+    r.s.mu.RUnlock()
+    out := make([]models.ConteoEdad, 0, len(counts))
+    for years, n := range counts {
+        out = append(out, models.ConteoEdad{Anios: years, Total: n})
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Anios < out[j].Anios })
+    return out, nil
+}
+
+func (r reporteRepo) CuidadosPorPeriodo(ctx context.Context, f models.ReporteFiltro, periodo string) ([]models.CuidadosPeriodo, error) {
+    type key struct{ periodo, tipo string }
+    rows := make(map[key]*models.CuidadosPeriodo)
+    for _, c := range r.cuidados(f) {
+        k := key{models.Periodo(c.FechaCuidado.In(f.Location), periodo), c.TipoCuidado}
+        row := rows[k]
+        if row == nil {
+            row = &models.CuidadosPeriodo{Periodo: k.periodo, Tipo: k.tipo}
+            rows[k] = row
+        }
+        row.Total++
+        switch c.Estado {
+        case models.CuidadoProgramado:
+            row.Programados++
+        case models.CuidadoCompletado:
+            row.Completados++
+        case models.CuidadoCancelado:
+            row.Cancelados++
+        }
+    }
+    out := make([]models.CuidadosPeriodo, 0, len(rows))
+    for _, row := range rows {
+        out = append(out, *row)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Periodo != out[j].Periodo {
+            return out[i].Periodo < out[j].Periodo
+        }
+        return out[i].Tipo < out[j].Tipo
+    })
+    return out, nil
+}
+
+func (r reporteRepo) Cumplimiento(ctx context.Context, f models.ReporteFiltro, hoy time.Time) ([]models.CumplimientoTipo, error) {
+    rows := make(map[string]*models.CumplimientoTipo)
+    for _, c := range r.cuidados(f) {
+        row := rows[c.TipoCuidado]
+        if row == nil {
+            row = &models.CumplimientoTipo{Tipo: c.TipoCuidado}
+            rows[c.TipoCuidado] = row
+        }
+        switch {
+        case !c.FechaCuidado.Before(hoy):
+            if c.Estado != models.CuidadoCancelado {
+                row.Pendientes++
+            }
+        case c.Estado == models.CuidadoCompletado:
+            row.Completados++
+        case c.Estado == models.CuidadoCancelado:
+            row.Cancelados++
+        default:
+            row.NoPresentados++
+        }
+    }
+    out := make([]models.CumplimientoTipo, 0, len(rows))
+    for _, row := range rows {
+        out = append(out, *row)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Tipo < out[j].Tipo })
+    return out, nil
+}
+
+func (r reporteRepo) PorDiaSemana(ctx context.Context, f models.ReporteFiltro) ([7]int, error) {
+    var out [7]int
+    for _, c := range r.cuidados(f) {
+        if c.Estado != models.CuidadoCancelado {
+            out[c.FechaCuidado.In(f.Location).Weekday()]++
+        }
+    }
+    return out, nil
+}
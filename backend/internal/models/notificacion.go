@@ -0,0 +1,196 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "time"
+)
+
+const (
+    NotificacionRecordatorio = "recordatorio"
+
+    NotificacionPendiente = "pendiente"
+    NotificacionEnviada   = "enviada"
+    NotificacionFallida   = "fallida"
+    NotificacionOmitida   = "omitida"
+)
+
+// Notificacion is one message in the outbox together with its delivery log.
+type Notificacion struct {
+    ID             int64                 `json:"id"`
+    CuidadoID      int64                 `json:"cuidado_id"`
+    Tipo           string                `json:"tipo"`
+    EnviarEn       time.Time             `json:"enviar_en"`
+    Estado         string                `json:"estado"`
+    Intentos       int                   `json:"intentos"`
+    ProximoIntento time.Time             `json:"proximo_intento"`
+    UltimoError    string                `json:"ultimo_error,omitempty"`
+    CreadoEn       time.Time             `json:"creado_en"`
+    EnviadoEn      *time.Time            `json:"enviado_en,omitempty"`
+    Entregas       []NotificacionEntrega `json:"entregas"`
+}
+
+type NotificacionEntrega struct {
+    Intento  int       `json:"intento"`
+    Estado   string    `json:"estado"`
+    Detalle  string    `json:"detalle,omitempty"`
+    CreadoEn time.Time `json:"creado_en"`
+}
+
+// Reminder is a claimed outbox entry joined with what the message needs.
+type Reminder struct {
+    ID                int64
+    Intentos          int
+    CuidadoID         int64
+    TipoCuidado       string
+    Descripcion       string
+    FechaCuidado      time.Time
+    EstadoCuidado     string
+    Mascota           string
+    PropietarioNombre string
+    PropietarioEmail  string
+}
+
+type NotificacionRepository interface {
+    ListByCuidado(ctx context.Context, cuidadoID int64) ([]Notificacion, error)
+}
+
+type NotificacionStore struct{ DB *sql.DB }
+
+var _ NotificacionRepository = NotificacionStore{}
+
+func enqueueReminder(ctx context.Context, tx *sql.Tx, cuidadoID int64, at time.Time) error {
+    q := `INSERT INTO notificaciones(cuidado_id, tipo, enviar_en, proximo_intento) VALUES ($1,$2,$3,$3)`
+    _, err := tx.ExecContext(ctx, q, cuidadoID, NotificacionRecordatorio, at)
+    return err
+}
+
+func rescheduleReminders(ctx context.Context, tx *sql.Tx, cuidadoID int64, at time.Time) error {
+    q := `UPDATE notificaciones SET enviar_en=$1, proximo_intento=$1, intentos=0, ultimo_error=''
+          WHERE cuidado_id=$2 AND estado=$3 AND enviar_en<>$1`
+    _, err := tx.ExecContext(ctx, q, at, cuidadoID, NotificacionPendiente)
+    return err
+}
+
+// ClaimDue leases up to limit pending reminders due at now by pushing their
+// next attempt to leaseUntil. SKIP LOCKED lets several replicas dispatch
+// concurrently without sending the same reminder twice.
+func (s NotificacionStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Reminder, error) {
+    q := `WITH due AS (
+            SELECT id FROM notificaciones
+            WHERE estado=$1 AND proximo_intento <= $2
+            ORDER BY proximo_intento
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+          )
+          UPDATE notificaciones n SET proximo_intento=$4
+          FROM due, cuidados c, mascotas m
+          WHERE n.id=due.id AND c.id=n.cuidado_id AND m.id=c.mascota_id
+          RETURNING n.id, n.intentos, c.id, c.tipo_cuidado, c.descripcion, c.fecha_cuidado, c.estado,
+                    m.nombre, m.propietario_nombre, m.propietario_email`
+    rows, err := s.DB.QueryContext(ctx, q, NotificacionPendiente, now, limit, leaseUntil)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Reminder, 0)
+    for rows.Next() {
+        var r Reminder
+        if err := rows.Scan(&r.ID, &r.Intentos, &r.CuidadoID, &r.TipoCuidado, &r.Descripcion, &r.FechaCuidado, &r.EstadoCuidado,
+            &r.Mascota, &r.PropietarioNombre, &r.PropietarioEmail); err != nil {
+            return nil, err
+        }
+        out = append(out, r)
+    }
+    return out, rows.Err()
+}
+
+// MarkSent, MarkRetry, MarkFailed and MarkSkipped record the outcome of one
+// attempt, updating the outbox row and appending to the delivery log in a
+// single statement.
+
+func (s NotificacionStore) MarkSent(ctx context.Context, id int64, attempt int, at time.Time) error {
+    return s.record(ctx, id, attempt, NotificacionEnviada, "", at, at)
+}
+
+func (s NotificacionStore) MarkRetry(ctx context.Context, id int64, attempt int, next time.Time, reason string) error {
+    return s.record(ctx, id, attempt, NotificacionPendiente, reason, next, time.Time{})
+}
+
+func (s NotificacionStore) MarkFailed(ctx context.Context, id int64, attempt int, reason string) error {
+    return s.record(ctx, id, attempt, NotificacionFallida, reason, time.Time{}, time.Time{})
+}
+
+func (s NotificacionStore) MarkSkipped(ctx context.Context, id int64, attempt int, reason string) error {
+    return s.record(ctx, id, attempt, NotificacionOmitida, reason, time.Time{}, time.Time{})
+}
+
+func (s NotificacionStore) record(ctx context.Context, id int64, attempt int, estado, detalle string, next, sentAt time.Time) error {
+    logEstado := estado
+    if estado == NotificacionPendiente {
+        logEstado = "error"
+    }
+    q := `WITH n AS (
+            UPDATE notificaciones SET estado=$2, intentos=$3, ultimo_error=$4,
+              proximo_intento=COALESCE($5, proximo_intento), enviado_en=$6
+            WHERE id=$1 RETURNING id
+          )
+          INSERT INTO notificacion_entregas(notificacion_id, intento, estado, detalle)
+          SELECT id, $3, $7, $4 FROM n`
+    res, err := s.DB.ExecContext(ctx, q, id, estado, attempt, detalle, nullTime(next), nullTime(sentAt), logEstado)
+    return affectedOne(res, err)
+}
+
+func (s NotificacionStore) ListByCuidado(ctx context.Context, cuidadoID int64) ([]Notificacion, error) {
+    q := `SELECT id, cuidado_id, tipo, enviar_en, estado, intentos, proximo_intento, ultimo_error, creado_en, enviado_en
+          FROM notificaciones WHERE cuidado_id=$1 ORDER BY id`
+    rows, err := s.DB.QueryContext(ctx, q, cuidadoID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Notificacion, 0)
+    index := make(map[int64]int)
+    for rows.Next() {
+        var n Notificacion
+        var sent sql.NullTime
+        if err := rows.Scan(&n.ID, &n.CuidadoID, &n.Tipo, &n.EnviarEn, &n.Estado, &n.Intentos, &n.ProximoIntento,
+            &n.UltimoError, &n.CreadoEn, &sent); err != nil {
+            return nil, err
+        }
+        if sent.Valid {
+            n.EnviadoEn = &sent.Time
+        }
+        n.Entregas = make([]NotificacionEntrega, 0)
+        index[n.ID] = len(out)
+        out = append(out, n)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    rows.Close()
+
+    q = `SELECT e.notificacion_id, e.intento, e.estado, e.detalle, e.creado_en
+         FROM notificacion_entregas e JOIN notificaciones n ON n.id=e.notificacion_id
+         WHERE n.cuidado_id=$1 ORDER BY e.id`
+    rows, err = s.DB.QueryContext(ctx, q, cuidadoID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var id int64
+        var e NotificacionEntrega
+        if err := rows.Scan(&id, &e.Intento, &e.Estado, &e.Detalle, &e.CreadoEn); err != nil {
+            return nil, err
+        }
+        if i, ok := index[id]; ok {
+            out[i].Entregas = append(out[i].Entregas, e)
+        }
+    }
+    return out, rows.Err()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+    return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
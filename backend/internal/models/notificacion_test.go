@@ -0,0 +1,81 @@
+package models_test
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/testutil/pgtest"
+)
+
+// TestNotificacionOutbox follows one reminder from the cuidado insert to a
+// failed attempt and a successful delivery.
+func TestNotificacionOutbox(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    mascotas := models.MascotaStore{DB: db.DB}
+    cuidados := models.CuidadoStore{DB: db.DB, ReminderLead: 24 * time.Hour}
+    outbox := models.NotificacionStore{DB: db.DB}
+
+    m := &models.Mascota{Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", Sexo: "Macho",
+        FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC), PropietarioEmail: "ana@example.com"}
+    if err := mascotas.Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+    fecha := time.Date(2030, 6, 10, 9, 0, 0, 0, time.UTC)
+    c := &models.Cuidado{TipoCuidado: "Bano", Descripcion: "Baño", FechaCuidado: fecha, MascotaID: m.ID}
+    if err := cuidados.Create(ctx, c); err != nil {
+        t.Fatal(err)
+    }
+
+    // Moving the cuidado moves the pending reminder with it.
+    c.FechaCuidado = fecha.Add(48 * time.Hour)
+    if err := cuidados.Update(ctx, c); err != nil {
+        t.Fatal(err)
+    }
+    dueAt := c.FechaCuidado.Add(-24 * time.Hour)
+
+    early, err := outbox.ClaimDue(ctx, dueAt.Add(-time.Minute), dueAt, 10)
+    if err != nil || len(early) != 0 {
+        t.Fatalf("claim before due = %v, %v; want none", early, err)
+    }
+    due, err := outbox.ClaimDue(ctx, dueAt, dueAt.Add(time.Hour), 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(due) != 1 || due[0].CuidadoID != c.ID || due[0].PropietarioEmail != "ana@example.com" || due[0].Mascota != "Firulais" {
+        t.Fatalf("claimed = %+v", due)
+    }
+    // The lease hides the row from a second dispatcher.
+    if again, _ := outbox.ClaimDue(ctx, dueAt, dueAt.Add(time.Hour), 10); len(again) != 0 {
+        t.Fatalf("leased reminder claimed twice: %+v", again)
+    }
+
+    id := due[0].ID
+    if err := outbox.MarkRetry(ctx, id, 1, dueAt.Add(time.Minute), "550 mailbox unavailable"); err != nil {
+        t.Fatal(err)
+    }
+    if err := outbox.MarkSent(ctx, id, 2, dueAt.Add(2*time.Minute)); err != nil {
+        t.Fatal(err)
+    }
+
+    list, err := outbox.ListByCuidado(ctx, c.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(list) != 1 {
+        t.Fatalf("notificaciones = %+v, want 1", list)
+    }
+    n := list[0]
+    if n.Estado != models.NotificacionEnviada || n.Intentos != 2 || n.EnviadoEn == nil || !n.EnviarEn.Equal(dueAt) {
+        t.Fatalf("notificacion = %+v", n)
+    }
+    if len(n.Entregas) != 2 || n.Entregas[0].Estado != "error" || n.Entregas[1].Estado != models.NotificacionEnviada {
+        t.Fatalf("entregas = %+v", n.Entregas)
+    }
+
+    if err := outbox.MarkSent(ctx, 999, 1, dueAt); err == nil {
+        t.Fatal("marking an unknown notificacion should fail")
+    }
+}
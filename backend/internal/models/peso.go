@@ -0,0 +1,69 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "time"
+)
+
+// Peso is one weighing of a mascota.
+type Peso struct {
+    ID        int64     `json:"id"`
+    MascotaID int64     `json:"mascota_id"`
+    PesoKg    float64   `json:"peso_kg"`
+    Fecha     time.Time `json:"fecha"`
+    Nota      string    `json:"nota"`
+}
+
+// PesoRepository stores weighings. Lists are newest first (fecha, then id
+// descending), so the first one is the current weight.
+type PesoRepository interface {
+    // Create fails with ErrNotFound when the mascota is missing.
+    Create(ctx context.Context, p *Peso) error
+    ListByMascota(ctx context.Context, mascotaID int64) ([]Peso, error)
+    // Latest returns ErrNotFound when the mascota was never weighed.
+    Latest(ctx context.Context, mascotaID int64) (*Peso, error)
+}
+
+type PesoStore struct{ DB *sql.DB }
+
+var _ PesoRepository = PesoStore{}
+
+const pesoColumns = `id, mascota_id, peso_kg::float8, fecha, nota`
+
+func scanPeso(row rowScanner, p *Peso) error {
+    return row.Scan(&p.ID, &p.MascotaID, &p.PesoKg, &p.Fecha, &p.Nota)
+}
+
+func (s PesoStore) Create(ctx context.Context, p *Peso) error {
+    q := `INSERT INTO pesos(mascota_id, peso_kg, fecha, nota) VALUES ($1,$2,$3,$4) RETURNING id`
+    err := s.DB.QueryRowContext(ctx, q, p.MascotaID, p.PesoKg, p.Fecha, p.Nota).Scan(&p.ID)
+    return mascotaRef(err, p.MascotaID)
+}
+
+func (s PesoStore) ListByMascota(ctx context.Context, mascotaID int64) ([]Peso, error) {
+    q := `SELECT ` + pesoColumns + ` FROM pesos WHERE mascota_id=$1 ORDER BY fecha DESC, id DESC`
+    rows, err := s.DB.QueryContext(ctx, q, mascotaID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Peso, 0)
+    for rows.Next() {
+        var p Peso
+        if err := scanPeso(rows, &p); err != nil {
+            return nil, err
+        }
+        out = append(out, p)
+    }
+    return out, rows.Err()
+}
+
+func (s PesoStore) Latest(ctx context.Context, mascotaID int64) (*Peso, error) {
+    q := `SELECT ` + pesoColumns + ` FROM pesos WHERE mascota_id=$1 ORDER BY fecha DESC, id DESC LIMIT 1`
+    var p Peso
+    if err := scanPeso(s.DB.QueryRowContext(ctx, q, mascotaID), &p); err != nil {
+        return nil, notFound(err)
+    }
+    return &p, nil
+}
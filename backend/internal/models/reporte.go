@@ -0,0 +1,298 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Periods of CuidadosPorPeriodo.
+const (
+    PeriodoMes    = "mes"
+    PeriodoSemana = "semana"
+)
+
+// ReporteFiltro narrows the cuidado reports to desde <= fecha_cuidado <
+// hasta (zero values leave that side open) and to a tipo. Location is the
+// clinic's time zone, which months, weeks and weekdays are counted in.
+type ReporteFiltro struct {
+    Desde    time.Time
+    Hasta    time.Time
+    Tipo     string
+    Location *time.Location
+}
+
+// GrupoMascotas counts the mascotas of an especie and/or sexo; the field
+// not grouped by is empty.
+type GrupoMascotas struct {
+    Especie string `json:"especie,omitempty"`
+    Sexo    string `json:"sexo,omitempty"`
+    Total   int    `json:"total"`
+}
+
+// ConteoEdad counts the mascotas that are Anios full years old.
+type ConteoEdad struct {
+    Anios int `json:"anios"`
+    Total int `json:"total"`
+}
+
+// CuidadosPeriodo counts the cuidados of a tipo in a period, by estado.
+// Periodo is "2006-01" for months and the ISO week ("2006-W01") for weeks.
+type CuidadosPeriodo struct {
+    Periodo     string `json:"periodo"`
+    Tipo        string `json:"tipo"`
+    Total       int    `json:"total"`
+    Programados int    `json:"programados"`
+    Completados int    `json:"completados"`
+    Cancelados  int    `json:"cancelados"`
+}
+
+// CumplimientoTipo splits the cuidados of a tipo dated before a day into
+// completed, cancelled and no-shows (still Programado), and counts those
+// from that day on as pending.
+type CumplimientoTipo struct {
+    Tipo          string `json:"tipo"`
+    Completados   int    `json:"completados"`
+    Cancelados    int    `json:"cancelados"`
+    NoPresentados int    `json:"no_presentados"`
+    Pendientes    int    `json:"pendientes"`
+}
+
+// Periodo names the period of CuidadosPorPeriodo t falls in, as seen in
+// t's location: "2006-01" for PeriodoMes, the ISO week for PeriodoSemana.
+func Periodo(t time.Time, periodo string) string {
+    if periodo == PeriodoSemana {
+        y, w := t.ISOWeek()
+        return fmt.Sprintf("%04d-W%02d", y, w)
+    }
+    return t.Format("2006-01")
+}
+
+// ReporteRepository computes the clinic statistics.
+type ReporteRepository interface {
+    // MascotasPorGrupo counts the mascotas by especie and/or sexo, ordered
+    // by them.
+    MascotasPorGrupo(ctx context.Context, porEspecie, porSexo bool) ([]GrupoMascotas, error)
+    // Edades counts the mascotas (of especie, when given) by full years
+    // of age on dia, youngest first.
+    Edades(ctx context.Context, dia time.Time, especie string) ([]ConteoEdad, error)
+    // CuidadosPorPeriodo counts the cuidados by periodo (PeriodoMes or
+    // PeriodoSemana) and tipo, ordered by both.
+    CuidadosPorPeriodo(ctx context.Context, f ReporteFiltro, periodo string) ([]CuidadosPeriodo, error)
+    // Cumplimiento splits the cuidados of each tipo around hoy, ordered by
+    // tipo.
+    Cumplimiento(ctx context.Context, f ReporteFiltro, hoy time.Time) ([]CumplimientoTipo, error)
+    // PorDiaSemana counts the cuidados that are not cancelled by weekday,
+    // indexed by time.Weekday.
+    PorDiaSemana(ctx context.Context, f ReporteFiltro) ([7]int, error)
+}
+
+// ReporteStore aggregates in SQL. With Materialized the cuidado reports
+// read the hourly counts of the reporte_cuidados_hora view, which Refresh
+// brings up to date, instead of the cuidados table: they are cheaper but
+// may lag behind, and their ranges are applied to whole hours.
+type ReporteStore struct {
+    DB           *sql.DB
+    Materialized bool
+}
+
+var _ ReporteRepository = ReporteStore{}
+
+// Refresh recomputes reporte_cuidados_hora without blocking the reports
+// that read it.
+func (s ReporteStore) Refresh(ctx context.Context) error {
+    _, err := s.DB.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY reporte_cuidados_hora`)
+    return err
+}
+
+// cuidadosSource is the relation the cuidado reports count from, with the
+// columns hora, tipo_cuidado, estado and total.
+func (s ReporteStore) cuidadosSource() string {
+    if s.Materialized {
+        return `reporte_cuidados_hora`
+    }
+    return `(SELECT fecha_cuidado AS hora, tipo_cuidado, estado, 1 AS total FROM cuidados)`
+}
+
+// where renders the range and tipo of f on the source, numbering its
+// parameters after args.
+func (f ReporteFiltro) where(args ...any) (string, []any) {
+    var where []string
+    cond := func(expr string, v any) {
+        args = append(args, v)
+        where = append(where, expr+" $"+strconv.Itoa(len(args)))
+    }
+    if !f.Desde.IsZero() {
+        cond("hora >=", f.Desde)
+    }
+    if !f.Hasta.IsZero() {
+        cond("hora <", f.Hasta)
+    }
+    if f.Tipo != "" {
+        cond("tipo_cuidado =", f.Tipo)
+    }
+    if len(where) == 0 {
+        return "", args
+    }
+    return ` WHERE ` + strings.Join(where, " AND "), args
+}
+
+func (s ReporteStore) MascotasPorGrupo(ctx context.Context, porEspecie, porSexo bool) ([]GrupoMascotas, error) {
+    especie, sexo := `''::text`, `''::text`
+    if porEspecie {
+        especie = `especie`
+    }
+    if porSexo {
+        sexo = `sexo`
+    }
+    q := `SELECT ` + especie + `, ` + sexo + `, count(*) FROM mascotas GROUP BY 1, 2 ORDER BY 1, 2`
+    rows, err := s.DB.QueryContext(ctx, q)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]GrupoMascotas, 0)
+    for rows.Next() {
+        var g GrupoMascotas
+        if err := rows.Scan(&g.Especie, &g.Sexo, &g.Total); err != nil {
+            return nil, err
+        }
+        out = append(out, g)
+    }
+    return out, rows.Err()
+}
+
+func (s ReporteStore) Edades(ctx context.Context, dia time.Time, especie string) ([]ConteoEdad, error) {
+    q := `SELECT date_part('year', age($1::date, fecha_nacimiento))::int AS anios, count(*)
+          FROM mascotas WHERE ($2 = '' OR especie = $2) AND fecha_nacimiento <= $1::date
+          GROUP BY 1 ORDER BY 1`
+    rows, err := s.DB.QueryContext(ctx, q, dia.Format("2006-01-02"), especie)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]ConteoEdad, 0)
+    for rows.Next() {
+        var c ConteoEdad
+        if err := rows.Scan(&c.Anios, &c.Total); err != nil {
+            return nil, err
+        }
+        out = append(out, c)
+    }
+    return out, rows.Err()
+}
+
+// CuidadosPorPeriodo reads the counts by instant and buckets them in Go:
+// Postgres only knows IANA zone names, and the clinic's may be "Local".
+func (s ReporteStore) CuidadosPorPeriodo(ctx context.Context, f ReporteFiltro, periodo string) ([]CuidadosPeriodo, error) {
+    where, args := f.where()
+    q := `SELECT hora, tipo_cuidado, estado, sum(total)::int
+          FROM ` + s.cuidadosSource() + ` s` + where + `
+          GROUP BY 1, 2, 3`
+    rows, err := s.DB.QueryContext(ctx, q, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    type key struct{ periodo, tipo string }
+    byKey := make(map[key]*CuidadosPeriodo)
+    for rows.Next() {
+        var (
+            hora         time.Time
+            tipo, estado string
+            total        int
+        )
+        if err := rows.Scan(&hora, &tipo, &estado, &total); err != nil {
+            return nil, err
+        }
+        k := key{Periodo(hora.In(f.Location), periodo), tipo}
+        c := byKey[k]
+        if c == nil {
+            c = &CuidadosPeriodo{Periodo: k.periodo, Tipo: tipo}
+            byKey[k] = c
+        }
+        c.Total += total
+        switch estado {
+        case CuidadoProgramado:
+            c.Programados += total
+        case CuidadoCompletado:
+            c.Completados += total
+        case CuidadoCancelado:
+            c.Cancelados += total
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    out := make([]CuidadosPeriodo, 0, len(byKey))
+    for _, c := range byKey {
+        out = append(out, *c)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Periodo != out[j].Periodo {
+            return out[i].Periodo < out[j].Periodo
+        }
+        return out[i].Tipo < out[j].Tipo
+    })
+    return out, nil
+}
+
+func (s ReporteStore) Cumplimiento(ctx context.Context, f ReporteFiltro, hoy time.Time) ([]CumplimientoTipo, error) {
+    where, args := f.where()
+    args = append(args, hoy)
+    hoyArg := `$` + strconv.Itoa(len(args))
+    q := `SELECT tipo_cuidado,
+                 coalesce(sum(total) FILTER (WHERE hora < ` + hoyArg + ` AND estado = 'Completado'), 0)::int,
+                 coalesce(sum(total) FILTER (WHERE hora < ` + hoyArg + ` AND estado = 'Cancelado'), 0)::int,
+                 coalesce(sum(total) FILTER (WHERE hora < ` + hoyArg + ` AND estado = 'Programado'), 0)::int,
+                 coalesce(sum(total) FILTER (WHERE hora >= ` + hoyArg + ` AND estado <> 'Cancelado'), 0)::int
+          FROM ` + s.cuidadosSource() + ` s` + where + `
+          GROUP BY 1 ORDER BY 1`
+    rows, err := s.DB.QueryContext(ctx, q, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]CumplimientoTipo, 0)
+    for rows.Next() {
+        var c CumplimientoTipo
+        if err := rows.Scan(&c.Tipo, &c.Completados, &c.Cancelados, &c.NoPresentados, &c.Pendientes); err != nil {
+            return nil, err
+        }
+        out = append(out, c)
+    }
+    return out, rows.Err()
+}
+
+// PorDiaSemana buckets in Go, like CuidadosPorPeriodo.
+func (s ReporteStore) PorDiaSemana(ctx context.Context, f ReporteFiltro) ([7]int, error) {
+    var out [7]int
+    where, args := f.where()
+    if where == "" {
+        where = ` WHERE estado <> 'Cancelado'`
+    } else {
+        where += ` AND estado <> 'Cancelado'`
+    }
+    q := `SELECT hora, sum(total)::int
+          FROM ` + s.cuidadosSource() + ` s` + where + ` GROUP BY 1`
+    rows, err := s.DB.QueryContext(ctx, q, args...)
+    if err != nil {
+        return out, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var (
+            hora  time.Time
+            total int
+        )
+        if err := rows.Scan(&hora, &total); err != nil {
+            return out, err
+        }
+        out[hora.In(f.Location).Weekday()] += total
+    }
+    return out, rows.Err()
+}
@@ -0,0 +1,127 @@
+package models_test
+
+import (
+    "context"
+    "reflect"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/models/memory"
+    "mascotas/internal/testutil/pgtest"
+)
+
+// TestReporteStore loads the same mascotas and cuidados in Postgres and
+// in memory and expects every report to agree, with and without the
+// materialized view.
+func TestReporteStore(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    mem := memory.New()
+    pg := struct {
+        mascotas models.MascotaRepository
+        cuidados models.CuidadoRepository
+    }{models.MascotaStore{DB: db.DB}, models.CuidadoStore{DB: db.DB}}
+    day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+    for _, m := range []models.Mascota{
+        {Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", FechaNacimiento: day(2019, 3, 10), Sexo: "Macho"},
+        {Nombre: "Toby", Especie: "Perro", Raza: "Beagle", FechaNacimiento: day(2029, 8, 1), Sexo: "Macho"},
+        {Nombre: "Misu", Especie: "Gato", Raza: "Siames", FechaNacimiento: day(2021, 11, 2), Sexo: "Hembra"},
+    } {
+        a, b := m, m
+        if err := pg.mascotas.Create(ctx, &a); err != nil {
+            t.Fatal(err)
+        }
+        if err := mem.Mascotas().Create(ctx, &b); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // Bogotá is UTC-5: the cuidado at 02:00 UTC on June 1st belongs to May.
+    bogota, err := time.LoadLocation("America/Bogota")
+    if err != nil {
+        t.Skip(err)
+    }
+    for _, c := range []models.Cuidado{
+        {TipoCuidado: "Vacunacion", FechaCuidado: time.Date(2030, 5, 20, 15, 0, 0, 0, time.UTC), MascotaID: 1, Estado: models.CuidadoCompletado},
+        {TipoCuidado: "Vacunacion", FechaCuidado: time.Date(2030, 6, 1, 2, 0, 0, 0, time.UTC), MascotaID: 2},
+        {TipoCuidado: "Bano", FechaCuidado: time.Date(2030, 6, 3, 14, 0, 0, 0, time.UTC), MascotaID: 3, Estado: models.CuidadoCancelado},
+        {TipoCuidado: "Bano", FechaCuidado: time.Date(2030, 6, 20, 14, 0, 0, 0, time.UTC), MascotaID: 1},
+    } {
+        a, b := c, c
+        a.Descripcion, b.Descripcion = "Prueba", "Prueba"
+        if err := pg.cuidados.Create(ctx, &a); err != nil {
+            t.Fatal(err)
+        }
+        if err := mem.Cuidados().Create(ctx, &b); err != nil {
+            t.Fatal(err)
+        }
+    }
+    stores := map[string]models.ReporteRepository{
+        "sql":          models.ReporteStore{DB: db.DB},
+        "materialized": models.ReporteStore{DB: db.DB, Materialized: true},
+    }
+    if err := (models.ReporteStore{DB: db.DB}).Refresh(ctx); err != nil {
+        t.Fatal(err)
+    }
+    want := mem.Reportes()
+    hoy := day(2030, 6, 5)
+    // time.Local is the default APP_TIMEZONE; its name, "Local", means
+    // nothing to Postgres.
+    filtros := []models.ReporteFiltro{
+        {Location: bogota},
+        {Location: time.Local},
+        {Location: time.UTC, Desde: day(2030, 6, 1), Hasta: day(2030, 7, 1)},
+        {Location: time.UTC, Tipo: "Bano"},
+    }
+    check := func(name, what string, got, want any, err error) {
+        t.Helper()
+        if err != nil {
+            t.Fatalf("%s %s: %v", name, what, err)
+        }
+        if !reflect.DeepEqual(got, want) {
+            t.Errorf("%s %s = %+v, want %+v", name, what, got, want)
+        }
+    }
+    for name, s := range stores {
+        for _, g := range [][2]bool{{true, false}, {false, true}, {true, true}} {
+            got, err := s.MascotasPorGrupo(ctx, g[0], g[1])
+            exp, _ := want.MascotasPorGrupo(ctx, g[0], g[1])
+            check(name, "MascotasPorGrupo", got, exp, err)
+        }
+        for _, especie := range []string{"", "Perro"} {
+            got, err := s.Edades(ctx, hoy, especie)
+            exp, _ := want.Edades(ctx, hoy, especie)
+            check(name, "Edades", got, exp, err)
+        }
+        for _, f := range filtros {
+            for _, p := range []string{models.PeriodoMes, models.PeriodoSemana} {
+                got, err := s.CuidadosPorPeriodo(ctx, f, p)
+                exp, _ := want.CuidadosPorPeriodo(ctx, f, p)
+                check(name, "CuidadosPorPeriodo "+p, got, exp, err)
+            }
+            got, err := s.Cumplimiento(ctx, f, hoy)
+            exp, _ := want.Cumplimiento(ctx, f, hoy)
+            check(name, "Cumplimiento", got, exp, err)
+            dias, err := s.PorDiaSemana(ctx, f)
+            expDias, _ := want.PorDiaSemana(ctx, f)
+            check(name, "PorDiaSemana", dias, expDias, err)
+        }
+    }
+}
+
+func TestPeriodo(t *testing.T) {
+    for _, tc := range []struct {
+        t       time.Time
+        periodo string
+        want    string
+    }{
+        {time.Date(2030, 6, 1, 2, 0, 0, 0, time.UTC), models.PeriodoMes, "2030-06"},
+        {time.Date(2030, 6, 1, 2, 0, 0, 0, time.UTC), models.PeriodoSemana, "2030-W22"},
+        {time.Date(2030, 12, 30, 9, 0, 0, 0, time.UTC), models.PeriodoSemana, "2031-W01"},
+        {time.Date(2030, 12, 30, 9, 0, 0, 0, time.UTC), models.PeriodoMes, "2030-12"},
+    } {
+        if got := models.Periodo(tc.t, tc.periodo); got != tc.want {
+            t.Errorf("Periodo(%s, %s) = %s, want %s", tc.t, tc.periodo, got, tc.want)
+        }
+    }
+}
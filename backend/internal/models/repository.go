@@ -0,0 +1,105 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrNotFound is returned by every repository when the requested record,
+// or a record it references, does not exist.
+var ErrNotFound = errors.New("not found")
+
+// MascotaRepository is the persistence contract for mascotas. Lists are
+// ordered by id ascending and deleting a mascota deletes its cuidados.
+// Writes that repeat another mascota's microchip, tatuaje or licencia fail
+// with a *DuplicateError.
+type MascotaRepository interface {
+    Create(ctx context.Context, m *Mascota) error
+    Get(ctx context.Context, id int64) (*Mascota, error)
+    // FindByIdentificacion returns the mascota whose field (IdentMicrochip,
+    // IdentTatuaje or IdentLicencia) equals value, or ErrNotFound.
+    FindByIdentificacion(ctx context.Context, field, value string) (*Mascota, error)
+    List(ctx context.Context) ([]Mascota, error)
+    ListPaged(ctx context.Context, limit, offset int64) ([]Mascota, error)
+    Update(ctx context.Context, m *Mascota) error
+    Delete(ctx context.Context, id int64) error
+    // CreateMany stores ms in a single transaction: either all of them are
+    // created or none is.
+    CreateMany(ctx context.Context, ms []*Mascota) error
+    // FindDuplicates returns, for each candidate, the id of an existing
+    // mascota with the same DuplicateKey, or 0 when there is none.
+    FindDuplicates(ctx context.Context, ms []Mascota) ([]int64, error)
+    // Stream calls fn for every mascota matching f, in id order, reading
+    // them one at a time; an error from fn stops it and is returned. It
+    // runs until the deadline of ctx, even past the statement timeout.
+    Stream(ctx context.Context, f MascotaFilter, fn func(Mascota) error) error
+}
+
+// CuidadoRepository is the persistence contract for cuidados. Lists are
+// ordered by fecha_cuidado descending (newest first, then id descending)
+// and writes referencing a missing mascota fail with ErrNotFound. Writes
+// that overlap another booking of the veterinario, both not cancelled,
+// fail with a *BookingConflict.
+type CuidadoRepository interface {
+    Create(ctx context.Context, c *Cuidado) error
+    Get(ctx context.Context, id int64) (*Cuidado, error)
+    ListByMascota(ctx context.Context, mascotaID int64) ([]Cuidado, error)
+    // ListBetween returns the cuidados with desde <= fecha_cuidado < hasta
+    // ordered by fecha_cuidado, then id.
+    ListBetween(ctx context.Context, desde, hasta time.Time) ([]Cuidado, error)
+    // Stream calls fn for every cuidado matching f ordered by
+    // fecha_cuidado, then id, reading them one at a time; an error from fn
+    // stops it and is returned. It runs until the deadline of ctx, even
+    // past the statement timeout.
+    Stream(ctx context.Context, f CuidadoFilter, fn func(Cuidado) error) error
+    // ListAgenda returns the cuidados matching f joined with the name and
+    // especie of their mascota, ordered by fecha_cuidado, then id.
+    ListAgenda(ctx context.Context, f CuidadoFilter) ([]AgendaCuidado, error)
+    // ListBorrados returns the deleted cuidados matching f like
+    // ListAgenda, as they were when deleted but Cancelado and with
+    // Secuencia bumped. The mascota is blank when it was deleted too.
+    ListBorrados(ctx context.Context, f CuidadoFilter) ([]AgendaCuidado, error)
+    Update(ctx context.Context, c *Cuidado) error
+    Delete(ctx context.Context, id int64) error
+}
+
+var (
+    _ MascotaRepository = MascotaStore{}
+    _ CuidadoRepository = CuidadoStore{}
+)
+
+func notFound(err error) error {
+    if errors.Is(err, sql.ErrNoRows) {
+        return ErrNotFound
+    }
+    return err
+}
+
+// affectedOne turns an UPDATE/DELETE that touched no rows into ErrNotFound.
+func affectedOne(res sql.Result, err error) error {
+    if err != nil {
+        return err
+    }
+    n, err := res.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// mascotaRef maps a foreign key violation on mascota_id to ErrNotFound.
+func mascotaRef(err error, mascotaID int64) error {
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+        return fmt.Errorf("mascota %d: %w", mascotaID, ErrNotFound)
+    }
+    return err
+}
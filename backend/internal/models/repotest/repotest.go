@@ -0,0 +1,581 @@
+// Package repotest is the conformance suite shared by every implementation
+// of models.MascotaRepository and models.CuidadoRepository.
+package repotest
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// Repos is one fresh, empty pair of repositories sharing the same storage.
+type Repos struct {
+    Mascotas models.MascotaRepository
+    Cuidados models.CuidadoRepository
+}
+
+// Run executes the suite. newRepos is called once per subtest and must
+// return empty repositories whose ids start again from 1.
+func Run(t *testing.T, newRepos func(t *testing.T) Repos) {
+    tests := []struct {
+        name string
+        fn   func(t *testing.T, r Repos)
+    }{
+        {"MascotaCRUD", testMascotaCRUD},
+        {"MascotaOrderingAndPaging", testMascotaOrderingAndPaging},
+        {"MascotaNotFound", testMascotaNotFound},
+        {"MascotaCreateManyAndDuplicates", testMascotaCreateManyAndDuplicates},
+        {"MascotaStream", testMascotaStream},
+        {"MascotaIdentificacion", testMascotaIdentificacion},
+        {"CuidadoCRUD", testCuidadoCRUD},
+        {"CuidadoEstado", testCuidadoEstado},
+        {"CuidadoOrdering", testCuidadoOrdering},
+        {"CuidadoListBetween", testCuidadoListBetween},
+        {"CuidadoStream", testCuidadoStream},
+        {"CuidadoAgenda", testCuidadoAgenda},
+        {"CuidadoNotFound", testCuidadoNotFound},
+        {"CascadeDelete", testCascadeDelete},
+        {"CuidadoBorrados", testCuidadoBorrados},
+    }
+    for _, tc := range tests {
+        t.Run(tc.name, func(t *testing.T) { tc.fn(t, newRepos(t)) })
+    }
+}
+
+func newMascota(nombre string) *models.Mascota {
+    return &models.Mascota{
+        Nombre:          nombre,
+        Especie:         "Perro",
+        Raza:            "Criollo",
+        FechaNacimiento: time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC),
+        Sexo:            "Macho",
+    }
+}
+
+func newCuidado(mascotaID int64, fecha time.Time) *models.Cuidado {
+    return &models.Cuidado{
+        TipoCuidado:  "Vacunacion",
+        Descripcion:  "Refuerzo anual",
+        FechaCuidado: fecha,
+        MascotaID:    mascotaID,
+    }
+}
+
+func mustCreateMascota(t *testing.T, r Repos, nombre string) *models.Mascota {
+    t.Helper()
+    m := newMascota(nombre)
+    if err := r.Mascotas.Create(context.Background(), m); err != nil {
+        t.Fatalf("create mascota: %v", err)
+    }
+    return m
+}
+
+func mustCreateCuidado(t *testing.T, r Repos, mascotaID int64, fecha time.Time) *models.Cuidado {
+    t.Helper()
+    c := newCuidado(mascotaID, fecha)
+    if err := r.Cuidados.Create(context.Background(), c); err != nil {
+        t.Fatalf("create cuidado: %v", err)
+    }
+    return c
+}
+
+func expectNotFound(t *testing.T, what string, err error) {
+    t.Helper()
+    if !errors.Is(err, models.ErrNotFound) {
+        t.Fatalf("%s: expected ErrNotFound, got %v", what, err)
+    }
+}
+
+func testMascotaCRUD(t *testing.T, r Repos) {
+    ctx := context.Background()
+    m := mustCreateMascota(t, r, "Firulais")
+    if m.ID != 1 {
+        t.Fatalf("first id = %d, want 1", m.ID)
+    }
+
+    got, err := r.Mascotas.Get(ctx, m.ID)
+    if err != nil {
+        t.Fatalf("get: %v", err)
+    }
+    if *got != *m {
+        t.Fatalf("get = %+v, want %+v", *got, *m)
+    }
+
+    m.Nombre = "Firulais II"
+    m.Sexo = "Hembra"
+    if err := r.Mascotas.Update(ctx, m); err != nil {
+        t.Fatalf("update: %v", err)
+    }
+    got, err = r.Mascotas.Get(ctx, m.ID)
+    if err != nil {
+        t.Fatalf("get after update: %v", err)
+    }
+    if got.Nombre != "Firulais II" || got.Sexo != "Hembra" {
+        t.Fatalf("update not persisted: %+v", *got)
+    }
+
+    if err := r.Mascotas.Delete(ctx, m.ID); err != nil {
+        t.Fatalf("delete: %v", err)
+    }
+    _, err = r.Mascotas.Get(ctx, m.ID)
+    expectNotFound(t, "get after delete", err)
+}
+
+func testMascotaOrderingAndPaging(t *testing.T, r Repos) {
+    ctx := context.Background()
+    for _, n := range []string{"Uno", "Dos", "Tres", "Cuatro"} {
+        mustCreateMascota(t, r, n)
+    }
+
+    all, err := r.Mascotas.List(ctx)
+    if err != nil {
+        t.Fatalf("list: %v", err)
+    }
+    if len(all) != 4 {
+        t.Fatalf("list len = %d, want 4", len(all))
+    }
+    for i, m := range all {
+        if m.ID != int64(i+1) {
+            t.Fatalf("list[%d].ID = %d, want ascending ids", i, m.ID)
+        }
+    }
+
+    page, err := r.Mascotas.ListPaged(ctx, 2, 1)
+    if err != nil {
+        t.Fatalf("list paged: %v", err)
+    }
+    if len(page) != 2 || page[0].Nombre != "Dos" || page[1].Nombre != "Tres" {
+        t.Fatalf("page = %+v, want Dos, Tres", page)
+    }
+
+    empty, err := r.Mascotas.ListPaged(ctx, 10, 10)
+    if err != nil {
+        t.Fatalf("list paged past end: %v", err)
+    }
+    if empty == nil || len(empty) != 0 {
+        t.Fatalf("past end = %#v, want empty non-nil slice", empty)
+    }
+}
+
+func testMascotaNotFound(t *testing.T, r Repos) {
+    ctx := context.Background()
+    _, err := r.Mascotas.Get(ctx, 99)
+    expectNotFound(t, "get", err)
+
+    m := newMascota("Fantasma")
+    m.ID = 99
+    expectNotFound(t, "update", r.Mascotas.Update(ctx, m))
+    expectNotFound(t, "delete", r.Mascotas.Delete(ctx, 99))
+}
+
+func testMascotaCreateManyAndDuplicates(t *testing.T, r Repos) {
+    ctx := context.Background()
+    existing := newMascota("Firulais")
+    existing.PropietarioEmail = "ana@example.com"
+    if err := r.Mascotas.Create(ctx, existing); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+
+    batch := []*models.Mascota{newMascota("Luna"), newMascota("Sol")}
+    if err := r.Mascotas.CreateMany(ctx, batch); err != nil {
+        t.Fatalf("create many: %v", err)
+    }
+    if batch[0].ID != existing.ID+1 || batch[1].ID != existing.ID+2 {
+        t.Fatalf("ids = %d, %d; want consecutive after %d", batch[0].ID, batch[1].ID, existing.ID)
+    }
+    all, err := r.Mascotas.List(ctx)
+    if err != nil || len(all) != 3 {
+        t.Fatalf("list = %v, %v; want 3 mascotas", all, err)
+    }
+
+    same := *newMascota("  FIRULAIS ")
+    same.PropietarioEmail = "Ana@Example.com"
+    otherOwner := *newMascota("Firulais")
+    otherOwner.PropietarioEmail = "otro@example.com"
+    dups, err := r.Mascotas.FindDuplicates(ctx, []models.Mascota{same, otherOwner, *newMascota("Luna")})
+    if err != nil {
+        t.Fatalf("find duplicates: %v", err)
+    }
+    want := []int64{existing.ID, 0, batch[0].ID}
+    if len(dups) != len(want) {
+        t.Fatalf("duplicates = %v, want %v", dups, want)
+    }
+    for i := range want {
+        if dups[i] != want[i] {
+            t.Fatalf("duplicates = %v, want %v", dups, want)
+        }
+    }
+}
+
+func testMascotaIdentificacion(t *testing.T, r Repos) {
+    ctx := context.Background()
+    m := newMascota("Firulais")
+    m.Microchip, m.Tatuaje, m.Licencia = "985112000123456", "ABC123", "BOG-2030-15"
+    if err := r.Mascotas.Create(ctx, m); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+    // Mascotas without identification do not collide with each other.
+    plain := mustCreateMascota(t, r, "Sin chip")
+    mustCreateMascota(t, r, "Tampoco")
+
+    got, err := r.Mascotas.FindByIdentificacion(ctx, models.IdentMicrochip, "985112000123456")
+    if err != nil || *got != *m {
+        t.Fatalf("find by microchip = %+v, %v; want %+v", got, err, *m)
+    }
+    if got, err := r.Mascotas.FindByIdentificacion(ctx, models.IdentLicencia, "BOG-2030-15"); err != nil || got.ID != m.ID {
+        t.Fatalf("find by licencia = %+v, %v", got, err)
+    }
+    _, err = r.Mascotas.FindByIdentificacion(ctx, models.IdentMicrochip, "985112000999999")
+    expectNotFound(t, "find unknown microchip", err)
+    _, err = r.Mascotas.FindByIdentificacion(ctx, models.IdentTatuaje, "")
+    expectNotFound(t, "find empty tatuaje", err)
+
+    expectDuplicate := func(what, field string, err error) {
+        t.Helper()
+        var dup *models.DuplicateError
+        if !errors.As(err, &dup) || dup.Field != field {
+            t.Fatalf("%s: expected duplicate %s, got %v", what, field, err)
+        }
+    }
+    again := newMascota("Otro")
+    again.Microchip = m.Microchip
+    expectDuplicate("create", models.IdentMicrochip, r.Mascotas.Create(ctx, again))
+    plain.Tatuaje = "ABC123"
+    expectDuplicate("update", models.IdentTatuaje, r.Mascotas.Update(ctx, plain))
+    batch := []*models.Mascota{newMascota("Luna"), newMascota("Sol")}
+    batch[0].Licencia, batch[1].Licencia = "X-1", "X-1"
+    expectDuplicate("create many", models.IdentLicencia, r.Mascotas.CreateMany(ctx, batch))
+    if all, err := r.Mascotas.List(ctx); err != nil || len(all) != 3 {
+        t.Fatalf("list = %v, %v; want the failed batch rolled back", all, err)
+    }
+    // Updating a mascota keeps its own identification.
+    m.Nombre = "Firulais II"
+    if err := r.Mascotas.Update(ctx, m); err != nil {
+        t.Fatalf("update: %v", err)
+    }
+}
+
+func testMascotaStream(t *testing.T, r Repos) {
+    ctx := context.Background()
+    for _, n := range []string{"Luna", "Sol", "Lunita", "Nube"} {
+        m := newMascota(n)
+        if n == "Nube" {
+            m.Especie = "Gato"
+        }
+        if err := r.Mascotas.Create(ctx, m); err != nil {
+            t.Fatalf("create: %v", err)
+        }
+    }
+    collect := func(f models.MascotaFilter) string {
+        t.Helper()
+        var got []string
+        err := r.Mascotas.Stream(ctx, f, func(m models.Mascota) error {
+            got = append(got, m.Nombre)
+            return nil
+        })
+        if err != nil {
+            t.Fatalf("stream %+v: %v", f, err)
+        }
+        return strings.Join(got, " ")
+    }
+    for _, tc := range []struct {
+        f    models.MascotaFilter
+        want string
+    }{
+        {models.MascotaFilter{}, "Luna Sol Lunita Nube"},
+        {models.MascotaFilter{Nombre: "LUN"}, "Luna Lunita"},
+        {models.MascotaFilter{Especie: "Perro", Limit: 2, Offset: 1}, "Sol Lunita"},
+        {models.MascotaFilter{Especie: "Gato", Sexo: "Hembra"}, ""},
+    } {
+        if got := collect(tc.f); got != tc.want {
+            t.Errorf("stream %+v = %q, want %q", tc.f, got, tc.want)
+        }
+    }
+
+    stop := errors.New("stop")
+    n := 0
+    err := r.Mascotas.Stream(ctx, models.MascotaFilter{}, func(models.Mascota) error {
+        n++
+        return stop
+    })
+    if !errors.Is(err, stop) || n != 1 {
+        t.Fatalf("stream after callback error: err = %v, calls = %d", err, n)
+    }
+}
+
+func testCuidadoStream(t *testing.T, r Repos) {
+    ctx := context.Background()
+    a := mustCreateMascota(t, r, "A")
+    b := mustCreateMascota(t, r, "B")
+    base := time.Date(2031, 1, 1, 9, 0, 0, 0, time.UTC)
+    mustCreateCuidado(t, r, a.ID, base.Add(48*time.Hour)) // 1
+    mustCreateCuidado(t, r, b.ID, base)                   // 2
+    c := mustCreateCuidado(t, r, a.ID, base)              // 3
+    c.Estado = models.CuidadoCancelado
+    if err := r.Cuidados.Update(ctx, c); err != nil {
+        t.Fatalf("update: %v", err)
+    }
+    for _, tc := range []struct {
+        f    models.CuidadoFilter
+        want []int64
+    }{
+        {models.CuidadoFilter{}, []int64{2, 3, 1}},
+        {models.CuidadoFilter{MascotaID: a.ID}, []int64{3, 1}},
+        {models.CuidadoFilter{Estado: models.CuidadoCancelado}, []int64{3}},
+        {models.CuidadoFilter{Tipo: "Bano"}, nil},
+        {models.CuidadoFilter{Desde: base.Add(time.Hour)}, []int64{1}},
+        {models.CuidadoFilter{Hasta: base.Add(48 * time.Hour)}, []int64{2, 3}},
+    } {
+        var got []int64
+        err := r.Cuidados.Stream(ctx, tc.f, func(c models.Cuidado) error {
+            got = append(got, c.ID)
+            return nil
+        })
+        if err != nil {
+            t.Fatalf("stream %+v: %v", tc.f, err)
+        }
+        if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+            t.Errorf("stream %+v = %v, want %v", tc.f, got, tc.want)
+        }
+    }
+}
+
+func testCuidadoAgenda(t *testing.T, r Repos) {
+    ctx := context.Background()
+    a := mustCreateMascota(t, r, "A")
+    b := newMascota("B")
+    b.Especie = "Gato"
+    if err := r.Mascotas.Create(ctx, b); err != nil {
+        t.Fatalf("create: %v", err)
+    }
+    base := time.Date(2031, 1, 1, 9, 0, 0, 0, time.UTC)
+    mustCreateCuidado(t, r, a.ID, base.Add(48*time.Hour)) // 1
+    mustCreateCuidado(t, r, b.ID, base)                   // 2
+    mustCreateCuidado(t, r, a.ID, base)                   // 3
+
+    got, err := r.Cuidados.ListAgenda(ctx, models.CuidadoFilter{Hasta: base.Add(24 * time.Hour)})
+    if err != nil {
+        t.Fatalf("agenda: %v", err)
+    }
+    if len(got) != 2 || got[0].ID != 2 || got[1].ID != 3 {
+        t.Fatalf("agenda = %+v, want cuidados 2 and 3", got)
+    }
+    if got[0].MascotaNombre != "B" || got[0].MascotaEspecie != "Gato" || got[1].MascotaNombre != "A" || got[1].MascotaID != a.ID {
+        t.Fatalf("agenda mascotas = %+v", got)
+    }
+    if !got[0].FechaCuidado.Equal(base) || got[0].Estado != models.CuidadoProgramado {
+        t.Fatalf("agenda cuidado = %+v", got[0].Cuidado)
+    }
+    got, err = r.Cuidados.ListAgenda(ctx, models.CuidadoFilter{Tipo: "Bano"})
+    if err != nil || len(got) != 0 {
+        t.Fatalf("empty agenda = %v, %v", got, err)
+    }
+}
+
+func testCuidadoCRUD(t *testing.T, r Repos) {
+    ctx := context.Background()
+    m := mustCreateMascota(t, r, "Michi")
+    fecha := time.Date(2031, 3, 4, 10, 30, 0, 0, time.UTC)
+    c := mustCreateCuidado(t, r, m.ID, fecha)
+    if c.ID != 1 {
+        t.Fatalf("first id = %d, want 1", c.ID)
+    }
+
+    got, err := r.Cuidados.Get(ctx, c.ID)
+    if err != nil {
+        t.Fatalf("get: %v", err)
+    }
+    if !got.FechaCuidado.Equal(fecha) || got.TipoCuidado != c.TipoCuidado || got.MascotaID != m.ID {
+        t.Fatalf("get = %+v, want %+v", *got, *c)
+    }
+
+    other := mustCreateMascota(t, r, "Otro")
+    c.Descripcion = "Cambio de dueño"
+    c.MascotaID = other.ID
+    if err := r.Cuidados.Update(ctx, c); err != nil {
+        t.Fatalf("update: %v", err)
+    }
+    moved, err := r.Cuidados.ListByMascota(ctx, other.ID)
+    if err != nil {
+        t.Fatalf("list: %v", err)
+    }
+    if len(moved) != 1 || moved[0].Descripcion != "Cambio de dueño" {
+        t.Fatalf("update not persisted: %+v", moved)
+    }
+
+    if err := r.Cuidados.Delete(ctx, c.ID); err != nil {
+        t.Fatalf("delete: %v", err)
+    }
+    _, err = r.Cuidados.Get(ctx, c.ID)
+    expectNotFound(t, "get after delete", err)
+}
+
+func testCuidadoEstado(t *testing.T, r Repos) {
+    ctx := context.Background()
+    m := mustCreateMascota(t, r, "Estado")
+    c := mustCreateCuidado(t, r, m.ID, time.Date(2031, 1, 1, 9, 0, 0, 0, time.UTC))
+    if c.Estado != models.CuidadoProgramado || c.Secuencia != 0 {
+        t.Fatalf("new cuidado estado = %q secuencia = %d, want %q and 0", c.Estado, c.Secuencia, models.CuidadoProgramado)
+    }
+
+    c.Estado = models.CuidadoCompletado
+    if err := r.Cuidados.Update(ctx, c); err != nil {
+        t.Fatalf("update: %v", err)
+    }
+    c.Estado = ""
+    c.Descripcion = "Sin cambiar estado"
+    if err := r.Cuidados.Update(ctx, c); err != nil {
+        t.Fatalf("update without estado: %v", err)
+    }
+    if c.Estado != models.CuidadoCompletado {
+        t.Fatalf("update returned estado %q, want the stored one", c.Estado)
+    }
+    got, err := r.Cuidados.Get(ctx, c.ID)
+    if err != nil {
+        t.Fatalf("get: %v", err)
+    }
+    if got.Estado != models.CuidadoCompletado || got.Descripcion != "Sin cambiar estado" || got.Secuencia != 2 {
+        t.Fatalf("get = %+v", *got)
+    }
+}
+
+func testCuidadoOrdering(t *testing.T, r Repos) {
+    ctx := context.Background()
+    m := mustCreateMascota(t, r, "Orden")
+    base := time.Date(2031, 1, 1, 9, 0, 0, 0, time.UTC)
+    mustCreateCuidado(t, r, m.ID, base)
+    mustCreateCuidado(t, r, m.ID, base.Add(48*time.Hour))
+    mustCreateCuidado(t, r, m.ID, base.Add(24*time.Hour))
+    mustCreateCuidado(t, r, m.ID, base.Add(24*time.Hour))
+
+    list, err := r.Cuidados.ListByMascota(ctx, m.ID)
+    if err != nil {
+        t.Fatalf("list: %v", err)
+    }
+    want := []int64{2, 4, 3, 1}
+    if len(list) != len(want) {
+        t.Fatalf("list len = %d, want %d", len(list), len(want))
+    }
+    for i, id := range want {
+        if list[i].ID != id {
+            t.Fatalf("list[%d].ID = %d, want %d (fecha desc, id desc)", i, list[i].ID, id)
+        }
+    }
+
+    none, err := r.Cuidados.ListByMascota(ctx, 404)
+    if err != nil {
+        t.Fatalf("list unknown mascota: %v", err)
+    }
+    if none == nil || len(none) != 0 {
+        t.Fatalf("unknown mascota = %#v, want empty non-nil slice", none)
+    }
+}
+
+func testCuidadoListBetween(t *testing.T, r Repos) {
+    ctx := context.Background()
+    a := mustCreateMascota(t, r, "A")
+    b := mustCreateMascota(t, r, "B")
+    base := time.Date(2031, 1, 1, 9, 0, 0, 0, time.UTC)
+    mustCreateCuidado(t, r, a.ID, base.Add(-time.Hour))    // 1: before
+    mustCreateCuidado(t, r, b.ID, base.Add(24*time.Hour))   // 2
+    mustCreateCuidado(t, r, a.ID, base)                     // 3: desde is inclusive
+    mustCreateCuidado(t, r, a.ID, base.Add(24*time.Hour))   // 4
+    mustCreateCuidado(t, r, b.ID, base.Add(48*time.Hour))   // 5: hasta is exclusive
+
+    list, err := r.Cuidados.ListBetween(ctx, base, base.Add(48*time.Hour))
+    if err != nil {
+        t.Fatalf("list between: %v", err)
+    }
+    want := []int64{3, 2, 4}
+    if len(list) != len(want) {
+        t.Fatalf("list between = %+v, want ids %v", list, want)
+    }
+    for i, id := range want {
+        if list[i].ID != id {
+            t.Fatalf("list[%d].ID = %d, want %d (fecha asc, id asc)", i, list[i].ID, id)
+        }
+    }
+}
+
+// testCuidadoBorrados deletes a cuidado, then a mascota with its cuidado,
+// and expects both tombstones Cancelado with Secuencia bumped.
+func testCuidadoBorrados(t *testing.T, r Repos) {
+    ctx := context.Background()
+    a := mustCreateMascota(t, r, "A")
+    b := mustCreateMascota(t, r, "B")
+    base := time.Date(2031, 1, 1, 9, 0, 0, 0, time.UTC)
+    c1 := mustCreateCuidado(t, r, a.ID, base.Add(24*time.Hour))
+    c2 := mustCreateCuidado(t, r, b.ID, base)
+    mustCreateCuidado(t, r, a.ID, base) // stays
+    c1.Descripcion = "Reprogramado"
+    if err := r.Cuidados.Update(ctx, c1); err != nil {
+        t.Fatalf("update: %v", err)
+    }
+    if err := r.Cuidados.Delete(ctx, c1.ID); err != nil {
+        t.Fatalf("delete cuidado: %v", err)
+    }
+    if err := r.Mascotas.Delete(ctx, b.ID); err != nil {
+        t.Fatalf("delete mascota: %v", err)
+    }
+
+    list, err := r.Cuidados.ListBorrados(ctx, models.CuidadoFilter{Desde: base, Hasta: base.Add(48 * time.Hour)})
+    if err != nil {
+        t.Fatalf("list borrados: %v", err)
+    }
+    if len(list) != 2 || list[0].ID != c2.ID || list[1].ID != c1.ID {
+        t.Fatalf("borrados = %+v, want ids %d, %d", list, c2.ID, c1.ID)
+    }
+    if got := list[1]; got.Estado != models.CuidadoCancelado || got.Secuencia != 2 || got.Descripcion != "Reprogramado" || got.MascotaNombre != "A" {
+        t.Errorf("deleted cuidado = %+v, want Cancelado, secuencia 2, mascota A", got)
+    }
+    if got := list[0]; got.Estado != models.CuidadoCancelado || got.Secuencia != 1 || got.MascotaNombre != "" {
+        t.Errorf("cuidado of a deleted mascota = %+v, want Cancelado, secuencia 1, no mascota", got)
+    }
+
+    mine, err := r.Cuidados.ListBorrados(ctx, models.CuidadoFilter{MascotaID: a.ID})
+    if err != nil || len(mine) != 1 || mine[0].ID != c1.ID {
+        t.Fatalf("borrados of mascota A = %+v, %v", mine, err)
+    }
+}
+
+func testCuidadoNotFound(t *testing.T, r Repos) {
+    ctx := context.Background()
+    _, err := r.Cuidados.Get(ctx, 99)
+    expectNotFound(t, "get", err)
+
+    fecha := time.Date(2031, 1, 1, 9, 0, 0, 0, time.UTC)
+    expectNotFound(t, "create for missing mascota", r.Cuidados.Create(ctx, newCuidado(404, fecha)))
+
+    m := mustCreateMascota(t, r, "Real")
+    c := mustCreateCuidado(t, r, m.ID, fecha)
+    c.MascotaID = 404
+    expectNotFound(t, "update to missing mascota", r.Cuidados.Update(ctx, c))
+
+    ghost := newCuidado(m.ID, fecha)
+    ghost.ID = 99
+    expectNotFound(t, "update", r.Cuidados.Update(ctx, ghost))
+    expectNotFound(t, "delete", r.Cuidados.Delete(ctx, 99))
+}
+
+func testCascadeDelete(t *testing.T, r Repos) {
+    ctx := context.Background()
+    keep := mustCreateMascota(t, r, "Queda")
+    gone := mustCreateMascota(t, r, "Se va")
+    fecha := time.Date(2031, 1, 1, 9, 0, 0, 0, time.UTC)
+    kept := mustCreateCuidado(t, r, keep.ID, fecha)
+    deleted := mustCreateCuidado(t, r, gone.ID, fecha)
+
+    if err := r.Mascotas.Delete(ctx, gone.ID); err != nil {
+        t.Fatalf("delete mascota: %v", err)
+    }
+    _, err := r.Cuidados.Get(ctx, deleted.ID)
+    expectNotFound(t, "cuidado of deleted mascota", err)
+    if _, err := r.Cuidados.Get(ctx, kept.ID); err != nil {
+        t.Fatalf("unrelated cuidado was removed: %v", err)
+    }
+}
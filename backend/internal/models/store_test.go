@@ -0,0 +1,23 @@
+package models_test
+
+import (
+    "os"
+    "testing"
+
+    "mascotas/internal/models"
+    "mascotas/internal/models/repotest"
+    "mascotas/internal/testutil/pgtest"
+)
+
+func TestMain(m *testing.M) {
+    os.Exit(pgtest.Main(m))
+}
+
+// TestPostgresConformance runs the repository suite against a real
+// database, one fresh database per subtest.
+func TestPostgresConformance(t *testing.T) {
+    repotest.Run(t, func(t *testing.T) repotest.Repos {
+        db := pgtest.NewDB(t)
+        return repotest.Repos{Mascotas: models.MascotaStore{DB: db.DB}, Cuidados: models.CuidadoStore{DB: db.DB}}
+    })
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "time"
+)
+
+// Dias are the weekdays of a Turno, indexed by time.Weekday.
+var Dias = [...]string{"domingo", "lunes", "martes", "miercoles", "jueves", "viernes", "sabado"}
+
+// Turno is a weekly working block: on Dia from Desde until Hasta, both
+// "HH:MM" in the clinic's time zone.
+type Turno struct {
+    Dia   string `json:"dia"`
+    Desde string `json:"desde"`
+    Hasta string `json:"hasta"`
+}
+
+// minutes returns the block as minutes since midnight; ok is false when
+// Desde or Hasta is not a valid HH:MM.
+func (t Turno) minutes() (desde, hasta int, ok bool) {
+    d, err1 := time.Parse("15:04", t.Desde)
+    h, err2 := time.Parse("15:04", t.Hasta)
+    if err1 != nil || err2 != nil {
+        return 0, 0, false
+    }
+    return d.Hour()*60 + d.Minute(), h.Hour()*60 + h.Minute(), true
+}
+
+// Valid reports whether the block names a weekday and ends after it
+// starts.
+func (t Turno) Valid() bool {
+    desde, hasta, ok := t.minutes()
+    if !ok || desde >= hasta {
+        return false
+    }
+    for _, d := range Dias {
+        if d == t.Dia {
+            return true
+        }
+    }
+    return false
+}
+
+// Veterinario is a member of the clinic's staff that cuidados are
+// assigned to.
+type Veterinario struct {
+    ID             int64     `json:"id"`
+    Nombre         string    `json:"nombre"`
+    Email          string    `json:"email"`
+    Telefono       string    `json:"telefono"`
+    Especialidades []string  `json:"especialidades"`
+    Horario        []Turno   `json:"horario"`
+    Activo         bool      `json:"activo"`
+    CreadoEn       time.Time `json:"creado_en"`
+}
+
+// Atiende reports whether [t, t+d) falls within a single block of the
+// horario. t must be in the clinic's time zone.
+func (v Veterinario) Atiende(t time.Time, d time.Duration) bool {
+    start := t.Hour()*60 + t.Minute()
+    end := start + int((d+time.Minute-1)/time.Minute)
+    if t.Second() != 0 || t.Nanosecond() != 0 {
+        end++
+    }
+    for _, turno := range v.Horario {
+        desde, hasta, ok := turno.minutes()
+        if ok && turno.Dia == Dias[t.Weekday()] && desde <= start && end <= hasta {
+            return true
+        }
+    }
+    return false
+}
+
+// VeterinarioRepository stores the staff directory. List is ordered by
+// nombre, then id.
+type VeterinarioRepository interface {
+    Create(ctx context.Context, v *Veterinario) error
+    Get(ctx context.Context, id int64) (*Veterinario, error)
+    List(ctx context.Context) ([]Veterinario, error)
+    Update(ctx context.Context, v *Veterinario) error
+}
+
+type VeterinarioStore struct{ DB *sql.DB }
+
+var _ VeterinarioRepository = VeterinarioStore{}
+
+const veterinarioColumns = `id, nombre, email, telefono, to_json(especialidades), horario, activo, creado_en`
+
+func scanVeterinario(row rowScanner, v *Veterinario) error {
+    var especialidades, horario []byte
+    if err := row.Scan(&v.ID, &v.Nombre, &v.Email, &v.Telefono, &especialidades, &horario, &v.Activo, &v.CreadoEn); err != nil {
+        return err
+    }
+    if err := json.Unmarshal(especialidades, &v.Especialidades); err != nil {
+        return err
+    }
+    return json.Unmarshal(horario, &v.Horario)
+}
+
+func (s VeterinarioStore) Create(ctx context.Context, v *Veterinario) error {
+    horario, err := json.Marshal(v.Horario)
+    if err != nil {
+        return err
+    }
+    q := `INSERT INTO veterinarios(nombre, email, telefono, especialidades, horario, activo)
+          VALUES ($1,$2,$3,$4,$5::jsonb,$6) RETURNING id, creado_en`
+    return s.DB.QueryRowContext(ctx, q, v.Nombre, v.Email, v.Telefono, v.Especialidades, string(horario), v.Activo).Scan(&v.ID, &v.CreadoEn)
+}
+
+func (s VeterinarioStore) Get(ctx context.Context, id int64) (*Veterinario, error) {
+    var v Veterinario
+    if err := scanVeterinario(s.DB.QueryRowContext(ctx, `SELECT `+veterinarioColumns+` FROM veterinarios WHERE id=$1`, id), &v); err != nil {
+        return nil, notFound(err)
+    }
+    return &v, nil
+}
+
+func (s VeterinarioStore) List(ctx context.Context) ([]Veterinario, error) {
+    rows, err := s.DB.QueryContext(ctx, `SELECT `+veterinarioColumns+` FROM veterinarios ORDER BY nombre, id`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Veterinario, 0)
+    for rows.Next() {
+        var v Veterinario
+        if err := scanVeterinario(rows, &v); err != nil {
+            return nil, err
+        }
+        out = append(out, v)
+    }
+    return out, rows.Err()
+}
+
+func (s VeterinarioStore) Update(ctx context.Context, v *Veterinario) error {
+    horario, err := json.Marshal(v.Horario)
+    if err != nil {
+        return err
+    }
+    q := `UPDATE veterinarios SET nombre=$1, email=$2, telefono=$3, especialidades=$4, horario=$5::jsonb, activo=$6
+          WHERE id=$7 RETURNING creado_en`
+    err = s.DB.QueryRowContext(ctx, q, v.Nombre, v.Email, v.Telefono, v.Especialidades, string(horario), v.Activo, v.ID).Scan(&v.CreadoEn)
+    return notFound(err)
+}
@@ -0,0 +1,142 @@
+package models_test
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/testutil/pgtest"
+)
+
+func TestAtiende(t *testing.T) {
+    v := models.Veterinario{Horario: []models.Turno{{Dia: "jueves", Desde: "09:00", Hasta: "13:00"}, {Dia: "jueves", Desde: "15:00", Hasta: "19:00"}}}
+    at := func(hh, mm int) time.Time { return time.Date(2030, 6, 6, hh, mm, 0, 0, time.UTC) } // a Thursday
+    for _, tc := range []struct {
+        t    time.Time
+        d    time.Duration
+        want bool
+    }{
+        {at(9, 0), 30 * time.Minute, true},
+        {at(12, 30), 30 * time.Minute, true},
+        {at(12, 45), 30 * time.Minute, false},
+        {at(13, 30), 20 * time.Minute, false},
+        {at(8, 50), 20 * time.Minute, false},
+        {at(15, 0), time.Hour, true},
+        {at(9, 0).AddDate(0, 0, 1), 20 * time.Minute, false},
+    } {
+        if got := v.Atiende(tc.t, tc.d); got != tc.want {
+            t.Errorf("Atiende(%s, %s) = %v, want %v", tc.t.Format("Mon 15:04"), tc.d, got, tc.want)
+        }
+    }
+    if (models.Turno{Dia: "jueves", Desde: "13:00", Hasta: "09:00"}).Valid() || (models.Turno{Dia: "thursday", Desde: "09:00", Hasta: "13:00"}).Valid() {
+        t.Fatal("invalid turnos accepted")
+    }
+}
+
+// TestVeterinarioStore round-trips a veterinario with its horario and
+// filters cuidados by the one assigned.
+func TestVeterinarioStore(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    vets := models.VeterinarioStore{DB: db.DB}
+    cuidados := models.CuidadoStore{DB: db.DB}
+    v := &models.Veterinario{Nombre: "Dra. Gómez", Especialidades: []string{"dermatología"}, Activo: true,
+        Horario: []models.Turno{{Dia: "jueves", Desde: "09:00", Hasta: "13:00"}}}
+    if err := vets.Create(ctx, v); err != nil {
+        t.Fatal(err)
+    }
+    if err := vets.Create(ctx, &models.Veterinario{Nombre: "Dr. Alba", Especialidades: []string{}, Horario: []models.Turno{}}); err != nil {
+        t.Fatal(err)
+    }
+    got, err := vets.Get(ctx, v.ID)
+    if err != nil || len(got.Horario) != 1 || got.Horario[0] != v.Horario[0] || got.Especialidades[0] != "dermatología" || !got.Activo {
+        t.Fatalf("Get = %+v, %v", got, err)
+    }
+    list, err := vets.List(ctx)
+    if err != nil || len(list) != 2 || list[0].Nombre != "Dr. Alba" {
+        t.Fatalf("List = %+v, %v", list, err)
+    }
+    v.Activo = false
+    if err := vets.Update(ctx, v); err != nil || v.CreadoEn.IsZero() {
+        t.Fatalf("Update = %+v, %v", v, err)
+    }
+
+    m := &models.Mascota{Nombre: "Misu", Especie: "Gato", Raza: "Siames", Sexo: "Hembra", FechaNacimiento: time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC)}
+    if err := (models.MascotaStore{DB: db.DB}).Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+    fecha := time.Date(2030, 6, 6, 9, 0, 0, 0, time.UTC)
+    asignado := &models.Cuidado{TipoCuidado: "Vacunacion", Descripcion: "Refuerzo", FechaCuidado: fecha, MascotaID: m.ID, VeterinarioID: v.ID}
+    libre := &models.Cuidado{TipoCuidado: "Bano", Descripcion: "Baño", FechaCuidado: fecha, MascotaID: m.ID}
+    for _, c := range []*models.Cuidado{asignado, libre} {
+        if err := cuidados.Create(ctx, c); err != nil {
+            t.Fatal(err)
+        }
+    }
+    var ids []int64
+    err = cuidados.Stream(ctx, models.CuidadoFilter{VeterinarioID: v.ID}, func(c models.Cuidado) error {
+        ids = append(ids, c.ID)
+        return nil
+    })
+    if err != nil || len(ids) != 1 || ids[0] != asignado.ID {
+        t.Fatalf("Stream by veterinario = %v, %v", ids, err)
+    }
+    if c, err := cuidados.Get(ctx, libre.ID); err != nil || c.VeterinarioID != 0 {
+        t.Fatalf("unassigned cuidado = %+v, %v", c, err)
+    }
+
+    // Concurrent bookings of the same slot: the veterinario row lock lets
+    // exactly one through.
+    v.Activo = true
+    if err := vets.Update(ctx, v); err != nil {
+        t.Fatal(err)
+    }
+    errs := make(chan error, 8)
+    for i := 0; i < cap(errs); i++ {
+        go func() {
+            errs <- cuidados.Create(ctx, &models.Cuidado{TipoCuidado: "Consulta Veterinaria", Descripcion: "Control",
+                FechaCuidado: fecha.Add(10 * time.Minute), MascotaID: m.ID, VeterinarioID: v.ID})
+        }()
+    }
+    var booked int
+    for i := 0; i < cap(errs); i++ {
+        var conflict *models.BookingConflict
+        switch err := <-errs; {
+        case err == nil:
+            booked++
+        case errors.As(err, &conflict) && conflict.Cuidado.VeterinarioID == v.ID:
+        default:
+            t.Fatalf("concurrent Create: %v", err)
+        }
+    }
+    if booked != 0 {
+        // asignado, at 09:00 for 20 minutes, already holds the slot.
+        t.Fatalf("%d concurrent bookings over asignado went through", booked)
+    }
+    for i := 0; i < cap(errs); i++ {
+        go func() {
+            errs <- cuidados.Create(ctx, &models.Cuidado{TipoCuidado: "Consulta Veterinaria", Descripcion: "Control",
+                FechaCuidado: fecha.Add(time.Hour), MascotaID: m.ID, VeterinarioID: v.ID})
+        }()
+    }
+    for i := 0; i < cap(errs); i++ {
+        if err := <-errs; err == nil {
+            booked++
+        } else if !errors.As(err, new(*models.BookingConflict)) {
+            t.Fatalf("concurrent Create: %v", err)
+        }
+    }
+    if booked != 1 {
+        t.Fatalf("%d concurrent bookings of a free slot went through, want 1", booked)
+    }
+
+    asignado.VeterinarioID = 0
+    if err := cuidados.Update(ctx, asignado); err != nil {
+        t.Fatal(err)
+    }
+    if c, err := cuidados.Get(ctx, asignado.ID); err != nil || c.VeterinarioID != 0 {
+        t.Fatalf("unassigned by update = %+v, %v", c, err)
+    }
+}
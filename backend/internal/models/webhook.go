@@ -0,0 +1,286 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "time"
+)
+
+const (
+    EntregaPendiente = "pendiente"
+    EntregaEntregada = "entregada"
+    EntregaFallida   = "fallida"
+)
+
+// Webhook is a subscription: matching events are POSTed to URL, signed
+// with Secreto. Secreto is only returned when the webhook is created.
+type Webhook struct {
+    ID          int64     `json:"id"`
+    URL         string    `json:"url"`
+    Eventos     []string  `json:"eventos"`
+    Secreto     string    `json:"secreto,omitempty"`
+    Descripcion string    `json:"descripcion"`
+    Activo      bool      `json:"activo"`
+    CreadoEn    time.Time `json:"creado_en"`
+}
+
+// WebhookEntrega is one event queued for one webhook, with its attempts.
+type WebhookEntrega struct {
+    ID             int64            `json:"id"`
+    WebhookID      int64            `json:"webhook_id"`
+    EventoID       int64            `json:"evento_id"`
+    Evento         string           `json:"evento"`
+    Estado         string           `json:"estado"`
+    Intentos       int              `json:"intentos"`
+    ProximoIntento time.Time        `json:"proximo_intento"`
+    UltimoStatus   *int             `json:"ultimo_status,omitempty"`
+    UltimoError    string           `json:"ultimo_error,omitempty"`
+    CreadoEn       time.Time        `json:"creado_en"`
+    EntregadoEn    *time.Time       `json:"entregado_en,omitempty"`
+    Historial      []WebhookIntento `json:"historial"`
+}
+
+// WebhookIntento records one HTTP attempt. StatusCode is 0 when no
+// response was received.
+type WebhookIntento struct {
+    Intento    int       `json:"intento"`
+    StatusCode int       `json:"status_code,omitempty"`
+    Error      string    `json:"error,omitempty"`
+    DuracionMs int64     `json:"duracion_ms"`
+    CreadoEn   time.Time `json:"creado_en"`
+}
+
+// WebhookDelivery is a claimed entrega with everything needed to send it.
+type WebhookDelivery struct {
+    ID        int64
+    Intentos  int
+    WebhookID int64
+    URL       string
+    Secreto   string
+    EventoID  int64
+    Evento    string
+    Payload   json.RawMessage
+    CreadoEn  time.Time
+}
+
+type WebhookRepository interface {
+    Create(ctx context.Context, w *Webhook) error
+    Get(ctx context.Context, id int64) (*Webhook, error)
+    List(ctx context.Context) ([]Webhook, error)
+    // Update keeps the stored secret when w.Secreto is empty.
+    Update(ctx context.Context, w *Webhook) error
+    Delete(ctx context.Context, id int64) error
+    ListEntregas(ctx context.Context, webhookID, limit, offset int64) ([]WebhookEntrega, error)
+    // Redeliver queues the event of an existing entrega again as a new
+    // entrega, leaving the original history untouched.
+    Redeliver(ctx context.Context, webhookID, entregaID int64) (*WebhookEntrega, error)
+}
+
+type WebhookStore struct{ DB *sql.DB }
+
+var _ WebhookRepository = WebhookStore{}
+
+// eventos is read back as JSON: database/sql cannot scan a TEXT[] into a
+// []string.
+const webhookColumns = `id, url, to_json(eventos), descripcion, activo, creado_en`
+
+func scanWebhook(row rowScanner, w *Webhook) error {
+    var eventos []byte
+    if err := row.Scan(&w.ID, &w.URL, &eventos, &w.Descripcion, &w.Activo, &w.CreadoEn); err != nil {
+        return err
+    }
+    return json.Unmarshal(eventos, &w.Eventos)
+}
+
+func (s WebhookStore) Create(ctx context.Context, w *Webhook) error {
+    q := `INSERT INTO webhooks(url, eventos, secreto, descripcion, activo) VALUES ($1,$2,$3,$4,$5) RETURNING id, creado_en`
+    return s.DB.QueryRowContext(ctx, q, w.URL, w.Eventos, w.Secreto, w.Descripcion, w.Activo).Scan(&w.ID, &w.CreadoEn)
+}
+
+func (s WebhookStore) Get(ctx context.Context, id int64) (*Webhook, error) {
+    q := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id=$1`
+    var w Webhook
+    if err := scanWebhook(s.DB.QueryRowContext(ctx, q, id), &w); err != nil {
+        return nil, notFound(err)
+    }
+    return &w, nil
+}
+
+func (s WebhookStore) List(ctx context.Context) ([]Webhook, error) {
+    rows, err := s.DB.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]Webhook, 0)
+    for rows.Next() {
+        var w Webhook
+        if err := scanWebhook(rows, &w); err != nil {
+            return nil, err
+        }
+        out = append(out, w)
+    }
+    return out, rows.Err()
+}
+
+func (s WebhookStore) Update(ctx context.Context, w *Webhook) error {
+    q := `UPDATE webhooks SET url=$1, eventos=$2, secreto=COALESCE(NULLIF($3,''), secreto), descripcion=$4, activo=$5
+          WHERE id=$6 RETURNING creado_en`
+    err := s.DB.QueryRowContext(ctx, q, w.URL, w.Eventos, w.Secreto, w.Descripcion, w.Activo, w.ID).Scan(&w.CreadoEn)
+    return notFound(err)
+}
+
+func (s WebhookStore) Delete(ctx context.Context, id int64) error {
+    res, err := s.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id=$1`, id)
+    return affectedOne(res, err)
+}
+
+const entregaColumns = `e.id, e.webhook_id, e.evento_id, ev.tipo, e.estado, e.intentos, e.proximo_intento,
+    e.ultimo_status, e.ultimo_error, e.creado_en, e.entregado_en`
+
+func scanEntrega(row rowScanner, e *WebhookEntrega) error {
+    var status sql.NullInt32
+    var delivered sql.NullTime
+    if err := row.Scan(&e.ID, &e.WebhookID, &e.EventoID, &e.Evento, &e.Estado, &e.Intentos, &e.ProximoIntento,
+        &status, &e.UltimoError, &e.CreadoEn, &delivered); err != nil {
+        return err
+    }
+    if status.Valid {
+        code := int(status.Int32)
+        e.UltimoStatus = &code
+    }
+    if delivered.Valid {
+        e.EntregadoEn = &delivered.Time
+    }
+    e.Historial = make([]WebhookIntento, 0)
+    return nil
+}
+
+// ListEntregas returns the newest entregas first. An unknown webhook is
+// reported as ErrNotFound rather than as an empty history.
+func (s WebhookStore) ListEntregas(ctx context.Context, webhookID, limit, offset int64) ([]WebhookEntrega, error) {
+    if _, err := s.Get(ctx, webhookID); err != nil {
+        return nil, err
+    }
+    q := `SELECT ` + entregaColumns + `
+          FROM webhook_entregas e JOIN eventos ev ON ev.id=e.evento_id
+          WHERE e.webhook_id=$1 ORDER BY e.id DESC LIMIT $2 OFFSET $3`
+    rows, err := s.DB.QueryContext(ctx, q, webhookID, limit, offset)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]WebhookEntrega, 0)
+    index := make(map[int64]int)
+    ids := make([]int64, 0)
+    for rows.Next() {
+        var e WebhookEntrega
+        if err := scanEntrega(rows, &e); err != nil {
+            return nil, err
+        }
+        index[e.ID] = len(out)
+        ids = append(ids, e.ID)
+        out = append(out, e)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+    rows.Close()
+    if len(ids) == 0 {
+        return out, nil
+    }
+
+    q = `SELECT entrega_id, intento, COALESCE(status_code, 0), error, duracion_ms, creado_en
+         FROM webhook_intentos WHERE entrega_id = ANY($1) ORDER BY id`
+    rows, err = s.DB.QueryContext(ctx, q, ids)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var id int64
+        var a WebhookIntento
+        if err := rows.Scan(&id, &a.Intento, &a.StatusCode, &a.Error, &a.DuracionMs, &a.CreadoEn); err != nil {
+            return nil, err
+        }
+        if i, ok := index[id]; ok {
+            out[i].Historial = append(out[i].Historial, a)
+        }
+    }
+    return out, rows.Err()
+}
+
+func (s WebhookStore) Redeliver(ctx context.Context, webhookID, entregaID int64) (*WebhookEntrega, error) {
+    q := `WITH n AS (
+            INSERT INTO webhook_entregas(webhook_id, evento_id)
+            SELECT webhook_id, evento_id FROM webhook_entregas WHERE id=$1 AND webhook_id=$2
+            RETURNING *
+          )
+          SELECT ` + entregaColumns + ` FROM n e JOIN eventos ev ON ev.id=e.evento_id`
+    var e WebhookEntrega
+    if err := scanEntrega(s.DB.QueryRowContext(ctx, q, entregaID, webhookID), &e); err != nil {
+        return nil, notFound(err)
+    }
+    return &e, nil
+}
+
+// ClaimDue leases up to limit pending entregas of active webhooks, like
+// NotificacionStore.ClaimDue.
+func (s WebhookStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]WebhookDelivery, error) {
+    q := `WITH due AS (
+            SELECT e.id FROM webhook_entregas e JOIN webhooks w ON w.id=e.webhook_id
+            WHERE e.estado=$1 AND e.proximo_intento <= $2 AND w.activo
+            ORDER BY e.proximo_intento, e.id
+            LIMIT $3
+            FOR UPDATE OF e SKIP LOCKED
+          )
+          UPDATE webhook_entregas e SET proximo_intento=$4
+          FROM due, webhooks w, eventos ev
+          WHERE e.id=due.id AND w.id=e.webhook_id AND ev.id=e.evento_id
+          RETURNING e.id, e.intentos, w.id, w.url, w.secreto, ev.id, ev.tipo, ev.payload, ev.creado_en`
+    rows, err := s.DB.QueryContext(ctx, q, EntregaPendiente, now, limit, leaseUntil)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    out := make([]WebhookDelivery, 0)
+    for rows.Next() {
+        var d WebhookDelivery
+        var payload []byte
+        if err := rows.Scan(&d.ID, &d.Intentos, &d.WebhookID, &d.URL, &d.Secreto, &d.EventoID, &d.Evento, &payload, &d.CreadoEn); err != nil {
+            return nil, err
+        }
+        d.Payload = payload
+        out = append(out, d)
+    }
+    return out, rows.Err()
+}
+
+// MarkDelivered, MarkRetry and MarkFailed record one attempt and move the
+// entrega to its next state in a single statement.
+
+func (s WebhookStore) MarkDelivered(ctx context.Context, id int64, a WebhookIntento) error {
+    return s.record(ctx, id, a, EntregaEntregada, time.Time{}, a.CreadoEn)
+}
+
+func (s WebhookStore) MarkRetry(ctx context.Context, id int64, a WebhookIntento, next time.Time) error {
+    return s.record(ctx, id, a, EntregaPendiente, next, time.Time{})
+}
+
+func (s WebhookStore) MarkFailed(ctx context.Context, id int64, a WebhookIntento) error {
+    return s.record(ctx, id, a, EntregaFallida, time.Time{}, time.Time{})
+}
+
+func (s WebhookStore) record(ctx context.Context, id int64, a WebhookIntento, estado string, next, deliveredAt time.Time) error {
+    status := sql.NullInt32{Int32: int32(a.StatusCode), Valid: a.StatusCode != 0}
+    q := `WITH e AS (
+            UPDATE webhook_entregas SET estado=$2, intentos=$3, ultimo_status=$4, ultimo_error=$5,
+              proximo_intento=COALESCE($6, proximo_intento), entregado_en=$7
+            WHERE id=$1 RETURNING id
+          )
+          INSERT INTO webhook_intentos(entrega_id, intento, status_code, error, duracion_ms)
+          SELECT id, $3, $4, $5, $8 FROM e`
+    res, err := s.DB.ExecContext(ctx, q, id, estado, a.Intento, status, a.Error, nullTime(next), nullTime(deliveredAt), a.DuracionMs)
+    return affectedOne(res, err)
+}
@@ -0,0 +1,130 @@
+package models_test
+
+import (
+    "context"
+    "encoding/json"
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+    "mascotas/internal/testutil/pgtest"
+)
+
+// TestWebhookQueue checks that changes fan out to the matching
+// subscriptions only, and follows one entrega through a failed attempt, a
+// delivery and a redelivery.
+func TestWebhookQueue(t *testing.T) {
+    db := pgtest.NewDB(t)
+    ctx := context.Background()
+    hooks := models.WebhookStore{DB: db.DB}
+    mascotas := models.MascotaStore{DB: db.DB}
+    cuidados := models.CuidadoStore{DB: db.DB}
+
+    crm := &models.Webhook{URL: "https://crm.example.com/hook", Eventos: []string{models.EventMascotaCreated}, Secreto: "crm-secret-000001", Activo: true}
+    all := &models.Webhook{URL: "https://erp.example.com/hook", Eventos: []string{"*"}, Secreto: "erp-secret-000001", Activo: true}
+    off := &models.Webhook{URL: "https://off.example.com/hook", Eventos: []string{"*"}, Secreto: "off-secret-000001", Activo: false}
+    for _, w := range []*models.Webhook{crm, all, off} {
+        if err := hooks.Create(ctx, w); err != nil {
+            t.Fatal(err)
+        }
+    }
+    got, err := hooks.Get(ctx, crm.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Secreto != "" || len(got.Eventos) != 1 || got.Eventos[0] != models.EventMascotaCreated {
+        t.Fatalf("get = %+v, want eventos and no secret", got)
+    }
+
+    m := &models.Mascota{Nombre: "Firulais", Especie: "Perro", Raza: "Criollo", Sexo: "Macho", FechaNacimiento: time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)}
+    if err := mascotas.Create(ctx, m); err != nil {
+        t.Fatal(err)
+    }
+    c := &models.Cuidado{TipoCuidado: "Bano", Descripcion: "Baño", FechaCuidado: time.Date(2030, 6, 10, 9, 0, 0, 0, time.UTC), MascotaID: m.ID}
+    if err := cuidados.Create(ctx, c); err != nil {
+        t.Fatal(err)
+    }
+    c.Estado = models.CuidadoCompletado
+    if err := cuidados.Update(ctx, c); err != nil {
+        t.Fatal(err)
+    }
+
+    crmEntregas, err := hooks.ListEntregas(ctx, crm.ID, 50, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(crmEntregas) != 1 || crmEntregas[0].Evento != models.EventMascotaCreated {
+        t.Fatalf("crm entregas = %+v, want only mascota.created", crmEntregas)
+    }
+    allEntregas, err := hooks.ListEntregas(ctx, all.ID, 50, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var tipos []string
+    for _, e := range allEntregas {
+        tipos = append(tipos, e.Evento)
+    }
+    want := []string{models.EventCuidadoCompleted, models.EventCuidadoUpdated, models.EventCuidadoCreated, models.EventMascotaCreated}
+    if len(tipos) != len(want) {
+        t.Fatalf("wildcard entregas = %v, want %v", tipos, want)
+    }
+    for i := range want {
+        if tipos[i] != want[i] {
+            t.Fatalf("wildcard entregas = %v, want %v", tipos, want)
+        }
+    }
+    if e, _ := hooks.ListEntregas(ctx, off.ID, 50, 0); len(e) != 0 {
+        t.Fatalf("inactive webhook got entregas: %+v", e)
+    }
+
+    now := time.Now()
+    due, err := hooks.ClaimDue(ctx, now, now.Add(time.Minute), 10)
+    if err != nil || len(due) != 5 {
+        t.Fatalf("claim = %+v, %v; want 5 entregas", due, err)
+    }
+    var d models.WebhookDelivery
+    for _, x := range due {
+        if x.WebhookID == crm.ID {
+            d = x
+        }
+    }
+    if d.Secreto != "crm-secret-000001" || d.Evento != models.EventMascotaCreated {
+        t.Fatalf("claimed = %+v", d)
+    }
+    if again, _ := hooks.ClaimDue(ctx, now, now.Add(time.Minute), 10); len(again) != 0 {
+        t.Fatalf("leased entregas claimed twice: %+v", again)
+    }
+    var payload models.Mascota
+    if err := json.Unmarshal(d.Payload, &payload); err != nil || payload.Nombre != "Firulais" {
+        t.Fatalf("payload = %s (%v)", d.Payload, err)
+    }
+
+    if err := hooks.MarkRetry(ctx, d.ID, models.WebhookIntento{Intento: 1, StatusCode: 503, Error: "respuesta HTTP 503"}, now.Add(time.Minute)); err != nil {
+        t.Fatal(err)
+    }
+    if err := hooks.MarkDelivered(ctx, d.ID, models.WebhookIntento{Intento: 2, StatusCode: 200, CreadoEn: now}); err != nil {
+        t.Fatal(err)
+    }
+    crmEntregas, err = hooks.ListEntregas(ctx, crm.ID, 50, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    e := crmEntregas[0]
+    if e.Estado != models.EntregaEntregada || e.Intentos != 2 || e.UltimoStatus == nil || *e.UltimoStatus != 200 || len(e.Historial) != 2 || e.Historial[0].StatusCode != 503 {
+        t.Fatalf("entrega = %+v", e)
+    }
+
+    again, err := hooks.Redeliver(ctx, crm.ID, e.ID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if again.ID == e.ID || again.EventoID != e.EventoID || again.Estado != models.EntregaPendiente || again.Intentos != 0 {
+        t.Fatalf("redelivered = %+v", again)
+    }
+    if _, err := hooks.Redeliver(ctx, all.ID, e.ID); err != models.ErrNotFound {
+        t.Fatalf("redeliver through another webhook: %v, want ErrNotFound", err)
+    }
+    if _, err := hooks.ListEntregas(ctx, 999, 50, 0); err != models.ErrNotFound {
+        t.Fatalf("entregas of unknown webhook: %v, want ErrNotFound", err)
+    }
+}
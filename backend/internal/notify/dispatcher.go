@@ -0,0 +1,94 @@
+package notify
+
+import (
+    "context"
+    "log"
+    "strings"
+    "time"
+
+    "mascotas/internal/clock"
+    "mascotas/internal/models"
+    "mascotas/internal/outbox"
+)
+
+// Outbox is the part of models.NotificacionStore the dispatcher uses.
+type Outbox interface {
+    ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Reminder, error)
+    MarkSent(ctx context.Context, id int64, attempt int, at time.Time) error
+    MarkRetry(ctx context.Context, id int64, attempt int, next time.Time, reason string) error
+    MarkFailed(ctx context.Context, id int64, attempt int, reason string) error
+    MarkSkipped(ctx context.Context, id int64, attempt int, reason string) error
+}
+
+// Dispatcher periodically sends due reminders, retrying failures with
+// exponential backoff until MaxAttempts is reached (see outbox.Worker).
+type Dispatcher struct {
+    Outbox       Outbox
+    Sender       Sender
+    Clock        clock.Clock
+    Location     *time.Location
+    PollInterval time.Duration
+    BatchSize    int
+    MaxAttempts  int
+    RetryBackoff time.Duration // delay after the first failure, doubled each time
+    MaxBackoff   time.Duration
+    SendTimeout  time.Duration
+}
+
+// Run dispatches until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+    d.worker().Run(ctx)
+}
+
+// RunOnce processes one batch of due reminders and returns how many it
+// handled.
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+    return d.worker().RunOnce(ctx)
+}
+
+func (d *Dispatcher) worker() *outbox.Worker[models.Reminder] {
+    return &outbox.Worker[models.Reminder]{
+        Name:         "notify",
+        Clock:        d.Clock,
+        PollInterval: d.PollInterval,
+        BatchSize:    d.BatchSize,
+        MaxAttempts:  d.MaxAttempts,
+        RetryBackoff: d.RetryBackoff,
+        MaxBackoff:   d.MaxBackoff,
+        Timeout:      d.SendTimeout,
+        Claim:        d.Outbox.ClaimDue,
+        Attempts:     func(r models.Reminder) int { return r.Intentos },
+        Deliver:      d.deliver,
+        Fail:         d.fail,
+    }
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, r models.Reminder, attempt int, now time.Time) error {
+    switch {
+    case r.PropietarioEmail == "":
+        return d.Outbox.MarkSkipped(ctx, r.ID, attempt, "la mascota no tiene email de propietario")
+    case !r.FechaCuidado.After(now):
+        return d.Outbox.MarkSkipped(ctx, r.ID, attempt, "el cuidado ya ocurrió")
+    case r.EstadoCuidado != "" && r.EstadoCuidado != models.CuidadoProgramado:
+        return d.Outbox.MarkSkipped(ctx, r.ID, attempt, "el cuidado está "+strings.ToLower(r.EstadoCuidado))
+    }
+
+    msg, err := renderReminder(r, d.Location)
+    if err == nil {
+        sendCtx, cancel := context.WithTimeout(ctx, d.SendTimeout)
+        err = d.Sender.Send(sendCtx, msg)
+        cancel()
+    }
+    if err != nil {
+        return outbox.Failed(err)
+    }
+    return d.Outbox.MarkSent(ctx, r.ID, attempt, d.Clock.Now())
+}
+
+func (d *Dispatcher) fail(ctx context.Context, r models.Reminder, attempt int, err error, retry time.Time) error {
+    log.Printf("notify: reminder %d attempt %d failed: %v", r.ID, attempt, err)
+    if retry.IsZero() {
+        return d.Outbox.MarkFailed(ctx, r.ID, attempt, err.Error())
+    }
+    return d.Outbox.MarkRetry(ctx, r.ID, attempt, retry, err.Error())
+}
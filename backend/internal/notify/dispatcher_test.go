@@ -0,0 +1,169 @@
+package notify
+
+import (
+    "context"
+    "mime"
+    "strings"
+    "testing"
+    "time"
+
+    "mascotas/internal/clock"
+    "mascotas/internal/models"
+    "mascotas/internal/notify/smtptest"
+)
+
+type outcome struct {
+    estado  string
+    attempt int
+    next    time.Time
+    reason  string
+}
+
+// fakeOutbox hands out its reminders once and records every outcome.
+type fakeOutbox struct {
+    due      []models.Reminder
+    outcomes map[int64]outcome
+}
+
+func (f *fakeOutbox) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.Reminder, error) {
+    due := f.due
+    f.due = nil
+    return due, nil
+}
+
+func (f *fakeOutbox) MarkSent(ctx context.Context, id int64, attempt int, at time.Time) error {
+    f.outcomes[id] = outcome{estado: models.NotificacionEnviada, attempt: attempt}
+    return nil
+}
+
+func (f *fakeOutbox) MarkRetry(ctx context.Context, id int64, attempt int, next time.Time, reason string) error {
+    f.outcomes[id] = outcome{estado: models.NotificacionPendiente, attempt: attempt, next: next, reason: reason}
+    return nil
+}
+
+func (f *fakeOutbox) MarkFailed(ctx context.Context, id int64, attempt int, reason string) error {
+    f.outcomes[id] = outcome{estado: models.NotificacionFallida, attempt: attempt, reason: reason}
+    return nil
+}
+
+func (f *fakeOutbox) MarkSkipped(ctx context.Context, id int64, attempt int, reason string) error {
+    f.outcomes[id] = outcome{estado: models.NotificacionOmitida, attempt: attempt, reason: reason}
+    return nil
+}
+
+var now = time.Date(2030, 6, 9, 9, 0, 0, 0, time.UTC)
+
+func newDispatcher(t *testing.T, out *fakeOutbox) (*Dispatcher, *smtptest.Server) {
+    t.Helper()
+    srv, err := smtptest.NewServer()
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(srv.Close)
+    return &Dispatcher{
+        Outbox:       out,
+        Sender:       SMTPSender{Host: srv.Host(), Port: srv.Port(), From: "clinica@example.com"},
+        Clock:        clock.Fixed(now),
+        Location:     time.UTC,
+        BatchSize:    10,
+        MaxAttempts:  3,
+        RetryBackoff: time.Minute,
+        MaxBackoff:   time.Hour,
+        SendTimeout:  5 * time.Second,
+    }, srv
+}
+
+func reminder(id int64, email string) models.Reminder {
+    return models.Reminder{
+        ID:                id,
+        CuidadoID:         id,
+        TipoCuidado:       "Vacunacion",
+        Descripcion:       "Refuerzo antirrábico",
+        FechaCuidado:      time.Date(2030, 6, 10, 9, 0, 0, 0, time.UTC),
+        Mascota:           "Firulais",
+        PropietarioNombre: "Ana",
+        PropietarioEmail:  email,
+    }
+}
+
+func TestDispatcherSendsReminder(t *testing.T) {
+    out := &fakeOutbox{due: []models.Reminder{reminder(1, "ana@example.com")}, outcomes: map[int64]outcome{}}
+    d, srv := newDispatcher(t, out)
+
+    n, err := d.RunOnce(context.Background())
+    if err != nil || n != 1 {
+        t.Fatalf("RunOnce = %d, %v", n, err)
+    }
+    if got := out.outcomes[1]; got.estado != models.NotificacionEnviada || got.attempt != 1 {
+        t.Fatalf("outcome = %+v, want enviada on attempt 1", got)
+    }
+    msgs := srv.Messages()
+    if len(msgs) != 1 || len(msgs[0].To) != 1 || msgs[0].To[0] != "ana@example.com" {
+        t.Fatalf("messages = %+v", msgs)
+    }
+    data := msgs[0].Data
+    var subject string
+    for _, line := range strings.Split(data, "\n") {
+        if v, ok := strings.CutPrefix(strings.TrimRight(line, "\r"), "Subject: "); ok {
+            subject, _ = new(mime.WordDecoder).DecodeHeader(v)
+        }
+    }
+    if subject != "Recordatorio: vacunación de Firulais el 10 de junio" {
+        t.Errorf("subject = %q", subject)
+    }
+    for _, want := range []string{"Hola Ana,", "lunes 10 de junio de 2030 a las 09:00", "Detalle: Refuerzo antirrábico"} {
+        if !strings.Contains(data, want) {
+            t.Errorf("body missing %q:\n%s", want, data)
+        }
+    }
+}
+
+func TestDispatcherRetriesWithBackoffThenFails(t *testing.T) {
+    out := &fakeOutbox{outcomes: map[int64]outcome{}}
+    d, srv := newDispatcher(t, out)
+    srv.RejectRcpt = func(string) bool { return true }
+
+    for attempt, wantNext := range []time.Duration{time.Minute, 2 * time.Minute} {
+        r := reminder(7, "nadie@example.com")
+        r.Intentos = attempt
+        out.due = []models.Reminder{r}
+        if _, err := d.RunOnce(context.Background()); err != nil {
+            t.Fatal(err)
+        }
+        got := out.outcomes[7]
+        if got.estado != models.NotificacionPendiente || !got.next.Equal(now.Add(wantNext)) || !strings.Contains(got.reason, "550") {
+            t.Fatalf("attempt %d outcome = %+v, want retry at +%s", attempt+1, got, wantNext)
+        }
+    }
+
+    r := reminder(7, "nadie@example.com")
+    r.Intentos = 2
+    out.due = []models.Reminder{r}
+    if _, err := d.RunOnce(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if got := out.outcomes[7]; got.estado != models.NotificacionFallida || got.attempt != 3 {
+        t.Fatalf("final outcome = %+v, want fallida on attempt 3", got)
+    }
+}
+
+func TestDispatcherSkipsUndeliverable(t *testing.T) {
+    past := reminder(2, "ana@example.com")
+    past.FechaCuidado = now.Add(-time.Hour)
+    cancelled := reminder(3, "ana@example.com")
+    cancelled.EstadoCuidado = models.CuidadoCancelado
+    out := &fakeOutbox{due: []models.Reminder{reminder(1, ""), past, cancelled}, outcomes: map[int64]outcome{}}
+    d, srv := newDispatcher(t, out)
+
+    if _, err := d.RunOnce(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    for id := int64(1); id <= 3; id++ {
+        if got := out.outcomes[id]; got.estado != models.NotificacionOmitida {
+            t.Errorf("reminder %d outcome = %+v, want omitida", id, got)
+        }
+    }
+    if len(srv.Messages()) != 0 {
+        t.Errorf("nothing should have been sent")
+    }
+}
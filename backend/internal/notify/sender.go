@@ -0,0 +1,107 @@
+// Package notify delivers reminder e-mails for upcoming cuidados from the
+// notificaciones outbox.
+package notify
+
+import (
+    "context"
+    "crypto/tls"
+    "fmt"
+    "mime"
+    "net"
+    "net/smtp"
+    "strings"
+    "time"
+)
+
+type Message struct {
+    To      string
+    Subject string
+    Body    string
+}
+
+// Sender delivers one message. Implementations must be safe for
+// concurrent use.
+type Sender interface {
+    Send(ctx context.Context, msg Message) error
+}
+
+// SMTPSender sends plain-text mail through an SMTP relay, upgrading to TLS
+// with STARTTLS when the server offers it.
+type SMTPSender struct {
+    Host     string
+    Port     int
+    Username string
+    Password string
+    From     string
+    Timeout  time.Duration
+}
+
+func (s SMTPSender) Send(ctx context.Context, msg Message) error {
+    timeout := s.Timeout
+    if timeout <= 0 {
+        timeout = 30 * time.Second
+    }
+    if dl, ok := ctx.Deadline(); ok && time.Until(dl) < timeout {
+        timeout = time.Until(dl)
+    }
+    addr := net.JoinHostPort(s.Host, fmt.Sprint(s.Port))
+    conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", addr)
+    if err != nil {
+        return err
+    }
+    conn.SetDeadline(time.Now().Add(timeout))
+    c, err := smtp.NewClient(conn, s.Host)
+    if err != nil {
+        conn.Close()
+        return err
+    }
+    defer c.Close()
+
+    if ok, _ := c.Extension("STARTTLS"); ok {
+        if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+            return err
+        }
+    }
+    if s.Username != "" {
+        if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+            return err
+        }
+    }
+    if err := c.Mail(s.From); err != nil {
+        return err
+    }
+    if err := c.Rcpt(msg.To); err != nil {
+        return err
+    }
+    w, err := c.Data()
+    if err != nil {
+        return err
+    }
+    if _, err := w.Write(formatMessage(s.From, msg)); err != nil {
+        return err
+    }
+    if err := w.Close(); err != nil {
+        return err
+    }
+    return c.Quit()
+}
+
+func formatMessage(from string, msg Message) []byte {
+    var b strings.Builder
+    fmt.Fprintf(&b, "From: %s\r\n", from)
+    fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+    fmt.Fprintf(&b, "Subject: %s\r\n", mimeHeader(msg.Subject))
+    fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    b.WriteString("MIME-Version: 1.0\r\n")
+    b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+    b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+    b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+    return []byte(b.String())
+}
+
+// mimeHeader encodes a header value that is not plain printable ASCII:
+// accents, common in Spanish, and CR or LF, which would otherwise let a
+// mascota name start headers of its own.
+func mimeHeader(s string) string {
+    return mime.QEncoding.Encode("UTF-8", s)
+}
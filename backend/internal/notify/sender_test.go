@@ -0,0 +1,35 @@
+package notify
+
+import (
+    "mime"
+    "strings"
+    "testing"
+)
+
+func TestFormatMessageEncodesSubject(t *testing.T) {
+    cases := []string{
+        "Recordatorio: vacunación de Luna",
+        "Recordatorio: baño de Rex\r\nBcc: victima@example.com",
+        "Recordatorio: baño de Rex\nBcc: victima@example.com",
+    }
+    for _, subject := range cases {
+        raw := string(formatMessage("clinica@example.com", Message{To: "ana@example.com", Subject: subject, Body: "Hola"}))
+        head, _, _ := strings.Cut(raw, "\r\n\r\n")
+        var got string
+        for _, line := range strings.Split(head, "\r\n") {
+            if strings.HasPrefix(line, "Bcc:") {
+                t.Fatalf("subject %q injected a header:\n%s", subject, head)
+            }
+            if v, ok := strings.CutPrefix(line, "Subject: "); ok {
+                got = v
+            }
+        }
+        if strings.Count(head, "\n") != strings.Count(head, "\r\n") {
+            t.Fatalf("bare LF in headers of %q:\n%s", subject, head)
+        }
+        decoded, err := new(mime.WordDecoder).DecodeHeader(got)
+        if err != nil || decoded != subject {
+            t.Errorf("Subject %q decodes to %q (%v), want %q", got, decoded, err, subject)
+        }
+    }
+}
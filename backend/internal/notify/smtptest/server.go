@@ -0,0 +1,133 @@
+// Package smtptest runs a minimal local SMTP server that records the
+// messages it receives, for testing senders without a real relay.
+package smtptest
+
+import (
+    "bufio"
+    "net"
+    "net/textproto"
+    "strings"
+    "sync"
+)
+
+type Received struct {
+    From string
+    To   []string
+    Data string
+}
+
+// Server accepts plain SMTP (no TLS, no auth) on a loopback port.
+type Server struct {
+    Addr string
+    // RejectRcpt, when set, makes RCPT TO fail with 550 for matching
+    // recipients.
+    RejectRcpt func(rcpt string) bool
+
+    ln       net.Listener
+    mu       sync.Mutex
+    messages []Received
+    wg       sync.WaitGroup
+}
+
+func NewServer() (*Server, error) {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        return nil, err
+    }
+    s := &Server{Addr: ln.Addr().String(), ln: ln}
+    s.wg.Add(1)
+    go s.serve()
+    return s, nil
+}
+
+// Host and Port split Addr for sender configuration.
+func (s *Server) Host() string {
+    h, _, _ := net.SplitHostPort(s.Addr)
+    return h
+}
+
+func (s *Server) Port() int {
+    return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *Server) Messages() []Received {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]Received(nil), s.messages...)
+}
+
+func (s *Server) Close() {
+    s.ln.Close()
+    s.wg.Wait()
+}
+
+func (s *Server) serve() {
+    defer s.wg.Done()
+    for {
+        conn, err := s.ln.Accept()
+        if err != nil {
+            return
+        }
+        s.wg.Add(1)
+        go func() {
+            defer s.wg.Done()
+            defer conn.Close()
+            s.session(textproto.NewConn(conn))
+        }()
+    }
+}
+
+func (s *Server) session(c *textproto.Conn) {
+    c.PrintfLine("220 smtptest ready")
+    var msg Received
+    for {
+        line, err := c.ReadLine()
+        if err != nil {
+            return
+        }
+        verb, arg, _ := strings.Cut(line, " ")
+        switch strings.ToUpper(verb) {
+        case "EHLO", "HELO":
+            c.PrintfLine("250 smtptest")
+        case "MAIL":
+            msg = Received{From: addrArg(arg)}
+            c.PrintfLine("250 OK")
+        case "RCPT":
+            rcpt := addrArg(arg)
+            if s.RejectRcpt != nil && s.RejectRcpt(rcpt) {
+                c.PrintfLine("550 mailbox unavailable")
+                continue
+            }
+            msg.To = append(msg.To, rcpt)
+            c.PrintfLine("250 OK")
+        case "DATA":
+            c.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+            data, err := readData(c.R)
+            if err != nil {
+                return
+            }
+            msg.Data = data
+            s.mu.Lock()
+            s.messages = append(s.messages, msg)
+            s.mu.Unlock()
+            c.PrintfLine("250 OK queued")
+        case "RSET", "NOOP":
+            c.PrintfLine("250 OK")
+        case "QUIT":
+            c.PrintfLine("221 bye")
+            return
+        default:
+            c.PrintfLine("502 command not implemented")
+        }
+    }
+}
+
+func addrArg(arg string) string {
+    _, addr, _ := strings.Cut(arg, ":")
+    return strings.Trim(strings.TrimSpace(addr), "<>")
+}
+
+func readData(r *bufio.Reader) (string, error) {
+    b, err := textproto.NewReader(r).ReadDotBytes()
+    return string(b), err
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+    "fmt"
+    "strings"
+    "text/template"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+var (
+    diasSemana = [...]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"}
+    meses      = [...]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}
+)
+
+var (
+    subjectTmpl = template.Must(template.New("asunto").Funcs(funcs).Parse(
+        `Recordatorio: {{cuidado .TipoCuidado}} de {{.Mascota}} el {{fechaCorta .Fecha}}`))
+    bodyTmpl = template.Must(template.New("cuerpo").Funcs(funcs).Parse(
+        `Hola{{with .Propietario}} {{.}}{{end}},
+
+Le recordamos que {{.Mascota}} tiene programado un cuidado de {{cuidado .TipoCuidado}} el {{fechaLarga .Fecha}}.
+{{with .Descripcion}}
+Detalle: {{.}}
+{{end}}
+Si no puede asistir, por favor comuníquese con la clínica para reprogramar la cita.
+
+Gracias por confiar en nosotros.
+`))
+)
+
+var funcs = template.FuncMap{
+    "cuidado": func(tipo string) string {
+        return strings.ToLower(models.NombreCuidado(tipo))
+    },
+    "fechaCorta": func(t time.Time) string {
+        return fmt.Sprintf("%d de %s", t.Day(), meses[t.Month()-1])
+    },
+    "fechaLarga": func(t time.Time) string {
+        return fmt.Sprintf("%s %d de %s de %d a las %s", diasSemana[t.Weekday()], t.Day(), meses[t.Month()-1], t.Year(), t.Format("15:04"))
+    },
+}
+
+type reminderData struct {
+    Propietario string
+    Mascota     string
+    TipoCuidado string
+    Descripcion string
+    Fecha       time.Time
+}
+
+// renderReminder builds the Spanish reminder for r with dates shown in loc.
+func renderReminder(r models.Reminder, loc *time.Location) (Message, error) {
+    data := reminderData{
+        Propietario: r.PropietarioNombre,
+        Mascota:     r.Mascota,
+        TipoCuidado: r.TipoCuidado,
+        Descripcion: r.Descripcion,
+        Fecha:       r.FechaCuidado.In(loc),
+    }
+    var subject, body strings.Builder
+    if err := subjectTmpl.Execute(&subject, data); err != nil {
+        return Message{}, err
+    }
+    if err := bodyTmpl.Execute(&body, data); err != nil {
+        return Message{}, err
+    }
+    return Message{To: r.PropietarioEmail, Subject: subject.String(), Body: body.String()}, nil
+}
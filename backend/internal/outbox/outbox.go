@@ -0,0 +1,116 @@
+// Package outbox runs the polling loop shared by the dispatchers of queued
+// deliveries (reminder e-mails, webhooks): claim a batch of due items
+// under a lease, attempt each one and schedule failed attempts for a retry
+// with exponential backoff until MaxAttempts is reached.
+package outbox
+
+import (
+    "context"
+    "errors"
+    "log"
+    "time"
+
+    "mascotas/internal/clock"
+)
+
+// Worker dispatches items of type T. The funcs do the work specific to
+// each queue; Worker decides when to claim and when to give up.
+type Worker[T any] struct {
+    // Name prefixes the log lines ("notify", "webhook").
+    Name         string
+    Clock        clock.Clock
+    PollInterval time.Duration
+    BatchSize    int
+    MaxAttempts  int
+    RetryBackoff time.Duration // delay after the first failure, doubled each time
+    MaxBackoff   time.Duration
+    // Timeout bounds one attempt; the lease of a batch covers all of them.
+    Timeout      time.Duration
+
+    // Claim leases up to limit due items until leaseUntil so other
+    // replicas leave them alone.
+    Claim    func(ctx context.Context, now, leaseUntil time.Time, limit int) ([]T, error)
+    // Attempts is how many times item was tried before.
+    Attempts func(item T) int
+    // Deliver makes attempt number attempt and records its success. A
+    // failed attempt is returned wrapped by Failed and handed to Fail; any
+    // other error stops the batch.
+    Deliver  func(ctx context.Context, item T, attempt int, now time.Time) error
+    // Fail records a failed attempt, to be retried at retry, or for good
+    // when retry is zero.
+    Fail     func(ctx context.Context, item T, attempt int, err error, retry time.Time) error
+}
+
+// failure marks an error as a failed attempt rather than a broken queue.
+type failure struct{ err error }
+
+func (f failure) Error() string { return f.err.Error() }
+func (f failure) Unwrap() error { return f.err }
+
+// Failed wraps the error of a failed attempt for Worker.Deliver.
+func Failed(err error) error {
+    return failure{err}
+}
+
+// Run dispatches until ctx is cancelled.
+func (w *Worker[T]) Run(ctx context.Context) {
+    t := time.NewTicker(w.PollInterval)
+    defer t.Stop()
+    for {
+        if _, err := w.RunOnce(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("%s: dispatch error: %v", w.Name, err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-t.C:
+        }
+    }
+}
+
+// RunOnce processes one batch of due items and returns how many it
+// handled.
+func (w *Worker[T]) RunOnce(ctx context.Context) (int, error) {
+    now := w.Clock.Now()
+    // The lease keeps other replicas away while this batch is in flight.
+    lease := now.Add(w.Timeout*time.Duration(w.BatchSize) + time.Minute)
+    due, err := w.Claim(ctx, now, lease, w.BatchSize)
+    if err != nil {
+        return 0, err
+    }
+    for _, item := range due {
+        if err := w.attempt(ctx, item, now); err != nil {
+            return 0, err
+        }
+    }
+    return len(due), nil
+}
+
+func (w *Worker[T]) attempt(ctx context.Context, item T, now time.Time) error {
+    attempt := w.Attempts(item) + 1
+    err := w.Deliver(ctx, item, attempt, now)
+    var f failure
+    if !errors.As(err, &f) {
+        return err
+    }
+    if ctx.Err() != nil {
+        return ctx.Err()
+    }
+    var retry time.Time
+    if attempt < w.MaxAttempts {
+        retry = w.Clock.Now().Add(w.Backoff(attempt))
+    }
+    return w.Fail(ctx, item, attempt, f.err, retry)
+}
+
+// Backoff is RetryBackoff * 2^(attempt-1), capped at MaxBackoff.
+func (w *Worker[T]) Backoff(attempt int) time.Duration {
+    b := w.RetryBackoff
+    for i := 1; i < attempt; i++ {
+        b *= 2
+        if w.MaxBackoff > 0 && b >= w.MaxBackoff {
+            return w.MaxBackoff
+        }
+    }
+    return b
+}
@@ -0,0 +1,100 @@
+package outbox
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "mascotas/internal/clock"
+)
+
+var now = time.Date(2030, 6, 9, 9, 0, 0, 0, time.UTC)
+
+type fail struct {
+    attempt int
+    err     string
+    retry   time.Time
+}
+
+// TestWorker runs a batch of attempts numbered from their previous
+// attempts: 1 is delivered, 2 fails and is retried, 3 fails for good.
+func TestWorker(t *testing.T) {
+    delivered := map[int]int{}
+    failed := map[int]fail{}
+    var lease time.Time
+    w := &Worker[int]{
+        Clock:        clock.Fixed(now),
+        BatchSize:    5,
+        MaxAttempts:  3,
+        RetryBackoff: time.Minute,
+        Timeout:      10 * time.Second,
+        Claim: func(ctx context.Context, now, leaseUntil time.Time, limit int) ([]int, error) {
+            lease = leaseUntil
+            return []int{0, 1, 2}, nil
+        },
+        Attempts: func(item int) int { return item },
+        Deliver: func(ctx context.Context, item, attempt int, now time.Time) error {
+            if item == 0 {
+                delivered[item] = attempt
+                return nil
+            }
+            return Failed(errors.New("550 buzón inexistente"))
+        },
+        Fail: func(ctx context.Context, item, attempt int, err error, retry time.Time) error {
+            failed[item] = fail{attempt, err.Error(), retry}
+            return nil
+        },
+    }
+
+    n, err := w.RunOnce(context.Background())
+    if err != nil || n != 3 {
+        t.Fatalf("RunOnce = %d, %v", n, err)
+    }
+    if want := now.Add(5*10*time.Second + time.Minute); !lease.Equal(want) {
+        t.Errorf("lease until %s, want %s", lease, want)
+    }
+    if delivered[0] != 1 {
+        t.Errorf("delivered = %v, want item 0 on attempt 1", delivered)
+    }
+    if got := failed[1]; got != (fail{2, "550 buzón inexistente", now.Add(2 * time.Minute)}) {
+        t.Errorf("item 1 = %+v, want a retry in 2m", got)
+    }
+    if got := failed[2]; got.attempt != 3 || !got.retry.IsZero() {
+        t.Errorf("item 2 = %+v, want failed for good on attempt 3", got)
+    }
+}
+
+// TestWorkerStopsOnQueueError: an error that is not a failed attempt
+// comes from the queue itself and ends the batch.
+func TestWorkerStopsOnQueueError(t *testing.T) {
+    var tried []int
+    w := &Worker[int]{
+        Clock:       clock.Fixed(now),
+        MaxAttempts: 3,
+        Claim: func(ctx context.Context, now, leaseUntil time.Time, limit int) ([]int, error) {
+            return []int{1, 2}, nil
+        },
+        Attempts: func(item int) int { return 0 },
+        Deliver: func(ctx context.Context, item, attempt int, now time.Time) error {
+            tried = append(tried, item)
+            return errors.New("conexión cerrada")
+        },
+        Fail: func(ctx context.Context, item, attempt int, err error, retry time.Time) error {
+            t.Errorf("Fail(%d) called for a queue error", item)
+            return nil
+        },
+    }
+    if _, err := w.RunOnce(context.Background()); err == nil || len(tried) != 1 {
+        t.Fatalf("RunOnce err %v after %v, want the queue error after the first item", err, tried)
+    }
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+    w := &Worker[int]{RetryBackoff: time.Minute, MaxBackoff: 10 * time.Minute}
+    for attempt, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 5: 10 * time.Minute, 20: 10 * time.Minute} {
+        if got := w.Backoff(attempt); got != want {
+            t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+        }
+    }
+}
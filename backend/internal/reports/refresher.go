@@ -0,0 +1,42 @@
+// Package reports keeps the precomputed report data up to date.
+package reports
+
+import (
+    "context"
+    "log"
+    "time"
+)
+
+// Refreshable is the part of models.ReporteStore the refresher uses.
+type Refreshable interface {
+    Refresh(ctx context.Context) error
+}
+
+// Refresher recomputes the materialized report views every Interval, so
+// the reports lag the cuidados by at most that long.
+type Refresher struct {
+    Repo     Refreshable
+    Interval time.Duration
+}
+
+// Run refreshes right away and then every Interval until ctx is
+// cancelled. A failed refresh is logged; the reports keep the last data.
+func (r *Refresher) Run(ctx context.Context) {
+    t := time.NewTicker(r.Interval)
+    defer t.Stop()
+    for {
+        if err := r.RunOnce(ctx); err != nil && ctx.Err() == nil {
+            log.Printf("reports: refresh error: %v", err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-t.C:
+        }
+    }
+}
+
+// RunOnce refreshes the views once.
+func (r *Refresher) RunOnce(ctx context.Context) error {
+    return r.Repo.Refresh(ctx)
+}
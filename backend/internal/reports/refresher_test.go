@@ -0,0 +1,42 @@
+package reports
+
+import (
+    "context"
+    "errors"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+type countingRepo struct {
+    calls atomic.Int32
+    err   error
+}
+
+func (c *countingRepo) Refresh(ctx context.Context) error {
+    c.calls.Add(1)
+    return c.err
+}
+
+func TestRefresherKeepsGoing(t *testing.T) {
+    repo := &countingRepo{err: errors.New("view is locked")}
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    go func() {
+        (&Refresher{Repo: repo, Interval: 5 * time.Millisecond}).Run(ctx)
+        close(done)
+    }()
+    deadline := time.Now().Add(2 * time.Second)
+    for repo.calls.Load() < 3 {
+        if time.Now().After(deadline) {
+            t.Fatalf("refreshed %d times, want at least 3", repo.calls.Load())
+        }
+        time.Sleep(time.Millisecond)
+    }
+    cancel()
+    select {
+    case <-done:
+    case <-time.After(time.Second):
+        t.Fatal("Run did not return after cancel")
+    }
+}
@@ -0,0 +1,127 @@
+// Package rules holds the clinic's business rules: what a request must
+// satisfy beyond the shape go-playground's tags check, because it depends
+// on today's date, on the especie or on other records. Every violation
+// carries a stable code clients can match on.
+package rules
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+// Codes of the violations of the default rules.
+const (
+    CodeNacimientoFuturo      = "fecha_nacimiento_future"
+    CodeEdadImplausible       = "fecha_nacimiento_implausible"
+    CodeNacimientoTrasCuidado = "fecha_nacimiento_after_cuidado"
+    CodeCuidadoAntesNacer     = "fecha_cuidado_before_birth"
+)
+
+// Violation is a broken rule about a field of the request.
+type Violation struct {
+    Field   string
+    Code    string
+    Message string
+}
+
+// Rule checks a subject and returns the violation it finds, or nil.
+type Rule[T any] func(T) *Violation
+
+// Set is the rules checked on a subject. Handlers hold one per subject, so
+// a deployment can add or drop rules.
+type Set[T any] []Rule[T]
+
+// Check runs every rule and returns all the violations, in rule order.
+func (s Set[T]) Check(v T) []Violation {
+    var out []Violation
+    for _, rule := range s {
+        if viol := rule(v); viol != nil {
+            out = append(out, *viol)
+        }
+    }
+    return out
+}
+
+// MascotaCheck is a mascota about to be stored. Hoy is today in the
+// clinic's time zone; PrimerCuidado is the day of its earliest cuidado
+// that is not cancelled, zero for a new mascota or one without any.
+type MascotaCheck struct {
+    Mascota       models.Mascota
+    Hoy           time.Time
+    PrimerCuidado time.Time
+}
+
+// CuidadoCheck is a cuidado about to be stored for Mascota. Dia is the
+// day of the cuidado in the clinic's time zone.
+type CuidadoCheck struct {
+    Cuidado models.Cuidado
+    Mascota models.Mascota
+    Dia     time.Time
+}
+
+// EdadMaxima is the plausible maximum age in years of each especie, a
+// little above the longest-lived ones on record.
+var EdadMaxima = map[string]int{
+    "Perro":  30,
+    "Gato":   35,
+    "Conejo": 20,
+}
+
+// Mascotas returns the default rules on mascotas.
+func Mascotas() Set[MascotaCheck] {
+    return Set[MascotaCheck]{NacimientoNoFuturo, EdadPlausible, NacimientoAntesDeCuidados}
+}
+
+// Cuidados returns the default rules on cuidados.
+func Cuidados() Set[CuidadoCheck] {
+    return Set[CuidadoCheck]{CuidadoTrasNacimiento}
+}
+
+func sameOrBefore(a, b time.Time) bool {
+    ay, am, ad := a.Date()
+    by, bm, bd := b.Date()
+    return ay < by || (ay == by && (am < bm || (am == bm && ad <= bd)))
+}
+
+// NacimientoNoFuturo rejects a birth date after today.
+func NacimientoNoFuturo(c MascotaCheck) *Violation {
+    if sameOrBefore(c.Mascota.FechaNacimiento, c.Hoy) {
+        return nil
+    }
+    return &Violation{Field: "fecha_nacimiento", Code: CodeNacimientoFuturo, Message: "no puede ser posterior a hoy"}
+}
+
+// EdadPlausible rejects a birth date that makes the mascota older than
+// EdadMaxima for its especie.
+func EdadPlausible(c MascotaCheck) *Violation {
+    max, ok := EdadMaxima[c.Mascota.Especie]
+    if !ok || models.MesesCumplidos(c.Mascota.FechaNacimiento, c.Hoy) < 12*(max+1) {
+        return nil
+    }
+    return &Violation{Field: "fecha_nacimiento", Code: CodeEdadImplausible,
+        Message: fmt.Sprintf("un %s no vive más de %d años", strings.ToLower(c.Mascota.Especie), max)}
+}
+
+// NacimientoAntesDeCuidados rejects a birth date after a cuidado the
+// mascota already has.
+func NacimientoAntesDeCuidados(c MascotaCheck) *Violation {
+    if c.PrimerCuidado.IsZero() || sameOrBefore(c.Mascota.FechaNacimiento, c.PrimerCuidado) {
+        return nil
+    }
+    return &Violation{Field: "fecha_nacimiento", Code: CodeNacimientoTrasCuidado,
+        Message: "es posterior a su primer cuidado, del " + c.PrimerCuidado.Format("2006-01-02")}
+}
+
+// CuidadoTrasNacimiento rejects a cuidado dated before the mascota was
+// born. An approximate birth date counts from its first day.
+func CuidadoTrasNacimiento(c CuidadoCheck) *Violation {
+    if sameOrBefore(c.Mascota.FechaNacimiento, c.Dia) {
+        return nil
+    }
+    return &Violation{Field: "fecha_cuidado", Code: CodeCuidadoAntesNacer,
+        Message: fmt.Sprintf("es anterior al nacimiento de %s (%s)", c.Mascota.Nombre,
+            models.FormatFechaNacimiento(c.Mascota.FechaNacimiento, c.Mascota.FechaNacimientoPrecision))}
+}
@@ -0,0 +1,65 @@
+package rules
+
+import (
+    "testing"
+    "time"
+
+    "mascotas/internal/models"
+)
+
+func TestMascotas(t *testing.T) {
+    day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+    hoy := day(2030, 6, 5)
+    for _, tc := range []struct {
+        name  string
+        check MascotaCheck
+        want  string
+    }{
+        {"born today", MascotaCheck{Mascota: models.Mascota{Especie: "Perro", FechaNacimiento: hoy}, Hoy: hoy}, ""},
+        {"born tomorrow", MascotaCheck{Mascota: models.Mascota{Especie: "Perro", FechaNacimiento: day(2030, 6, 6)}, Hoy: hoy}, CodeNacimientoFuturo},
+        {"oldest plausible", MascotaCheck{Mascota: models.Mascota{Especie: "Conejo", FechaNacimiento: day(2009, 6, 6)}, Hoy: hoy}, ""},
+        {"too old", MascotaCheck{Mascota: models.Mascota{Especie: "Conejo", FechaNacimiento: day(2009, 6, 5)}, Hoy: hoy}, CodeEdadImplausible},
+        {"unknown especie", MascotaCheck{Mascota: models.Mascota{Especie: "Tortuga", FechaNacimiento: day(1950, 1, 1)}, Hoy: hoy}, ""},
+        {"born the day of its first cuidado", MascotaCheck{Mascota: models.Mascota{Especie: "Gato", FechaNacimiento: day(2030, 5, 20)}, Hoy: hoy,
+            PrimerCuidado: day(2030, 5, 20)}, ""},
+        {"born after its first cuidado", MascotaCheck{Mascota: models.Mascota{Especie: "Gato", FechaNacimiento: day(2030, 5, 21)}, Hoy: hoy,
+            PrimerCuidado: day(2030, 5, 20)}, CodeNacimientoTrasCuidado},
+    } {
+        got := Mascotas().Check(tc.check)
+        switch {
+        case tc.want == "" && len(got) != 0:
+            t.Errorf("%s: Check = %+v, want no violations", tc.name, got)
+        case tc.want != "" && (len(got) != 1 || got[0].Code != tc.want || got[0].Field != "fecha_nacimiento"):
+            t.Errorf("%s: Check = %+v, want %s", tc.name, got, tc.want)
+        }
+    }
+}
+
+func TestCuidados(t *testing.T) {
+    m := models.Mascota{Nombre: "Firulais", FechaNacimiento: time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC), FechaNacimientoPrecision: models.PrecisionMes}
+    if got := Cuidados().Check(CuidadoCheck{Mascota: m, Dia: time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)}); len(got) != 0 {
+        t.Errorf("cuidado on the day of birth: Check = %+v", got)
+    }
+    got := Cuidados().Check(CuidadoCheck{Mascota: m, Dia: time.Date(2030, 2, 28, 0, 0, 0, 0, time.UTC)})
+    if len(got) != 1 || got[0].Code != CodeCuidadoAntesNacer || got[0].Field != "fecha_cuidado" {
+        t.Fatalf("cuidado before birth: Check = %+v", got)
+    }
+    if want := "es anterior al nacimiento de Firulais (2030-03)"; got[0].Message != want {
+        t.Errorf("Message = %q, want %q", got[0].Message, want)
+    }
+}
+
+func TestSetIsPluggable(t *testing.T) {
+    sinNombre := func(c MascotaCheck) *Violation {
+        if c.Mascota.Nombre == "" {
+            return &Violation{Field: "nombre", Code: "nombre_required"}
+        }
+        return nil
+    }
+    set := append(Mascotas(), sinNombre)
+    hoy := time.Date(2030, 6, 5, 0, 0, 0, 0, time.UTC)
+    got := set.Check(MascotaCheck{Mascota: models.Mascota{Especie: "Perro", FechaNacimiento: hoy.AddDate(0, 0, 1)}, Hoy: hoy})
+    if len(got) != 2 || got[0].Code != CodeNacimientoFuturo || got[1].Code != "nombre_required" {
+        t.Errorf("Check = %+v, want the default violation then the added one", got)
+    }
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+)
+
+// Local stores objects as files under Dir, one file per key.
+type Local struct {
+    Dir string
+}
+
+var _ Store = Local{}
+
+func (l Local) path(key string) (string, error) {
+    if err := checkKey(key); err != nil {
+        return "", err
+    }
+    return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file in the target directory and renames it
+// into place, so a reader never sees a partial object.
+func (l Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+    path, err := l.path(key)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+        return err
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+    n, err := io.Copy(tmp, r)
+    if err == nil && n != size {
+        err = fmt.Errorf("storage: wrote %d bytes of %d", n, size)
+    }
+    if err == nil {
+        err = tmp.Sync()
+    }
+    if cerr := tmp.Close(); err == nil {
+        err = cerr
+    }
+    if err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+func (l Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+    path, err := l.path(key)
+    if err != nil {
+        return nil, err
+    }
+    f, err := os.Open(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, ErrNotFound
+    }
+    return f, err
+}
+
+func (l Local) Delete(ctx context.Context, key string) error {
+    path, err := l.path(key)
+    if err != nil {
+        return err
+    }
+    if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    return nil
+}
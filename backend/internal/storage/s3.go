@@ -0,0 +1,212 @@
+package storage
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+    "time"
+)
+
+// unsignedPayload lets uploads stream without hashing the body first;
+// the transport (TLS in production) protects it instead.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3 stores objects in a bucket of an S3-compatible service, signing
+// every request with AWS Signature Version 4.
+type S3 struct {
+    // Endpoint is the service URL, e.g. https://s3.us-east-1.amazonaws.com
+    // or http://minio:9000.
+    Endpoint  string
+    Region    string
+    Bucket    string
+    AccessKey string
+    SecretKey string
+    // PathStyle addresses the bucket as Endpoint/Bucket/key instead of
+    // Bucket.host/key; MinIO and most self-hosted services need it.
+    PathStyle bool
+    Client    *http.Client
+    // Now is the clock used for signatures; nil means time.Now.
+    Now func() time.Time
+}
+
+var _ Store = (*S3)(nil)
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+    if err := checkKey(key); err != nil {
+        return nil, err
+    }
+    u, err := url.Parse(s.Endpoint)
+    if err != nil {
+        return nil, fmt.Errorf("storage: endpoint: %w", err)
+    }
+    if s.PathStyle {
+        u.Path = strings.TrimRight(u.Path, "/") + "/" + s.Bucket + "/" + key
+    } else {
+        u.Host = s.Bucket + "." + u.Host
+        u.Path = strings.TrimRight(u.Path, "/") + "/" + key
+    }
+    return u, nil
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+    u, err := s.objectURL(key)
+    if err != nil {
+        return nil, err
+    }
+    req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+    if err != nil {
+        return nil, err
+    }
+    if body != nil {
+        req.ContentLength = size
+        req.Header.Set("Content-Type", contentType)
+    }
+    now := time.Now
+    if s.Now != nil {
+        now = s.Now
+    }
+    s.sign(req, unsignedPayload, now())
+    client := s.Client
+    if client == nil {
+        client = http.DefaultClient
+    }
+    return client.Do(req)
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+    res, err := s.do(ctx, http.MethodPut, key, r, size, contentType)
+    if err != nil {
+        return err
+    }
+    defer res.Body.Close()
+    if res.StatusCode != http.StatusOK {
+        return s3Error(res)
+    }
+    return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+    res, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+    if err != nil {
+        return nil, err
+    }
+    switch res.StatusCode {
+    case http.StatusOK:
+        return res.Body, nil
+    case http.StatusNotFound:
+        res.Body.Close()
+        return nil, ErrNotFound
+    }
+    defer res.Body.Close()
+    return nil, s3Error(res)
+}
+
+// Delete succeeds for a missing key: S3 answers 204 either way.
+func (s *S3) Delete(ctx context.Context, key string) error {
+    res, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+    if err != nil {
+        return err
+    }
+    defer res.Body.Close()
+    if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+        return s3Error(res)
+    }
+    return nil
+}
+
+// s3Error reports an unexpected response with the start of its XML body,
+// which carries the service's error code.
+func s3Error(res *http.Response) error {
+    body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+    return fmt.Errorf("storage: %s %s: %s: %s", res.Request.Method, res.Request.URL.Path, res.Status, strings.TrimSpace(string(body)))
+}
+
+// sign adds the x-amz-date, x-amz-content-sha256 and Authorization
+// headers of Signature Version 4. Besides host, the Content-Type, Range
+// and x-amz-* headers are signed.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+    now = now.UTC()
+    amzDate := now.Format("20060102T150405Z")
+    day := now.Format("20060102")
+    req.Header.Set("X-Amz-Date", amzDate)
+    req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+    headers := map[string]string{"host": req.URL.Host}
+    for name, values := range req.Header {
+        name = strings.ToLower(name)
+        if name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+            headers[name] = strings.TrimSpace(strings.Join(values, ","))
+        }
+    }
+    names := make([]string, 0, len(headers))
+    for name := range headers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    var canonicalHeaders strings.Builder
+    for _, name := range names {
+        canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+    }
+    signedHeaders := strings.Join(names, ";")
+
+    canonicalRequest := strings.Join([]string{
+        req.Method,
+        req.URL.EscapedPath(),
+        canonicalQuery(req.URL.Query()),
+        canonicalHeaders.String(),
+        signedHeaders,
+        payloadHash,
+    }, "\n")
+    scope := day + "/" + s.Region + "/s3/aws4_request"
+    stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+    key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+    key = hmacSHA256(key, s.Region)
+    key = hmacSHA256(key, "s3")
+    key = hmacSHA256(key, "aws4_request")
+    signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+    req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+        ", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func canonicalQuery(q url.Values) string {
+    keys := make([]string, 0, len(q))
+    for k := range q {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    var parts []string
+    for _, k := range keys {
+        vs := append([]string(nil), q[k]...)
+        sort.Strings(vs)
+        for _, v := range vs {
+            parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+        }
+    }
+    return strings.Join(parts, "&")
+}
+
+// awsEscape is the URI encoding of Signature Version 4: everything but
+// unreserved characters is percent-encoded, spaces as %20.
+func awsEscape(s string) string {
+    return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(data))
+    return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+    sum := sha256.Sum256([]byte(data))
+    return hex.EncodeToString(sum[:])
+}
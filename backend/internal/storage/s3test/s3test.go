@@ -0,0 +1,101 @@
+// Package s3test runs an in-process stand-in for an S3-compatible service
+// (path-style PUT, GET and DELETE of objects), like a throwaway MinIO for
+// tests.
+package s3test
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+)
+
+const (
+    AccessKey = "test-access-key"
+    SecretKey = "test-secret-key-0000000000"
+    Region    = "us-east-1"
+)
+
+type object struct {
+    data        []byte
+    contentType string
+}
+
+// Server holds the objects of every bucket in memory. Requests must carry
+// a Signature Version 4 Authorization for AccessKey in Region; the
+// signature itself is not recomputed.
+type Server struct {
+    URL string
+
+    mu      sync.Mutex
+    objects map[string]object
+}
+
+// NewServer starts a server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+    s := &Server{objects: make(map[string]object)}
+    srv := httptest.NewServer(s)
+    t.Cleanup(srv.Close)
+    s.URL = srv.URL
+    return s
+}
+
+// Keys returns the stored "bucket/key" names.
+func (s *Server) Keys() []string {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    keys := make([]string, 0, len(s.objects))
+    for k := range s.objects {
+        keys = append(keys, k)
+    }
+    return keys
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    auth := r.Header.Get("Authorization")
+    if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+AccessKey+"/") ||
+        !strings.Contains(auth, "/"+Region+"/s3/aws4_request") ||
+        !strings.Contains(auth, "Signature=") || r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") == "" {
+        s.fail(w, http.StatusForbidden, "AccessDenied")
+        return
+    }
+    name := strings.TrimPrefix(r.URL.Path, "/")
+    if bucket, key, ok := strings.Cut(name, "/"); !ok || bucket == "" || key == "" {
+        s.fail(w, http.StatusBadRequest, "InvalidRequest")
+        return
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    switch r.Method {
+    case http.MethodPut:
+        data, err := io.ReadAll(r.Body)
+        if err != nil || int64(len(data)) != r.ContentLength {
+            s.fail(w, http.StatusBadRequest, "IncompleteBody")
+            return
+        }
+        s.objects[name] = object{data: data, contentType: r.Header.Get("Content-Type")}
+        w.WriteHeader(http.StatusOK)
+    case http.MethodGet:
+        o, ok := s.objects[name]
+        if !ok {
+            s.fail(w, http.StatusNotFound, "NoSuchKey")
+            return
+        }
+        w.Header().Set("Content-Type", o.contentType)
+        w.Write(o.data)
+    case http.MethodDelete:
+        delete(s.objects, name)
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        s.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+    }
+}
+
+func (s *Server) fail(w http.ResponseWriter, status int, code string) {
+    w.Header().Set("Content-Type", "application/xml")
+    w.WriteHeader(status)
+    fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code></Error>`, code)
+}
@@ -0,0 +1,43 @@
+// Package storage keeps uploaded files in a blob store: a local directory
+// or an S3-compatible bucket (AWS S3, MinIO, ...). Objects are addressed by
+// keys such as "adjuntos/2030/06/4f1c...": slash-separated segments of
+// letters, digits, '-', '_' and '.'.
+package storage
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// ErrNotFound is returned by Open for a key with no object.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Store is a blob store. Put overwrites an existing object and Delete of a
+// missing key succeeds, so both can be retried.
+type Store interface {
+    Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+    Open(ctx context.Context, key string) (io.ReadCloser, error)
+    Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that could escape the store's root or need
+// escaping: empty segments, "." and "..", and unusual characters.
+func checkKey(key string) error {
+    if key == "" {
+        return fmt.Errorf("storage: empty key")
+    }
+    for _, seg := range strings.Split(key, "/") {
+        if seg == "" || seg == "." || seg == ".." {
+            return fmt.Errorf("storage: invalid key %q", key)
+        }
+        for _, r := range seg {
+            if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+                return fmt.Errorf("storage: invalid key %q", key)
+            }
+        }
+    }
+    return nil
+}
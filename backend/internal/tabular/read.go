@@ -0,0 +1,268 @@
+// Package tabular reads and writes the spreadsheet formats used to move
+// data in and out of the clinic: CSV and XLSX (Office Open XML).
+package tabular
+
+import (
+    "archive/zip"
+    "bufio"
+    "bytes"
+    "encoding/csv"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "path"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ErrFormat reports a file that is not a well-formed CSV or XLSX.
+var ErrFormat = errors.New("tabular: invalid file")
+
+// maxPart bounds the uncompressed size of every XLSX part read, so a
+// small zip cannot expand into gigabytes.
+const maxPart = 64 << 20
+
+// IsXLSX reports whether data starts like a zip archive, which is how an
+// XLSX file is told apart from CSV.
+func IsXLSX(data []byte) bool {
+    return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// Read parses data as XLSX or CSV, see ReadXLSX and ReadCSV.
+func Read(data []byte) ([][]string, error) {
+    if IsXLSX(data) {
+        return ReadXLSX(bytes.NewReader(data), int64(len(data)))
+    }
+    return ReadCSV(bytes.NewReader(data))
+}
+
+// ReadCSV returns the records of r. The delimiter is ',' or ';' (what
+// spreadsheets use in locales with a decimal comma), whichever appears
+// more often in the first line; a UTF-8 BOM is ignored, and so is the
+// apostrophe the writers put before text that looks like a formula. Row i
+// of the result is record i+1 of the file.
+func ReadCSV(r io.Reader) ([][]string, error) {
+    br := bufio.NewReader(r)
+    if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+        br.Discard(3)
+    }
+    first, _ := br.Peek(4096)
+    if i := bytes.IndexByte(first, '\n'); i >= 0 {
+        first = first[:i]
+    }
+    cr := csv.NewReader(br)
+    if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+        cr.Comma = ';'
+    }
+    cr.FieldsPerRecord = -1
+    cr.TrimLeadingSpace = true
+    rows, err := cr.ReadAll()
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+    }
+    for _, row := range rows {
+        for i, v := range row {
+            row[i] = unescapeCell(v)
+        }
+    }
+    return rows, nil
+}
+
+// ReadXLSX returns the cells of the first worksheet as text. Row i of the
+// result is spreadsheet row i+1, so missing rows come back empty. Text
+// loses the apostrophe of escapeCell, as in ReadCSV. Numbers
+// (including dates, which XLSX stores as serial day numbers) are returned
+// as written in the file; see ExcelDate.
+func ReadXLSX(r io.ReaderAt, size int64) ([][]string, error) {
+    zr, err := zip.NewReader(r, size)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+    }
+    files := make(map[string]*zip.File, len(zr.File))
+    for _, f := range zr.File {
+        files[f.Name] = f
+    }
+
+    sheet, err := firstSheet(files)
+    if err != nil {
+        return nil, err
+    }
+    var shared []string
+    if f, ok := files["xl/sharedStrings.xml"]; ok {
+        var sst struct {
+            Items []xlsxText `xml:"si"`
+        }
+        if err := decodePart(f, &sst); err != nil {
+            return nil, err
+        }
+        shared = make([]string, len(sst.Items))
+        for i, si := range sst.Items {
+            shared[i] = si.String()
+        }
+    }
+
+    f, ok := files[sheet]
+    if !ok {
+        return nil, fmt.Errorf("%w: missing %s", ErrFormat, sheet)
+    }
+    var ws struct {
+        Rows []struct {
+            R     int `xml:"r,attr"`
+            Cells []struct {
+                R      string   `xml:"r,attr"`
+                T      string   `xml:"t,attr"`
+                V      string   `xml:"v"`
+                Inline xlsxText `xml:"is"`
+            } `xml:"c"`
+        } `xml:"sheetData>row"`
+    }
+    if err := decodePart(f, &ws); err != nil {
+        return nil, err
+    }
+
+    rows := make([][]string, 0, len(ws.Rows))
+    for _, row := range ws.Rows {
+        n := row.R
+        if n == 0 {
+            n = len(rows) + 1
+        }
+        if n < len(rows)+1 || n > 1<<20 {
+            return nil, fmt.Errorf("%w: row %d out of order", ErrFormat, n)
+        }
+        for len(rows) < n {
+            rows = append(rows, nil)
+        }
+        var cells []string
+        for i, c := range row.Cells {
+            col := i
+            if c.R != "" {
+                if col, err = columnIndex(c.R); err != nil {
+                    return nil, err
+                }
+            }
+            var v string
+            switch c.T {
+            case "s":
+                idx, err := strconv.Atoi(c.V)
+                if err != nil || idx < 0 || idx >= len(shared) {
+                    return nil, fmt.Errorf("%w: bad shared string in %s", ErrFormat, c.R)
+                }
+                v = unescapeCell(shared[idx])
+            case "inlineStr":
+                v = unescapeCell(c.Inline.String())
+            case "b":
+                v = map[string]string{"1": "TRUE", "0": "FALSE"}[c.V]
+            default: // n, str, e
+                v = c.V
+            }
+            for len(cells) <= col {
+                cells = append(cells, "")
+            }
+            cells[col] = v
+        }
+        rows[n-1] = cells
+    }
+    return rows, nil
+}
+
+// xlsxText is a shared or inline string: plain <t> or rich-text runs.
+type xlsxText struct {
+    T    string `xml:"t"`
+    Runs []struct {
+        T string `xml:"t"`
+    } `xml:"r"`
+}
+
+func (x xlsxText) String() string {
+    if len(x.Runs) == 0 {
+        return x.T
+    }
+    var b strings.Builder
+    for _, r := range x.Runs {
+        b.WriteString(r.T)
+    }
+    return b.String()
+}
+
+// firstSheet resolves the part name of the workbook's first worksheet.
+func firstSheet(files map[string]*zip.File) (string, error) {
+    wbFile, ok := files["xl/workbook.xml"]
+    if !ok {
+        return "", fmt.Errorf("%w: missing xl/workbook.xml", ErrFormat)
+    }
+    var wb struct {
+        Sheets []struct {
+            RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+        } `xml:"sheets>sheet"`
+    }
+    if err := decodePart(wbFile, &wb); err != nil {
+        return "", err
+    }
+    if len(wb.Sheets) == 0 {
+        return "", fmt.Errorf("%w: workbook has no sheets", ErrFormat)
+    }
+    relsFile, ok := files["xl/_rels/workbook.xml.rels"]
+    if !ok {
+        return "xl/worksheets/sheet1.xml", nil
+    }
+    var rels struct {
+        Items []struct {
+            ID     string `xml:"Id,attr"`
+            Target string `xml:"Target,attr"`
+        } `xml:"Relationship"`
+    }
+    if err := decodePart(relsFile, &rels); err != nil {
+        return "", err
+    }
+    for _, rel := range rels.Items {
+        if rel.ID == wb.Sheets[0].RID {
+            if strings.HasPrefix(rel.Target, "/") {
+                return strings.TrimPrefix(rel.Target, "/"), nil
+            }
+            return path.Join("xl", rel.Target), nil
+        }
+    }
+    return "", fmt.Errorf("%w: sheet relationship %q not found", ErrFormat, wb.Sheets[0].RID)
+}
+
+func decodePart(f *zip.File, v any) error {
+    rc, err := f.Open()
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrFormat, err)
+    }
+    defer rc.Close()
+    if err := xml.NewDecoder(io.LimitReader(rc, maxPart)).Decode(v); err != nil {
+        return fmt.Errorf("%w: %s: %v", ErrFormat, f.Name, err)
+    }
+    return nil
+}
+
+// columnIndex turns a cell reference such as "AB12" into its zero-based
+// column, 27.
+func columnIndex(ref string) (int, error) {
+    col := 0
+    i := 0
+    for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z' && col <= 16384; i++ {
+        col = col*26 + int(ref[i]-'A'+1)
+    }
+    if i == 0 || col > 16384 {
+        return 0, fmt.Errorf("%w: bad cell reference %q", ErrFormat, ref)
+    }
+    return col - 1, nil
+}
+
+// excelEpoch is day 0 of the 1900 date system. It sits on 30 December
+// because Excel counts the non-existent 29 February 1900.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// ExcelDate converts a serial day number, as stored in XLSX date cells,
+// to the UTC date it represents; the time of day is dropped.
+func ExcelDate(serial string) (time.Time, bool) {
+    f, err := strconv.ParseFloat(serial, 64)
+    if err != nil || f < 1 || f > 2958465 {
+        return time.Time{}, false
+    }
+    return excelEpoch.AddDate(0, 0, int(f)), true
+}
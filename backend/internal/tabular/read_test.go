@@ -0,0 +1,106 @@
+package tabular
+
+import (
+    "archive/zip"
+    "bytes"
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestReadCSV(t *testing.T) {
+    for name, in := range map[string]string{
+        "comma":     "nombre,especie\nFirulais,Perro\n\"Misu, la gata\",Gato\n",
+        "semicolon": "\xef\xbb\xbfnombre;especie\r\nFirulais;Perro\r\nMisu, la gata;Gato\r\n",
+    } {
+        t.Run(name, func(t *testing.T) {
+            rows, err := ReadCSV(strings.NewReader(in))
+            if err != nil {
+                t.Fatal(err)
+            }
+            want := [][]string{{"nombre", "especie"}, {"Firulais", "Perro"}, {"Misu, la gata", "Gato"}}
+            if !reflect.DeepEqual(rows, want) {
+                t.Fatalf("rows = %q, want %q", rows, want)
+            }
+        })
+    }
+}
+
+func TestReadCSVInvalid(t *testing.T) {
+    _, err := ReadCSV(strings.NewReader("a,b\n\"sin cerrar,c\n"))
+    if !errors.Is(err, ErrFormat) {
+        t.Fatalf("err = %v, want ErrFormat", err)
+    }
+}
+
+// buildXLSX zips the minimal parts of a workbook whose first sheet is
+// stored at a non-default path, to exercise relationship resolution.
+func buildXLSX(t *testing.T, sheet, shared string) []byte {
+    t.Helper()
+    var buf bytes.Buffer
+    zw := zip.NewWriter(&buf)
+    parts := map[string]string{
+        "xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+            `<sheets><sheet name="Mascotas" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+        "xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+            `<Relationship Id="rId7" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/datos.xml"/></Relationships>`,
+        "xl/worksheets/datos.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheet + `</sheetData></worksheet>`,
+        "xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + shared + `</sst>`,
+    }
+    for name, body := range parts {
+        w, err := zw.Create(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        w.Write([]byte(body))
+    }
+    if err := zw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+    data := buildXLSX(t,
+        `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c></row>`+
+            `<row r="3"><c r="A3" t="inlineStr"><is><t>Firulais</t></is></c><c r="C3"><v>43534</v></c></row>`+
+            `<row r="4"><c r="B4" t="s"><v>3</v></c><c r="C4" t="b"><v>1</v></c></row>`,
+        `<si><t>nombre</t></si><si><t>especie</t></si><si><t>fecha_nacimiento</t></si>`+
+            `<si><r><t>Ga</t></r><r><t>to</t></r></si>`,
+    )
+    if !IsXLSX(data) {
+        t.Fatal("IsXLSX = false for a zip")
+    }
+    rows, err := Read(data)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := [][]string{
+        {"nombre", "especie", "fecha_nacimiento"},
+        nil,
+        {"Firulais", "", "43534"},
+        {"", "Gato", "TRUE"},
+    }
+    if !reflect.DeepEqual(rows, want) {
+        t.Fatalf("rows = %q, want %q", rows, want)
+    }
+}
+
+func TestReadXLSXInvalid(t *testing.T) {
+    data := buildXLSX(t, `<row r="1"><c r="A1" t="s"><v>9</v></c></row>`, ``)
+    if _, err := Read(data); !errors.Is(err, ErrFormat) {
+        t.Fatalf("err = %v, want ErrFormat", err)
+    }
+}
+
+func TestExcelDate(t *testing.T) {
+    got, ok := ExcelDate("43534")
+    if want := time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+        t.Fatalf("ExcelDate = %v %v, want %v", got, ok, want)
+    }
+    if _, ok := ExcelDate("2019-03-10"); ok {
+        t.Fatal("ExcelDate accepted a non-number")
+    }
+}
@@ -0,0 +1,177 @@
+package tabular
+
+import (
+    "archive/zip"
+    "bufio"
+    "encoding/csv"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+)
+
+// Writer writes a table one row at a time. Integers and floats become
+// numeric cells in XLSX; any other value is written with fmt.Sprint, as
+// text a spreadsheet will not run as a formula (see escapeCell).
+type Writer interface {
+    WriteRow(values ...any) error
+    // Close completes the file; the output is not valid until then.
+    Close() error
+}
+
+type csvWriter struct {
+    w   *csv.Writer
+    row []string
+}
+
+// NewCSVWriter writes comma-separated values preceded by a UTF-8 BOM,
+// which spreadsheets need to detect the encoding.
+func NewCSVWriter(w io.Writer) (Writer, error) {
+    if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+        return nil, err
+    }
+    return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) WriteRow(values ...any) error {
+    c.row = c.row[:0]
+    for _, v := range values {
+        if isNumber(v) {
+            c.row = append(c.row, fmt.Sprint(v))
+        } else {
+            c.row = append(c.row, escapeCell(fmt.Sprint(v)))
+        }
+    }
+    return c.w.Write(c.row)
+}
+
+func isNumber(v any) bool {
+    switch v.(type) {
+    case int, int32, int64, uint, uint32, uint64, float32, float64:
+        return true
+    }
+    return false
+}
+
+// escapeCell keeps a spreadsheet from evaluating text written by the
+// clients, such as a nombre "=HYPERLINK(...)": text that starts like a
+// formula gets a leading apostrophe, which spreadsheets take as "this is
+// text" and Read removes again. Text that already starts with apostrophes
+// before such a character gets one more, so it reads back unchanged.
+func escapeCell(s string) string {
+    if startsFormula(strings.TrimLeft(s, "'")) {
+        return "'" + s
+    }
+    return s
+}
+
+// unescapeCell undoes escapeCell.
+func unescapeCell(s string) string {
+    if strings.HasPrefix(s, "'") && startsFormula(strings.TrimLeft(s, "'")) {
+        return s[1:]
+    }
+    return s
+}
+
+func startsFormula(s string) bool {
+    return s != "" && strings.IndexByte("=+-@\t\r", s[0]) >= 0
+}
+
+func (c *csvWriter) Close() error {
+    c.w.Flush()
+    return c.w.Error()
+}
+
+// The package parts written before the worksheet. Cells use inline
+// strings, so no shared string table (which would need every value up
+// front) is required.
+var xlsxParts = []struct{ name, body string }{
+    {"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+        `<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+        `<Default Extension="xml" ContentType="application/xml"/>` +
+        `<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+        `<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+        `</Types>`},
+    {"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+        `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+        `</Relationships>`},
+    {"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+        `<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+        `</Relationships>`},
+}
+
+type xlsxWriter struct {
+    zw  *zip.Writer
+    w   *bufio.Writer
+    row int
+}
+
+// NewXLSXWriter writes a workbook with a single worksheet named sheet,
+// streaming the rows straight into the zip archive.
+func NewXLSXWriter(w io.Writer, sheet string) (Writer, error) {
+    zw := zip.NewWriter(w)
+    for _, p := range xlsxParts {
+        f, err := zw.Create(p.name)
+        if err != nil {
+            return nil, err
+        }
+        if _, err := io.WriteString(f, p.body); err != nil {
+            return nil, err
+        }
+    }
+    f, err := zw.Create("xl/workbook.xml")
+    if err != nil {
+        return nil, err
+    }
+    io.WriteString(f, xml.Header+`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" `+
+        `xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`)
+    xml.EscapeText(f, []byte(sheet))
+    if _, err := io.WriteString(f, `" sheetId="1" r:id="rId1"/></sheets></workbook>`); err != nil {
+        return nil, err
+    }
+
+    f, err = zw.Create("xl/worksheets/sheet1.xml")
+    if err != nil {
+        return nil, err
+    }
+    x := &xlsxWriter{zw: zw, w: bufio.NewWriter(f)}
+    x.w.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+    return x, nil
+}
+
+func (x *xlsxWriter) WriteRow(values ...any) error {
+    x.row++
+    fmt.Fprintf(x.w, `<row r="%d">`, x.row)
+    for i, v := range values {
+        ref := columnName(i) + strconv.Itoa(x.row)
+        if isNumber(v) {
+            fmt.Fprintf(x.w, `<c r="%s"><v>%v</v></c>`, ref, v)
+            continue
+        }
+        fmt.Fprintf(x.w, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+        if err := xml.EscapeText(x.w, []byte(escapeCell(fmt.Sprint(v)))); err != nil {
+            return err
+        }
+        x.w.WriteString(`</t></is></c>`)
+    }
+    _, err := x.w.WriteString(`</row>`)
+    return err
+}
+
+func (x *xlsxWriter) Close() error {
+    x.w.WriteString(`</sheetData></worksheet>`)
+    if err := x.w.Flush(); err != nil {
+        return err
+    }
+    return x.zw.Close()
+}
+
+// columnName is the inverse of columnIndex: 0 is "A", 27 is "AB".
+func columnName(i int) string {
+    name := ""
+    for i++; i > 0; i = (i - 1) / 26 {
+        name = string(rune('A'+(i-1)%26)) + name
+    }
+    return name
+}
@@ -0,0 +1,114 @@
+package tabular
+
+import (
+    "archive/zip"
+    "bytes"
+    "io"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+var sample = [][]any{
+    {"id", "nombre", "descripcion"},
+    {int64(1), "Firulais", `Baño "medicado", <urgente> & más`},
+    {int64(2), "Misu", "línea 1\nlínea 2"},
+    {int64(-3), "=HYPERLINK(\"http://x.example\",\"ver\")", "+57 300 1234567"},
+    {int64(4), "@SUM(A1)", "'-ya con apóstrofo"},
+}
+
+var sampleText = [][]string{
+    {"id", "nombre", "descripcion"},
+    {"1", "Firulais", `Baño "medicado", <urgente> & más`},
+    {"2", "Misu", "línea 1\nlínea 2"},
+    {"-3", "=HYPERLINK(\"http://x.example\",\"ver\")", "+57 300 1234567"},
+    {"4", "@SUM(A1)", "'-ya con apóstrofo"},
+}
+
+func writeAll(t *testing.T, w Writer, err error) {
+    t.Helper()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, row := range sample {
+        if err := w.WriteRow(row...); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := w.Close(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+    var buf bytes.Buffer
+    w, err := NewCSVWriter(&buf)
+    writeAll(t, w, err)
+    if !strings.HasPrefix(buf.String(), "\xef\xbb\xbfid,nombre") {
+        t.Fatalf("output does not start with BOM and header: %q", buf.String())
+    }
+    if !strings.Contains(buf.String(), "\n-3,\"'=HYPERLINK(") || !strings.Contains(buf.String(), ",'+57 300") ||
+        !strings.Contains(buf.String(), ",''-ya") {
+        t.Fatalf("formulas are not escaped: %q", buf.String())
+    }
+    rows, err := Read(buf.Bytes())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(rows, sampleText) {
+        t.Fatalf("rows = %q, want %q", rows, sampleText)
+    }
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+    var buf bytes.Buffer
+    w, err := NewXLSXWriter(&buf, "Mascotas & cuidados")
+    writeAll(t, w, err)
+    if !IsXLSX(buf.Bytes()) {
+        t.Fatal("output is not a zip archive")
+    }
+    rows, err := Read(buf.Bytes())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(rows, sampleText) {
+        t.Fatalf("rows = %q, want %q", rows, sampleText)
+    }
+    if !bytes.Contains(buf.Bytes(), []byte("sheet1.xml")) {
+        t.Fatal("missing worksheet part")
+    }
+    sheet := readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+    if !strings.Contains(sheet, "<v>-3</v>") || !strings.Contains(sheet, ">&#39;=HYPERLINK(") || !strings.Contains(sheet, ">&#39;@SUM(A1)<") {
+        t.Fatalf("formulas are not escaped: %s", sheet)
+    }
+}
+
+// readPart returns the uncompressed content of a part of an XLSX file.
+func readPart(t *testing.T, data []byte, name string) string {
+    t.Helper()
+    zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+        t.Fatal(err)
+    }
+    f, err := zr.Open(name)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    b, err := io.ReadAll(f)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(b)
+}
+
+func TestColumnName(t *testing.T) {
+    for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+        if got := columnName(i); got != want {
+            t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+        }
+        if back, err := columnIndex(want + "1"); err != nil || back != i {
+            t.Errorf("columnIndex(%q) = %d, %v; want %d", want, back, err, i)
+        }
+    }
+}
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "mascotas/internal/clock"
+    "mascotas/internal/models"
+    "mascotas/internal/outbox"
+)
+
+// Queue is the part of models.WebhookStore the dispatcher uses.
+type Queue interface {
+    ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error)
+    MarkDelivered(ctx context.Context, id int64, a models.WebhookIntento) error
+    MarkRetry(ctx context.Context, id int64, a models.WebhookIntento, next time.Time) error
+    MarkFailed(ctx context.Context, id int64, a models.WebhookIntento) error
+}
+
+// Envelope is the JSON body POSTed to subscribers.
+type Envelope struct {
+    ID       int64           `json:"id"`
+    Evento   string          `json:"evento"`
+    CreadoEn time.Time       `json:"creado_en"`
+    Data     json.RawMessage `json:"data"`
+}
+
+// Dispatcher periodically POSTs queued entregas, retrying failures with
+// exponential backoff until MaxAttempts is reached (see outbox.Worker).
+// Any 2xx response counts as delivered.
+type Dispatcher struct {
+    Queue        Queue
+    Client       *http.Client
+    Clock        clock.Clock
+    PollInterval time.Duration
+    BatchSize    int
+    MaxAttempts  int
+    RetryBackoff time.Duration // delay after the first failure, doubled each time
+    MaxBackoff   time.Duration
+    Timeout      time.Duration
+}
+
+// Run dispatches until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+    d.worker().Run(ctx)
+}
+
+// RunOnce processes one batch of due entregas and returns how many it
+// handled.
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+    return d.worker().RunOnce(ctx)
+}
+
+func (d *Dispatcher) worker() *outbox.Worker[models.WebhookDelivery] {
+    return &outbox.Worker[models.WebhookDelivery]{
+        Name:         "webhook",
+        Clock:        d.Clock,
+        PollInterval: d.PollInterval,
+        BatchSize:    d.BatchSize,
+        MaxAttempts:  d.MaxAttempts,
+        RetryBackoff: d.RetryBackoff,
+        MaxBackoff:   d.MaxBackoff,
+        Timeout:      d.Timeout,
+        Claim:        d.Queue.ClaimDue,
+        Attempts:     func(e models.WebhookDelivery) int { return e.Intentos },
+        Deliver:      d.deliver,
+        Fail:         d.fail,
+    }
+}
+
+// failedIntento carries the record of a failed attempt to fail.
+type failedIntento struct{ a models.WebhookIntento }
+
+func (f failedIntento) Error() string { return f.a.Error }
+
+func (d *Dispatcher) deliver(ctx context.Context, e models.WebhookDelivery, attempt int, now time.Time) error {
+    a := models.WebhookIntento{Intento: attempt}
+    began := time.Now()
+    status, err := d.post(ctx, e, d.Clock.Now())
+    a.StatusCode = status
+    a.DuracionMs = time.Since(began).Milliseconds()
+    a.CreadoEn = d.Clock.Now()
+    if ctx.Err() != nil {
+        return ctx.Err()
+    }
+    if err != nil {
+        a.Error = err.Error()
+        return outbox.Failed(failedIntento{a})
+    }
+    return d.Queue.MarkDelivered(ctx, e.ID, a)
+}
+
+func (d *Dispatcher) fail(ctx context.Context, e models.WebhookDelivery, attempt int, err error, retry time.Time) error {
+    var f failedIntento
+    if !errors.As(err, &f) {
+        return err
+    }
+    log.Printf("webhook: entrega %d to %s attempt %d failed: %s", e.ID, e.URL, attempt, f.a.Error)
+    if retry.IsZero() {
+        return d.Queue.MarkFailed(ctx, e.ID, f.a)
+    }
+    return d.Queue.MarkRetry(ctx, e.ID, f.a, retry)
+}
+
+// post sends one attempt. A non-2xx status is reported as an error along
+// with the status code.
+func (d *Dispatcher) post(ctx context.Context, e models.WebhookDelivery, now time.Time) (int, error) {
+    body, err := json.Marshal(Envelope{ID: e.EventoID, Evento: e.Evento, CreadoEn: e.CreadoEn, Data: e.Payload})
+    if err != nil {
+        return 0, err
+    }
+    ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+    defer cancel()
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+    if err != nil {
+        return 0, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "mascotas-webhooks/1")
+    req.Header.Set(HeaderEvent, e.Evento)
+    req.Header.Set(HeaderDelivery, strconv.FormatInt(e.ID, 10))
+    req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+    req.Header.Set(HeaderSignature, Sign(e.Secreto, now, body))
+
+    client := d.Client
+    if client == nil {
+        client = http.DefaultClient
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, err
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return resp.StatusCode, fmt.Errorf("respuesta HTTP %d", resp.StatusCode)
+    }
+    return resp.StatusCode, nil
+}
@@ -0,0 +1,170 @@
+package webhook
+
+import (
+    "context"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "mascotas/internal/clock"
+    "mascotas/internal/models"
+)
+
+type outcome struct {
+    estado string
+    a      models.WebhookIntento
+    next   time.Time
+}
+
+// fakeQueue hands out its entregas once and records every outcome.
+type fakeQueue struct {
+    due      []models.WebhookDelivery
+    outcomes map[int64]outcome
+}
+
+func (q *fakeQueue) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+    due := q.due
+    q.due = nil
+    return due, nil
+}
+
+func (q *fakeQueue) MarkDelivered(ctx context.Context, id int64, a models.WebhookIntento) error {
+    q.outcomes[id] = outcome{estado: models.EntregaEntregada, a: a}
+    return nil
+}
+
+func (q *fakeQueue) MarkRetry(ctx context.Context, id int64, a models.WebhookIntento, next time.Time) error {
+    q.outcomes[id] = outcome{estado: models.EntregaPendiente, a: a, next: next}
+    return nil
+}
+
+func (q *fakeQueue) MarkFailed(ctx context.Context, id int64, a models.WebhookIntento) error {
+    q.outcomes[id] = outcome{estado: models.EntregaFallida, a: a}
+    return nil
+}
+
+var now = time.Date(2030, 6, 5, 10, 0, 0, 0, time.UTC)
+
+func newDispatcher(q Queue) *Dispatcher {
+    return &Dispatcher{
+        Queue:        q,
+        Clock:        clock.Fixed(now),
+        BatchSize:    10,
+        MaxAttempts:  3,
+        RetryBackoff: 30 * time.Second,
+        MaxBackoff:   time.Hour,
+        Timeout:      5 * time.Second,
+    }
+}
+
+func delivery(id int64, url string, intentos int) models.WebhookDelivery {
+    return models.WebhookDelivery{
+        ID: id, Intentos: intentos, WebhookID: 1, URL: url, Secreto: "s3cret",
+        EventoID: 42, Evento: models.EventMascotaCreated,
+        Payload:  json.RawMessage(`{"id":7,"nombre":"Firulais"}`),
+        CreadoEn: now.Add(-time.Minute),
+    }
+}
+
+func TestDispatcherSignsAndDelivers(t *testing.T) {
+    var got *http.Request
+    var body []byte
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got = r
+        body, _ = io.ReadAll(r.Body)
+        w.WriteHeader(http.StatusNoContent)
+    }))
+    defer srv.Close()
+
+    q := &fakeQueue{due: []models.WebhookDelivery{delivery(5, srv.URL, 0)}, outcomes: map[int64]outcome{}}
+    if _, err := newDispatcher(q).RunOnce(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if o := q.outcomes[5]; o.estado != models.EntregaEntregada || o.a.Intento != 1 || o.a.StatusCode != 204 {
+        t.Fatalf("outcome = %+v", o)
+    }
+    if got.Header.Get(HeaderEvent) != "mascota.created" || got.Header.Get(HeaderDelivery) != "5" {
+        t.Errorf("headers = %v", got.Header)
+    }
+    if !Verify("s3cret", got.Header.Get(HeaderSignature), got.Header.Get(HeaderTimestamp), body, now, 5*time.Minute) {
+        t.Errorf("signature %q does not verify", got.Header.Get(HeaderSignature))
+    }
+    var env Envelope
+    if err := json.Unmarshal(body, &env); err != nil {
+        t.Fatal(err)
+    }
+    if env.ID != 42 || env.Evento != "mascota.created" || string(env.Data) != `{"id":7,"nombre":"Firulais"}` {
+        t.Errorf("envelope = %+v", env)
+    }
+}
+
+func TestDispatcherRetriesThenFails(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.Error(w, "boom", http.StatusBadGateway)
+    }))
+    defer srv.Close()
+    q := &fakeQueue{outcomes: map[int64]outcome{}}
+    d := newDispatcher(q)
+
+    for intentos, wait := range []time.Duration{30 * time.Second, time.Minute} {
+        q.due = []models.WebhookDelivery{delivery(9, srv.URL, intentos)}
+        if _, err := d.RunOnce(context.Background()); err != nil {
+            t.Fatal(err)
+        }
+        o := q.outcomes[9]
+        if o.estado != models.EntregaPendiente || o.a.StatusCode != 502 || !o.next.Equal(now.Add(wait)) {
+            t.Fatalf("attempt %d outcome = %+v, want retry in %s", intentos+1, o, wait)
+        }
+    }
+
+    q.due = []models.WebhookDelivery{delivery(9, srv.URL, 2)}
+    if _, err := d.RunOnce(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if o := q.outcomes[9]; o.estado != models.EntregaFallida || o.a.Intento != 3 {
+        t.Fatalf("final outcome = %+v, want fallida on attempt 3", o)
+    }
+}
+
+func TestDispatcherConnectionError(t *testing.T) {
+    srv := httptest.NewServer(http.NotFoundHandler())
+    url := srv.URL
+    srv.Close()
+
+    q := &fakeQueue{due: []models.WebhookDelivery{delivery(3, url, 0)}, outcomes: map[int64]outcome{}}
+    if _, err := newDispatcher(q).RunOnce(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    if o := q.outcomes[3]; o.estado != models.EntregaPendiente || o.a.StatusCode != 0 || o.a.Error == "" {
+        t.Fatalf("outcome = %+v, want retry without status", o)
+    }
+}
+
+func TestVerify(t *testing.T) {
+    body := []byte(`{"id":1}`)
+    sig := Sign("k", now, body)
+    ts := "1906884000" // now
+    cases := []struct {
+        name              string
+        secret, sig, ts   string
+        body              []byte
+        at                time.Time
+        want              bool
+    }{
+        {"valid", "k", sig, ts, body, now, true},
+        {"wrong secret", "other", sig, ts, body, now, false},
+        {"tampered body", "k", sig, ts, []byte(`{"id":2}`), now, false},
+        {"stale", "k", sig, ts, body, now.Add(10 * time.Minute), false},
+        {"bad timestamp", "k", sig, "ayer", body, now, false},
+        {"missing prefix", "k", strings.TrimPrefix(sig, "sha256="), ts, body, now, false},
+    }
+    for _, tc := range cases {
+        if got := Verify(tc.secret, tc.sig, tc.ts, tc.body, tc.at, 5*time.Minute); got != tc.want {
+            t.Errorf("%s: Verify = %v, want %v", tc.name, got, tc.want)
+        }
+    }
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+    "errors"
+    "net"
+    "net/http"
+    "net/netip"
+    "net/url"
+    "strings"
+    "syscall"
+    "time"
+)
+
+// ErrPrivateTarget rejects a subscriber inside the clinic's own network:
+// anyone who can register a webhook would otherwise make the API reach
+// services that are not meant to be public, such as cloud metadata.
+var ErrPrivateTarget = errors.New("webhook: target address is not public")
+
+// PublicAddr reports whether ip is an internet address: not loopback,
+// private, link-local, multicast or unspecified.
+func PublicAddr(ip netip.Addr) bool {
+    ip = ip.Unmap()
+    return ip.IsValid() && ip.IsGlobalUnicast() && !ip.IsPrivate() &&
+        // 100.64.0.0/10, carrier-grade NAT, is not routed on the internet.
+        !(ip.Is4() && ip.As4()[0] == 100 && ip.As4()[1]&0xc0 == 64)
+}
+
+// CheckTarget rejects the URLs that plainly name a private host: localhost
+// or an address PublicAddr refuses. Other names are only known when
+// connecting; NewClient checks the address they resolve to.
+func CheckTarget(u *url.URL) error {
+    host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+    if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+        return ErrPrivateTarget
+    }
+    if ip, err := netip.ParseAddr(host); err == nil && !PublicAddr(ip) {
+        return ErrPrivateTarget
+    }
+    return nil
+}
+
+// NewClient returns the HTTP client the dispatcher delivers with. Unless
+// allowPrivate is set, it refuses to connect to an address PublicAddr
+// rejects, whatever name resolved to it and on every redirect, and it
+// ignores the proxy settings of the environment, which would hide the
+// address.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+    t := http.DefaultTransport.(*http.Transport).Clone()
+    if !allowPrivate {
+        d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublic}
+        t.DialContext = d.DialContext
+        t.Proxy = nil
+    }
+    return &http.Client{Timeout: timeout, Transport: t}
+}
+
+// dialPublic runs after the name is resolved, before connecting.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+    ap, err := netip.ParseAddrPort(address)
+    if err != nil || !PublicAddr(ap.Addr()) {
+        return ErrPrivateTarget
+    }
+    return nil
+}
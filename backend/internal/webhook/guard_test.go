@@ -0,0 +1,52 @@
+package webhook
+
+import (
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "testing"
+    "time"
+)
+
+func TestCheckTarget(t *testing.T) {
+    for raw, private := range map[string]bool{
+        "https://crm.example.com/hook":            false,
+        "https://203.0.113.7/hook":                false,
+        "http://localhost:8080/hook":              true,
+        "http://api.localhost/hook":               true,
+        "http://127.0.0.1/hook":                   true,
+        "http://10.1.2.3/hook":                    true,
+        "http://192.168.0.10/hook":                true,
+        "http://169.254.169.254/latest/meta-data": true,
+        "http://100.64.0.1/hook":                  true,
+        "http://0.0.0.0/hook":                     true,
+        "http://[::1]/hook":                       true,
+        "http://[fd00::1]/hook":                   true,
+        "http://[::ffff:127.0.0.1]/hook":          true,
+    } {
+        u, _ := url.Parse(raw)
+        if err := CheckTarget(u); (err != nil) != private {
+            t.Errorf("CheckTarget(%s) = %v, want private %v", raw, err, private)
+        }
+    }
+}
+
+// TestNewClientRefusesPrivate checks the dial-time guard, which also
+// covers names that resolve to a private address.
+func TestNewClientRefusesPrivate(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    defer srv.Close()
+    u, _ := url.Parse(srv.URL)
+    target := "http://localhost:" + u.Port()
+
+    _, err := NewClient(time.Second, false).Get(target)
+    if !errors.Is(err, ErrPrivateTarget) {
+        t.Fatalf("guarded client: %v, want ErrPrivateTarget", err)
+    }
+    resp, err := NewClient(time.Second, true).Get(target)
+    if err != nil {
+        t.Fatalf("client allowing private targets: %v", err)
+    }
+    resp.Body.Close()
+}
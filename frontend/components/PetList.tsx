@@ -2,7 +2,7 @@
 import React, { useState } from 'react'
 import CareList from './CareListFixed'
 import { useToast } from './Toast'
-import { usePets, removePet } from '../lib/hooks'
+import { usePets, removePet, useLiveUpdates } from '../lib/hooks'
 import { CardSkeleton } from './Skeleton'
 
 type Mascota = {
@@ -18,6 +18,7 @@ const API = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
 
 export default function PetList() {
   const { pets, isLoading } = usePets()
+  useLiveUpdates()
   const [selected, setSelected] = useState<Mascota | null>(null)
   const { show } = useToast()
 
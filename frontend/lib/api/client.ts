@@ -15,9 +15,9 @@ export type Cuidado = {
   mascota_id: number
 }
 
-type ApiError = { error: { code: string; message: string; fields?: { field: string; message: string }[] } }
+type ApiError = { error: { code: string; message: string; fields?: { field: string; code?: string; message: string }[] } }
 
-const API = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
+export const API = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
 
 export async function apiFetch<T>(path: string, init?: RequestInit): Promise<T> {
   const res = await fetch(`${API}${path}`, { ...init, headers: { 'Content-Type': 'application/json', ...(init?.headers || {}) } })
@@ -1,6 +1,7 @@
 "use client"
+import { useEffect } from 'react'
 import useSWR, { mutate as globalMutate } from 'swr'
-import { listPets, listCares, createPet, deletePet, createCare, deleteCare, Mascota, Cuidado } from './api/client'
+import { API, listPets, listCares, createPet, deletePet, createCare, deleteCare, Mascota, Cuidado } from './api/client'
 
 export function usePets(limit=50, offset=0) {
   const key = ['pets', limit, offset]
@@ -14,6 +15,28 @@ export function usePetCares(petId: number | null) {
   return { cares: data || [], error, isLoading, mutate }
 }
 
+// Live updates: revalidate cached lists when the backend reports a change
+// made from any desk (GET /eventos, Server-Sent Events). EventSource
+// reconnects by itself and resumes with Last-Event-ID. The stream needs
+// the staff token, which EventSource cannot send: without a proxy that
+// adds it the backend answers 403 and EventSource gives up.
+export function useLiveUpdates() {
+  useEffect(() => {
+    if (typeof EventSource === 'undefined') return
+    const es = new EventSource(`${API}/eventos`)
+    const onMascota = () => globalMutate((key:any) => Array.isArray(key) && key[0]==='pets')
+    const onCuidado = (ev: MessageEvent) => {
+      try {
+        const e = JSON.parse(ev.data)
+        globalMutate(['cares', e.mascota_id])
+      } catch {}
+    }
+    for (const t of ['mascota.created', 'mascota.updated', 'mascota.deleted']) es.addEventListener(t, onMascota)
+    for (const t of ['cuidado.created', 'cuidado.updated', 'cuidado.completed', 'cuidado.deleted']) es.addEventListener(t, onCuidado as EventListener)
+    return () => es.close()
+  }, [])
+}
+
 // Mutations
 export async function addPet(data: Omit<Mascota,'id'>) {
   const m = await createPet(data)